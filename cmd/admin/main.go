@@ -0,0 +1,437 @@
+// Command admin inspects and fixes orders on the store and broker of the
+// server configuration, for support.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/configs"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/bootstrap"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+)
+
+const usage = `usage: admin [--config file] [--output table|json] [--actor name] <command> [flags]
+
+  list [--status S1,S2] [--limit N] [--cursor C]   list orders
+  show ID                                          show an order with its status history
+  force-status --status S --reason R ID            set any status, even on a completed order, as --actor
+  cancel --reason R ID                             cancel an active order, as --actor
+  renotify ID                                      publish the current status of an order again
+  migrate [--dry-run] [--segments N]               rewrite the DynamoDB orders saved by older versions
+  backfill-status-keys [--dry-run]                 set the status index keys of older DynamoDB orders`
+
+var errUsage = errors.New("invalid usage")
+
+var commands = map[string]bool{"list": true, "show": true, "force-status": true, "cancel": true, "renotify": true, "migrate": true, "backfill-status-keys": true}
+
+// publishingCommands notify the status of an order, the others don't open
+// the broker.
+var publishingCommands = map[string]bool{"force-status": true, "cancel": true, "renotify": true}
+
+// tableCommands work on the DynamoDB table instead of the order use case.
+var tableCommands = map[string]func(context.Context, configs.AppConfig, printer, []string) error{
+	"migrate":              migrate,
+	"backfill-status-keys": backfillStatusKeys,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+func run(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	configFile := flags.String("config", "", "path to the YAML config file, defaults to "+configs.DefaultConfigFile)
+	output := flags.String("output", "table", "output format, table or json")
+	actor := flags.String("actor", defaultActor(), "who forces the status or cancels, kept in the order history, defaults to the OS user")
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if !commands[flags.Arg(0)] || (*output != "table" && *output != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	appConfig, err := configs.GetAppConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	log, err := logger.NewStderrLogger(appConfig.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printer := printer{out: out, json: *output == "json"}
+	if tableCommand, ok := tableCommands[flags.Arg(0)]; ok {
+		err = tableCommand(ctx, appConfig, printer, flags.Args()[1:])
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, err.Error())
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	orderUseCase, closeOrders, err := newOrderUseCase(ctx, appConfig, publishingCommands[flags.Arg(0)], log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer closeOrders()
+
+	err = runCommand(ctx, orderUseCase, printer, *actor, flags.Arg(0), flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// defaultActor is the name of the OS user, empty when it is unknown.
+func defaultActor() string {
+	current, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+	return current.Username
+}
+
+func runCommand(ctx context.Context, orderUseCase usecases.OrderUseCase, printer printer, actor string, command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	switch command {
+	case "list":
+		statuses := flags.String("status", "", "comma separated statuses")
+		limit := flags.Int("limit", 50, "orders per page")
+		cursor := flags.String("cursor", "", "cursor of the page, printed after the previous one")
+		err := flags.Parse(args)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errUsage, err.Error())
+		}
+
+		filter := models.OrderFilter{Limit: *limit, Cursor: *cursor}
+		if *statuses != "" {
+			filter.Statuses = strings.Split(*statuses, ",")
+		}
+		page, err := orderUseCase.GetOrders(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return printer.page(page)
+	case "show":
+		orderId, err := parseOrderArgs(flags, args)
+		if err != nil {
+			return err
+		}
+		return showOrder(ctx, orderUseCase, printer, orderId)
+	case "force-status":
+		status := flags.String("status", "", "status to set")
+		reason := flags.String("reason", "", "why the status is forced, kept in the order history")
+		orderId, err := parseOrderArgs(flags, args)
+		if err != nil {
+			return err
+		}
+		if actor == "" {
+			return fmt.Errorf("%w: --actor is required", errUsage)
+		}
+
+		err = orderUseCase.ForceOrderStatus(ctx, orderId, *status, *reason, actor)
+		if err != nil {
+			return err
+		}
+		return showOrder(ctx, orderUseCase, printer, orderId)
+	case "cancel":
+		reason := flags.String("reason", "", "why the order is cancelled, kept in the order history")
+		orderId, err := parseOrderArgs(flags, args)
+		if err != nil {
+			return err
+		}
+		if actor == "" {
+			return fmt.Errorf("%w: --actor is required", errUsage)
+		}
+
+		err = orderUseCase.CancelOrder(ctx, orderId, *reason, actor)
+		if err != nil {
+			return err
+		}
+		return showOrder(ctx, orderUseCase, printer, orderId)
+	case "renotify":
+		orderId, err := parseOrderArgs(flags, args)
+		if err != nil {
+			return err
+		}
+
+		err = orderUseCase.RenotifyOrder(ctx, orderId)
+		if err != nil {
+			return err
+		}
+		return showOrder(ctx, orderUseCase, printer, orderId)
+	default:
+		return fmt.Errorf("%w: unknown command [%s]", errUsage, command)
+	}
+}
+
+// parseOrderArgs parses the flags of a command on one order, accepting the
+// order ID before or after them.
+func parseOrderArgs(flags *flag.FlagSet, args []string) (int, error) {
+	var id string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, args = args[0], args[1:]
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", errUsage, err.Error())
+	}
+	if id == "" {
+		id = flags.Arg(0)
+	}
+
+	orderId, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid order ID [%s]", errUsage, id)
+	}
+	return orderId, nil
+}
+
+func showOrder(ctx context.Context, orderUseCase usecases.OrderUseCase, printer printer, orderId int) error {
+	order, err := orderUseCase.GetOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	return printer.order(order)
+}
+
+// migrate runs gateways.OrderMigrations, which only apply to the dynamodb
+// store.
+func migrate(ctx context.Context, appConfig configs.AppConfig, printer printer, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "count the orders each migration would change, without changing them")
+	segments := flags.Int("segments", 4, "segments of the parallel scan, kept by an interrupted migration when resumed")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err.Error())
+	}
+	if appConfig.StoreDriver != "dynamodb" {
+		return fmt.Errorf("migrate only applies to STORE_DRIVER dynamodb, got [%s]", appConfig.StoreDriver)
+	}
+
+	dynamodbClient, err := bootstrap.NewDynamoDBClient(ctx, appConfig.OrderTableEndpoint)
+	if err != nil {
+		return err
+	}
+	results, err := dynamodb.Migrate(ctx, dynamodbClient, appConfig.OrderTable, gateways.OrderMigrations, dynamodb.MigrateOptions{Segments: *segments, DryRun: *dryRun})
+	printErr := printer.migrations(results)
+	if err != nil {
+		return err
+	}
+	return printErr
+}
+
+// backfillStatusKeys runs gateways.BackfillOrderStatusKeys, which only
+// applies to the dynamodb store.
+func backfillStatusKeys(ctx context.Context, appConfig configs.AppConfig, printer printer, args []string) error {
+	flags := flag.NewFlagSet("backfill-status-keys", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "count the orders without updating them")
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err.Error())
+	}
+	if appConfig.StoreDriver != "dynamodb" {
+		return fmt.Errorf("backfill-status-keys only applies to STORE_DRIVER dynamodb, got [%s]", appConfig.StoreDriver)
+	}
+
+	dynamodbClient, err := bootstrap.NewDynamoDBClient(ctx, appConfig.OrderTableEndpoint)
+	if err != nil {
+		return err
+	}
+	backfill, err := gateways.BackfillOrderStatusKeys(ctx, dynamodbClient, appConfig.OrderTable, *dryRun)
+	if err != nil {
+		return err
+	}
+	return printer.backfill(backfill)
+}
+
+// newOrderUseCase builds the order use case on the store of appConfig, and
+// on its broker when the command publishes, along with a function releasing
+// them.
+func newOrderUseCase(ctx context.Context, appConfig configs.AppConfig, publishes bool, log logger.Logger) (usecases.OrderUseCase, func(), error) {
+	store, err := bootstrap.NewStore(ctx, appConfig, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var orderNotify gateways.OrderNotify = readOnlyNotify{}
+	closeBroker := func() error { return nil }
+	if publishes {
+		orderBroker, err := bootstrap.NewOrderBroker(ctx, appConfig, log)
+		if err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+		orderNotify = gateways.NewOrderNotify(orderBroker.Publisher, appConfig.OrderReadyEventsDestination, appConfig.PublishTimeout)
+		closeBroker = orderBroker.Close
+	}
+
+	webhookDispatcher := gateways.NewWebhookDispatcher(store.Webhooks, httpDriver.NewHttpClient(httpDriver.Options{Timeout: appConfig.WebhookTimeout, DenyPrivateAddresses: true}), appConfig.WebhookMaxAttempts, appConfig.WebhookRetryBackoff, log)
+	orderUseCase := usecases.NewOrderUseCase(store.Orders, orderNotify, webhookDispatcher, log)
+
+	closeOrders := func() {
+		webhookDispatcher.Close()
+		closeBroker()
+		store.Close()
+	}
+	return orderUseCase, closeOrders, nil
+}
+
+// readOnlyNotify stands in for the broker of the commands that don't
+// publish.
+type readOnlyNotify struct{}
+
+func (readOnlyNotify) NotifyOrder(ctx context.Context, orderId int, status string) error {
+	return errors.New("the broker is only opened by the commands that publish")
+}
+
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+func (p printer) page(page models.OrderPage) error {
+	if p.json {
+		return p.encode(page)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tCREATED AT\tSTATUS CHANGED AT\tITEMS")
+	for _, order := range page.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", order.ID, order.Status, formatTime(order.CreatedAt), formatTime(order.StatusChangedAt), formatItems(order.Items))
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if page.Next != "" {
+		fmt.Fprintf(p.out, "\nnext page: --cursor %s\n", page.Next)
+	}
+	return nil
+}
+
+func (p printer) order(order models.Order) error {
+	if p.json {
+		return p.encode(order)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", order.ID)
+	fmt.Fprintf(w, "STATUS\t%s\n", order.Status)
+	fmt.Fprintf(w, "CUSTOMER CPF\t%s\n", orDash(order.CustomerCPF))
+	fmt.Fprintf(w, "CREATED AT\t%s\n", formatTime(order.CreatedAt))
+	fmt.Fprintf(w, "STATUS CHANGED AT\t%s\n", formatTime(order.StatusChangedAt))
+	fmt.Fprintf(w, "ITEMS\t%s\n", formatItems(order.Items))
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out)
+	w = tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGED AT\tFROM\tTO\tREASON\tACTOR")
+	for _, change := range order.History {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatTime(change.ChangedAt), orDash(change.From), change.To, orDash(change.Reason), orDash(change.Actor))
+	}
+	return w.Flush()
+}
+
+func (p printer) migrations(results []dynamodb.MigrationResult) error {
+	if p.json {
+		return p.encode(results)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tSCANNED\tUPDATED\tSKIPPED")
+	for _, result := range results {
+		status := result.Status
+		if result.Resumed {
+			status += " (resumed)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\n", result.Version, result.Name, status, result.Scanned, result.Updated, result.Skipped)
+	}
+	return w.Flush()
+}
+
+func (p printer) backfill(backfill gateways.StatusKeysBackfill) error {
+	if p.json {
+		return p.encode(backfill)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "MISSING\t%d\n", backfill.Missing)
+	fmt.Fprintf(w, "UPDATED\t%d\n", backfill.Updated)
+	fmt.Fprintf(w, "SKIPPED\t%d\n", backfill.Skipped)
+	return w.Flush()
+}
+
+func (p printer) encode(v any) error {
+	encoder := json.NewEncoder(p.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatItems(items []models.OrderItem) string {
+	described := make([]string, len(items))
+	for i, item := range items {
+		described[i] = fmt.Sprintf("%dx %s", item.Quantity, item.Product.Name)
+	}
+	return orDash(strings.Join(described, ", "))
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
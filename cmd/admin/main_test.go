@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var forcedOrder = models.Order{
+	ID:              "42",
+	Status:          models.OrderStatusReady,
+	CreatedAt:       time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	StatusChangedAt: time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC),
+	Items:           []models.OrderItem{{Quantity: 2, Type: "GRILL", Product: models.Product{Name: "X-Burger"}}},
+	History: []models.OrderStatusChange{
+		{To: models.OrderStatusReceived, ChangedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{From: models.OrderStatusDelivered, To: models.OrderStatusReady, Reason: "delivered by mistake", Actor: "maria", ChangedAt: time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)},
+	},
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: []string{}},
+		{name: "unknown command", args: []string{"delete", "42"}},
+		{name: "unknown output", args: []string{"--output", "yaml", "show", "42"}},
+		{name: "unknown flag", args: []string{"--verbose", "show", "42"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			assert.Equal(t, 2, run(tt.args, out))
+			assert.Empty(t, out.String())
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name           string
+		actor          string
+		command        string
+		args           []string
+		mock           func(orderUseCase *mock_usecases.MockOrderUseCase)
+		expectedErr    error
+		expectedOutput string
+	}{
+		{
+			name:    "force status",
+			actor:   "maria",
+			command: "force-status",
+			args:    []string{"42", "--status", "READY", "--reason", "delivered by mistake"},
+			mock: func(orderUseCase *mock_usecases.MockOrderUseCase) {
+				orderUseCase.EXPECT().ForceOrderStatus(gomock.Any(), 42, models.OrderStatusReady, "delivered by mistake", "maria").Return(nil)
+				orderUseCase.EXPECT().GetOrder(gomock.Any(), 42).Return(forcedOrder, nil)
+			},
+			expectedOutput: "delivered by mistake  maria",
+		},
+		{
+			name:        "force status without actor",
+			command:     "force-status",
+			args:        []string{"42", "--status", "READY", "--reason", "delivered by mistake"},
+			expectedErr: errUsage,
+		},
+		{
+			name:    "cancel with the ID after the flags",
+			actor:   "maria",
+			command: "cancel",
+			args:    []string{"--reason", "customer gave up", "42"},
+			mock: func(orderUseCase *mock_usecases.MockOrderUseCase) {
+				orderUseCase.EXPECT().CancelOrder(gomock.Any(), 42, "customer gave up", "maria").Return(nil)
+				orderUseCase.EXPECT().GetOrder(gomock.Any(), 42).Return(forcedOrder, nil)
+			},
+			expectedOutput: "STATUS             READY",
+		},
+		{
+			name:        "cancel without actor",
+			command:     "cancel",
+			args:        []string{"42", "--reason", "customer gave up"},
+			expectedErr: errUsage,
+		},
+		{
+			name:    "list",
+			command: "list",
+			args:    []string{"--status", "READY,IN_PROGRESS", "--limit", "20"},
+			mock: func(orderUseCase *mock_usecases.MockOrderUseCase) {
+				filter := models.OrderFilter{Statuses: []string{models.OrderStatusReady, models.OrderStatusInProgress}, Limit: 20}
+				orderUseCase.EXPECT().GetOrders(gomock.Any(), filter).Return(models.OrderPage{Results: []models.Order{forcedOrder}, Next: "page-2"}, nil)
+			},
+			expectedOutput: "next page: --cursor page-2",
+		},
+		{
+			name:        "invalid order ID",
+			command:     "show",
+			args:        []string{"forty-two"},
+			expectedErr: errUsage,
+		},
+		{
+			name:        "unknown flag",
+			command:     "renotify",
+			args:        []string{"42", "--force"},
+			expectedErr: errUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+			if tt.mock != nil {
+				tt.mock(orderUseCase)
+			}
+			out := &bytes.Buffer{}
+
+			err := runCommand(context.Background(), orderUseCase, printer{out: out}, tt.actor, tt.command, tt.args)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, out.String(), tt.expectedOutput)
+		})
+	}
+}
+
+func TestPrinter_Order(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, printer{out: out}.order(forcedOrder))
+
+	expected := strings.Join([]string{
+		"ID                 42",
+		"STATUS             READY",
+		"CUSTOMER CPF       -",
+		"CREATED AT         2026-10-01T12:00:00Z",
+		"STATUS CHANGED AT  2026-10-01T12:30:00Z",
+		"ITEMS              2x X-Burger",
+		"",
+		"CHANGED AT            FROM       TO        REASON                ACTOR",
+		"2026-10-01T12:00:00Z  -          RECEIVED  -                     -",
+		"2026-10-01T12:30:00Z  DELIVERED  READY     delivered by mistake  maria",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, out.String())
+}
+
+func TestPrinter_JSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, printer{out: out, json: true}.order(forcedOrder))
+
+	order := models.Order{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &order))
+	assert.Equal(t, forcedOrder, order)
+	assert.Contains(t, out.String(), `"actor": "maria"`)
+
+	out.Reset()
+	require.NoError(t, printer{out: out, json: true}.page(models.OrderPage{Results: []models.Order{forcedOrder}, Next: "page-2"}))
+
+	page := models.OrderPage{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &page))
+	assert.Equal(t, "page-2", page.Next)
+	assert.Len(t, page.Results, 1)
+}
@@ -2,82 +2,182 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// the image has no zoneinfo, REPORT_TIMEZONE is resolved from it
+	_ "time/tzdata"
 
-	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events/broker"
-	"github.com/IgorRamosBR/g73-techchallenge-payment/pkg/dynamodb"
 	"github.com/IgorRamosBR/g73-techchallenge-production/configs"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/api"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/bootstrap"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	awsDynamoDb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/health"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
-	appConfig := configs.GetAppConfig()
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
+	configFile := flag.String("config", "", "path to the YAML config file, defaults to "+configs.DefaultConfigFile)
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
 
-	dynamodbClient, err := NewDynamoDBClient(appConfig.OrderTableEndpoint)
+	appConfig, err := configs.GetAppConfig(*configFile)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		out, err := appConfig.Redacted()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
 	}
 
-	brokerChannel, err := NewRabbitMQBrokerChannel(appConfig.OrderEventsBrokerUrl)
+	log, err := logger.NewLogger(appConfig.LogLevel)
 	if err != nil {
 		panic(err)
 	}
-	defer brokerChannel.Close()
+	if appConfig.LogLevel != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ordersPaidQueue, err := broker.NewRabbitMQConsumer(brokerChannel, appConfig.OrderInProgressEventsQueue)
+	tracerProvider, err := tracing.NewTracerProvider(ctx, appConfig.TracingExporter)
 	if err != nil {
 		panic(err)
 	}
+	defer tracerProvider.Shutdown(context.Background())
 
-	publisher := broker.NewRabbitMQPublisher(brokerChannel, appConfig.OrderEventsTopic)
-	defer publisher.Close()
-
-	orderRepository := gateways.NewOrderRepository(dynamodbClient, appConfig.OrderTable)
-	orderNotify := gateways.NewOrderNotify(publisher, appConfig.OrderReadyEventsDestination)
-	orderUseCase := usecases.NewOrderUseCase(orderRepository, orderNotify)
-	orderConsumerUseCase := usecases.NewOrderConsumerUseCase(ordersPaidQueue, orderUseCase)
-	orderConsumerUseCase.StartConsumers()
+	store, err := bootstrap.NewStore(ctx, appConfig, log)
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+	err = store.ScheduleArchive(ctx)
+	if err != nil {
+		panic(err)
+	}
 
-	orderController := controllers.NewOrderController(orderUseCase)
+	orderBroker, err := bootstrap.NewOrderBroker(ctx, appConfig, log)
+	if err != nil {
+		panic(err)
+	}
+	defer orderBroker.Close()
+	publisher := orderBroker.Publisher
 
-	api := api.NewApi(orderController)
-	api.Run(":" + appConfig.Port)
-}
+	ordersPaidQueue, closeOrdersPaidQueue, err := orderBroker.OpenConsumer(appConfig.OrderInProgressEventsQueue)
+	if err != nil {
+		panic(err)
+	}
+	defer closeOrdersPaidQueue()
 
-func NewDynamoDBClient(endpoint string) (dynamodb.DynamoDBClient, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background())
+	orderNotify := gateways.NewOrderNotify(publisher, appConfig.OrderReadyEventsDestination, appConfig.PublishTimeout)
+	webhookDispatcher := gateways.NewWebhookDispatcher(store.Webhooks, httpDriver.NewHttpClient(httpDriver.Options{Timeout: appConfig.WebhookTimeout, DenyPrivateAddresses: true}), appConfig.WebhookMaxAttempts, appConfig.WebhookRetryBackoff, log)
+	defer webhookDispatcher.Close()
+	err = webhookDispatcher.Resume(ctx)
 	if err != nil {
-		return nil, err
+		log.WithError(err).Errorf("failed to resume the pending webhook deliveries")
 	}
+	orderUseCase := usecases.NewOrderUseCase(store.Orders, orderNotify, webhookDispatcher, log)
+	deadLetterQueue := gateways.NewDeadLetterQueue(publisher, appConfig.DeadLetterDestination(), appConfig.PublishTimeout)
+	orderConsumerUseCase := usecases.NewOrderConsumerUseCase(deadLetterQueue, log)
+	orderConsumerUseCase.RegisterQueue(appConfig.OrderInProgressEventsQueue, ordersPaidQueue)
+	err = orderConsumerUseCase.RegisterHandler(usecases.HandlerRegistration{
+		Queue:       appConfig.OrderInProgressEventsQueue,
+		EventType:   usecases.OrderProductionEventType,
+		Version:     usecases.OrderProductionEventVersion,
+		Concurrency: appConfig.OrderProductionConcurrency,
+		Handle:      usecases.NewOrderProductionHandler(orderUseCase, log),
+	})
+	if err != nil {
+		panic(err)
+	}
+	orderConsumerUseCase.StartConsumers(ctx)
+	defer orderConsumerUseCase.StopConsumers()
 
-	if endpoint != "" {
-		client := awsDynamoDb.NewFromConfig(cfg, func(o *awsDynamoDb.Options) {
-			o.BaseEndpoint = aws.String(endpoint)
-		})
-		return dynamodb.NewDynamoDBClient(client), nil
+	stationCapacity, err := appConfig.StationCapacity()
+	if err != nil {
+		panic(err)
+	}
+	kitchenCapacity := usecases.KitchenCapacity{MaxActiveOrders: appConfig.KitchenMaxActiveOrders, Stations: stationCapacity}
+	backpressureUseCase := usecases.NewKitchenBackpressureUseCase(orderUseCase, orderConsumerUseCase, appConfig.OrderInProgressEventsQueue, kitchenCapacity, appConfig.KitchenCapacityCheckInterval, log)
+	if kitchenCapacity.IsLimited() {
+		go backpressureUseCase.Run(ctx)
 	}
 
-	client := awsDynamoDb.NewFromConfig(cfg)
-	return dynamodb.NewDynamoDBClient(client), nil
+	prometheus.MustRegister(metrics.NewOrderCollector(orderUseCase, appConfig.OrderMetricsRefreshInterval, log))
 
-}
+	orderController := controllers.NewOrderController(orderUseCase, log)
+	webhookController := controllers.NewWebhookController(usecases.NewWebhookUseCase(store.Webhooks, log), log)
 
-func NewRabbitMQBrokerChannel(url string) (*amqp.Channel, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
+	var devController *controllers.DevController
+	if appConfig.BrokerDriver == "memory" {
+		controller := controllers.NewDevController(usecases.NewDevEventUseCase(publisher, appConfig.OrderInProgressEventsQueue), log)
+		devController = &controller
 	}
 
-	ch, err := conn.Channel()
+	appHealth := health.NewHealth(appConfig.HealthCheckTimeout,
+		store.Checker,
+		orderBroker.Checker,
+		health.NewChecker("consumer", func(ctx context.Context) error {
+			if !orderConsumerUseCase.IsRunning() {
+				return errors.New("consumer is not running")
+			}
+			return nil
+		}),
+	)
+	healthController := controllers.NewHealthController(appHealth)
+	replayUseCase := usecases.NewReplayUseCase(orderUseCase, orderNotify, orderBroker.OpenConsumer, publisher, replayOptions(appConfig), log)
+	adminController := controllers.NewAdminController(backpressureUseCase, replayUseCase, log)
+
+	reportLocation, err := appConfig.ReportLocation()
 	if err != nil {
-		return nil, err
+		panic(err)
 	}
+	reportController := controllers.NewReportController(usecases.NewReportUseCase(orderUseCase, reportLocation, log), log)
 
-	return ch, err
+	if appConfig.AdminToken == "" {
+		log.Warnf("ADMIN_TOKEN is not set, the admin routes reject every request")
+	}
+	api := api.NewApi(orderController, webhookController, healthController, adminController, reportController, devController, appConfig.HttpRequestTimeout, appConfig.AdminToken, log)
+	server := &http.Server{Addr: ":" + appConfig.Port, Handler: api}
+	go func() {
+		<-ctx.Done()
+		// keep serving while readiness reports DRAINING so the instance
+		// leaves the load balancer before connections are closed
+		appHealth.StartDraining()
+		time.Sleep(appConfig.ShutdownDrainDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.HttpRequestTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Starting server on port [%s]", appConfig.Port)
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/configs"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/bootstrap"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+)
+
+const replayUsage = `usage: production replay <dead-letters|status-events> [flags]
+
+  dead-letters   move the dead letters matching the flags back to the work queue
+  status-events  publish again the status of the orders changed in [--from, --to)`
+
+func replayOptions(appConfig configs.AppConfig) usecases.ReplayOptions {
+	return usecases.ReplayOptions{
+		DeadLetterQueue:       appConfig.DeadLetterQueue(),
+		DeadLetterDestination: appConfig.DeadLetterDestination(),
+		ReplayDestination:     appConfig.ReplayDestination(),
+		IdleTimeout:           appConfig.ReplayIdleTimeout,
+	}
+}
+
+// runReplay runs the replay subcommand with args, printing its result as
+// JSON, and returns the exit code.
+func runReplay(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, replayUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("replay "+args[0], flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to the YAML config file, defaults to "+configs.DefaultConfigFile)
+	from := flags.String("from", "", "start of the window, as RFC 3339")
+	to := flags.String("to", "", "end of the window, exclusive, as RFC 3339")
+	dryRun := flags.Bool("dry-run", false, "list the messages without publishing them")
+
+	var eventType, orderId, statuses *string
+	var limit *int
+	switch args[0] {
+	case "dead-letters":
+		eventType = flags.String("event-type", "", "event type, with or without its version, e.g. "+usecases.OrderProductionEventType)
+		orderId = flags.String("order-id", "", "order ID")
+		limit = flags.Int("limit", 0, "maximum number of messages to replay, 0 for all")
+	case "status-events":
+		statuses = flags.String("status", "", "comma separated statuses, all of them by default")
+	default:
+		fmt.Fprintln(os.Stderr, replayUsage)
+		return 2
+	}
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	window, err := parseWindow(*from, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	appConfig, err := configs.GetAppConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	log, err := logger.NewStderrLogger(appConfig.LogLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	replayUseCase, closeReplay, err := newReplayUseCase(ctx, appConfig, log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer closeReplay()
+
+	var result dto.ReplayResult
+	if args[0] == "dead-letters" {
+		result, err = replayUseCase.ReplayDeadLetters(ctx, dto.DeadLetterReplayRequest{
+			EventType: *eventType,
+			OrderID:   *orderId,
+			From:      window[0],
+			To:        window[1],
+			Limit:     *limit,
+			DryRun:    *dryRun,
+		})
+	} else {
+		request := dto.StatusEventReplayRequest{From: window[0], To: window[1], DryRun: *dryRun}
+		if *statuses != "" {
+			request.Statuses = strings.Split(*statuses, ",")
+		}
+		result, err = replayUseCase.RepublishStatusEvents(ctx, request)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(result)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+func parseWindow(from string, to string) ([2]time.Time, error) {
+	var window [2]time.Time
+	for i, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return window, fmt.Errorf("invalid time [%s], expected RFC 3339: %w", value, err)
+		}
+		window[i] = t
+	}
+	return window, nil
+}
+
+// newReplayUseCase builds the replay use case on the store and broker of
+// appConfig, along with a function releasing them.
+func newReplayUseCase(ctx context.Context, appConfig configs.AppConfig, log logger.Logger) (usecases.ReplayUseCase, func(), error) {
+	store, err := bootstrap.NewStore(ctx, appConfig, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	orderBroker, err := bootstrap.NewOrderBroker(ctx, appConfig, log)
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+
+	orderNotify := gateways.NewOrderNotify(orderBroker.Publisher, appConfig.OrderReadyEventsDestination, appConfig.PublishTimeout)
+	webhookDispatcher := gateways.NewWebhookDispatcher(store.Webhooks, httpDriver.NewHttpClient(httpDriver.Options{Timeout: appConfig.WebhookTimeout, DenyPrivateAddresses: true}), appConfig.WebhookMaxAttempts, appConfig.WebhookRetryBackoff, log)
+	orderUseCase := usecases.NewOrderUseCase(store.Orders, orderNotify, webhookDispatcher, log)
+	replayUseCase := usecases.NewReplayUseCase(orderUseCase, orderNotify, orderBroker.OpenConsumer, orderBroker.Publisher, replayOptions(appConfig), log)
+
+	closeReplay := func() {
+		webhookDispatcher.Close()
+		orderBroker.Close()
+		store.Close()
+	}
+	return replayUseCase, closeReplay, nil
+}
@@ -1,31 +1,296 @@
 package configs
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const DefaultConfigFile = "/configs/config.yaml"
+
+// AppConfig is loaded in layers: the `default` tags first, then the optional
+// YAML file, then the `env` variables. Fields tagged `required` must be set
+// by one of the layers and fields tagged `secret` are redacted when printed.
 type AppConfig struct {
-	Port string
+	Port string `yaml:"port" env:"PORT" default:"8080" required:"true"`
+
+	StoreDriver        string `yaml:"storeDriver" env:"STORE_DRIVER" default:"dynamodb"`
+	OrderTable         string `yaml:"orderTable" env:"ORDER_TABLE"`
+	OrderTableEndpoint string `yaml:"orderTableEndpoint" env:"ORDER_TABLE_ENDPOINT"`
+	PostgresDSN        string `yaml:"postgresDsn" env:"POSTGRES_DSN" secret:"true"`
+
+	DynamoDBArchiveAt        string        `yaml:"dynamodbArchiveAt" env:"DYNAMODB_ARCHIVE_AT" default:"03:00"`
+	DynamoDBArchiveAfter     time.Duration `yaml:"dynamodbArchiveAfter" env:"DYNAMODB_ARCHIVE_AFTER" default:"24h"`
+	DynamoDBArchiveRetention time.Duration `yaml:"dynamodbArchiveRetention" env:"DYNAMODB_ARCHIVE_RETENTION" default:"720h"`
+	DynamoDBArchiveLookback  time.Duration `yaml:"dynamodbArchiveLookback" env:"DYNAMODB_ARCHIVE_LOOKBACK" default:"720h"`
+	ArchiveExportDir         string        `yaml:"archiveExportDir" env:"ARCHIVE_EXPORT_DIR"`
+	ArchiveExportBucket      string        `yaml:"archiveExportBucket" env:"ARCHIVE_EXPORT_BUCKET"`
+	ArchiveExportPrefix      string        `yaml:"archiveExportPrefix" env:"ARCHIVE_EXPORT_PREFIX" default:"orders"`
+	ArchiveExportEndpoint    string        `yaml:"archiveExportEndpoint" env:"ARCHIVE_EXPORT_ENDPOINT"`
+	ArchiveExportTimeout     time.Duration `yaml:"archiveExportTimeout" env:"ARCHIVE_EXPORT_TIMEOUT" default:"30s"`
+
+	SQLitePath             string        `yaml:"sqlitePath" env:"SQLITE_PATH" default:"production.db"`
+	SQLiteArchiveAt        string        `yaml:"sqliteArchiveAt" env:"SQLITE_ARCHIVE_AT" default:"03:00"`
+	SQLiteArchiveRetention time.Duration `yaml:"sqliteArchiveRetention" env:"SQLITE_ARCHIVE_RETENTION" default:"168h"`
+
+	BrokerDriver                string        `yaml:"brokerDriver" env:"BROKER_DRIVER" default:"rabbitmq"`
+	OrderEventsBrokerUrl        string        `yaml:"orderEventsBrokerUrl" env:"ORDER_EVENTS_BROKER_URL" secret:"true"`
+	RabbitMQPrefetch            int           `yaml:"rabbitmqPrefetch" env:"RABBITMQ_PREFETCH" default:"20"`
+	RabbitMQMaxAttempts         int           `yaml:"rabbitmqMaxAttempts" env:"RABBITMQ_MAX_ATTEMPTS" default:"5"`
+	KafkaBrokers                string        `yaml:"kafkaBrokers" env:"KAFKA_BROKERS"`
+	KafkaConsumerGroup          string        `yaml:"kafkaConsumerGroup" env:"KAFKA_CONSUMER_GROUP" default:"g73-techchallenge-production"`
+	KafkaMaxAttempts            int           `yaml:"kafkaMaxAttempts" env:"KAFKA_MAX_ATTEMPTS" default:"5"`
+	SQSEndpoint                 string        `yaml:"sqsEndpoint" env:"SQS_ENDPOINT"`
+	SNSEndpoint                 string        `yaml:"snsEndpoint" env:"SNS_ENDPOINT"`
+	SQSVisibilityTimeout        time.Duration `yaml:"sqsVisibilityTimeout" env:"SQS_VISIBILITY_TIMEOUT" default:"30s"`
+	OrderEventsTopic            string        `yaml:"orderEventsTopic" env:"ORDER_EVENTS_TOPIC"`
+	OrderInProgressEventsQueue  string        `yaml:"orderInProgressEventsQueue" env:"ORDER_EVENTS_IN_PROGRESS_QUEUE" required:"true"`
+	OrderReadyEventsDestination string        `yaml:"orderReadyEventsDestination" env:"ORDER_READY_EVENTS_DESTINATION" required:"true"`
+	OrderDeadLetterDestination  string        `yaml:"orderDeadLetterDestination" env:"ORDER_EVENTS_DEAD_LETTER_DESTINATION"`
+	OrderProductionConcurrency  int           `yaml:"orderProductionConcurrency" env:"ORDER_PRODUCTION_CONCURRENCY" default:"1"`
+	OrderDeadLetterQueue        string        `yaml:"orderDeadLetterQueue" env:"ORDER_EVENTS_DEAD_LETTER_QUEUE"`
+	OrderReplayDestination      string        `yaml:"orderReplayDestination" env:"ORDER_EVENTS_REPLAY_DESTINATION"`
+	ReplayIdleTimeout           time.Duration `yaml:"replayIdleTimeout" env:"REPLAY_IDLE_TIMEOUT" default:"5s"`
+
+	KitchenMaxActiveOrders       int           `yaml:"kitchenMaxActiveOrders" env:"KITCHEN_MAX_ACTIVE_ORDERS" default:"0"`
+	KitchenStationCapacity       string        `yaml:"kitchenStationCapacity" env:"KITCHEN_STATION_CAPACITY"`
+	KitchenCapacityCheckInterval time.Duration `yaml:"kitchenCapacityCheckInterval" env:"KITCHEN_CAPACITY_CHECK_INTERVAL" default:"5s"`
+
+	WebhookTimeout      time.Duration `yaml:"webhookTimeout" env:"WEBHOOK_TIMEOUT" default:"5s"`
+	WebhookMaxAttempts  int           `yaml:"webhookMaxAttempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"5"`
+	WebhookRetryBackoff time.Duration `yaml:"webhookRetryBackoff" env:"WEBHOOK_RETRY_BACKOFF" default:"2s"`
+	// WebhookDeliveryRetention is how long the dynamodb store keeps each
+	// delivery of the log.
+	WebhookDeliveryRetention time.Duration `yaml:"webhookDeliveryRetention" env:"WEBHOOK_DELIVERY_RETENTION" default:"720h"`
+
+	// ReportTimezone is an IANA name, the days and hours of the reports are
+	// in this timezone.
+	ReportTimezone string `yaml:"reportTimezone" env:"REPORT_TIMEZONE" default:"UTC"`
+
+	// AdminToken is the bearer token of the /v1/admin routes, which reject
+	// every request while it is empty.
+	AdminToken string `yaml:"adminToken" env:"ADMIN_TOKEN" secret:"true"`
 
-	OrderTable         string
-	OrderTableEndpoint string
+	LogLevel        string `yaml:"logLevel" env:"LOG_LEVEL" default:"info"`
+	TracingExporter string `yaml:"tracingExporter" env:"TRACING_EXPORTER" default:"none"`
 
-	OrderEventsBrokerUrl        string
-	OrderEventsTopic            string
-	OrderInProgressEventsQueue  string
-	OrderReadyEventsDestination string
+	HttpRequestTimeout          time.Duration `yaml:"httpRequestTimeout" env:"HTTP_REQUEST_TIMEOUT" default:"5s"`
+	RepositoryTimeout           time.Duration `yaml:"repositoryTimeout" env:"REPOSITORY_TIMEOUT" default:"2s"`
+	PublishTimeout              time.Duration `yaml:"publishTimeout" env:"PUBLISH_TIMEOUT" default:"2s"`
+	MessageProcessingTimeout    time.Duration `yaml:"messageProcessingTimeout" env:"MESSAGE_PROCESSING_TIMEOUT" default:"10s"`
+	HealthCheckTimeout          time.Duration `yaml:"healthCheckTimeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ShutdownDrainDelay          time.Duration `yaml:"shutdownDrainDelay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s"`
+	OrderMetricsRefreshInterval time.Duration `yaml:"orderMetricsRefreshInterval" env:"ORDER_METRICS_REFRESH_INTERVAL" default:"30s"`
 }
 
-func GetAppConfig() AppConfig {
+// GetAppConfig loads and validates the configuration. An empty path falls
+// back to DefaultConfigFile, which is skipped when it does not exist; an
+// explicit path must exist.
+func GetAppConfig(path string) (AppConfig, error) {
 	appConfig := AppConfig{}
 
-	appConfig.Port = os.Getenv("PORT")
-	appConfig.OrderTable = os.Getenv("ORDER_TABLE")
-	appConfig.OrderTableEndpoint = os.Getenv("ORDER_TABLE_ENDPOINT")
-	appConfig.OrderEventsBrokerUrl = os.Getenv("ORDER_EVENTS_BROKER_URL")
-	appConfig.OrderEventsTopic = os.Getenv("ORDER_EVENTS_TOPIC")
-	appConfig.OrderInProgressEventsQueue = os.Getenv("ORDER_EVENTS_IN_PROGRESS_QUEUE")
-	appConfig.OrderReadyEventsDestination = os.Getenv("ORDER_READY_EVENTS_DESTINATION")
+	err := applyDefaults(&appConfig)
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	if path == "" {
+		path = DefaultConfigFile
+		if _, err := os.Stat(path); err != nil {
+			path = ""
+		}
+	}
+	if path != "" {
+		err = applyFile(&appConfig, path)
+		if err != nil {
+			return AppConfig{}, err
+		}
+	}
+
+	problems := applyEnv(&appConfig)
+	problems = append(problems, appConfig.validate()...)
+	if len(problems) > 0 {
+		return AppConfig{}, newValidationError(problems)
+	}
+
+	return appConfig, nil
+}
+
+// DeadLetterDestination is where unprocessable order events are published,
+// by default the in-progress queue name with a ".dlq" suffix.
+func (c AppConfig) DeadLetterDestination() string {
+	if c.OrderDeadLetterDestination != "" {
+		return c.OrderDeadLetterDestination
+	}
+	return c.OrderInProgressEventsQueue + ".dlq"
+}
+
+// DeadLetterQueue is the queue the dead letters are read from to be
+// replayed. Except with sqs, whose dead letters are published to an SNS
+// topic, it defaults to DeadLetterDestination; empty disables the replay.
+func (c AppConfig) DeadLetterQueue() string {
+	if c.OrderDeadLetterQueue != "" || c.BrokerDriver == "sqs" {
+		return c.OrderDeadLetterQueue
+	}
+	return c.DeadLetterDestination()
+}
+
+// ReplayDestination is where replayed dead letters are published, by
+// default the in-progress queue.
+func (c AppConfig) ReplayDestination() string {
+	if c.OrderReplayDestination != "" {
+		return c.OrderReplayDestination
+	}
+	return c.OrderInProgressEventsQueue
+}
+
+// DynamoDBArchiveEnabled reports whether completed orders are archived,
+// which requires somewhere to export them before they expire.
+func (c AppConfig) DynamoDBArchiveEnabled() bool {
+	return c.ArchiveExportDir != "" || c.ArchiveExportBucket != ""
+}
+
+func (c AppConfig) ReportLocation() (*time.Location, error) {
+	location, err := time.LoadLocation(c.ReportTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("REPORT_TIMEZONE must be an IANA timezone, got [%s]", c.ReportTimezone)
+	}
+	return location, nil
+}
+
+// StationCapacity parses KitchenStationCapacity, a comma separated list of
+// station=maxActiveOrders pairs such as "GRILL=10,DRINK=20".
+func (c AppConfig) StationCapacity() (map[string]int, error) {
+	capacity := map[string]int{}
+	if c.KitchenStationCapacity == "" {
+		return capacity, nil
+	}
+
+	for _, pair := range strings.Split(c.KitchenStationCapacity, ",") {
+		station, limit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || station == "" {
+			return nil, fmt.Errorf("KITCHEN_STATION_CAPACITY must be a list of station=maxActiveOrders, got [%s]", c.KitchenStationCapacity)
+		}
+		maxActiveOrders, err := strconv.Atoi(limit)
+		if err != nil || maxActiveOrders < 1 {
+			return nil, fmt.Errorf("KITCHEN_STATION_CAPACITY of station [%s] must be at least 1, got [%s]", station, limit)
+		}
+		capacity[station] = maxActiveOrders
+	}
+	return capacity, nil
+}
+
+func (c AppConfig) validate() []string {
+	problems := missingRequired(c)
+
+	switch c.StoreDriver {
+	case "dynamodb":
+		if c.OrderTable == "" {
+			problems = append(problems, "ORDER_TABLE (orderTable) is required when STORE_DRIVER is dynamodb")
+		}
+		if c.ArchiveExportDir != "" && c.ArchiveExportBucket != "" {
+			problems = append(problems, "only one of ARCHIVE_EXPORT_DIR and ARCHIVE_EXPORT_BUCKET can be set")
+		}
+		if _, err := time.Parse("15:04", c.DynamoDBArchiveAt); err != nil {
+			problems = append(problems, fmt.Sprintf("DYNAMODB_ARCHIVE_AT must be a time of day as HH:MM, got [%s]", c.DynamoDBArchiveAt))
+		}
+		if c.DynamoDBArchiveLookback < 0 {
+			problems = append(problems, fmt.Sprintf("DYNAMODB_ARCHIVE_LOOKBACK must not be negative, got [%s]", c.DynamoDBArchiveLookback))
+		}
+	case "postgres":
+		if c.PostgresDSN == "" {
+			problems = append(problems, "POSTGRES_DSN (postgresDsn) is required when STORE_DRIVER is postgres")
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			problems = append(problems, "SQLITE_PATH (sqlitePath) is required when STORE_DRIVER is sqlite")
+		}
+		if _, err := time.Parse("15:04", c.SQLiteArchiveAt); err != nil {
+			problems = append(problems, fmt.Sprintf("SQLITE_ARCHIVE_AT must be a time of day as HH:MM, got [%s]", c.SQLiteArchiveAt))
+		}
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("STORE_DRIVER must be one of [dynamodb postgres sqlite memory], got [%s]", c.StoreDriver))
+	}
+
+	switch c.BrokerDriver {
+	case "rabbitmq":
+		if c.OrderEventsBrokerUrl == "" {
+			problems = append(problems, "ORDER_EVENTS_BROKER_URL (orderEventsBrokerUrl) is required when BROKER_DRIVER is rabbitmq")
+		}
+		// every worker needs a delivery in hand to be busy
+		if c.RabbitMQPrefetch < c.OrderProductionConcurrency {
+			problems = append(problems, fmt.Sprintf("RABBITMQ_PREFETCH must be at least ORDER_PRODUCTION_CONCURRENCY [%d], got [%d]", c.OrderProductionConcurrency, c.RabbitMQPrefetch))
+		}
+		if c.RabbitMQMaxAttempts < 1 {
+			problems = append(problems, fmt.Sprintf("RABBITMQ_MAX_ATTEMPTS must be at least 1, got [%d]", c.RabbitMQMaxAttempts))
+		}
+	case "kafka":
+		if c.KafkaBrokers == "" {
+			problems = append(problems, "KAFKA_BROKERS (kafkaBrokers) is required when BROKER_DRIVER is kafka")
+		}
+		if c.KafkaConsumerGroup == "" {
+			problems = append(problems, "KAFKA_CONSUMER_GROUP (kafkaConsumerGroup) is required when BROKER_DRIVER is kafka")
+		}
+		if c.KafkaMaxAttempts < 1 {
+			problems = append(problems, fmt.Sprintf("KAFKA_MAX_ATTEMPTS must be at least 1, got [%d]", c.KafkaMaxAttempts))
+		}
+		// offsets are committed in order, concurrent loops would skip messages
+		if c.OrderProductionConcurrency > 1 {
+			problems = append(problems, fmt.Sprintf("ORDER_PRODUCTION_CONCURRENCY must be 1 when BROKER_DRIVER is kafka, got [%d]", c.OrderProductionConcurrency))
+		}
+	case "sqs":
+		if c.SQSVisibilityTimeout < 2*time.Second || c.SQSVisibilityTimeout > 12*time.Hour {
+			problems = append(problems, fmt.Sprintf("SQS_VISIBILITY_TIMEOUT must be between 2s and 12h, got [%s]", c.SQSVisibilityTimeout))
+		}
+		if !strings.HasPrefix(c.OrderReadyEventsDestination, "arn:") {
+			problems = append(problems, fmt.Sprintf("ORDER_READY_EVENTS_DESTINATION must be an SNS topic ARN when BROKER_DRIVER is sqs, got [%s]", c.OrderReadyEventsDestination))
+		}
+		if !strings.HasPrefix(c.DeadLetterDestination(), "arn:") {
+			problems = append(problems, fmt.Sprintf("ORDER_EVENTS_DEAD_LETTER_DESTINATION must be an SNS topic ARN when BROKER_DRIVER is sqs, got [%s]", c.DeadLetterDestination()))
+		}
+		if c.DeadLetterQueue() != "" && !strings.HasPrefix(c.ReplayDestination(), "arn:") {
+			problems = append(problems, fmt.Sprintf("ORDER_EVENTS_REPLAY_DESTINATION must be an SNS topic ARN when BROKER_DRIVER is sqs, got [%s]", c.ReplayDestination()))
+		}
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("BROKER_DRIVER must be one of [rabbitmq kafka sqs memory], got [%s]", c.BrokerDriver))
+	}
+
+	if c.OrderProductionConcurrency < 1 {
+		problems = append(problems, fmt.Sprintf("ORDER_PRODUCTION_CONCURRENCY must be at least 1, got [%d]", c.OrderProductionConcurrency))
+	}
+
+	if c.KitchenMaxActiveOrders < 0 {
+		problems = append(problems, fmt.Sprintf("KITCHEN_MAX_ACTIVE_ORDERS must not be negative, got [%d]", c.KitchenMaxActiveOrders))
+	}
+	if _, err := c.StationCapacity(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if c.WebhookMaxAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("WEBHOOK_MAX_ATTEMPTS must be at least 1, got [%d]", c.WebhookMaxAttempts))
+	}
+
+	if _, err := c.ReportLocation(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be one of [debug info warn error], got [%s]", c.LogLevel))
+	}
+
+	switch c.TracingExporter {
+	case "otlp", "stdout", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER must be one of [otlp stdout none], got [%s]", c.TracingExporter))
+	}
+
+	problems = append(problems, nonPositiveDurations(c)...)
 
-	return appConfig
+	return problems
 }
@@ -0,0 +1,173 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "******"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func applyDefaults(appConfig *AppConfig) error {
+	return eachField(appConfig, func(field reflect.StructField, value reflect.Value) error {
+		defaultValue, ok := field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		return setValue(value, defaultValue)
+	})
+}
+
+func applyFile(appConfig *AppConfig, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file [%s], error: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(appConfig)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file [%s], error: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(appConfig *AppConfig) []string {
+	var problems []string
+	_ = eachField(appConfig, func(field reflect.StructField, value reflect.Value) error {
+		key := field.Tag.Get("env")
+		envValue := os.Getenv(key)
+		if key == "" || envValue == "" {
+			return nil
+		}
+
+		if err := setValue(value, envValue); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err.Error()))
+		}
+		return nil
+	})
+
+	return problems
+}
+
+func missingRequired(appConfig AppConfig) []string {
+	var missing []string
+	_ = eachField(&appConfig, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			missing = append(missing, fmt.Sprintf("%s (%s) is required", field.Tag.Get("env"), field.Tag.Get("yaml")))
+		}
+		return nil
+	})
+	return missing
+}
+
+func nonPositiveDurations(appConfig AppConfig) []string {
+	var problems []string
+	_ = eachField(&appConfig, func(field reflect.StructField, value reflect.Value) error {
+		if value.Type() == durationType && value.Int() <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be a positive duration", field.Tag.Get("env")))
+		}
+		return nil
+	})
+	return problems
+}
+
+// Redacted renders the effective configuration as YAML, replacing the value
+// of every field tagged `secret` that is set.
+func (c AppConfig) Redacted() (string, error) {
+	values := map[string]string{}
+	_ = eachField(&c, func(field reflect.StructField, value reflect.Value) error {
+		key := field.Tag.Get("yaml")
+		switch {
+		case field.Tag.Get("secret") == "true" && !value.IsZero():
+			values[key] = redacted
+		case value.Type() == durationType:
+			values[key] = time.Duration(value.Int()).String()
+		default:
+			values[key] = fmt.Sprint(value.Interface())
+		}
+		return nil
+	})
+
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func eachField(appConfig *AppConfig, fn func(field reflect.StructField, value reflect.Value) error) error {
+	configValue := reflect.ValueOf(appConfig).Elem()
+	configType := configValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		if err := fn(configType.Field(i), configValue.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration [%s]", raw)
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer [%s]", raw)
+		}
+		value.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean [%s]", raw)
+		}
+		value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config type [%s]", value.Type())
+	}
+
+	return nil
+}
+
+// ValidationError lists every configuration problem found, so startup
+// reports all of them at once instead of failing on the first.
+type ValidationError struct {
+	Problems []string
+}
+
+func newValidationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return ValidationError{Problems: problems}
+}
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
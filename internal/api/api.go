@@ -1,16 +1,56 @@
 package api
 
 import (
+	"time"
+
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func NewApi(orderController controllers.OrderController) *gin.Engine {
-	router := gin.Default()
+// NewApi builds the HTTP router. The dev routes are only registered when
+// devController is not nil, and the admin and webhook routes require
+// adminToken.
+func NewApi(orderController controllers.OrderController, webhookController controllers.WebhookController, healthController controllers.HealthController, adminController controllers.AdminController, reportController controllers.ReportController, devController *controllers.DevController, requestTimeout time.Duration, adminToken string, log logger.Logger) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/healthz", healthController.LivenessHandler)
+	router.GET("/readyz", healthController.ReadinessHandler)
+
+	router.Use(
+		otelgin.Middleware(tracing.ServiceName),
+		requestIDMiddleware(),
+		requestLoggerMiddleware(log),
+		metricsMiddleware(),
+		timeoutMiddleware(requestTimeout),
+	)
+
 	v1 := router.Group("/v1")
 	{
 		v1.GET("/orders", orderController.GetOrdersHandler)
 		v1.PUT("/orders/:id/status", orderController.UpdateOrderStatusHandler)
+
+		webhooks := v1.Group("/webhooks", adminAuthMiddleware(adminToken))
+		webhooks.POST("", webhookController.CreateSubscriptionHandler)
+		webhooks.GET("", webhookController.GetSubscriptionsHandler)
+		webhooks.DELETE("/:id", webhookController.DeleteSubscriptionHandler)
+		webhooks.GET("/:id/deliveries", webhookController.GetDeliveriesHandler)
+
+		admin := v1.Group("/admin", adminAuthMiddleware(adminToken))
+		admin.GET("/queues", adminController.GetQueuesHandler)
+		admin.PUT("/queues/:queue/mode", adminController.SetQueueModeHandler)
+		admin.POST("/replay/dead-letters", adminController.ReplayDeadLettersHandler)
+		admin.POST("/replay/status-events", adminController.RepublishStatusEventsHandler)
+
+		v1.GET("/reports/daily", reportController.GetDailyReportHandler)
+	}
+
+	if devController != nil {
+		v1.POST("/dev/events", devController.PublishOrderEventHandler)
 	}
 
 	return router
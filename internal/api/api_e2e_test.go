@@ -0,0 +1,320 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/api"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/health"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	inProgressQueue = "orders-in-progress"
+	readyQueue      = "orders-ready"
+	deadLetterQueue = "orders-in-progress.dlq"
+	testAdminToken  = "admin-secret"
+)
+
+// newInProcessApi wires the service the way cmd/main.go does with the
+// memory store and the memory broker.
+func newInProcessApi(t *testing.T) (http.Handler, broker.MemoryBroker) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	log := logger.NewNopLogger()
+
+	memoryBroker := broker.NewMemoryBroker()
+	t.Cleanup(func() { memoryBroker.Close() })
+
+	orderRepository := gateways.NewInMemoryOrderRepository()
+	webhookRepository := gateways.NewInMemoryWebhookRepository()
+	orderNotify := gateways.NewOrderNotify(memoryBroker, readyQueue, time.Second)
+	webhookDispatcher := gateways.NewWebhookDispatcher(webhookRepository, httpDriver.NewHttpClient(httpDriver.Options{Timeout: time.Second}), 3, 10*time.Millisecond, log)
+	t.Cleanup(webhookDispatcher.Close)
+	orderUseCase := usecases.NewOrderUseCase(orderRepository, orderNotify, webhookDispatcher, log)
+	consumer := memoryBroker.NewConsumer(inProgressQueue, time.Second, log)
+	deadLetters := gateways.NewDeadLetterQueue(memoryBroker, deadLetterQueue, time.Second)
+	consumers := usecases.NewOrderConsumerUseCase(deadLetters, log)
+	consumers.RegisterQueue(inProgressQueue, consumer)
+	require.NoError(t, consumers.RegisterHandler(usecases.HandlerRegistration{
+		Queue:       inProgressQueue,
+		EventType:   usecases.OrderProductionEventType,
+		Version:     usecases.OrderProductionEventVersion,
+		Concurrency: 2,
+		Handle:      usecases.NewOrderProductionHandler(orderUseCase, log),
+	}))
+	consumers.StartConsumers(ctx)
+	t.Cleanup(consumers.StopConsumers)
+	backpressure := usecases.NewKitchenBackpressureUseCase(orderUseCase, consumers, inProgressQueue, usecases.KitchenCapacity{}, time.Hour, log)
+	openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+		return memoryBroker.NewConsumer(queue, time.Second, log), func() error { return nil }, nil
+	}
+	replayOptions := usecases.ReplayOptions{
+		DeadLetterQueue:       deadLetterQueue,
+		DeadLetterDestination: deadLetterQueue,
+		ReplayDestination:     inProgressQueue,
+		IdleTimeout:           50 * time.Millisecond,
+	}
+	replay := usecases.NewReplayUseCase(orderUseCase, orderNotify, openConsumer, memoryBroker, replayOptions, log)
+
+	devController := controllers.NewDevController(usecases.NewDevEventUseCase(memoryBroker, inProgressQueue), log)
+	router := api.NewApi(
+		controllers.NewOrderController(orderUseCase, log),
+		controllers.NewWebhookController(usecases.NewWebhookUseCase(webhookRepository, log), log),
+		controllers.NewHealthController(health.NewHealth(time.Second)),
+		controllers.NewAdminController(backpressure, replay, log),
+		controllers.NewReportController(usecases.NewReportUseCase(orderUseCase, time.UTC, log), log),
+		&devController,
+		5*time.Second,
+		testAdminToken,
+		log,
+	)
+
+	return router, memoryBroker
+}
+
+// serve sends the request as an admin, the other routes ignoring the token.
+func serve(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestOrderFlowWithoutExternalDependencies(t *testing.T) {
+	router, memoryBroker := newInProcessApi(t)
+
+	w := serve(router, http.MethodPost, "/v1/dev/events",
+		`{"id": 42, "status": "PAID", "items": [{"quantity": 2, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var orders []models.Order
+	require.Eventually(t, func() bool {
+		w := serve(router, http.MethodGet, "/v1/orders", "")
+		orders = nil
+		return w.Code == http.StatusOK && json.Unmarshal(w.Body.Bytes(), &orders) == nil && len(orders) == 1
+	}, 2*time.Second, 10*time.Millisecond, "consumed event creates the order")
+	assert.Equal(t, "42", orders[0].ID)
+	assert.Equal(t, models.OrderStatusCreated, orders[0].Status)
+	assert.Equal(t, "Batata Frita", orders[0].Items[0].Product.Name)
+
+	w = serve(router, http.MethodPut, "/v1/orders/42/status", `{"status": "READY"}`)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	notifications := make(chan []byte, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go memoryBroker.NewConsumer(readyQueue, time.Second, logger.NewNopLogger()).StartConsumer(ctx, func(ctx context.Context, message []byte) error {
+		notifications <- message
+		return nil
+	})
+
+	select {
+	case message := <-notifications:
+		event, err := broker.DecodeCloudEvent(message)
+		require.NoError(t, err)
+		assert.Equal(t, gateways.OrderStatusChangedEventType+".v1", event.Type)
+		assert.Equal(t, "42", event.Subject)
+
+		var data events.OrderStatusEventDTO
+		require.NoError(t, json.Unmarshal(event.Data, &data))
+		assert.Equal(t, events.OrderStatusEventDTO{OrderId: 42, Status: models.OrderStatusReady}, data)
+	case <-time.After(2 * time.Second):
+		t.Fatal("order ready notification was not published")
+	}
+}
+
+func TestDailyReport(t *testing.T) {
+	router, _ := newInProcessApi(t)
+
+	w := serve(router, http.MethodPost, "/v1/dev/events",
+		`{"id": 42, "status": "PAID", "items": [{"quantity": 2, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Eventually(t, func() bool {
+		return serve(router, http.MethodPut, "/v1/orders/42/status", `{"status": "READY"}`).Code == http.StatusNoContent
+	}, 2*time.Second, 10*time.Millisecond, "consumed event creates the order")
+	w = serve(router, http.MethodPut, "/v1/orders/42/status", `{"status": "DELIVERED"}`)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	var report dto.DailyReport
+	w = serve(router, http.MethodGet, "/v1/reports/daily", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.Partial)
+	assert.Equal(t, 1, report.Summary.Delivered)
+	assert.Equal(t, 2, report.Summary.Products["Batata Frita"].Items)
+	assert.Contains(t, report.Summary.Stages, usecases.ReportStageTotal)
+
+	w = serve(router, http.MethodGet, "/v1/reports/daily?format=csv", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), ",product,Batata Frita,1,1,0,2,")
+}
+
+func TestConsumerRoutesEvents(t *testing.T) {
+	router, memoryBroker := newInProcessApi(t)
+	ctx := context.Background()
+
+	legacy := `{"id": 7, "status": "PAID", "items": [{"quantity": 1, "type": "UNIT", "product": {"name": "Suco"}}]}`
+	require.NoError(t, memoryBroker.Publish(ctx, inProgressQueue, "7", []byte(legacy)))
+
+	unknownVersion, err := broker.NewCloudEvent("/g73-techchallenge-order", usecases.OrderProductionEventType, 2, "", "8", map[string]int{"id": 8})
+	require.NoError(t, err)
+	message, _ := json.Marshal(unknownVersion)
+	require.NoError(t, memoryBroker.Publish(ctx, inProgressQueue, "8", message))
+	require.NoError(t, memoryBroker.Publish(ctx, inProgressQueue, "9", []byte("not json")))
+
+	require.Eventually(t, func() bool {
+		return memoryBroker.Pending(deadLetterQueue) == 2
+	}, 2*time.Second, 10*time.Millisecond, "unknown versions and malformed messages are dead lettered")
+	assert.Equal(t, 0, memoryBroker.Pending(inProgressQueue))
+
+	var page models.OrderPage
+	w := serve(router, http.MethodGet, "/v1/orders?limit=10", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Results, 1, "legacy payloads are still accepted")
+	assert.Equal(t, "7", page.Results[0].ID)
+}
+
+func TestForcedQueuePause(t *testing.T) {
+	router, memoryBroker := newInProcessApi(t)
+
+	w := serve(router, http.MethodPut, "/v1/admin/queues/"+inProgressQueue+"/mode", `{"mode": "PAUSED"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"state":"PAUSED"`)
+
+	w = serve(router, http.MethodPost, "/v1/dev/events", `{"id": 42, "items": [{"quantity": 1, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, memoryBroker.Pending(inProgressQueue), "a paused queue keeps its messages")
+
+	w = serve(router, http.MethodPut, "/v1/admin/queues/"+inProgressQueue+"/mode", `{"mode": "AUTO"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Eventually(t, func() bool {
+		return memoryBroker.Pending(inProgressQueue) == 0
+	}, 2*time.Second, 10*time.Millisecond, "the queue is consumed again")
+
+	w = serve(router, http.MethodGet, "/v1/admin/queues", "")
+	assert.Contains(t, w.Body.String(), `"state":"RUNNING"`)
+	assert.Contains(t, w.Body.String(), `"mode":"AUTO"`)
+}
+
+func TestReplay(t *testing.T) {
+	router, memoryBroker := newInProcessApi(t)
+	ctx := context.Background()
+
+	unknownVersion, err := broker.NewCloudEvent("/g73-techchallenge-order", usecases.OrderProductionEventType, 2, "", "8", map[string]int{"id": 8})
+	require.NoError(t, err)
+	message, _ := json.Marshal(unknownVersion)
+	require.NoError(t, memoryBroker.Publish(ctx, inProgressQueue, "8", message))
+	require.Eventually(t, func() bool {
+		return memoryBroker.Pending(deadLetterQueue) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	w := serve(router, http.MethodPost, "/v1/admin/replay/dead-letters", `{"orderId": "8", "dryRun": true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"orderId":"8"`)
+	assert.Equal(t, 1, memoryBroker.Pending(deadLetterQueue), "a dry run leaves the dead letters")
+
+	w = serve(router, http.MethodPost, "/v1/dev/events", `{"id": 42, "items": [{"quantity": 1, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Eventually(t, func() bool {
+		return serve(router, http.MethodPut, "/v1/orders/42/status", `{"status": "READY"}`).Code == http.StatusNoContent
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, memoryBroker.Pending(readyQueue))
+
+	window := fmt.Sprintf(`{"from": "%s", "to": "%s", "statuses": ["READY"]}`,
+		time.Now().Add(-time.Minute).UTC().Format(time.RFC3339), time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+	w = serve(router, http.MethodPost, "/v1/admin/replay/status-events", window)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"orderId":"42","eventType":"br.com.g73.production.order.status_changed.v1","status":"READY"`)
+	assert.Equal(t, 2, memoryBroker.Pending(readyQueue), "the status is published again")
+}
+
+func TestWebhookFlow(t *testing.T) {
+	router, _ := newInProcessApi(t)
+
+	callbacks := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	partner := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		callbacks <- r
+		bodies <- body
+	}))
+	defer partner.Close()
+	// subscriptions are https only, so the dispatcher must trust the
+	// certificate of the partner
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = partner.Client().Transport
+	t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+
+	unauthorized := httptest.NewRecorder()
+	router.ServeHTTP(unauthorized, httptest.NewRequest(http.MethodGet, "/v1/webhooks", nil))
+	assert.Equal(t, http.StatusUnauthorized, unauthorized.Code, "webhooks are managed by the admins")
+
+	w := serve(router, http.MethodPost, "/v1/webhooks",
+		`{"url": "`+partner.URL+`", "events": ["READY"], "secret": "partner-secret-0001"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "partner-secret-0001", "the secret is never returned")
+	var subscription models.WebhookSubscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subscription))
+
+	w = serve(router, http.MethodPost, "/v1/dev/events", `{"id": 42, "items": [{"quantity": 1, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Eventually(t, func() bool {
+		return serve(router, http.MethodPut, "/v1/orders/42/status", `{"status": "READY"}`).Code == http.StatusNoContent
+	}, 2*time.Second, 10*time.Millisecond)
+
+	select {
+	case callback := <-callbacks:
+		body := <-bodies
+		signature := gateways.SignWebhookPayload("partner-secret-0001", callback.Header.Get(gateways.WebhookTimestampHeader), body)
+		assert.Equal(t, signature, callback.Header.Get(gateways.WebhookSignatureHeader))
+		assert.Contains(t, string(body), `"orderId":42`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the partner was not called")
+	}
+
+	var deliveries struct {
+		Results []models.WebhookDelivery `json:"results"`
+	}
+	require.Eventually(t, func() bool {
+		w := serve(router, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries?orderId=42", "")
+		return w.Code == http.StatusOK && json.Unmarshal(w.Body.Bytes(), &deliveries) == nil &&
+			len(deliveries.Results) == 1 && deliveries.Results[0].Status == models.WebhookDeliverySucceeded
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, deliveries.Results[0].Attempts)
+
+	w = serve(router, http.MethodDelete, "/v1/webhooks/"+subscription.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(router, http.MethodGet, "/v1/webhooks/"+subscription.ID+"/deliveries", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDevRoutesAreDisabledByDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := api.NewApi(controllers.OrderController{}, controllers.WebhookController{}, controllers.HealthController{}, controllers.AdminController{}, controllers.ReportController{}, nil, time.Second, testAdminToken, logger.NewNopLogger())
+
+	w := serve(router, http.MethodPost, "/v1/dev/events", `{"id": 42}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
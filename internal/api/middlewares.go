@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), start)
+	}
+}
+
+// timeoutMiddleware bounds the request context so the deadline reaches every
+// downstream call made on behalf of the request.
+func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// requestIDMiddleware propagates the caller's X-Request-ID, generating one
+// when absent, and stores it in the request context for logging.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// adminAuthMiddleware requires the Authorization header to be the bearer
+// token, rejecting every request when token is empty.
+func adminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || !found || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, controllers.ErrorResponse{
+				Message: "unauthorized",
+				Err:     "a valid admin bearer token is required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func requestLoggerMiddleware(log logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := log.WithContext(c.Request.Context()).WithFields(logger.Fields{
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+			"route":     c.FullPath(),
+			"status":    c.Writer.Status(),
+			"latency":   time.Since(start).String(),
+			"client_ip": c.ClientIP(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithFields(logger.Fields{"errors": c.Errors.String()})
+		}
+
+		switch {
+		case c.Writer.Status() >= 500:
+			entry.Errorf("request completed")
+		case c.Writer.Status() >= 400:
+			entry.Warnf("request completed")
+		default:
+			entry.Infof("request completed")
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var contextRequestID string
+	router := gin.New()
+	router.Use(requestIDMiddleware())
+	router.GET("/ping", func(c *gin.Context) {
+		contextRequestID = logger.RequestIDFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	t.Run("propagates incoming request id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(requestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "abc-123", w.Header().Get(requestIDHeader))
+		assert.Equal(t, "abc-123", contextRequestID)
+	})
+
+	t.Run("generates request id when missing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Len(t, w.Header().Get(requestIDHeader), 32)
+		assert.Equal(t, w.Header().Get(requestIDHeader), contextRequestID)
+	})
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		expectedCode  int
+	}{
+		{name: "valid token", token: "secret", authorization: "Bearer secret", expectedCode: http.StatusOK},
+		{name: "missing token", token: "secret", expectedCode: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer other", expectedCode: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "secret", authorization: "secret", expectedCode: http.StatusUnauthorized},
+		{name: "no token configured", authorization: "Bearer ", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin", adminAuthMiddleware(tt.token), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
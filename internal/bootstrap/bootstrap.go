@@ -0,0 +1,301 @@
+// Package bootstrap builds the store and the broker selected by the
+// configuration, shared by the server and the command line tools.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/configs"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/postgres"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/s3"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/sqlite"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/health"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/scheduler"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awsDynamoDb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Store holds the repositories backed by the store selected by
+// STORE_DRIVER, the readiness check of its backing service and a function
+// releasing it. ScheduleArchive starts the daily archive of the completed
+// orders until ctx is cancelled; only the server calls it, so the command
+// line tools never run the archive.
+type Store struct {
+	Orders          gateways.OrderRepository
+	Webhooks        gateways.WebhookRepository
+	Checker         health.Checker
+	ScheduleArchive func(ctx context.Context) error
+	Close           func() error
+}
+
+func noArchive(ctx context.Context) error {
+	return nil
+}
+
+func NewStore(ctx context.Context, appConfig configs.AppConfig, log logger.Logger) (Store, error) {
+	switch appConfig.StoreDriver {
+	case "memory":
+		checker := health.NewChecker("store", func(ctx context.Context) error {
+			return nil
+		})
+		return Store{
+			Orders:          gateways.NewInMemoryOrderRepository(),
+			Webhooks:        gateways.NewInMemoryWebhookRepository(),
+			Checker:         checker,
+			ScheduleArchive: noArchive,
+			Close:           func() error { return nil },
+		}, nil
+	case "sqlite":
+		db, err := sqlite.NewSQLiteDB(ctx, appConfig.SQLitePath)
+		if err != nil {
+			return Store{}, err
+		}
+		archiver := gateways.NewSQLiteOrderArchiver(db)
+		scheduleArchive := func(ctx context.Context) error {
+			return scheduler.RunDaily(ctx, "sqlite-archive", appConfig.SQLiteArchiveAt, func(ctx context.Context) error {
+				archived, err := archiver.ArchiveOrders(ctx, time.Now().Add(-appConfig.SQLiteArchiveRetention))
+				log.Infof("archived [%d] completed orders", archived)
+				return err
+			}, log)
+		}
+		return Store{
+			Orders:          gateways.NewSQLiteOrderRepository(db, appConfig.RepositoryTimeout),
+			Webhooks:        gateways.NewSQLiteWebhookRepository(db, appConfig.RepositoryTimeout),
+			Checker:         health.NewChecker("sqlite", db.PingContext),
+			ScheduleArchive: scheduleArchive,
+			Close:           db.Close,
+		}, nil
+	case "postgres":
+		db, err := postgres.NewPostgresDB(ctx, appConfig.PostgresDSN)
+		if err != nil {
+			return Store{}, err
+		}
+		return Store{
+			Orders:          gateways.NewPostgresOrderRepository(db, appConfig.RepositoryTimeout),
+			Webhooks:        gateways.NewPostgresWebhookRepository(db, appConfig.RepositoryTimeout),
+			Checker:         health.NewChecker("postgres", db.PingContext),
+			ScheduleArchive: noArchive,
+			Close:           db.Close,
+		}, nil
+	default:
+		dynamodbClient, err := NewDynamoDBClient(ctx, appConfig.OrderTableEndpoint)
+		if err != nil {
+			return Store{}, err
+		}
+		checker := health.NewChecker("dynamodb", func(ctx context.Context) error {
+			return gateways.CheckOrderTable(ctx, dynamodbClient, appConfig.OrderTable)
+		})
+		scheduleArchive := noArchive
+		if appConfig.DynamoDBArchiveEnabled() {
+			scheduleArchive = func(ctx context.Context) error {
+				return scheduleDynamoDBArchive(ctx, appConfig, dynamodbClient, log)
+			}
+		}
+		return Store{
+			Orders:          gateways.NewOrderRepository(dynamodbClient, appConfig.OrderTable, appConfig.RepositoryTimeout),
+			Webhooks:        gateways.NewWebhookRepository(dynamodbClient, appConfig.OrderTable, appConfig.RepositoryTimeout, appConfig.WebhookDeliveryRetention),
+			Checker:         checker,
+			ScheduleArchive: scheduleArchive,
+			Close:           func() error { return nil },
+		}, nil
+	}
+}
+
+func scheduleDynamoDBArchive(ctx context.Context, appConfig configs.AppConfig, dynamodbClient dynamodb.DynamoDBClient, log logger.Logger) error {
+	exporter := gateways.NewFileOrderExporter(appConfig.ArchiveExportDir)
+	if appConfig.ArchiveExportBucket != "" {
+		s3Client, err := NewS3Client(ctx, appConfig.ArchiveExportEndpoint)
+		if err != nil {
+			return err
+		}
+		exporter = gateways.NewS3OrderExporter(s3Client, appConfig.ArchiveExportBucket, appConfig.ArchiveExportPrefix, appConfig.ArchiveExportTimeout)
+	}
+
+	archiver := gateways.NewOrderArchiver(dynamodbClient, appConfig.OrderTable, exporter, appConfig.DynamoDBArchiveLookback, appConfig.DynamoDBArchiveRetention, appConfig.RepositoryTimeout)
+	return scheduler.RunDaily(ctx, "dynamodb-archive", appConfig.DynamoDBArchiveAt, func(ctx context.Context) error {
+		archived, err := archiver.ArchiveOrders(ctx, time.Now().Add(-appConfig.DynamoDBArchiveAfter))
+		log.Infof("archived [%d] completed orders", archived)
+		return err
+	}, log)
+}
+
+func NewDynamoDBClient(ctx context.Context, endpoint string) (dynamodb.DynamoDBClient, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint != "" {
+		client := awsDynamoDb.NewFromConfig(cfg, func(o *awsDynamoDb.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		})
+		return dynamodb.NewDynamoDBClient(client), nil
+	}
+
+	client := awsDynamoDb.NewFromConfig(cfg)
+	return dynamodb.NewDynamoDBClient(client), nil
+
+}
+
+// NewS3Client returns a client for S3 or, with an endpoint, for an S3
+// compatible storage, which is addressed by path.
+func NewS3Client(ctx context.Context, endpoint string) (s3.S3Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := awsS3.NewFromConfig(cfg, func(o *awsS3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return s3.NewS3Client(client), nil
+}
+
+func NewSQSClient(ctx context.Context, endpoint string) (*sqs.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+func NewSNSClient(ctx context.Context, endpoint string) (*sns.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sns.NewFromConfig(cfg, func(o *sns.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// Broker holds the publisher selected by BROKER_DRIVER, a function opening
+// a consumer of one of its queues, the readiness check of the broker and a
+// function closing it.
+type Broker struct {
+	Publisher    broker.Publisher
+	OpenConsumer broker.ConsumerOpener
+	Checker      health.Checker
+	Close        func() error
+}
+
+func NewOrderBroker(ctx context.Context, appConfig configs.AppConfig, log logger.Logger) (Broker, error) {
+	noClose := func() error { return nil }
+
+	switch appConfig.BrokerDriver {
+	case "memory":
+		memoryBroker := broker.NewMemoryBroker()
+		checker := health.NewChecker("broker", func(ctx context.Context) error {
+			return nil
+		})
+		openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+			return memoryBroker.NewConsumer(queue, appConfig.MessageProcessingTimeout, log), noClose, nil
+		}
+		return Broker{Publisher: memoryBroker, OpenConsumer: openConsumer, Checker: checker, Close: memoryBroker.Close}, nil
+	case "kafka":
+		brokers := strings.Split(appConfig.KafkaBrokers, ",")
+		publisher := broker.NewKafkaPublisher(broker.NewKafkaWriter(brokers))
+		checker := health.NewChecker("kafka", func(ctx context.Context) error {
+			return broker.PingKafka(ctx, brokers)
+		})
+		openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+			reader := broker.NewKafkaReader(brokers, appConfig.KafkaConsumerGroup, queue)
+			return broker.NewKafkaConsumer(reader, queue, appConfig.MessageProcessingTimeout, appConfig.KafkaMaxAttempts, publisher, appConfig.DeadLetterDestination(), log), reader.Close, nil
+		}
+		return Broker{Publisher: publisher, OpenConsumer: openConsumer, Checker: checker, Close: publisher.Close}, nil
+	case "sqs":
+		sqsClient, err := NewSQSClient(ctx, appConfig.SQSEndpoint)
+		if err != nil {
+			return Broker{}, err
+		}
+		snsClient, err := NewSNSClient(ctx, appConfig.SNSEndpoint)
+		if err != nil {
+			return Broker{}, err
+		}
+
+		checker := health.NewChecker("sqs", func(ctx context.Context) error {
+			_, err := sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(appConfig.OrderInProgressEventsQueue)})
+			return err
+		})
+		openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+			consumer, err := broker.NewSQSConsumer(ctx, sqsClient, queue, appConfig.SQSVisibilityTimeout, appConfig.MessageProcessingTimeout, log)
+			return consumer, noClose, err
+		}
+		publisher := broker.NewSNSPublisher(snsClient)
+		return Broker{Publisher: publisher, OpenConsumer: openConsumer, Checker: checker, Close: publisher.Close}, nil
+	default:
+		brokerConnection, brokerChannel, err := NewRabbitMQBrokerChannel(appConfig.OrderEventsBrokerUrl)
+		if err != nil {
+			return Broker{}, err
+		}
+
+		publisher := broker.NewRabbitMQPublisher(brokerConnection, brokerChannel, appConfig.OrderEventsTopic)
+		checker := health.NewChecker("rabbitmq", func(ctx context.Context) error {
+			if brokerConnection.IsClosed() {
+				return errors.New("connection is closed")
+			}
+			if brokerChannel.IsClosed() {
+				return errors.New("channel is closed")
+			}
+			return nil
+		})
+		// each consumer has its own channel, so its prefetch and the
+		// deliveries left when it is closed don't affect the others
+		openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+			consumerChannel, err := brokerConnection.Channel()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open a channel for queue [%s]: %w", queue, err)
+			}
+			consumer, err := broker.NewRabbitMQConsumer(consumerChannel, queue, appConfig.RabbitMQPrefetch, usecases.OrderEventKey, appConfig.MessageProcessingTimeout, appConfig.RabbitMQMaxAttempts, publisher, appConfig.DeadLetterDestination(), log)
+			if err != nil {
+				consumerChannel.Close()
+				return nil, nil, err
+			}
+			return consumer, consumerChannel.Close, nil
+		}
+		closeBroker := func() error {
+			publisher.Close()
+			return brokerConnection.Close()
+		}
+		return Broker{Publisher: publisher, OpenConsumer: openConsumer, Checker: checker, Close: closeBroker}, nil
+	}
+}
+
+func NewRabbitMQBrokerChannel(url string) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, ch, err
+}
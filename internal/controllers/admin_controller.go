@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminController exposes the consumption of the queues, and the replay
+// of events, to the operators.
+type AdminController struct {
+	backpressureUseCase usecases.KitchenBackpressureUseCase
+	replayUseCase       usecases.ReplayUseCase
+	logger              logger.Logger
+}
+
+func NewAdminController(backpressureUseCase usecases.KitchenBackpressureUseCase, replayUseCase usecases.ReplayUseCase, logger logger.Logger) AdminController {
+	return AdminController{
+		backpressureUseCase: backpressureUseCase,
+		replayUseCase:       replayUseCase,
+		logger:              logger,
+	}
+}
+
+func (a AdminController) GetQueuesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, a.backpressureUseCase.Status())
+}
+
+// SetQueueModeHandler forces a queue paused or running, or gives it back
+// to the kitchen capacity check with AUTO.
+func (a AdminController) SetQueueModeHandler(c *gin.Context) {
+	var modeRequest dto.ConsumerModeRequest
+	err := bindStrictJSON(c, &modeRequest)
+	if err != nil {
+		fields := getFieldErrors(&modeRequest, err)
+		if fields != nil {
+			handleValidationErrorResponse(c, "invalid queue mode payload", err, fields)
+			return
+		}
+		handleBadRequestResponse(c, "failed to bind queue mode payload", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = a.backpressureUseCase.SetMode(c.Param("queue"), usecases.ConsumerMode(modeRequest.Mode))
+	if errors.Is(err, usecases.ErrQueueNotFound) {
+		handleNotFoundResponse(c, "queue not found", err)
+		return
+	}
+	if err != nil {
+		a.logger.WithContext(ctx).WithError(err).Errorf("failed to set queue mode")
+		handleInternalServerResponse(c, "failed to set queue mode", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, a.backpressureUseCase.Status())
+}
+
+// ReplayDeadLettersHandler moves the dead letters matching the request back
+// to the work queue, or only lists them with dryRun.
+func (a AdminController) ReplayDeadLettersHandler(c *gin.Context) {
+	var replayRequest dto.DeadLetterReplayRequest
+	if !a.bindReplayRequest(c, &replayRequest) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	result, err := a.replayUseCase.ReplayDeadLetters(ctx, replayRequest)
+	a.handleReplay(c, "failed to replay dead letters", result, err)
+}
+
+// RepublishStatusEventsHandler publishes again the status of the orders
+// whose status changed in the requested window.
+func (a AdminController) RepublishStatusEventsHandler(c *gin.Context) {
+	var replayRequest dto.StatusEventReplayRequest
+	if !a.bindReplayRequest(c, &replayRequest) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	result, err := a.replayUseCase.RepublishStatusEvents(ctx, replayRequest)
+	a.handleReplay(c, "failed to republish status events", result, err)
+}
+
+func (a AdminController) bindReplayRequest(c *gin.Context, replayRequest any) bool {
+	err := bindStrictJSON(c, replayRequest)
+	if err != nil {
+		fields := getFieldErrors(replayRequest, err)
+		if fields != nil {
+			handleValidationErrorResponse(c, "invalid replay payload", err, fields)
+			return false
+		}
+		handleBadRequestResponse(c, "failed to bind replay payload", err)
+		return false
+	}
+	return true
+}
+
+func (a AdminController) handleReplay(c *gin.Context, message string, result dto.ReplayResult, err error) {
+	switch {
+	case errors.Is(err, usecases.ErrInvalidReplayWindow):
+		handleBadRequestResponse(c, message, err)
+	case errors.Is(err, usecases.ErrReplayDisabled):
+		handleConflictResponse(c, message, err)
+	case err != nil:
+		a.logger.WithContext(c.Request.Context()).WithError(err).Errorf("%s", message)
+		handleInternalServerResponse(c, message, err)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,221 @@
+package controllers_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAdminController(t *testing.T) {
+	status := usecases.BackpressureStatus{
+		Load: usecases.KitchenLoad{ActiveOrders: 12, Overloaded: []string{usecases.KitchenTotal}},
+		Queues: []usecases.ConsumerQueueStatus{{
+			QueueStatus: usecases.QueueStatus{Queue: "orders-in-progress", State: usecases.QueueStatePaused},
+			Mode:        usecases.ConsumerModeAuto,
+		}},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		mockSetup    func(m *mock_usecases.MockKitchenBackpressureUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "get queues",
+			method: http.MethodGet,
+			path:   "/admin/queues",
+			mockSetup: func(m *mock_usecases.MockKitchenBackpressureUseCase) {
+				m.EXPECT().Status().Return(status)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"queue":"orders-in-progress","state":"PAUSED","workers":0,"handlers":null,"mode":"AUTO"`,
+		},
+		{
+			name:   "force pause",
+			method: http.MethodPut,
+			path:   "/admin/queues/orders-in-progress/mode",
+			body:   `{"mode": "PAUSED"}`,
+			mockSetup: func(m *mock_usecases.MockKitchenBackpressureUseCase) {
+				m.EXPECT().SetMode("orders-in-progress", usecases.ConsumerModePaused).Return(nil)
+				m.EXPECT().Status().Return(status)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"overloaded":["total"]`,
+		},
+		{
+			name:         "invalid mode",
+			method:       http.MethodPut,
+			path:         "/admin/queues/orders-in-progress/mode",
+			body:         `{"mode": "STOPPED"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"rule":"oneof"`,
+		},
+		{
+			name:   "unknown queue",
+			method: http.MethodPut,
+			path:   "/admin/queues/orders-cancelled/mode",
+			body:   `{"mode": "RUNNING"}`,
+			mockSetup: func(m *mock_usecases.MockKitchenBackpressureUseCase) {
+				m.EXPECT().SetMode("orders-cancelled", usecases.ConsumerModeRunning).Return(fmt.Errorf("failed to set mode: %w", usecases.ErrQueueNotFound))
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "resume error",
+			method: http.MethodPut,
+			path:   "/admin/queues/orders-in-progress/mode",
+			body:   `{"mode": "AUTO"}`,
+			mockSetup: func(m *mock_usecases.MockKitchenBackpressureUseCase) {
+				m.EXPECT().SetMode("orders-in-progress", usecases.ConsumerModeAuto).Return(errors.New("channel closed"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			mockBackpressureUseCase := mock_usecases.NewMockKitchenBackpressureUseCase(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockBackpressureUseCase)
+			}
+
+			adminController := controllers.NewAdminController(mockBackpressureUseCase, nil, logger.NewNopLogger())
+			router := gin.New()
+			router.GET("/admin/queues", adminController.GetQueuesHandler)
+			router.PUT("/admin/queues/:queue/mode", adminController.SetQueueModeHandler)
+
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestAdminController_Replay(t *testing.T) {
+	from := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	result := dto.ReplayResult{
+		DryRun:   true,
+		Scanned:  3,
+		Messages: []dto.ReplayedMessage{{OrderID: "7", EventType: "br.com.g73.order.production.v1", Time: from}},
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		mockSetup    func(m *mock_usecases.MockReplayUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "dry run dead letters",
+			path: "/admin/replay/dead-letters",
+			body: `{"orderId": "7", "dryRun": true}`,
+			mockSetup: func(m *mock_usecases.MockReplayUseCase) {
+				m.EXPECT().ReplayDeadLetters(gomock.Any(), dto.DeadLetterReplayRequest{OrderID: "7", DryRun: true}).Return(result, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"dryRun":true,"scanned":3,"messages":[{"orderId":"7","eventType":"br.com.g73.order.production.v1","time":"2024-05-10T00:00:00Z"}]}`,
+		},
+		{
+			name:         "dead letters limit too large",
+			path:         "/admin/replay/dead-letters",
+			body:         `{"limit": 20000}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"rule":"lte"`,
+		},
+		{
+			name: "no dead letter queue",
+			path: "/admin/replay/dead-letters",
+			body: `{}`,
+			mockSetup: func(m *mock_usecases.MockReplayUseCase) {
+				m.EXPECT().ReplayDeadLetters(gomock.Any(), dto.DeadLetterReplayRequest{}).Return(dto.ReplayResult{}, usecases.ErrReplayDisabled)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name: "republish status events",
+			path: "/admin/replay/status-events",
+			body: `{"from": "2024-05-10T00:00:00Z", "to": "2024-05-11T00:00:00Z", "statuses": ["READY"]}`,
+			mockSetup: func(m *mock_usecases.MockReplayUseCase) {
+				request := dto.StatusEventReplayRequest{From: from, To: to, Statuses: []string{"READY"}}
+				m.EXPECT().RepublishStatusEvents(gomock.Any(), request).Return(result, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"scanned":3`,
+		},
+		{
+			name:         "invalid status",
+			path:         "/admin/replay/status-events",
+			body:         `{"from": "2024-05-10T00:00:00Z", "to": "2024-05-11T00:00:00Z", "statuses": ["LOST"]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"rule":"oneof"`,
+		},
+		{
+			name: "invalid window",
+			path: "/admin/replay/status-events",
+			body: `{"from": "2024-05-11T00:00:00Z", "to": "2024-05-10T00:00:00Z"}`,
+			mockSetup: func(m *mock_usecases.MockReplayUseCase) {
+				m.EXPECT().RepublishStatusEvents(gomock.Any(), gomock.Any()).Return(dto.ReplayResult{}, fmt.Errorf("%w: to must be after from", usecases.ErrInvalidReplayWindow))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "publish error",
+			path: "/admin/replay/status-events",
+			body: `{"from": "2024-05-10T00:00:00Z", "to": "2024-05-11T00:00:00Z"}`,
+			mockSetup: func(m *mock_usecases.MockReplayUseCase) {
+				m.EXPECT().RepublishStatusEvents(gomock.Any(), gomock.Any()).Return(dto.ReplayResult{}, errors.New("broker unavailable"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			mockReplayUseCase := mock_usecases.NewMockReplayUseCase(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockReplayUseCase)
+			}
+
+			adminController := controllers.NewAdminController(nil, mockReplayUseCase, logger.NewNopLogger())
+			router := gin.New()
+			router.POST("/admin/replay/dead-letters", adminController.ReplayDeadLettersHandler)
+			router.POST("/admin/replay/status-events", adminController.RepublishStatusEventsHandler)
+
+			req, _ := http.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// DevController exposes the endpoints only available when the service
+// runs without external dependencies.
+type DevController struct {
+	devEventUseCase usecases.DevEventUseCase
+	logger          logger.Logger
+}
+
+func NewDevController(devEventUseCase usecases.DevEventUseCase, logger logger.Logger) DevController {
+	return DevController{
+		devEventUseCase: devEventUseCase,
+		logger:          logger,
+	}
+}
+
+func (d DevController) PublishOrderEventHandler(c *gin.Context) {
+	var orderEventRequest dto.OrderEventRequest
+	err := bindStrictJSON(c, &orderEventRequest)
+	if err != nil {
+		fields := getFieldErrors(&orderEventRequest, err)
+		if fields != nil {
+			handleValidationErrorResponse(c, "invalid order event payload", err, fields)
+			return
+		}
+		handleBadRequestResponse(c, "failed to bind order event payload", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = d.devEventUseCase.PublishOrderEvent(ctx, orderEventRequest)
+	if err != nil {
+		d.logger.WithContext(ctx).WithError(err).Errorf("failed to publish order event")
+		handleInternalServerResponse(c, "failed to publish order event", err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,82 @@
+package controllers_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPublishOrderEventHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func(m *mock_usecases.MockDevEventUseCase)
+		expectedCode int
+	}{
+		{
+			name: "success",
+			body: `{"id": 7, "status": "PAID", "items": [{"quantity": 1, "type": "UNIT", "product": {"name": "Batata Frita"}}]}`,
+			mockSetup: func(m *mock_usecases.MockDevEventUseCase) {
+				request := dto.OrderEventRequest{
+					ID:     7,
+					Status: "PAID",
+					Items: []dto.OrderEventItemRequest{
+						{Quantity: 1, Type: "UNIT", Product: dto.OrderEventProductRequest{Name: "Batata Frita"}},
+					},
+				}
+				m.EXPECT().PublishOrderEvent(gomock.Any(), request).Return(nil)
+			},
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "missing id",
+			body:         `{"items": [{"quantity": 1}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown field",
+			body:         `{"id": 7, "items": [{"quantity": 1}], "paid": true}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "publish error",
+			body: `{"id": 7, "items": [{"quantity": 1}]}`,
+			mockSetup: func(m *mock_usecases.MockDevEventUseCase) {
+				m.EXPECT().PublishOrderEvent(gomock.Any(), gomock.Any()).Return(errors.New("broker is closed"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			mockDevEventUseCase := mock_usecases.NewMockDevEventUseCase(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockDevEventUseCase)
+			}
+
+			devController := controllers.NewDevController(mockDevEventUseCase, logger.NewNopLogger())
+			router := gin.New()
+			router.POST("/dev/events", devController.PublishOrderEventHandler)
+
+			req, _ := http.NewRequest(http.MethodPost, "/dev/events", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/health"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	health health.Health
+}
+
+func NewHealthController(health health.Health) HealthController {
+	return HealthController{
+		health: health,
+	}
+}
+
+func (h HealthController) LivenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.health.Liveness())
+}
+
+func (h HealthController) ReadinessHandler(c *gin.Context) {
+	report := h.health.Readiness(c.Request.Context())
+	if report.Status != health.StatusUp {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
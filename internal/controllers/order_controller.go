@@ -1,32 +1,61 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
 	"github.com/gin-gonic/gin"
 )
 
 type OrderController struct {
 	orderUseCase usecases.OrderUseCase
+	logger       logger.Logger
 }
 
-func NewOrderController(orderUseCase usecases.OrderUseCase) OrderController {
+func NewOrderController(orderUseCase usecases.OrderUseCase, logger logger.Logger) OrderController {
 	return OrderController{
 		orderUseCase: orderUseCase,
+		logger:       logger,
 	}
 }
 
 func (o OrderController) GetOrdersHandler(c *gin.Context) {
-	orders, err := o.orderUseCase.GetOrders()
+	filter, paginated, err := getOrderFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		handleBadRequestResponse(c, "invalid query parameters", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	ctx := c.Request.Context()
+	if !paginated {
+		orders, err := o.orderUseCase.GetAllOrders(ctx, filter)
+		if err != nil {
+			o.logger.WithContext(ctx).WithError(err).Errorf("failed to get orders")
+			handleInternalServerResponse(c, "failed to get orders", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, orders)
+		return
+	}
+
+	page, err := o.orderUseCase.GetOrders(ctx, filter)
+	if err != nil {
+		if errors.Is(err, gateways.ErrInvalidCursor) {
+			handleBadRequestResponse(c, "invalid query parameters", err)
+			return
+		}
+		o.logger.WithContext(ctx).WithError(err).Errorf("failed to get orders")
+		handleInternalServerResponse(c, "failed to get orders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 func (o OrderController) UpdateOrderStatusHandler(c *gin.Context) {
@@ -34,20 +63,35 @@ func (o OrderController) UpdateOrderStatusHandler(c *gin.Context) {
 
 	orderId, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "[id] path parameter is invalid"})
+		handleBadRequestResponse(c, "[id] path parameter is invalid", err)
 		return
 	}
+	ctx := logger.ContextWithOrderID(c.Request.Context(), id)
 
 	var orderStatusRequest dto.OrderStatusRequest
-	err = c.BindJSON(&orderStatusRequest)
+	err = bindStrictJSON(c, &orderStatusRequest)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid requests"})
+		fields := getFieldErrors(&orderStatusRequest, err)
+		if fields != nil {
+			handleValidationErrorResponse(c, "invalid order status payload", err, fields)
+			return
+		}
+		handleBadRequestResponse(c, "failed to bind order status payload", err)
 		return
 	}
 
-	err = o.orderUseCase.UpdateOrderStatus(orderId, orderStatusRequest.Status)
+	err = o.orderUseCase.UpdateOrderStatusWithReason(ctx, orderId, orderStatusRequest.Status, orderStatusRequest.Reason, orderStatusRequest.Actor)
+	if errors.Is(err, gateways.ErrOrderNotFound) {
+		handleNotFoundResponse(c, "order not found", err)
+		return
+	}
+	if errors.Is(err, gateways.ErrOrderConflict) {
+		handleConflictResponse(c, "order was modified concurrently, try again", err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		o.logger.WithContext(ctx).WithError(err).Errorf("failed to update order status to [%s]", orderStatusRequest.Status)
+		handleBadRequestResponse(c, "failed to update order status", err)
 		return
 	}
 
@@ -1,135 +1,251 @@
 package controllers_test
 
-// import (
-// 	"bytes"
-// 	"errors"
-// 	"net/http"
-// 	"net/http/httptest"
-// 	"testing"
-
-// 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
-// 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
-// 	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
-// 	"github.com/gin-gonic/gin"
-// 	"github.com/onsi/ginkgo"
-// 	"github.com/onsi/gomega"
-// 	"go.uber.org/mock/gomock"
-// )
-
-// func TestOrderController(t *testing.T) {
-// 	gomega.RegisterFailHandler(ginkgo.Fail)
-// 	ginkgo.RunSpecs(t, "OrderController Suite")
-// }
-
-// var _ = ginkgo.Describe("OrderController", func() {
-// 	var (
-// 		mockCtrl         *gomock.Controller
-// 		mockOrderUseCase *mock_usecases.MockOrderUseCase
-// 		orderController  controllers.OrderController
-// 		router           *gin.Engine
-// 	)
-
-// 	ginkgo.BeforeEach(func() {
-// 		mockCtrl = gomock.NewController(ginkgo.GinkgoT())
-// 		mockOrderUseCase = mock_usecases.NewMockOrderUseCase(mockCtrl)
-// 		orderController = controllers.NewOrderController(mockOrderUseCase)
-
-// 		router = gin.Default()
-// 		router.GET("/orders", orderController.GetOrdersHandler)
-// 		router.PUT("/orders/:id/status", orderController.UpdateOrderStatusHandler)
-// 	})
-
-// 	ginkgo.AfterEach(func() {
-// 		mockCtrl.Finish()
-// 	})
-
-// 	ginkgo.Describe("GET /orders", func() {
-// 		ginkgo.Context("when there are orders", func() {
-// 			ginkgo.It("should return a list of orders", func() {
-// 				expectedOrders := createOrder()
-// 				mockOrderUseCase.EXPECT().GetOrders().Return(expectedOrders, nil)
-
-// 				req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
-// 				w := httptest.NewRecorder()
-// 				router.ServeHTTP(w, req)
-
-// 				gomega.Expect(w.Code).To(gomega.Equal(http.StatusOK))
-// 			})
-// 		})
-
-// 		ginkgo.Context("when there is an error fetching orders", func() {
-// 			ginkgo.It("should return an internal server error", func() {
-// 				mockOrderUseCase.EXPECT().GetOrders().Return(models.ProductionOrderPage{}, errors.New("some error"))
-
-// 				req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
-// 				w := httptest.NewRecorder()
-// 				router.ServeHTTP(w, req)
-
-// 				gomega.Expect(w.Code).To(gomega.Equal(http.StatusInternalServerError))
-// 			})
-// 		})
-// 	})
-
-// 	ginkgo.Describe("PUT /orders/:id/status", func() {
-// 		ginkgo.Context("when the request is valid", func() {
-// 			ginkgo.It("should update the order status", func() {
-// 				orderID := "1"
-// 				mockOrderUseCase.EXPECT().UpdateOrderStatus(orderID, "PAID").Return(nil)
-
-// 				body := bytes.NewBufferString(`{"status": "PAID"}`)
-// 				req, _ := http.NewRequest(http.MethodPut, "/orders/1/status", body)
-// 				req.Header.Set("Content-Type", "application/json")
-// 				w := httptest.NewRecorder()
-// 				router.ServeHTTP(w, req)
-
-// 				gomega.Expect(w.Code).To(gomega.Equal(http.StatusNoContent))
-// 			})
-// 		})
-
-// 		ginkgo.Context("when the request body is invalid", func() {
-// 			ginkgo.It("should return a bad request error", func() {
-// 				body := bytes.NewBufferString(`{"status":`)
-// 				req, _ := http.NewRequest(http.MethodPut, "/orders/1/status", body)
-// 				req.Header.Set("Content-Type", "application/json")
-// 				w := httptest.NewRecorder()
-// 				router.ServeHTTP(w, req)
-
-// 				gomega.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
-// 			})
-// 		})
-
-// 		ginkgo.Context("when there is an error updating the order status", func() {
-// 			ginkgo.It("should return a bad request error", func() {
-// 				orderID := "1"
-// 				mockOrderUseCase.EXPECT().UpdateOrderStatus(orderID, "PAID").Return(errors.New("some error"))
-
-// 				body := bytes.NewBufferString(`{"status": "PAID"}`)
-// 				req, _ := http.NewRequest(http.MethodPut, "/orders/1/status", body)
-// 				req.Header.Set("Content-Type", "application/json")
-// 				w := httptest.NewRecorder()
-// 				router.ServeHTTP(w, req)
-
-// 				gomega.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
-// 			})
-// 		})
-// 	})
-// })
-
-// func createOrder() models.ProductionOrderPage {
-// 	return models.ProductionOrderPage{
-// 		Results: []models.ProductionOrder{
-// 			{
-// 				ID:     123,
-// 				Status: "PAID",
-// 				Products: []models.Product{
-// 					{
-// 						Name:        "Batata Frita",
-// 						Description: "Batata canoa",
-// 						Category:    "Acompanhamento",
-// 					},
-// 				},
-// 			},
-// 		},
-// 		Next: new(int),
-// 	}
-// }
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func setupRouter(t *testing.T) (*gin.Engine, *mock_usecases.MockOrderUseCase) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	mockOrderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	orderController := controllers.NewOrderController(mockOrderUseCase, logger.NewNopLogger())
+
+	router := gin.New()
+	router.GET("/orders", orderController.GetOrdersHandler)
+	router.PUT("/orders/:id/status", orderController.UpdateOrderStatusHandler)
+
+	return router, mockOrderUseCase
+}
+
+func TestGetOrdersHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		mockSetup    func(m *mock_usecases.MockOrderUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "every order without pagination",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				orders := []models.Order{{ID: "1", Status: models.OrderStatusCreated}}
+				m.EXPECT().GetAllOrders(gomock.Any(), models.OrderFilter{Limit: 100}).Return(orders, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"id":"1"`,
+		},
+		{
+			name:  "filtered without pagination",
+			query: "?status=READY",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				filter := models.OrderFilter{Statuses: []string{models.OrderStatusReady}, Limit: 100}
+				m.EXPECT().GetAllOrders(gomock.Any(), filter).Return([]models.Order{}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[]`,
+		},
+		{
+			name:  "filters and pagination",
+			query: "?status=CREATED,READY&status=IN_PROGRESS&limit=10&cursor=abc",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				filter := models.OrderFilter{
+					Statuses: []string{models.OrderStatusCreated, models.OrderStatusReady, models.OrderStatusInProgress},
+					Limit:    10,
+					Cursor:   "abc",
+				}
+				page := models.OrderPage{Results: []models.Order{{ID: "1", Status: models.OrderStatusCreated}}, Next: "def"}
+				m.EXPECT().GetOrders(gomock.Any(), filter).Return(page, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"next":"def"`,
+		},
+		{
+			name:  "cursor with the default limit",
+			query: "?cursor=abc",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().GetOrders(gomock.Any(), models.OrderFilter{Limit: 50, Cursor: "abc"}).Return(models.OrderPage{}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"results":null}`,
+		},
+		{
+			name:         "invalid limit",
+			query:        "?limit=1000",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown status",
+			query:        "?status=READY,PAID",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `[status] must be one of`,
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=abc",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return(models.OrderPage{}, gateways.ErrInvalidCursor)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "use case error",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().GetAllOrders(gomock.Any(), gomock.Any()).Return(nil, errors.New("some error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:  "paginated use case error",
+			query: "?limit=10",
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return(models.OrderPage{}, errors.New("some error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderUseCase := setupRouter(t)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockOrderUseCase)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "/orders"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestUpdateOrderStatusHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		mockSetup      func(m *mock_usecases.MockOrderUseCase)
+		expectedCode   int
+		expectedFields []controllers.FieldError
+	}{
+		{
+			name: "success",
+			path: "/orders/1/status",
+			body: `{"status": "READY"}`,
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().UpdateOrderStatusWithReason(gomock.Any(), 1, models.OrderStatusReady, "", "").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name: "reason and actor reach the use case",
+			path: "/orders/1/status",
+			body: `{"status": "READY", "reason": "kitchen finished", "actor": "chef"}`,
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().UpdateOrderStatusWithReason(gomock.Any(), 1, models.OrderStatusReady, "kitchen finished", "chef").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "invalid id",
+			path:         "/orders/abc/status",
+			body:         `{"status": "READY"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "malformed body",
+			path:         "/orders/1/status",
+			body:         `{"status":`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown field",
+			path:         "/orders/1/status",
+			body:         `{"status": "READY", "priority": 1}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "empty status",
+			path:         "/orders/1/status",
+			body:         `{"status": ""}`,
+			expectedCode: http.StatusBadRequest,
+			expectedFields: []controllers.FieldError{
+				{Field: "status", Rule: "required", Message: "[status] is required"},
+			},
+		},
+		{
+			name:         "unknown status",
+			path:         "/orders/1/status",
+			body:         `{"status": "BURNT"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedFields: []controllers.FieldError{
+				{Field: "status", Rule: "oneof", Message: "[status] must be one of [CREATED RECEIVED IN_PROGRESS READY DELIVERED CANCELLED]"},
+			},
+		},
+		{
+			name: "order not found",
+			path: "/orders/1/status",
+			body: `{"status": "READY"}`,
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().UpdateOrderStatusWithReason(gomock.Any(), 1, models.OrderStatusReady, "", "").Return(gateways.ErrOrderNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "concurrent update",
+			path: "/orders/1/status",
+			body: `{"status": "READY"}`,
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().UpdateOrderStatusWithReason(gomock.Any(), 1, models.OrderStatusReady, "", "").Return(gateways.ErrOrderConflict)
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name: "use case error",
+			path: "/orders/1/status",
+			body: `{"status": "READY"}`,
+			mockSetup: func(m *mock_usecases.MockOrderUseCase) {
+				m.EXPECT().UpdateOrderStatusWithReason(gomock.Any(), 1, models.OrderStatusReady, "", "").Return(errors.New("some error"))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderUseCase := setupRouter(t)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockOrderUseCase)
+			}
+
+			req, _ := http.NewRequest(http.MethodPut, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedFields != nil {
+				var response controllers.ErrorResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedFields, response.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const csvContentType = "text/csv"
+
+var reportCSVHeader = []string{"date", "hour", "group", "name", "orders", "delivered", "cancelled", "items", "bottleneck", "stage", "count", "avg_seconds", "p90_seconds"}
+
+type ReportController struct {
+	reportUseCase usecases.ReportUseCase
+	logger        logger.Logger
+}
+
+func NewReportController(reportUseCase usecases.ReportUseCase, logger logger.Logger) ReportController {
+	return ReportController{
+		reportUseCase: reportUseCase,
+		logger:        logger,
+	}
+}
+
+// GetDailyReportHandler reports the day given as YYYY-MM-DD, today when it
+// is missing, in JSON or, with format=csv or an Accept of text/csv, in CSV.
+func (r ReportController) GetDailyReportHandler(c *gin.Context) {
+	var date time.Time
+	var err error
+	if dateQueryParam := c.Query("date"); dateQueryParam != "" {
+		date, err = time.Parse("2006-01-02", dateQueryParam)
+		if err != nil {
+			handleBadRequestResponse(c, "invalid query parameters", fmt.Errorf("[date] must be formatted as YYYY-MM-DD"))
+			return
+		}
+	}
+
+	format := c.Query("format")
+	if format == "" && strings.Contains(c.GetHeader("Accept"), csvContentType) {
+		format = "csv"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		handleBadRequestResponse(c, "invalid query parameters", fmt.Errorf("[format] must be json or csv"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	report, err := r.reportUseCase.GetDailyReport(ctx, date)
+	if err != nil {
+		if errors.Is(err, usecases.ErrInvalidReportDate) {
+			handleBadRequestResponse(c, "invalid query parameters", err)
+			return
+		}
+		r.logger.WithContext(ctx).WithError(err).Errorf("failed to get daily report")
+		handleInternalServerResponse(c, "failed to get daily report", err)
+		return
+	}
+
+	if format != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	content, err := encodeReportCSV(report)
+	if err != nil {
+		handleInternalServerResponse(c, "failed to encode daily report", err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%s.csv"`, report.Date))
+	c.Data(http.StatusOK, csvContentType+"; charset=utf-8", content)
+}
+
+// encodeReportCSV flattens the report into one row per stage of each group,
+// the day summary having an empty hour. Groups without any stage, such as
+// the ones with only cancelled orders, have a row with an empty stage.
+func encodeReportCSV(report dto.DailyReport) ([]byte, error) {
+	var content bytes.Buffer
+	writer := csv.NewWriter(&content)
+	rows := [][]string{reportCSVHeader}
+
+	appendBreakdown := func(hour string, breakdown dto.ReportBreakdown) {
+		rows = appendStatsRows(rows, report.Date, hour, "all", "", breakdown.ReportStats)
+		for _, name := range sortedKeys(breakdown.Products) {
+			rows = appendStatsRows(rows, report.Date, hour, "product", name, breakdown.Products[name])
+		}
+		for _, name := range sortedKeys(breakdown.ItemTypes) {
+			rows = appendStatsRows(rows, report.Date, hour, "itemType", name, breakdown.ItemTypes[name])
+		}
+	}
+	appendBreakdown("", report.Summary)
+	for _, hour := range report.Hours {
+		appendBreakdown(strconv.Itoa(hour.Hour), hour.ReportBreakdown)
+	}
+
+	err := writer.WriteAll(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+	return content.Bytes(), nil
+}
+
+func appendStatsRows(rows [][]string, date, hour, group, name string, stats dto.ReportStats) [][]string {
+	row := []string{
+		date, hour, group, name,
+		strconv.Itoa(stats.Orders), strconv.Itoa(stats.Delivered), strconv.Itoa(stats.Cancelled), strconv.Itoa(stats.Items),
+		stats.Bottleneck,
+	}
+	if len(stats.Stages) == 0 {
+		return append(rows, append(row, "", "", "", ""))
+	}
+
+	for _, stage := range sortedKeys(stats.Stages) {
+		stageStats := stats.Stages[stage]
+		rows = append(rows, append(append([]string{}, row...),
+			stage,
+			strconv.Itoa(stageStats.Count),
+			strconv.FormatFloat(stageStats.AvgSeconds, 'f', 1, 64),
+			strconv.FormatFloat(stageStats.P90Seconds, 'f', 1, 64),
+		))
+	}
+	return rows
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
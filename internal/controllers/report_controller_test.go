@@ -0,0 +1,144 @@
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReportController_GetDailyReportHandler(t *testing.T) {
+	date := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	delivered := dto.ReportStats{
+		Orders:     1,
+		Delivered:  1,
+		Items:      2,
+		Stages:     map[string]dto.StageStats{"READY": {Count: 1, AvgSeconds: 600, P90Seconds: 600}, "TOTAL": {Count: 1, AvgSeconds: 1800, P90Seconds: 1800}},
+		Bottleneck: "READY",
+	}
+	cancelled := dto.ReportStats{Orders: 1, Cancelled: 1, Items: 1, Stages: map[string]dto.StageStats{}}
+	report := dto.DailyReport{
+		Date:     "2024-03-10",
+		Timezone: "UTC",
+		Summary:  dto.ReportBreakdown{ReportStats: delivered, Products: map[string]dto.ReportStats{"X-Burger": delivered}, ItemTypes: map[string]dto.ReportStats{}},
+		Hours: []dto.HourlyReport{
+			{Hour: 14, ReportBreakdown: dto.ReportBreakdown{ReportStats: cancelled, Products: map[string]dto.ReportStats{}, ItemTypes: map[string]dto.ReportStats{}}},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		path                string
+		accept              string
+		mockSetup           func(m *mock_usecases.MockReportUseCase)
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name: "json",
+			path: "/reports/daily?date=2024-03-10",
+			mockSetup: func(m *mock_usecases.MockReportUseCase) {
+				m.EXPECT().GetDailyReport(gomock.Any(), date).Return(report, nil)
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `"bottleneck":"READY","products":{"X-Burger":`,
+		},
+		{
+			name: "csv",
+			path: "/reports/daily?date=2024-03-10&format=csv",
+			mockSetup: func(m *mock_usecases.MockReportUseCase) {
+				m.EXPECT().GetDailyReport(gomock.Any(), date).Return(report, nil)
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "date,hour,group,name,orders,delivered,cancelled,items,bottleneck,stage,count,avg_seconds,p90_seconds\n" +
+				"2024-03-10,,all,,1,1,0,2,READY,READY,1,600.0,600.0\n" +
+				"2024-03-10,,all,,1,1,0,2,READY,TOTAL,1,1800.0,1800.0\n" +
+				"2024-03-10,,product,X-Burger,1,1,0,2,READY,READY,1,600.0,600.0\n" +
+				"2024-03-10,,product,X-Burger,1,1,0,2,READY,TOTAL,1,1800.0,1800.0\n" +
+				"2024-03-10,14,all,,1,0,1,1,,,,,\n",
+		},
+		{
+			name:   "csv from the accept header, today by default",
+			path:   "/reports/daily",
+			accept: "text/csv",
+			mockSetup: func(m *mock_usecases.MockReportUseCase) {
+				m.EXPECT().GetDailyReport(gomock.Any(), time.Time{}).Return(report, nil)
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "2024-03-10,14,all,,1,0,1,1,,,,,\n",
+		},
+		{
+			name:         "invalid date",
+			path:         "/reports/daily?date=10/03/2024",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"error":"[date] must be formatted as YYYY-MM-DD"`,
+		},
+		{
+			name:         "invalid format",
+			path:         "/reports/daily?format=xml",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"error":"[format] must be json or csv"`,
+		},
+		{
+			name: "future date",
+			path: "/reports/daily?date=2999-01-01",
+			mockSetup: func(m *mock_usecases.MockReportUseCase) {
+				m.EXPECT().GetDailyReport(gomock.Any(), time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)).Return(dto.DailyReport{}, fmt.Errorf("%w [2999-01-01]: the day has not started", usecases.ErrInvalidReportDate))
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"error":"invalid report date [2999-01-01]: the day has not started"`,
+		},
+		{
+			name: "internal error",
+			path: "/reports/daily?date=2024-03-10",
+			mockSetup: func(m *mock_usecases.MockReportUseCase) {
+				m.EXPECT().GetDailyReport(gomock.Any(), date).Return(dto.DailyReport{}, errors.New("internal error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `"message":"failed to get daily report"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			mockReportUseCase := mock_usecases.NewMockReportUseCase(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockReportUseCase)
+			}
+
+			reportController := controllers.NewReportController(mockReportUseCase, logger.NewNopLogger())
+			router := gin.New()
+			router.GET("/reports/daily", reportController.GetDailyReportHandler)
+
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.expectedContentType != "" {
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
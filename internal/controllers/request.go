@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// bindStrictJSON decodes the request body into obj rejecting unknown fields,
+// then runs the binding validation declared on its struct tags.
+func bindStrictJSON(c *gin.Context, obj any) error {
+	if c.Request.Body == nil {
+		return errors.New("request body is empty")
+	}
+
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// getFieldErrors maps validation errors to the JSON field names of obj,
+// returning nil when err is not a validation error.
+func getFieldErrors(obj any, err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	objType := reflect.TypeOf(obj)
+	if objType.Kind() == reflect.Pointer {
+		objType = objType.Elem()
+	}
+
+	fields := make([]FieldError, len(validationErrors))
+	for i, fieldErr := range validationErrors {
+		name := fieldErr.Field()
+		if field, ok := objType.FieldByName(fieldErr.StructField()); ok {
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+		}
+
+		fields[i] = FieldError{
+			Field:   name,
+			Rule:    fieldErr.Tag(),
+			Message: getFieldErrorMessage(name, fieldErr),
+		}
+	}
+
+	return fields
+}
+
+func getFieldErrorMessage(name string, fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("[%s] is required", name)
+	case "oneof":
+		return fmt.Sprintf("[%s] must be one of [%s]", name, fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("[%s] must have at most %s characters", name, fieldErr.Param())
+	default:
+		return fmt.Sprintf("[%s] failed on the [%s] rule", name, fieldErr.Tag())
+	}
+}
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// getOrderFilter reads the `status` (repeated or comma separated), `limit`
+// and `cursor` query parameters. The listing is paginated only when limit
+// or cursor is given; otherwise the filter reads every order, by pages of
+// maxPageLimit.
+func getOrderFilter(c *gin.Context) (filter models.OrderFilter, paginated bool, err error) {
+	filter = models.OrderFilter{
+		Limit:  maxPageLimit,
+		Cursor: c.Query("cursor"),
+	}
+
+	for _, param := range c.QueryArray("status") {
+		for _, status := range strings.Split(param, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if !models.IsOrderStatus(status) {
+				return models.OrderFilter{}, false, fmt.Errorf("[status] must be one of [%s]", strings.Join(models.OrderStatuses, " "))
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	limitQueryParam := c.Query("limit")
+	if limitQueryParam == "" && filter.Cursor == "" {
+		return filter, false, nil
+	}
+
+	filter.Limit = defaultPageLimit
+	if limitQueryParam != "" {
+		limit, err := strconv.Atoi(limitQueryParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return models.OrderFilter{}, false, fmt.Errorf("[limit] must be an integer between 1 and %d", maxPageLimit)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, true, nil
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ErrorResponse struct {
+	Message string       `json:"message"`
+	Err     string       `json:"error"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func handleBadRequestResponse(c *gin.Context, message string, err error) {
+	badRequestError := ErrorResponse{
+		Message: message,
+		Err:     err.Error(),
+	}
+	c.JSON(http.StatusBadRequest, badRequestError)
+}
+
+func handleNotFoundResponse(c *gin.Context, message string, err error) {
+	notFoundError := ErrorResponse{
+		Message: message,
+		Err:     err.Error(),
+	}
+	c.JSON(http.StatusNotFound, notFoundError)
+}
+
+func handleConflictResponse(c *gin.Context, message string, err error) {
+	conflictError := ErrorResponse{
+		Message: message,
+		Err:     err.Error(),
+	}
+	c.JSON(http.StatusConflict, conflictError)
+}
+
+func handleValidationErrorResponse(c *gin.Context, message string, err error, fields []FieldError) {
+	validationError := ErrorResponse{
+		Message: message,
+		Err:     err.Error(),
+		Fields:  fields,
+	}
+	c.JSON(http.StatusBadRequest, validationError)
+}
+
+func handleInternalServerResponse(c *gin.Context, message string, err error) {
+	internalServerError := ErrorResponse{
+		Message: message,
+		Err:     err.Error(),
+	}
+	c.JSON(http.StatusInternalServerError, internalServerError)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	webhookUseCase usecases.WebhookUseCase
+	logger         logger.Logger
+}
+
+func NewWebhookController(webhookUseCase usecases.WebhookUseCase, logger logger.Logger) WebhookController {
+	return WebhookController{
+		webhookUseCase: webhookUseCase,
+		logger:         logger,
+	}
+}
+
+func (w WebhookController) CreateSubscriptionHandler(c *gin.Context) {
+	var subscriptionRequest dto.WebhookSubscriptionRequest
+	err := bindStrictJSON(c, &subscriptionRequest)
+	if err != nil {
+		fields := getFieldErrors(&subscriptionRequest, err)
+		if fields != nil {
+			handleValidationErrorResponse(c, "invalid webhook subscription payload", err, fields)
+			return
+		}
+		handleBadRequestResponse(c, "failed to bind webhook subscription payload", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	subscription, err := w.webhookUseCase.CreateSubscription(ctx, subscriptionRequest)
+	if err != nil {
+		w.logger.WithContext(ctx).WithError(err).Errorf("failed to create webhook subscription")
+		handleInternalServerResponse(c, "failed to create webhook subscription", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (w WebhookController) GetSubscriptionsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	subscriptions, err := w.webhookUseCase.GetSubscriptions(ctx)
+	if err != nil {
+		w.logger.WithContext(ctx).WithError(err).Errorf("failed to get webhook subscriptions")
+		handleInternalServerResponse(c, "failed to get webhook subscriptions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookSubscriptionList{Results: subscriptions})
+}
+
+func (w WebhookController) DeleteSubscriptionHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := w.webhookUseCase.DeleteSubscription(ctx, c.Param("id"))
+	if errors.Is(err, gateways.ErrWebhookNotFound) {
+		handleNotFoundResponse(c, "webhook subscription not found", err)
+		return
+	}
+	if err != nil {
+		w.logger.WithContext(ctx).WithError(err).Errorf("failed to delete webhook subscription")
+		handleInternalServerResponse(c, "failed to delete webhook subscription", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (w WebhookController) GetDeliveriesHandler(c *gin.Context) {
+	filter, err := getWebhookDeliveryFilter(c)
+	if err != nil {
+		handleBadRequestResponse(c, "invalid query parameters", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	deliveries, err := w.webhookUseCase.GetDeliveries(ctx, filter)
+	if errors.Is(err, gateways.ErrWebhookNotFound) {
+		handleNotFoundResponse(c, "webhook subscription not found", err)
+		return
+	}
+	if err != nil {
+		w.logger.WithContext(ctx).WithError(err).Errorf("failed to get webhook deliveries")
+		handleInternalServerResponse(c, "failed to get webhook deliveries", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookDeliveryList{Results: deliveries})
+}
+
+// getWebhookDeliveryFilter reads the `status`, `orderId` and `limit` query
+// parameters of the delivery log of the `id` subscription.
+func getWebhookDeliveryFilter(c *gin.Context) (models.WebhookDeliveryFilter, error) {
+	filter := models.WebhookDeliveryFilter{
+		SubscriptionID: c.Param("id"),
+		Status:         c.Query("status"),
+		Limit:          defaultPageLimit,
+	}
+
+	switch filter.Status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		return models.WebhookDeliveryFilter{}, fmt.Errorf("[status] must be one of [%s %s %s]", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed)
+	}
+
+	orderIDQueryParam := c.Query("orderId")
+	if orderIDQueryParam != "" {
+		orderID, err := strconv.Atoi(orderIDQueryParam)
+		if err != nil || orderID < 1 {
+			return models.WebhookDeliveryFilter{}, fmt.Errorf("[orderId] must be a positive integer")
+		}
+		filter.OrderID = orderID
+	}
+
+	limitQueryParam := c.Query("limit")
+	if limitQueryParam != "" {
+		limit, err := strconv.Atoi(limitQueryParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return models.WebhookDeliveryFilter{}, fmt.Errorf("[limit] must be an integer between 1 and %d", maxPageLimit)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,172 @@
+package controllers_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/controllers"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestWebhookController(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		mockSetup    func(m *mock_usecases.MockWebhookUseCase)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "create subscription",
+			method: http.MethodPost,
+			path:   "/webhooks",
+			body:   `{"url": "https://partner.example.com/hooks", "events": ["READY"], "secret": "0123456789abcdef"}`,
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				request := dto.WebhookSubscriptionRequest{URL: "https://partner.example.com/hooks", Events: []string{"READY"}, Secret: "0123456789abcdef"}
+				m.EXPECT().CreateSubscription(gomock.Any(), request).Return(models.WebhookSubscription{ID: "wh-1", Secret: "0123456789abcdef"}, nil)
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `"id":"wh-1"`,
+		},
+		{
+			name:         "create subscription with unknown event",
+			method:       http.MethodPost,
+			path:         "/webhooks",
+			body:         `{"url": "https://partner.example.com/hooks", "events": ["PAID"], "secret": "0123456789abcdef"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"rule":"oneof"`,
+		},
+		{
+			name:         "create subscription with short secret",
+			method:       http.MethodPost,
+			path:         "/webhooks",
+			body:         `{"url": "https://partner.example.com/hooks", "events": ["READY"], "secret": "short"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"field":"secret"`,
+		},
+		{
+			name:         "create subscription with plain http url",
+			method:       http.MethodPost,
+			path:         "/webhooks",
+			body:         `{"url": "http://partner.example.com/hooks", "events": ["READY"], "secret": "0123456789abcdef"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"field":"url"`,
+		},
+		{
+			name:         "create subscription with invalid url",
+			method:       http.MethodPost,
+			path:         "/webhooks",
+			body:         `{"url": "partner", "events": ["READY"], "secret": "0123456789abcdef"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"field":"url"`,
+		},
+		{
+			name:   "list subscriptions",
+			method: http.MethodGet,
+			path:   "/webhooks",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				m.EXPECT().GetSubscriptions(gomock.Any()).Return([]models.WebhookSubscription{{ID: "wh-1", Secret: "0123456789abcdef"}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"results":[{"id":"wh-1","url":"","events":null,"createdAt":"0001-01-01T00:00:00Z"}]}`,
+		},
+		{
+			name:   "delete subscription",
+			method: http.MethodDelete,
+			path:   "/webhooks/wh-1",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				m.EXPECT().DeleteSubscription(gomock.Any(), "wh-1").Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:   "delete unknown subscription",
+			method: http.MethodDelete,
+			path:   "/webhooks/wh-1",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				m.EXPECT().DeleteSubscription(gomock.Any(), "wh-1").Return(gateways.ErrWebhookNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "get deliveries with filters",
+			method: http.MethodGet,
+			path:   "/webhooks/wh-1/deliveries?status=FAILED&orderId=7&limit=10",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				filter := models.WebhookDeliveryFilter{SubscriptionID: "wh-1", Status: "FAILED", OrderID: 7, Limit: 10}
+				m.EXPECT().GetDeliveries(gomock.Any(), filter).Return([]models.WebhookDelivery{{ID: "d-1"}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"id":"d-1"`,
+		},
+		{
+			name:         "get deliveries with invalid status",
+			method:       http.MethodGet,
+			path:         "/webhooks/wh-1/deliveries?status=DONE",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "get deliveries with invalid order id",
+			method:       http.MethodGet,
+			path:         "/webhooks/wh-1/deliveries?orderId=abc",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:   "get deliveries of unknown subscription",
+			method: http.MethodGet,
+			path:   "/webhooks/wh-1/deliveries",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				m.EXPECT().GetDeliveries(gomock.Any(), gomock.Any()).Return(nil, gateways.ErrWebhookNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:   "get deliveries error",
+			method: http.MethodGet,
+			path:   "/webhooks/wh-1/deliveries",
+			mockSetup: func(m *mock_usecases.MockWebhookUseCase) {
+				m.EXPECT().GetDeliveries(gomock.Any(), gomock.Any()).Return(nil, errors.New("table not found"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			mockWebhookUseCase := mock_usecases.NewMockWebhookUseCase(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockWebhookUseCase)
+			}
+
+			webhookController := controllers.NewWebhookController(mockWebhookUseCase, logger.NewNopLogger())
+			router := gin.New()
+			router.POST("/webhooks", webhookController.CreateSubscriptionHandler)
+			router.GET("/webhooks", webhookController.GetSubscriptionsHandler)
+			router.DELETE("/webhooks/:id", webhookController.DeleteSubscriptionHandler)
+			router.GET("/webhooks/:id/deliveries", webhookController.GetDeliveriesHandler)
+
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			assert.NotContains(t, w.Body.String(), "0123456789abcdef", "the secret is never returned")
+		})
+	}
+}
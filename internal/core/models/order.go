@@ -2,6 +2,15 @@ package models
 
 import "time"
 
+const (
+	OrderStatusCreated    = "CREATED"
+	OrderStatusReceived   = "RECEIVED"
+	OrderStatusInProgress = "IN_PROGRESS"
+	OrderStatusReady      = "READY"
+	OrderStatusDelivered  = "DELIVERED"
+	OrderStatusCancelled  = "CANCELLED"
+)
+
 type Order struct {
 	ID          string      `json:"id" dynamodbav:"PK"`
 	Status      string      `json:"status" dynamodbav:"Status"`
@@ -10,6 +19,23 @@ type Order struct {
 	FinishedAt  time.Time   `json:"updatedAt" dynamodbav:"FinishedAt"`
 	Items       []OrderItem `json:"items" dynamodbav:"Items"`
 	Entity      string      `json:"entity" dynamodbav:"GSI1PK"`
+	// StatusChangedAt is set by the repositories when the order is saved
+	// and on every status update.
+	StatusChangedAt time.Time `json:"statusChangedAt" dynamodbav:"StatusChangedAt"`
+	// History is only loaded when a single order is read, or listed with
+	// OrderFilter.WithHistory.
+	History []OrderStatusChange `json:"history,omitempty" dynamodbav:"StatusHistory,omitempty"`
+}
+
+// OrderStatusChange is one entry of the status history of an order. From
+// is empty on creation, and Reason and Actor are only set when the caller
+// gave them.
+type OrderStatusChange struct {
+	From      string    `json:"from,omitempty" dynamodbav:"From,omitempty"`
+	To        string    `json:"to" dynamodbav:"To"`
+	Reason    string    `json:"reason,omitempty" dynamodbav:"Reason,omitempty"`
+	Actor     string    `json:"actor,omitempty" dynamodbav:"Actor,omitempty"`
+	ChangedAt time.Time `json:"changedAt" dynamodbav:"ChangedAt"`
 }
 
 type OrderItem struct {
@@ -22,3 +48,73 @@ type Product struct {
 	Name        string `json:"name" dynamodbav:"Name"`
 	Description string `json:"description" dynamodbav:"Description"`
 }
+
+// OrderStatuses lists every status an order can have.
+var OrderStatuses = []string{
+	OrderStatusCreated,
+	OrderStatusReceived,
+	OrderStatusInProgress,
+	OrderStatusReady,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+}
+
+// IsOrderStatus reports whether status is one of OrderStatuses.
+func IsOrderStatus(status string) bool {
+	for _, s := range OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the order is still being handled by the kitchen.
+func (o Order) IsActive() bool {
+	return o.Status != OrderStatusDelivered && o.Status != OrderStatusCancelled
+}
+
+// OrderFilter selects the orders returned by a listing. An empty Statuses
+// matches every status, a zero ChangedFrom or ChangedTo leaves that side
+// of the window on StatusChangedAt open and a zero Limit returns every
+// matching order.
+type OrderFilter struct {
+	Statuses    []string
+	ChangedFrom time.Time
+	// ChangedTo is exclusive.
+	ChangedTo time.Time
+	Limit     int
+	Cursor    string
+	// WithHistory loads the status history of the listed orders, left out
+	// otherwise.
+	WithHistory bool
+}
+
+// MatchesStatus reports whether the filter accepts the given status.
+func (f OrderFilter) MatchesStatus(status string) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, s := range f.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesChangedAt reports whether the filter accepts an order whose status
+// last changed at changedAt.
+func (f OrderFilter) MatchesChangedAt(changedAt time.Time) bool {
+	if !f.ChangedFrom.IsZero() && changedAt.Before(f.ChangedFrom) {
+		return false
+	}
+	return f.ChangedTo.IsZero() || changedAt.Before(f.ChangedTo)
+}
+
+// OrderPage is one page of a listing; Next is the opaque cursor for the
+// following page and is empty on the last one.
+type OrderPage struct {
+	Results []Order `json:"results"`
+	Next    string  `json:"next,omitempty"`
+}
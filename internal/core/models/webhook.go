@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliverySucceeded = "SUCCEEDED"
+	WebhookDeliveryFailed    = "FAILED"
+)
+
+// WebhookSubscription asks for an HTTP callback to URL whenever an order
+// reaches one of the Events, which are order statuses. Secret signs the
+// payloads and is never returned by the API.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Accepts reports whether the subscription wants the given order status.
+func (s WebhookSubscription) Accepts(status string) bool {
+	for _, event := range s.Events {
+		if event == status {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the log entry of one callback, updated after every
+// attempt.
+type WebhookDelivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscriptionId"`
+	OrderID        int       `json:"orderId"`
+	Event          string    `json:"event"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastStatusCode int       `json:"lastStatusCode,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	// Owner is the dispatcher sending the delivery; no other one claims it
+	// before LeaseUntil.
+	Owner      string    `json:"-" dynamodbav:",omitempty"`
+	LeaseUntil time.Time `json:"-" dynamodbav:"-"`
+}
+
+// WebhookDeliveryFilter selects the deliveries of a subscription, newest
+// first. Zero values match everything and a zero Limit returns every entry.
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         string
+	OrderID        int
+	Limit          int
+}
+
+// Matches reports whether the filter accepts the delivery.
+func (f WebhookDeliveryFilter) Matches(delivery WebhookDelivery) bool {
+	return (f.SubscriptionID == "" || f.SubscriptionID == delivery.SubscriptionID) &&
+		(f.Status == "" || f.Status == delivery.Status) &&
+		(f.OrderID == 0 || f.OrderID == delivery.OrderID)
+}
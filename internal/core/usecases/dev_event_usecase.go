@@ -0,0 +1,67 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+)
+
+// DevEventUseCase injects order events when the service runs on the
+// in-memory broker, taking the place of the order service.
+type DevEventUseCase interface {
+	PublishOrderEvent(ctx context.Context, request dto.OrderEventRequest) error
+}
+
+type devEventUseCase struct {
+	publisher   broker.Publisher
+	destination string
+}
+
+func NewDevEventUseCase(publisher broker.Publisher, destination string) DevEventUseCase {
+	return &devEventUseCase{
+		publisher:   publisher,
+		destination: destination,
+	}
+}
+
+func (u *devEventUseCase) PublishOrderEvent(ctx context.Context, request dto.OrderEventRequest) error {
+	event := events.OrderProductionDTO{
+		ID:     request.ID,
+		Status: request.Status,
+		Items:  make([]events.OrderItemProductionDTO, len(request.Items)),
+	}
+	for i, item := range request.Items {
+		event.Items[i] = events.OrderItemProductionDTO{
+			Quantity: item.Quantity,
+			Type:     item.Type,
+			Products: events.OrderProductionProductDTO{
+				Name:        item.Product.Name,
+				Description: item.Product.Description,
+				Category:    item.Product.Category,
+			},
+		}
+	}
+
+	envelope, err := broker.NewCloudEvent(gateways.EventSource, OrderProductionEventType, OrderProductionEventVersion, gateways.EventsSchemaURL+"#OrderProductionDTO", strconv.Itoa(request.ID), event)
+	if err != nil {
+		return fmt.Errorf("failed to create order event: %w", err)
+	}
+
+	message, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order event: %w", err)
+	}
+
+	err = u.publisher.Publish(ctx, u.destination, strconv.Itoa(request.ID), message)
+	if err != nil {
+		return fmt.Errorf("failed to publish order event: %w", err)
+	}
+
+	return nil
+}
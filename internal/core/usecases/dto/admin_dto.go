@@ -0,0 +1,5 @@
+package dto
+
+type ConsumerModeRequest struct {
+	Mode string `json:"mode" binding:"required,oneof=AUTO PAUSED RUNNING"`
+}
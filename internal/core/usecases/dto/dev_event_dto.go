@@ -0,0 +1,21 @@
+package dto
+
+// OrderEventRequest is the order paid event, as published by the order
+// service to the in-progress queue.
+type OrderEventRequest struct {
+	ID     int                     `json:"id" binding:"required,gt=0"`
+	Status string                  `json:"status"`
+	Items  []OrderEventItemRequest `json:"items" binding:"required,min=1"`
+}
+
+type OrderEventItemRequest struct {
+	Quantity int                      `json:"quantity"`
+	Type     string                   `json:"type"`
+	Product  OrderEventProductRequest `json:"product"`
+}
+
+type OrderEventProductRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
@@ -1,5 +1,7 @@
 package dto
 
 type OrderStatusRequest struct {
-	Status string `json:"status"`
+	Status string `json:"status" binding:"required,oneof=CREATED RECEIVED IN_PROGRESS READY DELIVERED CANCELLED"`
+	Reason string `json:"reason" binding:"omitempty,max=255"`
+	Actor  string `json:"actor" binding:"omitempty,max=100"`
 }
@@ -0,0 +1,40 @@
+package dto
+
+import "time"
+
+// DeadLetterReplayRequest selects the dead letters moved back to the work
+// queue. Empty fields match every message; a time window only matches
+// enveloped events, as the legacy payloads carry no time.
+type DeadLetterReplayRequest struct {
+	EventType string    `json:"eventType" binding:"omitempty,max=255"`
+	OrderID   string    `json:"orderId" binding:"omitempty,max=64"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Limit     int       `json:"limit" binding:"gte=0,lte=10000"`
+	DryRun    bool      `json:"dryRun"`
+}
+
+// StatusEventReplayRequest selects the orders whose status changed in
+// [From, To) to have their current status published again.
+type StatusEventReplayRequest struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Statuses []string  `json:"statuses" binding:"omitempty,dive,oneof=CREATED RECEIVED IN_PROGRESS READY DELIVERED CANCELLED"`
+	DryRun   bool      `json:"dryRun"`
+}
+
+type ReplayedMessage struct {
+	OrderID   string    `json:"orderId,omitempty"`
+	EventID   string    `json:"eventId,omitempty"`
+	EventType string    `json:"eventType,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// ReplayResult lists the messages replayed, or the ones that would be with
+// DryRun, out of the Scanned messages or orders.
+type ReplayResult struct {
+	DryRun   bool              `json:"dryRun"`
+	Scanned  int               `json:"scanned"`
+	Messages []ReplayedMessage `json:"messages"`
+}
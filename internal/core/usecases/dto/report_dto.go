@@ -0,0 +1,45 @@
+package dto
+
+// DailyReport aggregates the orders completed on Date, delivered or
+// cancelled, by the hour of Timezone they were completed in. Partial is set
+// while the day is not over.
+type DailyReport struct {
+	Date     string          `json:"date"`
+	Timezone string          `json:"timezone"`
+	Partial  bool            `json:"partial"`
+	Summary  ReportBreakdown `json:"summary"`
+	Hours    []HourlyReport  `json:"hours"`
+}
+
+// HourlyReport is one hour of a day with completed orders.
+type HourlyReport struct {
+	Hour int `json:"hour"`
+	ReportBreakdown
+}
+
+// ReportBreakdown splits the stats by product name and by item type, an
+// order counting once for each of them among its items.
+type ReportBreakdown struct {
+	ReportStats
+	Products  map[string]ReportStats `json:"products"`
+	ItemTypes map[string]ReportStats `json:"itemTypes"`
+}
+
+// ReportStats counts the completed orders and their items. Stages are the
+// times the delivered orders spent in each status, plus TOTAL from their
+// creation to the delivery; Bottleneck is the status with the highest
+// average time.
+type ReportStats struct {
+	Orders     int                   `json:"orders"`
+	Delivered  int                   `json:"delivered"`
+	Cancelled  int                   `json:"cancelled"`
+	Items      int                   `json:"items"`
+	Stages     map[string]StageStats `json:"stages"`
+	Bottleneck string                `json:"bottleneck,omitempty"`
+}
+
+type StageStats struct {
+	Count      int     `json:"count"`
+	AvgSeconds float64 `json:"avgSeconds"`
+	P90Seconds float64 `json:"p90Seconds"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+
+type WebhookSubscriptionRequest struct {
+	URL    string   `json:"url" binding:"required,url,startswith=https://,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=RECEIVED IN_PROGRESS READY DELIVERED CANCELLED"`
+	Secret string   `json:"secret" binding:"required,min=16,max=256"`
+}
+
+type WebhookSubscriptionList struct {
+	Results []models.WebhookSubscription `json:"results"`
+}
+
+type WebhookDeliveryList struct {
+	Results []models.WebhookDelivery `json:"results"`
+}
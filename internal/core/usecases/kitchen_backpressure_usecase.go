@@ -0,0 +1,364 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+)
+
+// KitchenTotal is reported in KitchenLoad.Overloaded when the kitchen has
+// reached KitchenCapacity.MaxActiveOrders.
+const KitchenTotal = "total"
+
+var ErrInvalidConsumerMode = errors.New("invalid consumer mode")
+
+const (
+	// kitchenLoadRecountInterval is how often the active orders are all
+	// listed again, in case a status change was missed by the checks in
+	// between, which only list the orders changed since the previous one.
+	kitchenLoadRecountInterval = time.Hour
+	// kitchenLoadSyncOverlap lists again the orders changed a little before
+	// the previous check, as the clocks of the instances saving them drift.
+	kitchenLoadSyncOverlap = 30 * time.Second
+)
+
+// activeOrderStatuses are the statuses of the orders reported by
+// models.Order.IsActive.
+var activeOrderStatuses = []string{
+	models.OrderStatusCreated,
+	models.OrderStatusReceived,
+	models.OrderStatusInProgress,
+	models.OrderStatusReady,
+}
+
+type ConsumerMode string
+
+const (
+	// ConsumerModeAuto pauses the queue while the kitchen is at capacity.
+	ConsumerModeAuto    ConsumerMode = "AUTO"
+	ConsumerModePaused  ConsumerMode = "PAUSED"
+	ConsumerModeRunning ConsumerMode = "RUNNING"
+)
+
+// KitchenCapacity caps the active orders of the kitchen, in total and per
+// station. The station of an item is its type, and an order counts once
+// for each station of its items. Zero or missing limits are unlimited.
+type KitchenCapacity struct {
+	MaxActiveOrders int
+	Stations        map[string]int
+}
+
+// IsLimited reports whether any limit is set, the load being only checked
+// when it is.
+func (c KitchenCapacity) IsLimited() bool {
+	if c.MaxActiveOrders > 0 {
+		return true
+	}
+	for _, limit := range c.Stations {
+		if limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type KitchenLoad struct {
+	ActiveOrders int            `json:"activeOrders"`
+	Stations     map[string]int `json:"stations"`
+	// Overloaded lists the stations at capacity, and KitchenTotal when the
+	// kitchen as a whole is.
+	Overloaded []string  `json:"overloaded"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+type ConsumerQueueStatus struct {
+	QueueStatus
+	Mode ConsumerMode `json:"mode"`
+}
+
+type BackpressureStatus struct {
+	Load   KitchenLoad           `json:"load"`
+	Queues []ConsumerQueueStatus `json:"queues"`
+}
+
+// KitchenBackpressureUseCase pauses the production queue while the kitchen
+// is at capacity, leaving the new orders in the broker, and resumes it once
+// orders are completed. Each queue can also be forced paused or running.
+type KitchenBackpressureUseCase interface {
+	// Run checks the kitchen load every interval until ctx is cancelled. It
+	// is only started when the capacity is limited.
+	Run(ctx context.Context)
+	Check(ctx context.Context) error
+	SetMode(queue string, mode ConsumerMode) error
+	Status() BackpressureStatus
+}
+
+type kitchenBackpressureUseCase struct {
+	orderUseCase    OrderUseCase
+	consumerUseCase OrderConsumerUseCase
+	queue           string
+	capacity        KitchenCapacity
+	interval        time.Duration
+	logger          logger.Logger
+
+	// applying serializes the checks, so a queue is never paused and resumed
+	// at once. It is held while pausing, which waits for the messages in
+	// process, so it is taken before mu and mu is released first.
+	applying sync.Mutex
+	mu       sync.Mutex
+	load     KitchenLoad
+	modes    map[string]ConsumerMode
+
+	// counting guards the active orders, by id with their stations, kept
+	// up to date from the orders changed since syncedAt.
+	counting    sync.Mutex
+	active      map[string][]string
+	syncedAt    time.Time
+	recountedAt time.Time
+}
+
+// queueAction is a pause or resume decided from the load and the modes.
+type queueAction struct {
+	queue  string
+	mode   ConsumerMode
+	run    bool
+	change bool
+}
+
+func NewKitchenBackpressureUseCase(orderUseCase OrderUseCase, consumerUseCase OrderConsumerUseCase, queue string, capacity KitchenCapacity, interval time.Duration, logger logger.Logger) KitchenBackpressureUseCase {
+	return &kitchenBackpressureUseCase{
+		orderUseCase:    orderUseCase,
+		consumerUseCase: consumerUseCase,
+		queue:           queue,
+		capacity:        capacity,
+		interval:        interval,
+		logger:          logger,
+		load:            KitchenLoad{Stations: map[string]int{}, Overloaded: []string{}},
+		modes:           map[string]ConsumerMode{},
+	}
+}
+
+func (u *kitchenBackpressureUseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		err := u.Check(ctx)
+		if err != nil && ctx.Err() == nil {
+			u.logger.WithError(err).Errorf("failed to check the kitchen capacity")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check updates the count of the active orders and pauses or resumes the
+// queues. When the orders can't be listed the queues are left as they are.
+func (u *kitchenBackpressureUseCase) Check(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "kitchenBackpressureUseCase.Check")
+	defer tracing.EndSpan(span, &err)
+
+	load, err := u.countLoad(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.SetKitchenLoad(load.ActiveOrders, load.Stations)
+
+	u.applying.Lock()
+	defer u.applying.Unlock()
+
+	u.mu.Lock()
+	u.load = load
+	actions := u.decide()
+	u.mu.Unlock()
+
+	return u.apply(load, actions)
+}
+
+func (u *kitchenBackpressureUseCase) SetMode(queue string, mode ConsumerMode) error {
+	switch mode {
+	case ConsumerModeAuto, ConsumerModePaused, ConsumerModeRunning:
+	default:
+		return fmt.Errorf("failed to set mode of queue [%s]: %w [%s]", queue, ErrInvalidConsumerMode, mode)
+	}
+
+	if !u.hasQueue(queue) {
+		return fmt.Errorf("failed to set mode of queue [%s]: %w", queue, ErrQueueNotFound)
+	}
+
+	u.applying.Lock()
+	defer u.applying.Unlock()
+
+	u.mu.Lock()
+	u.modes[queue] = mode
+	load := u.load
+	actions := u.decide()
+	u.mu.Unlock()
+	u.logger.Infof("queue [%s] set to mode [%s]", queue, mode)
+
+	return u.apply(load, actions)
+}
+
+func (u *kitchenBackpressureUseCase) Status() BackpressureStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	queues := u.consumerUseCase.Queues()
+	statuses := make([]ConsumerQueueStatus, len(queues))
+	for i, queue := range queues {
+		statuses[i] = ConsumerQueueStatus{QueueStatus: queue, Mode: u.mode(queue.Queue)}
+	}
+
+	return BackpressureStatus{Load: u.load, Queues: statuses}
+}
+
+// countLoad lists the active orders on the first check and every
+// kitchenLoadRecountInterval, and in between only the orders changed since
+// the previous check, whatever their status, to follow them in and out of
+// the kitchen.
+func (u *kitchenBackpressureUseCase) countLoad(ctx context.Context) (KitchenLoad, error) {
+	u.counting.Lock()
+	defer u.counting.Unlock()
+
+	now := time.Now().UTC()
+	if u.active == nil || now.Sub(u.recountedAt) >= kitchenLoadRecountInterval {
+		active := map[string][]string{}
+		err := u.listOrders(ctx, models.OrderFilter{Statuses: activeOrderStatuses}, func(order models.Order) {
+			active[order.ID] = orderStations(order)
+		})
+		if err != nil {
+			return KitchenLoad{}, fmt.Errorf("failed to get active orders: %w", err)
+		}
+		u.active, u.recountedAt = active, now
+	} else {
+		filter := models.OrderFilter{Statuses: models.OrderStatuses, ChangedFrom: u.syncedAt.Add(-kitchenLoadSyncOverlap)}
+		err := u.listOrders(ctx, filter, func(order models.Order) {
+			if order.IsActive() {
+				u.active[order.ID] = orderStations(order)
+			} else {
+				delete(u.active, order.ID)
+			}
+		})
+		if err != nil {
+			return KitchenLoad{}, fmt.Errorf("failed to get changed orders: %w", err)
+		}
+	}
+	u.syncedAt = now
+
+	load := KitchenLoad{ActiveOrders: len(u.active), Stations: map[string]int{}, Overloaded: []string{}, CheckedAt: now}
+	for station := range u.capacity.Stations {
+		load.Stations[station] = 0
+	}
+	for _, stations := range u.active {
+		for _, station := range stations {
+			load.Stations[station]++
+		}
+	}
+
+	if u.capacity.MaxActiveOrders > 0 && load.ActiveOrders >= u.capacity.MaxActiveOrders {
+		load.Overloaded = append(load.Overloaded, KitchenTotal)
+	}
+	for station, limit := range u.capacity.Stations {
+		if limit > 0 && load.Stations[station] >= limit {
+			load.Overloaded = append(load.Overloaded, station)
+		}
+	}
+	sort.Strings(load.Overloaded)
+
+	return load, nil
+}
+
+func (u *kitchenBackpressureUseCase) listOrders(ctx context.Context, filter models.OrderFilter, visit func(order models.Order)) error {
+	for {
+		page, err := u.orderUseCase.GetOrders(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, order := range page.Results {
+			visit(order)
+		}
+
+		if page.Next == "" {
+			return nil
+		}
+		filter.Cursor = page.Next
+	}
+}
+
+// orderStations returns the stations of the items of order, once each.
+func orderStations(order models.Order) []string {
+	stations := []string{}
+	seen := map[string]bool{}
+	for _, item := range order.Items {
+		if !seen[item.Type] {
+			seen[item.Type] = true
+			stations = append(stations, item.Type)
+		}
+	}
+	return stations
+}
+
+// decide must be called with mu held.
+func (u *kitchenBackpressureUseCase) decide() []queueAction {
+	queues := u.consumerUseCase.Queues()
+	actions := make([]queueAction, len(queues))
+	for i, queue := range queues {
+		mode := u.mode(queue.Queue)
+		run := mode == ConsumerModeRunning ||
+			mode == ConsumerModeAuto && (queue.Queue != u.queue || len(u.load.Overloaded) == 0)
+		change := run && queue.State == QueueStatePaused || !run && queue.State == QueueStateRunning
+
+		actions[i] = queueAction{queue: queue.Queue, mode: mode, run: run, change: change}
+	}
+	return actions
+}
+
+// apply pauses and resumes the queues without mu held, as pausing waits for
+// the messages in process.
+func (u *kitchenBackpressureUseCase) apply(load KitchenLoad, actions []queueAction) error {
+	errs := []error{}
+	for _, action := range actions {
+		switch {
+		case action.change && action.run:
+			errs = append(errs, u.consumerUseCase.ResumeQueue(action.queue))
+		case action.change:
+			if action.mode == ConsumerModeAuto {
+				u.logger.Warnf("kitchen at capacity on %v with [%d] active orders, pausing queue [%s]", load.Overloaded, load.ActiveOrders, action.queue)
+				metrics.IncConsumerBackpressurePause(action.queue)
+			}
+			errs = append(errs, u.consumerUseCase.PauseQueue(action.queue))
+		}
+		metrics.SetConsumerPaused(action.queue, !action.run)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *kitchenBackpressureUseCase) mode(queue string) ConsumerMode {
+	mode, ok := u.modes[queue]
+	if !ok {
+		return ConsumerModeAuto
+	}
+	return mode
+}
+
+func (u *kitchenBackpressureUseCase) hasQueue(queue string) bool {
+	for _, status := range u.consumerUseCase.Queues() {
+		if status.Queue == queue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func activeOrders(itemTypes ...string) []models.Order {
+	orders := make([]models.Order, len(itemTypes))
+	for i, itemType := range itemTypes {
+		orders[i] = models.Order{ID: strconv.Itoa(i + 1), Status: models.OrderStatusInProgress, Items: []models.OrderItem{{Type: itemType}, {Type: itemType}}}
+	}
+	return orders
+}
+
+func TestKitchenBackpressureUseCase_Check(t *testing.T) {
+	activeFilter := models.OrderFilter{Statuses: []string{models.OrderStatusCreated, models.OrderStatusReceived, models.OrderStatusInProgress, models.OrderStatusReady}}
+
+	tests := []struct {
+		name               string
+		capacity           usecases.KitchenCapacity
+		orders             []models.Order
+		expectedStations   map[string]int
+		expectedOverloaded []string
+		expectedState      usecases.QueueState
+	}{
+		{
+			name:               "below capacity",
+			capacity:           usecases.KitchenCapacity{MaxActiveOrders: 4, Stations: map[string]int{"GRILL": 2}},
+			orders:             activeOrders("GRILL", "DRINK", "DRINK"),
+			expectedStations:   map[string]int{"GRILL": 1, "DRINK": 2},
+			expectedOverloaded: []string{},
+			expectedState:      usecases.QueueStateRunning,
+		},
+		{
+			name:               "kitchen at capacity",
+			capacity:           usecases.KitchenCapacity{MaxActiveOrders: 3},
+			orders:             activeOrders("GRILL", "DRINK", "DRINK"),
+			expectedStations:   map[string]int{"GRILL": 1, "DRINK": 2},
+			expectedOverloaded: []string{usecases.KitchenTotal},
+			expectedState:      usecases.QueueStatePaused,
+		},
+		{
+			name:               "station at capacity",
+			capacity:           usecases.KitchenCapacity{Stations: map[string]int{"GRILL": 2, "DESSERT": 1}},
+			orders:             activeOrders("GRILL", "GRILL", "DRINK"),
+			expectedStations:   map[string]int{"GRILL": 2, "DRINK": 1, "DESSERT": 0},
+			expectedOverloaded: []string{"GRILL"},
+			expectedState:      usecases.QueueStatePaused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+			orderUseCase.EXPECT().GetOrders(gomock.Any(), activeFilter).Return(models.OrderPage{Results: tt.orders[:1], Next: "page-2"}, nil)
+			nextFilter := activeFilter
+			nextFilter.Cursor = "page-2"
+			orderUseCase.EXPECT().GetOrders(gomock.Any(), nextFilter).Return(models.OrderPage{Results: tt.orders[1:]}, nil)
+
+			consumerUseCase, _ := newConsumerUseCase(t, &fakeDeadLetterQueue{})
+			backpressure := usecases.NewKitchenBackpressureUseCase(orderUseCase, consumerUseCase, ordersQueue, tt.capacity, time.Second, logger.NewNopLogger())
+
+			require.NoError(t, backpressure.Check(context.Background()))
+
+			status := backpressure.Status()
+			assert.Equal(t, 3, status.Load.ActiveOrders)
+			assert.Equal(t, tt.expectedStations, status.Load.Stations)
+			assert.Equal(t, tt.expectedOverloaded, status.Load.Overloaded)
+			assert.Equal(t, tt.expectedState, status.Queues[0].State)
+			assert.Equal(t, usecases.ConsumerModeAuto, status.Queues[0].Mode)
+		})
+	}
+}
+
+func TestKitchenBackpressureUseCase_ResumesWhenOrdersComplete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	var checkedAt time.Time
+	changedFilter := gomock.Cond(func(x any) bool {
+		filter := x.(models.OrderFilter)
+		return assert.ObjectsAreEqual(models.OrderStatuses, filter.Statuses) && filter.ChangedFrom.Before(checkedAt) && !filter.ChangedFrom.IsZero()
+	})
+	delivered := activeOrders("GRILL")
+	delivered[0].Status = models.OrderStatusDelivered
+	gomock.InOrder(
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+			checkedAt = time.Now().UTC()
+			return models.OrderPage{Results: activeOrders("GRILL", "GRILL")}, nil
+		}),
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), changedFilter).Return(models.OrderPage{}, errors.New("table not found")),
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), changedFilter).Return(models.OrderPage{Results: delivered}, nil),
+	)
+
+	consumerUseCase, consumer := newConsumerUseCase(t, &fakeDeadLetterQueue{}, orderProductionRegistration(orderUseCase))
+	backpressure := usecases.NewKitchenBackpressureUseCase(orderUseCase, consumerUseCase, ordersQueue, usecases.KitchenCapacity{MaxActiveOrders: 2}, time.Second, logger.NewNopLogger())
+
+	require.NoError(t, backpressure.Check(context.Background()))
+	assert.Equal(t, usecases.QueueStatePaused, consumerUseCase.Queues()[0].State)
+
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 1, map[string]any{"id": 3})
+	assert.Error(t, backpressure.Check(context.Background()))
+	assert.Equal(t, usecases.QueueStatePaused, consumerUseCase.Queues()[0].State, "a failed count keeps the queue as it is")
+
+	orderUseCase.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(nil)
+	require.NoError(t, backpressure.Check(context.Background()))
+	assert.Equal(t, usecases.QueueStateRunning, consumerUseCase.Queues()[0].State)
+	assert.Equal(t, 1, backpressure.Status().Load.ActiveOrders, "only the changed orders are listed again")
+	assert.NoError(t, consumer.result(t), "the order left in the queue is consumed")
+}
+
+func TestKitchenBackpressureUseCase_SetMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return(models.OrderPage{Results: activeOrders("GRILL")}, nil).AnyTimes()
+
+	consumerUseCase, _ := newConsumerUseCase(t, &fakeDeadLetterQueue{})
+	backpressure := usecases.NewKitchenBackpressureUseCase(orderUseCase, consumerUseCase, ordersQueue, usecases.KitchenCapacity{MaxActiveOrders: 1}, time.Second, logger.NewNopLogger())
+
+	require.NoError(t, backpressure.SetMode(ordersQueue, usecases.ConsumerModeRunning))
+	require.NoError(t, backpressure.Check(context.Background()))
+	assert.Equal(t, usecases.QueueStateRunning, consumerUseCase.Queues()[0].State, "a forced running queue ignores the capacity")
+
+	require.NoError(t, backpressure.SetMode(ordersQueue, usecases.ConsumerModeAuto))
+	assert.Equal(t, usecases.QueueStatePaused, consumerUseCase.Queues()[0].State, "back to the last load")
+
+	require.NoError(t, backpressure.SetMode(ordersQueue, usecases.ConsumerModePaused))
+	assert.Equal(t, usecases.ConsumerModePaused, backpressure.Status().Queues[0].Mode)
+
+	assert.ErrorIs(t, backpressure.SetMode("orders-cancelled", usecases.ConsumerModePaused), usecases.ErrQueueNotFound)
+	assert.ErrorIs(t, backpressure.SetMode(ordersQueue, "STOPPED"), usecases.ErrInvalidConsumerMode)
+}
+
+func TestKitchenBackpressureUseCase_StatusWhilePausing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return(models.OrderPage{Results: activeOrders("GRILL")}, nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	orderUseCase.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, order models.Order) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	consumerUseCase, consumer := newConsumerUseCase(t, &fakeDeadLetterQueue{}, orderProductionRegistration(orderUseCase))
+	backpressure := usecases.NewKitchenBackpressureUseCase(orderUseCase, consumerUseCase, ordersQueue, usecases.KitchenCapacity{MaxActiveOrders: 1}, time.Second, logger.NewNopLogger())
+
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 1, map[string]any{"id": 3})
+	<-started
+
+	checked := make(chan error)
+	go func() { checked <- backpressure.Check(context.Background()) }()
+	assert.Eventually(t, func() bool {
+		return consumerUseCase.Queues()[0].State == usecases.QueueStatePaused
+	}, time.Second, time.Millisecond, "the queue is pausing")
+
+	status := make(chan usecases.BackpressureStatus)
+	go func() { status <- backpressure.Status() }()
+	select {
+	case <-status:
+	case <-time.After(time.Second):
+		t.Fatal("the status waits for the queue to pause")
+	}
+
+	close(release)
+	require.NoError(t, <-checked)
+	assert.Equal(t, usecases.QueueStatePaused, consumerUseCase.Queues()[0].State)
+	assert.NoError(t, consumer.result(t))
+}
+
+func TestKitchenCapacity_IsLimited(t *testing.T) {
+	assert.False(t, usecases.KitchenCapacity{}.IsLimited())
+	assert.False(t, usecases.KitchenCapacity{Stations: map[string]int{"GRILL": 0}}.IsLimited())
+	assert.True(t, usecases.KitchenCapacity{MaxActiveOrders: 10}.IsLimited())
+	assert.True(t, usecases.KitchenCapacity{Stations: map[string]int{"GRILL": 2}}.IsLimited())
+}
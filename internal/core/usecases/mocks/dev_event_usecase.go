@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dev_event_usecase.go
+//
+// Generated by this command:
+//
+//	mockgen -source=dev_event_usecase.go -destination=mocks/dev_event_usecase.go
+//
+
+// Package mock_usecases is a generated GoMock package.
+package mock_usecases
+
+import (
+	context "context"
+	reflect "reflect"
+
+	dto "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDevEventUseCase is a mock of DevEventUseCase interface.
+type MockDevEventUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockDevEventUseCaseMockRecorder
+}
+
+// MockDevEventUseCaseMockRecorder is the mock recorder for MockDevEventUseCase.
+type MockDevEventUseCaseMockRecorder struct {
+	mock *MockDevEventUseCase
+}
+
+// NewMockDevEventUseCase creates a new mock instance.
+func NewMockDevEventUseCase(ctrl *gomock.Controller) *MockDevEventUseCase {
+	mock := &MockDevEventUseCase{ctrl: ctrl}
+	mock.recorder = &MockDevEventUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDevEventUseCase) EXPECT() *MockDevEventUseCaseMockRecorder {
+	return m.recorder
+}
+
+// PublishOrderEvent mocks base method.
+func (m *MockDevEventUseCase) PublishOrderEvent(ctx context.Context, request dto.OrderEventRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishOrderEvent", ctx, request)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishOrderEvent indicates an expected call of PublishOrderEvent.
+func (mr *MockDevEventUseCaseMockRecorder) PublishOrderEvent(ctx, request any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishOrderEvent", reflect.TypeOf((*MockDevEventUseCase)(nil).PublishOrderEvent), ctx, request)
+}
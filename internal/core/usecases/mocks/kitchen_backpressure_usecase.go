@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: kitchen_backpressure_usecase.go
+//
+// Generated by this command:
+//
+//	mockgen -source=kitchen_backpressure_usecase.go -destination=mocks/kitchen_backpressure_usecase.go
+//
+
+// Package mock_usecases is a generated GoMock package.
+package mock_usecases
+
+import (
+	context "context"
+	reflect "reflect"
+
+	usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockKitchenBackpressureUseCase is a mock of KitchenBackpressureUseCase interface.
+type MockKitchenBackpressureUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockKitchenBackpressureUseCaseMockRecorder
+}
+
+// MockKitchenBackpressureUseCaseMockRecorder is the mock recorder for MockKitchenBackpressureUseCase.
+type MockKitchenBackpressureUseCaseMockRecorder struct {
+	mock *MockKitchenBackpressureUseCase
+}
+
+// NewMockKitchenBackpressureUseCase creates a new mock instance.
+func NewMockKitchenBackpressureUseCase(ctrl *gomock.Controller) *MockKitchenBackpressureUseCase {
+	mock := &MockKitchenBackpressureUseCase{ctrl: ctrl}
+	mock.recorder = &MockKitchenBackpressureUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKitchenBackpressureUseCase) EXPECT() *MockKitchenBackpressureUseCaseMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockKitchenBackpressureUseCase) Check(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockKitchenBackpressureUseCaseMockRecorder) Check(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockKitchenBackpressureUseCase)(nil).Check), ctx)
+}
+
+// Run mocks base method.
+func (m *MockKitchenBackpressureUseCase) Run(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Run", ctx)
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockKitchenBackpressureUseCaseMockRecorder) Run(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockKitchenBackpressureUseCase)(nil).Run), ctx)
+}
+
+// SetMode mocks base method.
+func (m *MockKitchenBackpressureUseCase) SetMode(queue string, mode usecases.ConsumerMode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMode", queue, mode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMode indicates an expected call of SetMode.
+func (mr *MockKitchenBackpressureUseCaseMockRecorder) SetMode(queue, mode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMode", reflect.TypeOf((*MockKitchenBackpressureUseCase)(nil).SetMode), queue, mode)
+}
+
+// Status mocks base method.
+func (m *MockKitchenBackpressureUseCase) Status() usecases.BackpressureStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(usecases.BackpressureStatus)
+	return ret0
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockKitchenBackpressureUseCaseMockRecorder) Status() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockKitchenBackpressureUseCase)(nil).Status))
+}
@@ -0,0 +1,170 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: order_usecase.go
+//
+// Generated by this command:
+//
+//	mockgen -source=order_usecase.go -destination=mocks/order_usecase.go
+//
+
+// Package mock_usecases is a generated GoMock package.
+package mock_usecases
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOrderUseCase is a mock of OrderUseCase interface.
+type MockOrderUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrderUseCaseMockRecorder
+}
+
+// MockOrderUseCaseMockRecorder is the mock recorder for MockOrderUseCase.
+type MockOrderUseCaseMockRecorder struct {
+	mock *MockOrderUseCase
+}
+
+// NewMockOrderUseCase creates a new mock instance.
+func NewMockOrderUseCase(ctrl *gomock.Controller) *MockOrderUseCase {
+	mock := &MockOrderUseCase{ctrl: ctrl}
+	mock.recorder = &MockOrderUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOrderUseCase) EXPECT() *MockOrderUseCaseMockRecorder {
+	return m.recorder
+}
+
+// CancelOrder mocks base method.
+func (m *MockOrderUseCase) CancelOrder(ctx context.Context, orderId int, reason, actor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelOrder", ctx, orderId, reason, actor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelOrder indicates an expected call of CancelOrder.
+func (mr *MockOrderUseCaseMockRecorder) CancelOrder(ctx, orderId, reason, actor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelOrder", reflect.TypeOf((*MockOrderUseCase)(nil).CancelOrder), ctx, orderId, reason, actor)
+}
+
+// CreateOrder mocks base method.
+func (m *MockOrderUseCase) CreateOrder(ctx context.Context, order models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrder", ctx, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOrder indicates an expected call of CreateOrder.
+func (mr *MockOrderUseCaseMockRecorder) CreateOrder(ctx, order any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockOrderUseCase)(nil).CreateOrder), ctx, order)
+}
+
+// ForceOrderStatus mocks base method.
+func (m *MockOrderUseCase) ForceOrderStatus(ctx context.Context, orderId int, orderStatus, reason, actor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceOrderStatus", ctx, orderId, orderStatus, reason, actor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceOrderStatus indicates an expected call of ForceOrderStatus.
+func (mr *MockOrderUseCaseMockRecorder) ForceOrderStatus(ctx, orderId, orderStatus, reason, actor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceOrderStatus", reflect.TypeOf((*MockOrderUseCase)(nil).ForceOrderStatus), ctx, orderId, orderStatus, reason, actor)
+}
+
+// GetAllOrders mocks base method.
+func (m *MockOrderUseCase) GetAllOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllOrders", ctx, filter)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllOrders indicates an expected call of GetAllOrders.
+func (mr *MockOrderUseCaseMockRecorder) GetAllOrders(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllOrders", reflect.TypeOf((*MockOrderUseCase)(nil).GetAllOrders), ctx, filter)
+}
+
+// GetOrder mocks base method.
+func (m *MockOrderUseCase) GetOrder(ctx context.Context, orderId int) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", ctx, orderId)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockOrderUseCaseMockRecorder) GetOrder(ctx, orderId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderUseCase)(nil).GetOrder), ctx, orderId)
+}
+
+// GetOrders mocks base method.
+func (m *MockOrderUseCase) GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, filter)
+	ret0, _ := ret[0].(models.OrderPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockOrderUseCaseMockRecorder) GetOrders(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrderUseCase)(nil).GetOrders), ctx, filter)
+}
+
+// RenotifyOrder mocks base method.
+func (m *MockOrderUseCase) RenotifyOrder(ctx context.Context, orderId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenotifyOrder", ctx, orderId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenotifyOrder indicates an expected call of RenotifyOrder.
+func (mr *MockOrderUseCaseMockRecorder) RenotifyOrder(ctx, orderId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenotifyOrder", reflect.TypeOf((*MockOrderUseCase)(nil).RenotifyOrder), ctx, orderId)
+}
+
+// UpdateOrderStatus mocks base method.
+func (m *MockOrderUseCase) UpdateOrderStatus(ctx context.Context, orderId int, orderStatus string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", ctx, orderId, orderStatus)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockOrderUseCaseMockRecorder) UpdateOrderStatus(ctx, orderId, orderStatus any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockOrderUseCase)(nil).UpdateOrderStatus), ctx, orderId, orderStatus)
+}
+
+// UpdateOrderStatusWithReason mocks base method.
+func (m *MockOrderUseCase) UpdateOrderStatusWithReason(ctx context.Context, orderId int, orderStatus, reason, actor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatusWithReason", ctx, orderId, orderStatus, reason, actor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOrderStatusWithReason indicates an expected call of UpdateOrderStatusWithReason.
+func (mr *MockOrderUseCaseMockRecorder) UpdateOrderStatusWithReason(ctx, orderId, orderStatus, reason, actor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatusWithReason", reflect.TypeOf((*MockOrderUseCase)(nil).UpdateOrderStatusWithReason), ctx, orderId, orderStatus, reason, actor)
+}
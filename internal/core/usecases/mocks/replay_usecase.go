@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: replay_usecase.go
+//
+// Generated by this command:
+//
+//	mockgen -source=replay_usecase.go -destination=mocks/replay_usecase.go
+//
+
+// Package mock_usecases is a generated GoMock package.
+package mock_usecases
+
+import (
+	context "context"
+	reflect "reflect"
+
+	dto "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockReplayUseCase is a mock of ReplayUseCase interface.
+type MockReplayUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockReplayUseCaseMockRecorder
+}
+
+// MockReplayUseCaseMockRecorder is the mock recorder for MockReplayUseCase.
+type MockReplayUseCaseMockRecorder struct {
+	mock *MockReplayUseCase
+}
+
+// NewMockReplayUseCase creates a new mock instance.
+func NewMockReplayUseCase(ctrl *gomock.Controller) *MockReplayUseCase {
+	mock := &MockReplayUseCase{ctrl: ctrl}
+	mock.recorder = &MockReplayUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReplayUseCase) EXPECT() *MockReplayUseCaseMockRecorder {
+	return m.recorder
+}
+
+// ReplayDeadLetters mocks base method.
+func (m *MockReplayUseCase) ReplayDeadLetters(ctx context.Context, request dto.DeadLetterReplayRequest) (dto.ReplayResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDeadLetters", ctx, request)
+	ret0, _ := ret[0].(dto.ReplayResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayDeadLetters indicates an expected call of ReplayDeadLetters.
+func (mr *MockReplayUseCaseMockRecorder) ReplayDeadLetters(ctx, request any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDeadLetters", reflect.TypeOf((*MockReplayUseCase)(nil).ReplayDeadLetters), ctx, request)
+}
+
+// RepublishStatusEvents mocks base method.
+func (m *MockReplayUseCase) RepublishStatusEvents(ctx context.Context, request dto.StatusEventReplayRequest) (dto.ReplayResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RepublishStatusEvents", ctx, request)
+	ret0, _ := ret[0].(dto.ReplayResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RepublishStatusEvents indicates an expected call of RepublishStatusEvents.
+func (mr *MockReplayUseCaseMockRecorder) RepublishStatusEvents(ctx, request any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RepublishStatusEvents", reflect.TypeOf((*MockReplayUseCase)(nil).RepublishStatusEvents), ctx, request)
+}
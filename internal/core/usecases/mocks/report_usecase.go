@@ -0,0 +1,57 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: report_usecase.go
+//
+// Generated by this command:
+//
+//	mockgen -source=report_usecase.go -destination=mocks/report_usecase.go
+//
+
+// Package mock_usecases is a generated GoMock package.
+package mock_usecases
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	dto "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockReportUseCase is a mock of ReportUseCase interface.
+type MockReportUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockReportUseCaseMockRecorder
+}
+
+// MockReportUseCaseMockRecorder is the mock recorder for MockReportUseCase.
+type MockReportUseCaseMockRecorder struct {
+	mock *MockReportUseCase
+}
+
+// NewMockReportUseCase creates a new mock instance.
+func NewMockReportUseCase(ctrl *gomock.Controller) *MockReportUseCase {
+	mock := &MockReportUseCase{ctrl: ctrl}
+	mock.recorder = &MockReportUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReportUseCase) EXPECT() *MockReportUseCaseMockRecorder {
+	return m.recorder
+}
+
+// GetDailyReport mocks base method.
+func (m *MockReportUseCase) GetDailyReport(ctx context.Context, date time.Time) (dto.DailyReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyReport", ctx, date)
+	ret0, _ := ret[0].(dto.DailyReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDailyReport indicates an expected call of GetDailyReport.
+func (mr *MockReportUseCaseMockRecorder) GetDailyReport(ctx, date any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyReport", reflect.TypeOf((*MockReportUseCase)(nil).GetDailyReport), ctx, date)
+}
@@ -1,78 +1,365 @@
 package usecases
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
-	"time"
+	"sort"
+	"sync"
+	"sync/atomic"
 
-	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events"
-	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+const (
+	deadLetterMalformed    = "malformed"
+	deadLetterUnknownEvent = "unknown_event"
+	deadLetterInvalidData  = "invalid_data"
+)
+
+var (
+	// ErrInvalidEventData is returned by an EventHandler when the event
+	// payload can never be processed, which sends the message to the dead
+	// letter queue instead of retrying it.
+	ErrInvalidEventData = errors.New("invalid event data")
+	ErrQueueNotFound    = errors.New("queue not found")
+)
+
+// EventHandler processes the events of one type and version.
+type EventHandler func(ctx context.Context, event broker.CloudEvent) error
+
+type QueueState string
+
+const (
+	QueueStateStopped QueueState = "STOPPED"
+	QueueStateRunning QueueState = "RUNNING"
+	QueueStatePaused  QueueState = "PAUSED"
+)
+
+// HandlerRegistration binds the events of EventType and Version arriving
+// on Queue to Handle. Concurrency caps how many of those events are
+// processed at once; the queue runs one worker per slot of its handlers,
+// in the pool of a broker.PooledConsumer or as separate consumer loops.
+type HandlerRegistration struct {
+	Queue       string
+	EventType   string
+	Version     int
+	Concurrency int
+	Handle      EventHandler
+}
+
+type QueueStatus struct {
+	Queue    string     `json:"queue"`
+	State    QueueState `json:"state"`
+	Workers  int        `json:"workers"`
+	Handlers []string   `json:"handlers"`
+}
+
+// OrderConsumerUseCase is the registry of the inbound queues and the
+// handlers of the events they carry. Queues and handlers are registered
+// before StartConsumers; each queue can then be paused and resumed on its
+// own.
 type OrderConsumerUseCase interface {
-	StartConsumers()
+	RegisterQueue(queue string, consumer broker.Consumer)
+	RegisterHandler(registration HandlerRegistration) error
+	StartConsumers(ctx context.Context)
+	// StopConsumers stops every queue and waits for the messages in process.
+	StopConsumers()
+	// PauseQueue stops pulling messages from queue and waits for the ones
+	// in process; ResumeQueue starts pulling them again.
+	PauseQueue(queue string) error
+	ResumeQueue(queue string) error
+	// IsRunning reports whether every queue not paused is pulling messages.
+	IsRunning() bool
+	Queues() []QueueStatus
 }
 
-type orderConsumerUseCase struct {
-	orderPaidConsumer broker.Consumer
-	orderUsecase      OrderUseCase
+type eventRoute struct {
+	eventType string
+	version   int
+}
+
+type eventHandler struct {
+	handle EventHandler
+	// slots holds a token per event in process
+	slots chan struct{}
 }
 
-type OrderConsumerUseCaseConfig struct {
-	OrderPaidConsumer broker.Consumer
-	OrderUseCase      OrderUseCase
+type consumerQueue struct {
+	name     string
+	consumer broker.Consumer
+	handlers map[eventRoute]*eventHandler
+	workers  int
+
+	state   QueueState
+	cancel  context.CancelFunc
+	loops   *sync.WaitGroup
+	running atomic.Int32
 }
 
-func NewOrderConsumerUseCase(orderPaidConsumer broker.Consumer, orderUsecase OrderUseCase) OrderConsumerUseCase {
+// loopCount keeps a loop on queues without handlers, so their messages
+// still reach the dead letter queue. Pooled consumers run their workers in
+// a single loop.
+func (q *consumerQueue) loopCount() int {
+	if _, ok := q.consumer.(broker.PooledConsumer); ok || q.workers < 1 {
+		return 1
+	}
+	return q.workers
+}
+
+// loopWorkers is how many messages each loop processes at once.
+func (q *consumerQueue) loopWorkers() int {
+	if _, ok := q.consumer.(broker.PooledConsumer); ok && q.workers > 1 {
+		return q.workers
+	}
+	return 1
+}
+
+type orderConsumerUseCase struct {
+	deadLetterQueue gateways.DeadLetterQueue
+	logger          logger.Logger
+
+	mu     sync.Mutex
+	ctx    context.Context
+	queues map[string]*consumerQueue
+}
+
+func NewOrderConsumerUseCase(deadLetterQueue gateways.DeadLetterQueue, logger logger.Logger) OrderConsumerUseCase {
 	return &orderConsumerUseCase{
-		orderPaidConsumer: orderPaidConsumer,
-		orderUsecase:      orderUsecase,
+		deadLetterQueue: deadLetterQueue,
+		logger:          logger,
+		queues:          map[string]*consumerQueue{},
 	}
 }
 
-func (u *orderConsumerUseCase) StartConsumers() {
-	go u.orderPaidConsumer.StartConsumer(u.processOrderMessage)
+func (u *orderConsumerUseCase) RegisterQueue(queue string, consumer broker.Consumer) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.queues[queue] = &consumerQueue{
+		name:     queue,
+		consumer: consumer,
+		handlers: map[eventRoute]*eventHandler{},
+		state:    QueueStateStopped,
+	}
 }
 
-func (u *orderConsumerUseCase) processOrderMessage(message []byte) error {
-	var productionOrder events.OrderProductionDTO
-	err := json.Unmarshal(message, &productionOrder)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshall message, error: %w", err)
+func (u *orderConsumerUseCase) RegisterHandler(registration HandlerRegistration) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	queue, ok := u.queues[registration.Queue]
+	if !ok {
+		return fmt.Errorf("failed to register handler of [%s]: %w", registration.EventType, ErrQueueNotFound)
 	}
 
-	order := mapEventOrderToOrder(productionOrder)
-	err = u.orderUsecase.CreateOrder(order)
-	if err != nil {
-		return fmt.Errorf("failed to update order status, error: %w", err)
+	concurrency := registration.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	route := eventRoute{eventType: registration.EventType, version: registration.Version}
+	if previous, ok := queue.handlers[route]; ok {
+		queue.workers -= cap(previous.slots)
 	}
+	queue.handlers[route] = &eventHandler{handle: registration.Handle, slots: make(chan struct{}, concurrency)}
+	queue.workers += concurrency
 
 	return nil
 }
 
-func mapEventOrderToOrder(productionOrder events.OrderProductionDTO) models.Order {
-	orderItems := make([]models.OrderItem, len(productionOrder.Items))
-	for i, item := range productionOrder.Items {
-		orderItem := models.OrderItem{
-			Quantity: item.Quantity,
-			Type:     item.Type,
-			Product: models.Product{
-				Name:        item.Products.Name,
-				Description: item.Products.Description,
-			},
+func (u *orderConsumerUseCase) StartConsumers(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.ctx = ctx
+	for _, queue := range u.queues {
+		u.startQueue(queue)
+	}
+}
+
+func (u *orderConsumerUseCase) StopConsumers() {
+	u.mu.Lock()
+	loops := []*sync.WaitGroup{}
+	for _, queue := range u.queues {
+		if queue.state == QueueStateRunning {
+			queue.cancel()
+			loops = append(loops, queue.loops)
 		}
-		orderItems[i] = orderItem
+		queue.state = QueueStateStopped
+	}
+	u.mu.Unlock()
+
+	for _, wg := range loops {
+		wg.Wait()
 	}
+}
+
+func (u *orderConsumerUseCase) PauseQueue(name string) error {
+	u.mu.Lock()
+	queue, ok := u.queues[name]
+	if !ok {
+		u.mu.Unlock()
+		return fmt.Errorf("failed to pause queue [%s]: %w", name, ErrQueueNotFound)
+	}
+	if queue.state != QueueStateRunning {
+		u.mu.Unlock()
+		return nil
+	}
+	queue.cancel()
+	queue.state = QueueStatePaused
+	loops := queue.loops
+	u.mu.Unlock()
+
+	loops.Wait()
+	u.logger.Infof("paused queue [%s]", name)
+	return nil
+}
+
+func (u *orderConsumerUseCase) ResumeQueue(name string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	queue, ok := u.queues[name]
+	if !ok {
+		return fmt.Errorf("failed to resume queue [%s]: %w", name, ErrQueueNotFound)
+	}
+	if queue.state != QueueStatePaused {
+		return nil
+	}
+
+	u.startQueue(queue)
+	u.logger.Infof("resumed queue [%s]", name)
+	return nil
+}
+
+func (u *orderConsumerUseCase) IsRunning() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.ctx == nil || u.ctx.Err() != nil {
+		return false
+	}
+	for _, queue := range u.queues {
+		if queue.state == QueueStateRunning && int(queue.running.Load()) < queue.loopCount() {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *orderConsumerUseCase) Queues() []QueueStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	statuses := make([]QueueStatus, 0, len(u.queues))
+	for _, queue := range u.queues {
+		handlers := make([]string, 0, len(queue.handlers))
+		for route := range queue.handlers {
+			handlers = append(handlers, fmt.Sprintf("%s.v%d", route.eventType, route.version))
+		}
+		sort.Strings(handlers)
+
+		statuses = append(statuses, QueueStatus{
+			Queue:    queue.name,
+			State:    queue.state,
+			Workers:  int(queue.running.Load()) * queue.loopWorkers(),
+			Handlers: handlers,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Queue < statuses[j].Queue })
+
+	return statuses
+}
+
+// startQueue must be called with mu held.
+func (u *orderConsumerUseCase) startQueue(queue *consumerQueue) {
+	ctx, cancel := context.WithCancel(u.ctx)
+	queue.cancel = cancel
+	queue.loops = &sync.WaitGroup{}
+	queue.state = QueueStateRunning
+
+	processMessage := func(ctx context.Context, message []byte) error {
+		return u.processMessage(ctx, queue, message)
+	}
+	for i := 0; i < queue.loopCount(); i++ {
+		queue.loops.Add(1)
+		queue.running.Add(1)
+		go func(loops *sync.WaitGroup) {
+			defer loops.Done()
+			defer queue.running.Add(-1)
+			if pool, ok := queue.consumer.(broker.PooledConsumer); ok {
+				pool.StartPool(ctx, queue.loopWorkers(), processMessage)
+				return
+			}
+			queue.consumer.StartConsumer(ctx, processMessage)
+		}(queue.loops)
+	}
+}
+
+// processMessage routes the message to the handler of its event type
+// and version. Messages that no handler can take are moved to the dead
+// letter queue and acked; only a failed handler or dead letter publish
+// makes the message be redelivered.
+func (u *orderConsumerUseCase) processMessage(ctx context.Context, queue *consumerQueue, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, "orderConsumerUseCase.processMessage")
+	span.SetAttributes(attribute.String("messaging.destination.name", queue.name))
+	defer tracing.EndSpan(span, &err)
+
+	event, err := broker.DecodeCloudEvent(message)
+	if errors.Is(err, broker.ErrNotCloudEvent) {
+		event, err = legacyOrderProductionEvent(message)
+	}
+	if err != nil {
+		return u.deadLetter(ctx, "", message, deadLetterMalformed, err)
+	}
+
+	eventType, version := event.EventType()
+	span.SetAttributes(attribute.String("event.id", event.ID), attribute.String("event.type", event.Type))
+
+	handler, ok := queue.handlers[eventRoute{eventType: eventType, version: version}]
+	if !ok {
+		return u.deadLetter(ctx, event.Subject, message, deadLetterUnknownEvent, fmt.Errorf("no handler for event [%s] version [%d] on queue [%s]", eventType, version, queue.name))
+	}
+
+	select {
+	case handler.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-handler.slots }()
+
+	err = handler.handle(ctx, event)
+	if errors.Is(err, ErrInvalidEventData) {
+		return u.deadLetter(ctx, event.Subject, message, deadLetterInvalidData, err)
+	}
+	return err
+}
+
+func (u *orderConsumerUseCase) deadLetter(ctx context.Context, key string, message []byte, reason string, cause error) error {
+	u.logger.WithContext(ctx).WithError(cause).Warnf("moving message to the dead letter queue, reason [%s]", reason)
+
+	err := u.deadLetterQueue.Send(ctx, key, message, reason)
+	if err != nil {
+		return fmt.Errorf("failed to dead letter message, error: %w", err)
+	}
+	return nil
+}
 
-	order := models.Order{
-		ID:        strconv.Itoa(productionOrder.ID),
-		Status:    "CREATED",
-		CreatedAt: time.Now(),
-		Items:     orderItems,
-		Entity:    "ORDER",
+// legacyOrderProductionEvent wraps a bare events.OrderProductionDTO, as the
+// order service published before the envelope, in an event of its type.
+func legacyOrderProductionEvent(message []byte) (broker.CloudEvent, error) {
+	if !json.Valid(message) {
+		return broker.CloudEvent{}, errors.New("message is not valid json")
 	}
 
-	return order
+	return broker.CloudEvent{
+		SpecVersion: broker.CloudEventsSpecVersion,
+		Type:        fmt.Sprintf("%s.v%d", OrderProductionEventType, OrderProductionEventVersion),
+		Data:        message,
+	}, nil
 }
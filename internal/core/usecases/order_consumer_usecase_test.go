@@ -0,0 +1,296 @@
+package usecases_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const ordersQueue = "orders-in-progress"
+
+// fakeConsumer hands the messages sent to it to the processing function
+// and reports the results, as a broker would before acking or nacking.
+type fakeConsumer struct {
+	messages chan []byte
+	results  chan error
+}
+
+func newFakeConsumer() *fakeConsumer {
+	return &fakeConsumer{messages: make(chan []byte, 10), results: make(chan error, 10)}
+}
+
+func (c *fakeConsumer) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-c.messages:
+			c.results <- processMessage(ctx, message)
+		}
+	}
+}
+
+func (c *fakeConsumer) result(t *testing.T) error {
+	select {
+	case err := <-c.results:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not processed")
+		return nil
+	}
+}
+
+type fakeDeadLetterQueue struct {
+	mu      sync.Mutex
+	reasons []string
+	err     error
+}
+
+func (q *fakeDeadLetterQueue) Send(ctx context.Context, key string, message []byte, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return q.err
+	}
+	q.reasons = append(q.reasons, reason)
+	return nil
+}
+
+func newConsumerUseCase(t *testing.T, deadLetterQueue gateways.DeadLetterQueue, registrations ...usecases.HandlerRegistration) (usecases.OrderConsumerUseCase, *fakeConsumer) {
+	consumer := newFakeConsumer()
+	consumerUseCase := usecases.NewOrderConsumerUseCase(deadLetterQueue, logger.NewNopLogger())
+	consumerUseCase.RegisterQueue(ordersQueue, consumer)
+	for _, registration := range registrations {
+		registration.Queue = ordersQueue
+		require.NoError(t, consumerUseCase.RegisterHandler(registration))
+	}
+
+	consumerUseCase.StartConsumers(context.Background())
+	t.Cleanup(consumerUseCase.StopConsumers)
+	return consumerUseCase, consumer
+}
+
+func envelope(t *testing.T, eventType string, version int, data any) []byte {
+	event, err := broker.NewCloudEvent("/test", eventType, version, "", "", data)
+	require.NoError(t, err)
+	message, err := json.Marshal(event)
+	require.NoError(t, err)
+	return message
+}
+
+func orderProductionRegistration(orderUseCase usecases.OrderUseCase) usecases.HandlerRegistration {
+	return usecases.HandlerRegistration{
+		EventType: usecases.OrderProductionEventType,
+		Version:   usecases.OrderProductionEventVersion,
+		Handle:    usecases.NewOrderProductionHandler(orderUseCase, logger.NewNopLogger()),
+	}
+}
+
+func TestOrderConsumerUseCase_CreatesOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	deadLetterQueue := &fakeDeadLetterQueue{}
+	_, consumer := newConsumerUseCase(t, deadLetterQueue, orderProductionRegistration(orderUseCase))
+
+	orderUseCase.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, order models.Order) error {
+		assert.Equal(t, models.OrderStatusCreated, order.Status)
+		return nil
+	}).Times(2)
+	orderUseCase.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(gateways.ErrOrderAlreadyExists)
+
+	consumer.messages <- []byte(`{"id": 1, "status": "PAID", "items": []}`)
+	assert.NoError(t, consumer.result(t), "legacy payload")
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 1, map[string]any{"id": 2, "status": "PAID"})
+	assert.NoError(t, consumer.result(t))
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 1, map[string]any{"id": 2, "status": "PAID"})
+	assert.NoError(t, consumer.result(t), "redelivered order")
+
+	assert.Empty(t, deadLetterQueue.reasons)
+}
+
+func TestOrderConsumerUseCase_DeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	deadLetterQueue := &fakeDeadLetterQueue{}
+	_, consumer := newConsumerUseCase(t, deadLetterQueue, orderProductionRegistration(mock_usecases.NewMockOrderUseCase(ctrl)))
+
+	messages := [][]byte{
+		[]byte(`order 1`),
+		envelope(t, usecases.OrderProductionEventType, 2, map[string]any{"id": 1}),
+		envelope(t, "br.com.g73.order.cancelled", 1, map[string]any{"id": 1}),
+		envelope(t, usecases.OrderProductionEventType, 1, "not an order"),
+	}
+	for _, message := range messages {
+		consumer.messages <- message
+		assert.NoError(t, consumer.result(t), "dead lettered messages are acked")
+	}
+
+	assert.Equal(t, []string{"malformed", "unknown_event", "unknown_event", "invalid_data"}, deadLetterQueue.reasons)
+}
+
+func TestOrderConsumerUseCase_DeadLetterFailureIsRedelivered(t *testing.T) {
+	deadLetterQueue := &fakeDeadLetterQueue{err: errors.New("broker unavailable")}
+	_, consumer := newConsumerUseCase(t, deadLetterQueue)
+
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 9, map[string]any{"id": 1})
+
+	assert.ErrorContains(t, consumer.result(t), "failed to dead letter message")
+}
+
+func TestOrderConsumerUseCase_RoutesToRegisteredHandlers(t *testing.T) {
+	deadLetterQueue := &fakeDeadLetterQueue{}
+	handled := make(chan string, 1)
+	_, consumer := newConsumerUseCase(t, deadLetterQueue,
+		usecases.HandlerRegistration{
+			EventType: usecases.OrderProductionEventType,
+			Version:   2,
+			Handle: func(ctx context.Context, event broker.CloudEvent) error {
+				handled <- event.Type
+				return nil
+			},
+		},
+		usecases.HandlerRegistration{
+			EventType: "br.com.g73.order.cancelled",
+			Version:   1,
+			Handle: func(ctx context.Context, event broker.CloudEvent) error {
+				return errors.New("store unavailable")
+			},
+		},
+	)
+
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 2, map[string]any{"id": 1})
+	assert.NoError(t, consumer.result(t))
+	assert.Equal(t, usecases.OrderProductionEventType+".v2", <-handled)
+
+	consumer.messages <- envelope(t, "br.com.g73.order.cancelled", 1, map[string]any{"id": 1})
+	assert.EqualError(t, consumer.result(t), "store unavailable", "handler failures are redelivered")
+	assert.Empty(t, deadLetterQueue.reasons)
+}
+
+func TestOrderConsumerUseCase_HandlerConcurrency(t *testing.T) {
+	var inProcess, maxInProcess atomic.Int32
+	release := make(chan struct{})
+	busy := func(ctx context.Context, event broker.CloudEvent) error {
+		current := inProcess.Add(1)
+		defer inProcess.Add(-1)
+		for {
+			max := maxInProcess.Load()
+			if current <= max || maxInProcess.CompareAndSwap(max, current) {
+				break
+			}
+		}
+		<-release
+		return nil
+	}
+
+	consumerUseCase, consumer := newConsumerUseCase(t, &fakeDeadLetterQueue{},
+		usecases.HandlerRegistration{EventType: "br.com.g73.order.priority", Version: 1, Concurrency: 2, Handle: busy},
+		usecases.HandlerRegistration{EventType: "br.com.g73.order.cancelled", Version: 1, Concurrency: 3, Handle: busy},
+	)
+	assert.Equal(t, 5, consumerUseCase.Queues()[0].Workers, "a consumer loop per handler slot")
+
+	for i := 0; i < 4; i++ {
+		consumer.messages <- envelope(t, "br.com.g73.order.priority", 1, map[string]any{"id": i})
+	}
+	assert.Eventually(t, func() bool { return inProcess.Load() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), maxInProcess.Load(), "the other loops wait for a slot of the handler")
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, consumer.result(t))
+	}
+}
+
+func TestOrderConsumerUseCase_Lifecycle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	orderUseCase.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(nil)
+
+	consumerUseCase, consumer := newConsumerUseCase(t, &fakeDeadLetterQueue{}, orderProductionRegistration(orderUseCase))
+	assert.True(t, consumerUseCase.IsRunning())
+
+	require.NoError(t, consumerUseCase.PauseQueue(ordersQueue))
+	assert.Equal(t, []usecases.QueueStatus{{
+		Queue:    ordersQueue,
+		State:    usecases.QueueStatePaused,
+		Workers:  0,
+		Handlers: []string{usecases.OrderProductionEventType + ".v1"},
+	}}, consumerUseCase.Queues())
+	assert.True(t, consumerUseCase.IsRunning(), "a paused queue is not a failure")
+
+	consumer.messages <- envelope(t, usecases.OrderProductionEventType, 1, map[string]any{"id": 1})
+	select {
+	case <-consumer.results:
+		t.Fatal("a paused queue must not process messages")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, consumerUseCase.ResumeQueue(ordersQueue))
+	assert.NoError(t, consumer.result(t))
+	assert.Equal(t, usecases.QueueStateRunning, consumerUseCase.Queues()[0].State)
+
+	assert.ErrorIs(t, consumerUseCase.PauseQueue("orders-cancelled"), usecases.ErrQueueNotFound)
+	assert.ErrorIs(t, consumerUseCase.ResumeQueue("orders-cancelled"), usecases.ErrQueueNotFound)
+	assert.ErrorIs(t, consumerUseCase.RegisterHandler(usecases.HandlerRegistration{Queue: "orders-cancelled"}), usecases.ErrQueueNotFound)
+
+	consumerUseCase.StopConsumers()
+	assert.Equal(t, usecases.QueueStateStopped, consumerUseCase.Queues()[0].State)
+	assert.Equal(t, 0, consumerUseCase.Queues()[0].Workers)
+}
+
+func TestOrderConsumerUseCase_NotRunningWhenALoopExits(t *testing.T) {
+	consumerUseCase := usecases.NewOrderConsumerUseCase(&fakeDeadLetterQueue{}, logger.NewNopLogger())
+	assert.False(t, consumerUseCase.IsRunning(), "not started")
+
+	// the broker closing the delivery channel ends the loop on its own
+	consumerUseCase.RegisterQueue(ordersQueue, consumerFunc(func(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {}))
+	require.NoError(t, consumerUseCase.RegisterHandler(usecases.HandlerRegistration{Queue: ordersQueue, EventType: "br.com.g73.order.priority", Version: 1}))
+	consumerUseCase.StartConsumers(context.Background())
+
+	assert.Eventually(t, func() bool { return !consumerUseCase.IsRunning() }, time.Second, time.Millisecond)
+}
+
+type consumerFunc func(ctx context.Context, processMessage func(ctx context.Context, message []byte) error)
+
+func (f consumerFunc) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	f(ctx, processMessage)
+}
+
+type pooledConsumer struct {
+	consumerFunc
+	pools chan int
+}
+
+func (c pooledConsumer) StartPool(ctx context.Context, workers int, processMessage func(ctx context.Context, message []byte) error) {
+	c.pools <- workers
+	<-ctx.Done()
+}
+
+func TestOrderConsumerUseCase_PooledConsumerRunsASingleLoop(t *testing.T) {
+	consumer := pooledConsumer{pools: make(chan int, 5)}
+	consumerUseCase := usecases.NewOrderConsumerUseCase(&fakeDeadLetterQueue{}, logger.NewNopLogger())
+	consumerUseCase.RegisterQueue(ordersQueue, consumer)
+	require.NoError(t, consumerUseCase.RegisterHandler(usecases.HandlerRegistration{Queue: ordersQueue, EventType: "br.com.g73.order.priority", Version: 1, Concurrency: 2}))
+	require.NoError(t, consumerUseCase.RegisterHandler(usecases.HandlerRegistration{Queue: ordersQueue, EventType: "br.com.g73.order.cancelled", Version: 1, Concurrency: 3}))
+	consumerUseCase.StartConsumers(context.Background())
+	defer consumerUseCase.StopConsumers()
+
+	assert.Equal(t, 5, <-consumer.pools, "a worker per handler slot")
+	assert.Eventually(t, consumerUseCase.IsRunning, time.Second, time.Millisecond)
+	assert.Equal(t, 5, consumerUseCase.Queues()[0].Workers)
+	assert.Empty(t, consumer.pools, "a single pool for the queue")
+}
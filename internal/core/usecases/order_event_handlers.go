@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// OrderProductionEventType is published by the order service when an
+	// order is paid and goes to the kitchen. Messages without the CloudEvents
+	// envelope are read as its first version.
+	OrderProductionEventType    = "br.com.g73.order.production"
+	OrderProductionEventVersion = 1
+)
+
+// NewOrderProductionHandler creates the orders sent to the kitchen.
+// Redelivered events of orders that already exist are skipped.
+func NewOrderProductionHandler(orderUsecase OrderUseCase, log logger.Logger) EventHandler {
+	return func(ctx context.Context, event broker.CloudEvent) error {
+		var productionOrder events.OrderProductionDTO
+		err := json.Unmarshal(event.Data, &productionOrder)
+		if err != nil {
+			return fmt.Errorf("%w: failed to unmarshall order production, error: %v", ErrInvalidEventData, err)
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("order.id", productionOrder.ID))
+
+		order := mapEventOrderToOrder(productionOrder)
+		ctx = logger.ContextWithOrderID(ctx, order.ID)
+		log.WithContext(ctx).Debugf("processing order message")
+
+		err = orderUsecase.CreateOrder(ctx, order)
+		if errors.Is(err, gateways.ErrOrderAlreadyExists) {
+			// redelivered message, the order was already created
+			log.WithContext(ctx).Infof("order already exists, skipping message")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update order status, error: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// OrderEventKey returns the order ID of an inbound message, the subject of
+// an enveloped event or the id of a legacy payload, so pooled consumers
+// keep the events of an order in order.
+func OrderEventKey(message []byte) string {
+	event, err := broker.DecodeCloudEvent(message)
+	if err == nil {
+		return event.Subject
+	}
+
+	var legacy struct {
+		ID int `json:"id"`
+	}
+	if json.Unmarshal(message, &legacy) != nil || legacy.ID == 0 {
+		return ""
+	}
+	return strconv.Itoa(legacy.ID)
+}
+
+func mapEventOrderToOrder(productionOrder events.OrderProductionDTO) models.Order {
+	orderItems := make([]models.OrderItem, len(productionOrder.Items))
+	for i, item := range productionOrder.Items {
+		orderItem := models.OrderItem{
+			Quantity: item.Quantity,
+			Type:     item.Type,
+			Product: models.Product{
+				Name:        item.Products.Name,
+				Description: item.Products.Description,
+			},
+		}
+		orderItems[i] = orderItem
+	}
+
+	order := models.Order{
+		ID:        strconv.Itoa(productionOrder.ID),
+		Status:    models.OrderStatusCreated,
+		CreatedAt: time.Now(),
+		Items:     orderItems,
+		Entity:    "ORDER",
+	}
+
+	return order
+}
@@ -1,56 +1,217 @@
 package usecases
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases")
+
+var (
+	ErrInvalidOrderStatus = errors.New("invalid order status")
+	ErrOrderNotActive     = errors.New("order is no longer active")
+	ErrReasonRequired     = errors.New("a reason is required")
+	ErrActorRequired      = errors.New("an actor is required")
 )
 
 type OrderUseCase interface {
-	GetOrders() ([]models.Order, error)
-	CreateOrder(order models.Order) error
-	UpdateOrderStatus(orderId int, orderStatus string) error
+	GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error)
+	// GetAllOrders reads every page of the filter, filter.Limit being the
+	// page size.
+	GetAllOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	// GetOrder returns the order with its status history.
+	GetOrder(ctx context.Context, orderId int) (models.Order, error)
+	CreateOrder(ctx context.Context, order models.Order) error
+	UpdateOrderStatus(ctx context.Context, orderId int, orderStatus string) error
+	// UpdateOrderStatusWithReason is UpdateOrderStatus keeping reason and
+	// actor, both optional, in the status history.
+	UpdateOrderStatusWithReason(ctx context.Context, orderId int, orderStatus string, reason string, actor string) error
+	// ForceOrderStatus sets any status, even on a completed order, keeping
+	// reason and actor in the status history. It is meant for support only.
+	ForceOrderStatus(ctx context.Context, orderId int, orderStatus string, reason string, actor string) error
+	// CancelOrder cancels an active order, keeping reason and actor in the
+	// history.
+	CancelOrder(ctx context.Context, orderId int, reason string, actor string) error
+	// RenotifyOrder publishes the current status of the order again.
+	// Webhooks are not delivered again.
+	RenotifyOrder(ctx context.Context, orderId int) error
 }
 
 type orderUseCase struct {
-	orderRepository gateways.OrderRepository
-	orderNotify     gateways.OrderNotify
+	orderRepository   gateways.OrderRepository
+	orderNotify       gateways.OrderNotify
+	webhookDispatcher gateways.WebhookDispatcher
+	logger            logger.Logger
 }
 
-func NewOrderUseCase(orderRepository gateways.OrderRepository, orderNotify gateways.OrderNotify) OrderUseCase {
+func NewOrderUseCase(orderRepository gateways.OrderRepository, orderNotify gateways.OrderNotify, webhookDispatcher gateways.WebhookDispatcher, logger logger.Logger) OrderUseCase {
 	return &orderUseCase{
-		orderRepository: orderRepository,
-		orderNotify:     orderNotify,
+		orderRepository:   orderRepository,
+		orderNotify:       orderNotify,
+		webhookDispatcher: webhookDispatcher,
+		logger:            logger,
 	}
 }
 
-func (o orderUseCase) GetOrders() ([]models.Order, error) {
-	orders, err := o.orderRepository.GetOrders()
+func (o orderUseCase) GetOrders(ctx context.Context, filter models.OrderFilter) (page models.OrderPage, err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.GetOrders")
+	defer tracing.EndSpan(span, &err)
+
+	page, err = o.orderRepository.GetOrders(ctx, filter)
 	if err != nil {
-		return nil, err
+		return models.OrderPage{}, err
+	}
+
+	return page, nil
+}
+
+func (o orderUseCase) GetAllOrders(ctx context.Context, filter models.OrderFilter) (orders []models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.GetAllOrders")
+	defer tracing.EndSpan(span, &err)
+
+	orders = []models.Order{}
+	for {
+		page, err := o.orderRepository.GetOrders(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Results...)
+
+		if page.Next == "" {
+			return orders, nil
+		}
+		filter.Cursor = page.Next
+	}
+}
+
+func (o orderUseCase) GetOrder(ctx context.Context, orderId int) (order models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.GetOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+
+	return o.orderRepository.GetOrder(ctx, orderId)
+}
+
+func (o *orderUseCase) UpdateOrderStatus(ctx context.Context, orderId int, orderStatus string) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.UpdateOrderStatus")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", orderStatus))
+	defer tracing.EndSpan(span, &err)
+
+	return o.changeOrderStatus(ctx, orderId, orderStatus, "", "")
+}
+
+func (o *orderUseCase) UpdateOrderStatusWithReason(ctx context.Context, orderId int, orderStatus string, reason string, actor string) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.UpdateOrderStatusWithReason")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", orderStatus))
+	defer tracing.EndSpan(span, &err)
+
+	return o.changeOrderStatus(ctx, orderId, orderStatus, reason, actor)
+}
+
+func (o *orderUseCase) ForceOrderStatus(ctx context.Context, orderId int, orderStatus string, reason string, actor string) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.ForceOrderStatus")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", orderStatus))
+	defer tracing.EndSpan(span, &err)
+
+	if !models.IsOrderStatus(orderStatus) {
+		return fmt.Errorf("%w [%s]", ErrInvalidOrderStatus, orderStatus)
+	}
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	if actor == "" {
+		return ErrActorRequired
 	}
 
-	return orders, nil
+	o.logger.WithContext(ctx).WithFields(logger.Fields{"orderId": orderId, "reason": reason, "actor": actor}).Warnf("forcing order status to [%s]", orderStatus)
+	return o.changeOrderStatus(ctx, orderId, orderStatus, reason, actor)
 }
 
-func (o *orderUseCase) UpdateOrderStatus(orderId int, orderStatus string) error {
-	err := o.orderRepository.UpdateOrderStatus(orderId, orderStatus)
+func (o *orderUseCase) CancelOrder(ctx context.Context, orderId int, reason string, actor string) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.CancelOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	if actor == "" {
+		return ErrActorRequired
+	}
+
+	order, err := o.orderRepository.GetOrder(ctx, orderId)
 	if err != nil {
 		return err
 	}
+	if !order.IsActive() {
+		return fmt.Errorf("failed to cancel order [%d]: %w, status is [%s]", orderId, ErrOrderNotActive, order.Status)
+	}
+
+	o.logger.WithContext(ctx).WithFields(logger.Fields{"orderId": orderId, "reason": reason, "actor": actor}).Warnf("cancelling order")
+	return o.changeOrderStatus(ctx, orderId, models.OrderStatusCancelled, reason, actor)
+}
 
-	err = o.orderNotify.NotifyOrder(orderId, orderStatus)
+func (o *orderUseCase) RenotifyOrder(ctx context.Context, orderId int) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.RenotifyOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+
+	order, err := o.orderRepository.GetOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+
+	err = o.orderNotify.NotifyOrder(ctx, orderId, order.Status)
 	if err != nil {
 		return err
 	}
 
+	o.logger.WithContext(ctx).Infof("order status [%s] notified again", order.Status)
 	return nil
 }
 
-func (o *orderUseCase) CreateOrder(order models.Order) error {
-	err := o.orderRepository.SaveOrder(order)
+func (o *orderUseCase) changeOrderStatus(ctx context.Context, orderId int, orderStatus string, reason string, actor string) error {
+	err := o.orderRepository.UpdateOrderStatusWithReason(ctx, orderId, orderStatus, reason, actor)
 	if err != nil {
 		return err
 	}
 
+	// the status is saved, so the partners are told even when the broker
+	// is not; partners must not fail the kitchen, so webhook errors are
+	// only logged
+	notifyErr := o.orderNotify.NotifyOrder(ctx, orderId, orderStatus)
+	dispatchErr := o.webhookDispatcher.Dispatch(ctx, orderId, orderStatus)
+	if dispatchErr != nil {
+		o.logger.WithContext(ctx).WithError(dispatchErr).Warnf("failed to dispatch webhooks for status [%s]", orderStatus)
+	}
+	if notifyErr != nil {
+		return notifyErr
+	}
+
+	o.logger.WithContext(ctx).Infof("order status updated to [%s]", orderStatus)
+
+	return nil
+}
+
+func (o *orderUseCase) CreateOrder(ctx context.Context, order models.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "orderUseCase.CreateOrder")
+	span.SetAttributes(attribute.String("order.id", order.ID))
+	defer tracing.EndSpan(span, &err)
+
+	err = o.orderRepository.SaveOrder(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	o.logger.WithContext(ctx).Infof("order created with [%d] items", len(order.Items))
+
 	return nil
 }
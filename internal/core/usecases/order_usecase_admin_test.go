@@ -0,0 +1,139 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWebhookDispatcher struct {
+	dispatched map[int]string
+}
+
+func (d *fakeWebhookDispatcher) Dispatch(ctx context.Context, orderId int, status string) error {
+	d.dispatched[orderId] = status
+	return nil
+}
+
+func (d *fakeWebhookDispatcher) Resume(ctx context.Context) error {
+	return nil
+}
+
+func (d *fakeWebhookDispatcher) Close() {}
+
+// newAdminOrderUseCase returns an order use case on a memory repository
+// holding order 1, active, and order 2, delivered.
+func newAdminOrderUseCase(t *testing.T) (usecases.OrderUseCase, *fakeOrderNotify, *fakeWebhookDispatcher) {
+	ctx := context.Background()
+	repository := gateways.NewInMemoryOrderRepository()
+	require.NoError(t, repository.SaveOrder(ctx, models.Order{ID: "1", Status: models.OrderStatusInProgress, CreatedAt: time.Now()}))
+	require.NoError(t, repository.SaveOrder(ctx, models.Order{ID: "2", Status: models.OrderStatusDelivered, CreatedAt: time.Now()}))
+
+	orderNotify := &fakeOrderNotify{notified: map[int]string{}}
+	dispatcher := &fakeWebhookDispatcher{dispatched: map[int]string{}}
+	return usecases.NewOrderUseCase(repository, orderNotify, dispatcher, logger.NewNopLogger()), orderNotify, dispatcher
+}
+
+func TestOrderUseCase_GetAllOrders(t *testing.T) {
+	orderUseCase, _, _ := newAdminOrderUseCase(t)
+
+	orders, err := orderUseCase.GetAllOrders(context.Background(), models.OrderFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, orders, 2, "every page is read")
+
+	orders, err = orderUseCase.GetAllOrders(context.Background(), models.OrderFilter{Statuses: []string{models.OrderStatusCancelled}, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []models.Order{}, orders)
+}
+
+func TestOrderUseCase_ForceOrderStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		orderId     int
+		status      string
+		reason      string
+		actor       string
+		expectedErr error
+	}{
+		{name: "completed order", orderId: 2, status: models.OrderStatusReady, reason: "delivered by mistake", actor: "maria"},
+		{name: "unknown status", orderId: 2, status: "LOST", reason: "delivered by mistake", actor: "maria", expectedErr: usecases.ErrInvalidOrderStatus},
+		{name: "without reason", orderId: 2, status: models.OrderStatusReady, actor: "maria", expectedErr: usecases.ErrReasonRequired},
+		{name: "without actor", orderId: 2, status: models.OrderStatusReady, reason: "delivered by mistake", expectedErr: usecases.ErrActorRequired},
+		{name: "unknown order", orderId: 404, status: models.OrderStatusReady, reason: "typo", actor: "maria", expectedErr: gateways.ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderUseCase, orderNotify, dispatcher := newAdminOrderUseCase(t)
+
+			err := orderUseCase.ForceOrderStatus(context.Background(), tt.orderId, tt.status, tt.reason, tt.actor)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, orderNotify.notified)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, map[int]string{tt.orderId: tt.status}, orderNotify.notified)
+			assert.Equal(t, map[int]string{tt.orderId: tt.status}, dispatcher.dispatched)
+
+			order, err := orderUseCase.GetOrder(context.Background(), tt.orderId)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, order.Status)
+			assert.Equal(t, tt.reason, order.History[len(order.History)-1].Reason)
+			assert.Equal(t, tt.actor, order.History[len(order.History)-1].Actor)
+		})
+	}
+}
+
+func TestOrderUseCase_CancelOrder(t *testing.T) {
+	orderUseCase, orderNotify, _ := newAdminOrderUseCase(t)
+	ctx := context.Background()
+
+	err := orderUseCase.CancelOrder(ctx, 1, "", "maria")
+	assert.ErrorIs(t, err, usecases.ErrReasonRequired)
+
+	err = orderUseCase.CancelOrder(ctx, 1, "customer gave up", "")
+	assert.ErrorIs(t, err, usecases.ErrActorRequired)
+
+	err = orderUseCase.CancelOrder(ctx, 2, "customer gave up", "maria")
+	assert.ErrorIs(t, err, usecases.ErrOrderNotActive, "completed orders can only be forced")
+
+	err = orderUseCase.CancelOrder(ctx, 1, "customer gave up", "maria")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: models.OrderStatusCancelled}, orderNotify.notified)
+
+	order, err := orderUseCase.GetOrder(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	assert.Equal(t, models.OrderStatusChange{From: models.OrderStatusInProgress, To: models.OrderStatusCancelled, Reason: "customer gave up", Actor: "maria", ChangedAt: order.StatusChangedAt}, order.History[1])
+}
+
+func TestOrderUseCase_CancelOrder_NotifyFails(t *testing.T) {
+	orderUseCase, orderNotify, dispatcher := newAdminOrderUseCase(t)
+	orderNotify.err = errors.New("broker unavailable")
+
+	err := orderUseCase.CancelOrder(context.Background(), 1, "customer gave up", "maria")
+	assert.ErrorIs(t, err, orderNotify.err)
+	assert.Equal(t, map[int]string{1: models.OrderStatusCancelled}, dispatcher.dispatched, "webhooks do not depend on the broker")
+}
+
+func TestOrderUseCase_RenotifyOrder(t *testing.T) {
+	orderUseCase, orderNotify, dispatcher := newAdminOrderUseCase(t)
+
+	err := orderUseCase.RenotifyOrder(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{2: models.OrderStatusDelivered}, orderNotify.notified)
+	assert.Empty(t, dispatcher.dispatched, "webhooks are not delivered again")
+
+	err = orderUseCase.RenotifyOrder(context.Background(), 404)
+	assert.ErrorIs(t, err, gateways.ErrOrderNotFound)
+}
@@ -0,0 +1,245 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrReplayDisabled      = errors.New("no dead letter queue to replay from is configured")
+	ErrInvalidReplayWindow = errors.New("invalid replay window")
+
+	// errReplayDone hands a message back to the dead letter queue once it
+	// has been scanned entirely.
+	errReplayDone = errors.New("dead letter queue scanned")
+)
+
+type ReplayOptions struct {
+	// DeadLetterQueue is read from; DeadLetterDestination is where the
+	// messages left in it are published back.
+	DeadLetterQueue       string
+	DeadLetterDestination string
+	ReplayDestination     string
+	// IdleTimeout is how long the dead letter queue is waited on for a
+	// message before it is taken as empty.
+	IdleTimeout time.Duration
+}
+
+// ReplayUseCase reprocesses events after a fix: dead letters are moved
+// back to the work queue, and the status of orders is published again.
+type ReplayUseCase interface {
+	ReplayDeadLetters(ctx context.Context, request dto.DeadLetterReplayRequest) (dto.ReplayResult, error)
+	RepublishStatusEvents(ctx context.Context, request dto.StatusEventReplayRequest) (dto.ReplayResult, error)
+}
+
+type replayUseCase struct {
+	orderUseCase OrderUseCase
+	orderNotify  gateways.OrderNotify
+	openConsumer broker.ConsumerOpener
+	publisher    broker.Publisher
+	options      ReplayOptions
+	logger       logger.Logger
+}
+
+func NewReplayUseCase(orderUseCase OrderUseCase, orderNotify gateways.OrderNotify, openConsumer broker.ConsumerOpener, publisher broker.Publisher, options ReplayOptions, logger logger.Logger) ReplayUseCase {
+	return &replayUseCase{
+		orderUseCase: orderUseCase,
+		orderNotify:  orderNotify,
+		openConsumer: openConsumer,
+		publisher:    publisher,
+		options:      options,
+		logger:       logger,
+	}
+}
+
+// ReplayDeadLetters reads the dead letter queue until it is empty, or has
+// only messages already scanned. The matching messages are published to
+// the replay destination and the others back to the dead letter queue, so
+// with DryRun every message stays there.
+func (u *replayUseCase) ReplayDeadLetters(ctx context.Context, request dto.DeadLetterReplayRequest) (result dto.ReplayResult, err error) {
+	ctx, span := tracer.Start(ctx, "replayUseCase.ReplayDeadLetters")
+	span.SetAttributes(attribute.Bool("replay.dry_run", request.DryRun))
+	defer tracing.EndSpan(span, &err)
+
+	if u.options.DeadLetterQueue == "" {
+		return dto.ReplayResult{}, ErrReplayDisabled
+	}
+	if !request.From.IsZero() && !request.To.IsZero() && !request.To.After(request.From) {
+		return dto.ReplayResult{}, fmt.Errorf("%w: to must be after from", ErrInvalidReplayWindow)
+	}
+
+	consumer, closeConsumer, err := u.openConsumer(u.options.DeadLetterQueue)
+	if err != nil {
+		return dto.ReplayResult{}, fmt.Errorf("failed to open the dead letter queue [%s]: %w", u.options.DeadLetterQueue, err)
+	}
+	defer closeConsumer()
+
+	drainCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	idle := time.AfterFunc(u.options.IdleTimeout, stop)
+	defer idle.Stop()
+
+	result = dto.ReplayResult{DryRun: request.DryRun, Messages: []dto.ReplayedMessage{}}
+	var mu sync.Mutex
+	var publishErr error
+	kept := map[[sha256.Size]byte]bool{}
+
+	consumer.StartConsumer(drainCtx, func(_ context.Context, message []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		idle.Stop()
+		defer idle.Reset(u.options.IdleTimeout)
+
+		sum := sha256.Sum256(message)
+		if kept[sum] || drainCtx.Err() != nil {
+			stop()
+			return errReplayDone
+		}
+		result.Scanned++
+
+		// the message in hand is always published with ctx, so stopping the
+		// scan never leaves it half moved
+		replayed, matches := matchDeadLetter(message, request)
+		destination := u.options.DeadLetterDestination
+		if matches && !request.DryRun {
+			destination = u.options.ReplayDestination
+		}
+		err := u.publisher.Publish(ctx, destination, replayed.OrderID, message)
+		if err != nil {
+			publishErr = fmt.Errorf("failed to publish dead letter to [%s]: %w", destination, err)
+			stop()
+			return publishErr
+		}
+
+		if destination == u.options.DeadLetterDestination {
+			kept[sum] = true
+		}
+		if matches {
+			result.Messages = append(result.Messages, replayed)
+			if request.Limit > 0 && len(result.Messages) >= request.Limit {
+				stop()
+			}
+		}
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	span.SetAttributes(attribute.Int("replay.scanned", result.Scanned), attribute.Int("replay.matched", len(result.Messages)))
+	if publishErr != nil {
+		return result, publishErr
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	u.logger.WithContext(ctx).Infof("replayed [%d] of [%d] dead letters, dry run [%t]", len(result.Messages), result.Scanned, request.DryRun)
+	return result, nil
+}
+
+// RepublishStatusEvents publishes again the current status of the orders
+// whose status last changed in the window.
+func (u *replayUseCase) RepublishStatusEvents(ctx context.Context, request dto.StatusEventReplayRequest) (result dto.ReplayResult, err error) {
+	ctx, span := tracer.Start(ctx, "replayUseCase.RepublishStatusEvents")
+	span.SetAttributes(attribute.Bool("replay.dry_run", request.DryRun))
+	defer tracing.EndSpan(span, &err)
+
+	if request.From.IsZero() || request.To.IsZero() || !request.To.After(request.From) {
+		return dto.ReplayResult{}, fmt.Errorf("%w: from and to are required and to must be after from", ErrInvalidReplayWindow)
+	}
+
+	result = dto.ReplayResult{DryRun: request.DryRun, Messages: []dto.ReplayedMessage{}}
+	filter := models.OrderFilter{Statuses: request.Statuses, ChangedFrom: request.From, ChangedTo: request.To}
+	for {
+		page, err := u.orderUseCase.GetOrders(ctx, filter)
+		if err != nil {
+			return result, err
+		}
+
+		for _, order := range page.Results {
+			result.Scanned++
+			if !request.DryRun {
+				err = u.republish(ctx, order)
+				if err != nil {
+					return result, err
+				}
+			}
+			result.Messages = append(result.Messages, dto.ReplayedMessage{
+				OrderID:   order.ID,
+				EventType: fmt.Sprintf("%s.v%d", gateways.OrderStatusChangedEventType, gateways.OrderStatusChangedEventVersion),
+				Status:    order.Status,
+				Time:      order.StatusChangedAt,
+			})
+		}
+
+		if page.Next == "" {
+			break
+		}
+		filter.Cursor = page.Next
+	}
+
+	u.logger.WithContext(ctx).Infof("republished the status of [%d] of [%d] orders, dry run [%t]", len(result.Messages), result.Scanned, request.DryRun)
+	return result, nil
+}
+
+func (u *replayUseCase) republish(ctx context.Context, order models.Order) error {
+	orderId, err := strconv.Atoi(order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to republish order [%s]: %w", order.ID, err)
+	}
+	return u.orderNotify.NotifyOrder(ctx, orderId, order.Status)
+}
+
+// matchDeadLetter describes a dead letter and reports whether the request
+// selects it. Malformed messages are never replayed, they would only be
+// dead lettered again.
+func matchDeadLetter(message []byte, request dto.DeadLetterReplayRequest) (dto.ReplayedMessage, bool) {
+	event, err := broker.DecodeCloudEvent(message)
+	if errors.Is(err, broker.ErrNotCloudEvent) {
+		event, err = legacyOrderProductionEvent(message)
+	}
+	if err != nil {
+		return dto.ReplayedMessage{}, false
+	}
+
+	replayed := dto.ReplayedMessage{
+		OrderID:   OrderEventKey(message),
+		EventID:   event.ID,
+		EventType: event.Type,
+		Time:      event.Time,
+	}
+
+	eventType, _ := event.EventType()
+	if request.EventType != "" && request.EventType != eventType && request.EventType != event.Type {
+		return replayed, false
+	}
+	if request.OrderID != "" && request.OrderID != replayed.OrderID {
+		return replayed, false
+	}
+	if !request.From.IsZero() || !request.To.IsZero() {
+		if event.Time.IsZero() {
+			return replayed, false
+		}
+		if !request.From.IsZero() && event.Time.Before(request.From) {
+			return replayed, false
+		}
+		if !request.To.IsZero() && !event.Time.Before(request.To) {
+			return replayed, false
+		}
+	}
+
+	return replayed, true
+}
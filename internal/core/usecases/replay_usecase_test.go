@@ -0,0 +1,207 @@
+package usecases_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const ordersDeadLetterQueue = ordersQueue + ".dlq"
+
+type fakeOrderNotify struct {
+	mu       sync.Mutex
+	notified map[int]string
+	err      error
+}
+
+func (n *fakeOrderNotify) NotifyOrder(ctx context.Context, orderId int, status string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.notified[orderId] = status
+	return nil
+}
+
+// orderEnvelope wraps an event about orderId, which is the message key.
+func orderEnvelope(t *testing.T, eventType string, version int, orderId string) []byte {
+	event, err := broker.NewCloudEvent("/test", eventType, version, "", orderId, map[string]any{"id": orderId})
+	require.NoError(t, err)
+	message, err := json.Marshal(event)
+	require.NoError(t, err)
+	return message
+}
+
+// newReplayUseCase replays from a memory broker holding the dead letters of
+// the test: a legacy order, an order of an unknown version, an event of
+// another type and a malformed message.
+func newReplayUseCase(t *testing.T, orderUseCase usecases.OrderUseCase, orderNotify *fakeOrderNotify) (usecases.ReplayUseCase, broker.MemoryBroker) {
+	memoryBroker := broker.NewMemoryBroker()
+	t.Cleanup(func() { memoryBroker.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, memoryBroker.Publish(ctx, ordersDeadLetterQueue, "7", []byte(`{"id": 7, "status": "PAID", "items": []}`)))
+	require.NoError(t, memoryBroker.Publish(ctx, ordersDeadLetterQueue, "8", orderEnvelope(t, usecases.OrderProductionEventType, 2, "8")))
+	require.NoError(t, memoryBroker.Publish(ctx, ordersDeadLetterQueue, "9", orderEnvelope(t, "br.com.g73.order.cancelled", 1, "9")))
+	require.NoError(t, memoryBroker.Publish(ctx, ordersDeadLetterQueue, "", []byte("not json")))
+
+	openConsumer := func(queue string) (broker.Consumer, func() error, error) {
+		return memoryBroker.NewConsumer(queue, time.Second, logger.NewNopLogger()), func() error { return nil }, nil
+	}
+	options := usecases.ReplayOptions{
+		DeadLetterQueue:       ordersDeadLetterQueue,
+		DeadLetterDestination: ordersDeadLetterQueue,
+		ReplayDestination:     ordersQueue,
+		IdleTimeout:           100 * time.Millisecond,
+	}
+	return usecases.NewReplayUseCase(orderUseCase, orderNotify, openConsumer, memoryBroker, options, logger.NewNopLogger()), memoryBroker
+}
+
+func replayedOrderIDs(result dto.ReplayResult) []string {
+	ids := []string{}
+	for _, message := range result.Messages {
+		ids = append(ids, message.OrderID)
+	}
+	return ids
+}
+
+func TestReplayUseCase_ReplayDeadLetters(t *testing.T) {
+	tests := []struct {
+		name             string
+		request          dto.DeadLetterReplayRequest
+		expectedOrderIDs []string
+		expectedPending  int
+	}{
+		{
+			name:             "by event type",
+			request:          dto.DeadLetterReplayRequest{EventType: usecases.OrderProductionEventType},
+			expectedOrderIDs: []string{"7", "8"},
+			expectedPending:  2,
+		},
+		{
+			name:             "dry run",
+			request:          dto.DeadLetterReplayRequest{EventType: usecases.OrderProductionEventType, DryRun: true},
+			expectedOrderIDs: []string{"7", "8"},
+			expectedPending:  4,
+		},
+		{
+			name:             "by order and time, legacy payloads have no time",
+			request:          dto.DeadLetterReplayRequest{From: time.Now().Add(-time.Hour), OrderID: "9"},
+			expectedOrderIDs: []string{"9"},
+			expectedPending:  3,
+		},
+		{
+			name:             "every well formed message up to the limit",
+			request:          dto.DeadLetterReplayRequest{Limit: 2},
+			expectedOrderIDs: []string{"7", "8"},
+			expectedPending:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayUseCase, memoryBroker := newReplayUseCase(t, nil, nil)
+
+			result, err := replayUseCase.ReplayDeadLetters(context.Background(), tt.request)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.request.DryRun, result.DryRun)
+			assert.Equal(t, tt.expectedOrderIDs, replayedOrderIDs(result))
+			assert.Equal(t, tt.expectedPending, memoryBroker.Pending(ordersDeadLetterQueue))
+			if tt.request.DryRun {
+				assert.Equal(t, 0, memoryBroker.Pending(ordersQueue))
+			} else {
+				assert.Equal(t, len(tt.expectedOrderIDs), memoryBroker.Pending(ordersQueue))
+			}
+		})
+	}
+}
+
+func TestReplayUseCase_ReplayDeadLettersErrors(t *testing.T) {
+	replayUseCase := usecases.NewReplayUseCase(nil, nil, nil, nil, usecases.ReplayOptions{}, logger.NewNopLogger())
+	_, err := replayUseCase.ReplayDeadLetters(context.Background(), dto.DeadLetterReplayRequest{})
+	assert.ErrorIs(t, err, usecases.ErrReplayDisabled)
+
+	replayUseCase, _ = newReplayUseCase(t, nil, nil)
+	now := time.Now()
+	_, err = replayUseCase.ReplayDeadLetters(context.Background(), dto.DeadLetterReplayRequest{From: now, To: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, usecases.ErrInvalidReplayWindow)
+}
+
+func TestReplayUseCase_RepublishStatusEvents(t *testing.T) {
+	from := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	orders := []models.Order{
+		{ID: "2", Status: models.OrderStatusReady, StatusChangedAt: from},
+		{ID: "3", Status: models.OrderStatusDelivered, StatusChangedAt: from.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name             string
+		request          dto.StatusEventReplayRequest
+		notifyErr        error
+		expectedNotified map[int]string
+		expectedErr      error
+	}{
+		{
+			name:             "republishes the window",
+			request:          dto.StatusEventReplayRequest{From: from, To: to},
+			expectedNotified: map[int]string{2: models.OrderStatusReady, 3: models.OrderStatusDelivered},
+		},
+		{
+			name:             "dry run",
+			request:          dto.StatusEventReplayRequest{From: from, To: to, DryRun: true},
+			expectedNotified: map[int]string{},
+		},
+		{
+			name:             "publish error",
+			request:          dto.StatusEventReplayRequest{From: from, To: to},
+			notifyErr:        errors.New("broker unavailable"),
+			expectedNotified: map[int]string{},
+			expectedErr:      errors.New("broker unavailable"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+			// the window is filtered by the store
+			filter := models.OrderFilter{Statuses: tt.request.Statuses, ChangedFrom: from, ChangedTo: to}
+			orderUseCase.EXPECT().GetOrders(gomock.Any(), filter).Return(models.OrderPage{Results: orders[:1], Next: "page-2"}, nil)
+			filter.Cursor = "page-2"
+			orderUseCase.EXPECT().GetOrders(gomock.Any(), filter).Return(models.OrderPage{Results: orders[1:]}, nil).MaxTimes(1)
+			orderNotify := &fakeOrderNotify{notified: map[int]string{}, err: tt.notifyErr}
+			replayUseCase, _ := newReplayUseCase(t, orderUseCase, orderNotify)
+
+			result, err := replayUseCase.RepublishStatusEvents(context.Background(), tt.request)
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 2, result.Scanned)
+			assert.Equal(t, []string{"2", "3"}, replayedOrderIDs(result))
+			assert.Equal(t, tt.expectedNotified, orderNotify.notified)
+		})
+	}
+
+	replayUseCase, _ := newReplayUseCase(t, nil, nil)
+	_, err := replayUseCase.RepublishStatusEvents(context.Background(), dto.StatusEventReplayRequest{From: from})
+	assert.ErrorIs(t, err, usecases.ErrInvalidReplayWindow)
+}
@@ -0,0 +1,367 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// ReportStageTotal is the stage from the creation of an order to its
+	// delivery.
+	ReportStageTotal = "TOTAL"
+
+	reportDateLayout = "2006-01-02"
+	reportPageSize   = 100
+	// reportSyncOverlap is how long after its end a day stays partial, as
+	// an order completed just before the end may only be listed after it.
+	reportSyncOverlap = time.Minute
+	// reportCachedDays bounds the days kept in memory.
+	reportCachedDays = 31
+)
+
+var ErrInvalidReportDate = errors.New("invalid report date")
+
+// ReportUseCase reports the production of a day from the completed orders.
+type ReportUseCase interface {
+	// GetDailyReport reports the calendar day of date, today when it is
+	// zero, in the timezone of the reports.
+	GetDailyReport(ctx context.Context, date time.Time) (dto.DailyReport, error)
+}
+
+type reportUseCase struct {
+	orderUseCase OrderUseCase
+	location     *time.Location
+	logger       logger.Logger
+
+	// mu guards days, the syncs of a day are serialized by its own lock so
+	// the reports of other days are not held up by them
+	mu   sync.Mutex
+	days map[string]*reportDay
+}
+
+// reportDay is the state of a day kept between requests: an order listed
+// again unchanged is not folded again.
+type reportDay struct {
+	mu     sync.Mutex
+	orders map[string]reportOrder
+	// syncedUntil is when the day was last synced, zero before the first sync
+	syncedUntil time.Time
+	complete    bool
+}
+
+// reportOrder is what the report needs from a completed order.
+type reportOrder struct {
+	status    string
+	changedAt time.Time
+	hour      int
+	// products and itemTypes are the quantities ordered of each
+	products  map[string]int
+	itemTypes map[string]int
+	// stages are the seconds spent in each status, only for delivered orders
+	stages map[string]float64
+}
+
+func NewReportUseCase(orderUseCase OrderUseCase, location *time.Location, logger logger.Logger) ReportUseCase {
+	return &reportUseCase{
+		orderUseCase: orderUseCase,
+		location:     location,
+		logger:       logger,
+		days:         map[string]*reportDay{},
+	}
+}
+
+func (u *reportUseCase) GetDailyReport(ctx context.Context, date time.Time) (report dto.DailyReport, err error) {
+	ctx, span := tracer.Start(ctx, "reportUseCase.GetDailyReport")
+	defer tracing.EndSpan(span, &err)
+
+	now := time.Now().In(u.location)
+	if date.IsZero() {
+		date = now
+	}
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, u.location)
+	end := start.AddDate(0, 0, 1)
+	if start.After(now) {
+		return dto.DailyReport{}, fmt.Errorf("%w [%s]: the day has not started", ErrInvalidReportDate, start.Format(reportDateLayout))
+	}
+	span.SetAttributes(attribute.String("report.date", start.Format(reportDateLayout)))
+
+	u.mu.Lock()
+	day := u.day(start.Format(reportDateLayout))
+	u.mu.Unlock()
+
+	day.mu.Lock()
+	defer day.mu.Unlock()
+
+	err = u.sync(ctx, day, start, end, now)
+	if err != nil {
+		return dto.DailyReport{}, err
+	}
+
+	return buildDailyReport(start, u.location, day), nil
+}
+
+// day returns the cached state of the day, evicting the oldest day cached
+// when there are too many.
+func (u *reportUseCase) day(key string) *reportDay {
+	day, ok := u.days[key]
+	if ok {
+		return day
+	}
+
+	if len(u.days) >= reportCachedDays {
+		oldest := ""
+		for cached := range u.days {
+			if oldest == "" || cached < oldest {
+				oldest = cached
+			}
+		}
+		delete(u.days, oldest)
+	}
+
+	day = &reportDay{orders: map[string]reportOrder{}}
+	u.days[key] = day
+	return day
+}
+
+// sync folds the orders completed in the day that are new or changed since
+// the last sync. The first sync lists the whole day, the next ones only the
+// orders changed since the last, in any status, so the ones moved out of
+// DELIVERED and CANCELLED or completed again on another day are dropped. A
+// complete day is not synced again.
+func (u *reportUseCase) sync(ctx context.Context, day *reportDay, start, end, now time.Time) error {
+	if day.complete {
+		return nil
+	}
+
+	filter := models.OrderFilter{
+		Statuses:    []string{models.OrderStatusDelivered, models.OrderStatusCancelled},
+		ChangedFrom: start,
+		ChangedTo:   end,
+		Limit:       reportPageSize,
+		WithHistory: true,
+	}
+	if now.Before(end) {
+		filter.ChangedTo = now
+	}
+	if !day.syncedUntil.IsZero() {
+		filter.Statuses = models.OrderStatuses
+		filter.ChangedFrom = day.syncedUntil.Add(-reportSyncOverlap)
+		filter.ChangedTo = now
+	}
+
+	folded, dropped := 0, 0
+	for {
+		page, err := u.orderUseCase.GetOrders(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, order := range page.Results {
+			cached, ok := day.orders[order.ID]
+			if !completedIn(order, start, end) {
+				if ok {
+					delete(day.orders, order.ID)
+					dropped++
+				}
+				continue
+			}
+			if ok && cached.status == order.Status && cached.changedAt.Equal(order.StatusChangedAt) {
+				continue
+			}
+			day.orders[order.ID] = newReportOrder(order, u.location)
+			folded++
+		}
+
+		if page.Next == "" {
+			break
+		}
+		filter.Cursor = page.Next
+	}
+
+	day.syncedUntil = now
+	day.complete = !now.Before(end.Add(reportSyncOverlap))
+	u.logger.WithContext(ctx).Debugf("report of [%s] synced, [%d] orders folded, [%d] dropped, complete [%t]", start.Format(reportDateLayout), folded, dropped, day.complete)
+	return nil
+}
+
+// completedIn reports whether the order was delivered or cancelled between
+// start and end.
+func completedIn(order models.Order, start, end time.Time) bool {
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusCancelled {
+		return false
+	}
+	return !order.StatusChangedAt.Before(start) && order.StatusChangedAt.Before(end)
+}
+
+func newReportOrder(order models.Order, location *time.Location) reportOrder {
+	folded := reportOrder{
+		status:    order.Status,
+		changedAt: order.StatusChangedAt,
+		hour:      order.StatusChangedAt.In(location).Hour(),
+		products:  map[string]int{},
+		itemTypes: map[string]int{},
+		stages:    map[string]float64{},
+	}
+	for _, item := range order.Items {
+		folded.products[item.Product.Name] += item.Quantity
+		folded.itemTypes[item.Type] += item.Quantity
+	}
+	if order.Status != models.OrderStatusDelivered {
+		return folded
+	}
+
+	history := make([]models.OrderStatusChange, len(order.History))
+	copy(history, order.History)
+	sort.SliceStable(history, func(i, j int) bool { return history[i].ChangedAt.Before(history[j].ChangedAt) })
+	for i := 0; i+1 < len(history); i++ {
+		folded.stages[history[i].To] += history[i+1].ChangedAt.Sub(history[i].ChangedAt).Seconds()
+	}
+	folded.stages[ReportStageTotal] = order.StatusChangedAt.Sub(order.CreatedAt).Seconds()
+
+	return folded
+}
+
+// statsBuilder accumulates the orders of a group of the report.
+type statsBuilder struct {
+	stats   dto.ReportStats
+	samples map[string][]float64
+}
+
+func (b *statsBuilder) add(order reportOrder, items int) {
+	if b.samples == nil {
+		b.samples = map[string][]float64{}
+	}
+	b.stats.Orders++
+	b.stats.Items += items
+	switch order.status {
+	case models.OrderStatusDelivered:
+		b.stats.Delivered++
+	case models.OrderStatusCancelled:
+		b.stats.Cancelled++
+	}
+	for stage, seconds := range order.stages {
+		b.samples[stage] = append(b.samples[stage], seconds)
+	}
+}
+
+func (b *statsBuilder) build() dto.ReportStats {
+	stats := b.stats
+	stats.Stages = map[string]dto.StageStats{}
+	stages := make([]string, 0, len(b.samples))
+	for stage := range b.samples {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	slowest := 0.0
+	for _, stage := range stages {
+		samples := b.samples[stage]
+		sort.Float64s(samples)
+		sum := 0.0
+		for _, seconds := range samples {
+			sum += seconds
+		}
+		// nearest rank
+		rank := int(math.Ceil(0.9*float64(len(samples)))) - 1
+		stageStats := dto.StageStats{
+			Count:      len(samples),
+			AvgSeconds: sum / float64(len(samples)),
+			P90Seconds: samples[rank],
+		}
+		stats.Stages[stage] = stageStats
+
+		if stage == ReportStageTotal {
+			continue
+		}
+		if stats.Bottleneck == "" || stageStats.AvgSeconds > slowest {
+			slowest = stageStats.AvgSeconds
+			stats.Bottleneck = stage
+		}
+	}
+	return stats
+}
+
+type breakdownBuilder struct {
+	all       statsBuilder
+	products  map[string]*statsBuilder
+	itemTypes map[string]*statsBuilder
+}
+
+func newBreakdownBuilder() *breakdownBuilder {
+	return &breakdownBuilder{products: map[string]*statsBuilder{}, itemTypes: map[string]*statsBuilder{}}
+}
+
+func (b *breakdownBuilder) add(order reportOrder) {
+	items := 0
+	for name, quantity := range order.products {
+		items += quantity
+		addToGroup(b.products, name, order, quantity)
+	}
+	for itemType, quantity := range order.itemTypes {
+		addToGroup(b.itemTypes, itemType, order, quantity)
+	}
+	b.all.add(order, items)
+}
+
+func addToGroup(groups map[string]*statsBuilder, name string, order reportOrder, items int) {
+	group, ok := groups[name]
+	if !ok {
+		group = &statsBuilder{}
+		groups[name] = group
+	}
+	group.add(order, items)
+}
+
+func (b *breakdownBuilder) build() dto.ReportBreakdown {
+	breakdown := dto.ReportBreakdown{
+		ReportStats: b.all.build(),
+		Products:    map[string]dto.ReportStats{},
+		ItemTypes:   map[string]dto.ReportStats{},
+	}
+	for name, group := range b.products {
+		breakdown.Products[name] = group.build()
+	}
+	for itemType, group := range b.itemTypes {
+		breakdown.ItemTypes[itemType] = group.build()
+	}
+	return breakdown
+}
+
+func buildDailyReport(start time.Time, location *time.Location, day *reportDay) dto.DailyReport {
+	summary := newBreakdownBuilder()
+	hours := map[int]*breakdownBuilder{}
+	for _, order := range day.orders {
+		summary.add(order)
+		hour, ok := hours[order.hour]
+		if !ok {
+			hour = newBreakdownBuilder()
+			hours[order.hour] = hour
+		}
+		hour.add(order)
+	}
+
+	report := dto.DailyReport{
+		Date:     start.Format(reportDateLayout),
+		Timezone: location.String(),
+		Partial:  !day.complete,
+		Summary:  summary.build(),
+		Hours:    []dto.HourlyReport{},
+	}
+	for hour, builder := range hours {
+		report.Hours = append(report.Hours, dto.HourlyReport{Hour: hour, ReportBreakdown: builder.build()})
+	}
+	sort.Slice(report.Hours, func(i, j int) bool { return report.Hours[i].Hour < report.Hours[j].Hour })
+
+	return report
+}
@@ -0,0 +1,207 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	mock_usecases "github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/mocks"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var reportLocation = time.FixedZone("BRT", -3*60*60)
+
+// reportTime is a time of 2024-03-10 in the timezone of the reports.
+func reportTime(hour, minute int) time.Time {
+	return time.Date(2024, 3, 10, hour, minute, 0, 0, reportLocation).UTC()
+}
+
+// completedOrder went through statuses, each at the given time, the last
+// being the time it was completed.
+func completedOrder(id string, items []models.OrderItem, statuses []string, times []time.Time) models.Order {
+	order := models.Order{
+		ID:              id,
+		Status:          statuses[len(statuses)-1],
+		CreatedAt:       times[0],
+		StatusChangedAt: times[len(times)-1],
+		Items:           items,
+	}
+	for i, status := range statuses {
+		order.History = append(order.History, models.OrderStatusChange{To: status, ChangedAt: times[i]})
+	}
+	return order
+}
+
+func reportItem(quantity int, itemType, name string) models.OrderItem {
+	return models.OrderItem{Quantity: quantity, Type: itemType, Product: models.Product{Name: name}}
+}
+
+func TestReportUseCase_GetDailyReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	reportUseCase := usecases.NewReportUseCase(orderUseCase, reportLocation, logger.NewNopLogger())
+
+	flow := []string{models.OrderStatusReceived, models.OrderStatusInProgress, models.OrderStatusReady, models.OrderStatusDelivered}
+	orders := []models.Order{
+		completedOrder("1", []models.OrderItem{reportItem(2, "LANCHE", "X-Burger"), reportItem(1, "BEBIDA", "Coca")}, flow,
+			[]time.Time{reportTime(10, 0), reportTime(10, 5), reportTime(10, 20), reportTime(10, 30)}),
+		completedOrder("2", []models.OrderItem{reportItem(1, "LANCHE", "X-Burger")}, flow,
+			[]time.Time{reportTime(10, 40), reportTime(10, 42), reportTime(10, 47), reportTime(10, 50)}),
+		completedOrder("3", []models.OrderItem{reportItem(1, "BEBIDA", "Coca")}, []string{models.OrderStatusReceived, models.OrderStatusCancelled},
+			[]time.Time{reportTime(14, 0), reportTime(14, 10)}),
+	}
+
+	filter := models.OrderFilter{
+		Statuses:    []string{models.OrderStatusDelivered, models.OrderStatusCancelled},
+		ChangedFrom: time.Date(2024, 3, 10, 0, 0, 0, 0, reportLocation),
+		ChangedTo:   time.Date(2024, 3, 11, 0, 0, 0, 0, reportLocation),
+		Limit:       100,
+		WithHistory: true,
+	}
+	secondPage := filter
+	secondPage.Cursor = "page-2"
+	gomock.InOrder(
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), filter).Return(models.OrderPage{Results: []models.Order{orders[0], orders[1]}, Next: "page-2"}, nil),
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), secondPage).Return(models.OrderPage{Results: []models.Order{orders[2]}}, nil),
+	)
+
+	report, err := reportUseCase.GetDailyReport(context.Background(), time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, "2024-03-10", report.Date)
+	assert.Equal(t, "BRT", report.Timezone)
+	assert.False(t, report.Partial)
+
+	summary := report.Summary
+	assert.Equal(t, 3, summary.Orders)
+	assert.Equal(t, 2, summary.Delivered)
+	assert.Equal(t, 1, summary.Cancelled)
+	assert.Equal(t, 5, summary.Items)
+	assert.Equal(t, models.OrderStatusInProgress, summary.Bottleneck)
+	assert.Equal(t, map[string]dto.StageStats{
+		models.OrderStatusReceived:   {Count: 2, AvgSeconds: 210, P90Seconds: 300},
+		models.OrderStatusInProgress: {Count: 2, AvgSeconds: 600, P90Seconds: 900},
+		models.OrderStatusReady:      {Count: 2, AvgSeconds: 390, P90Seconds: 600},
+		usecases.ReportStageTotal:    {Count: 2, AvgSeconds: 1200, P90Seconds: 1800},
+	}, summary.Stages)
+
+	assert.Equal(t, 3, summary.Products["X-Burger"].Items)
+	assert.Equal(t, 2, summary.Products["Coca"].Orders)
+	assert.Equal(t, 1, summary.Products["Coca"].Cancelled)
+	assert.Equal(t, dto.StageStats{Count: 1, AvgSeconds: 1800, P90Seconds: 1800}, summary.Products["Coca"].Stages[usecases.ReportStageTotal])
+	assert.Equal(t, 2, summary.ItemTypes["LANCHE"].Delivered)
+
+	require.Len(t, report.Hours, 2)
+	assert.Equal(t, 10, report.Hours[0].Hour)
+	assert.Equal(t, 2, report.Hours[0].Delivered)
+	assert.Equal(t, 14, report.Hours[1].Hour)
+	assert.Equal(t, 1, report.Hours[1].Cancelled)
+	assert.Empty(t, report.Hours[1].Stages)
+
+	// a complete day is not listed again
+	again, err := reportUseCase.GetDailyReport(context.Background(), time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, report, again)
+}
+
+func TestReportUseCase_GetDailyReport_Today(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	reportUseCase := usecases.NewReportUseCase(orderUseCase, reportLocation, logger.NewNopLogger())
+
+	now := time.Now()
+	first := models.Order{ID: "1", Status: models.OrderStatusCancelled, CreatedAt: now, StatusChangedAt: now}
+	second := models.Order{ID: "2", Status: models.OrderStatusCancelled, CreatedAt: now, StatusChangedAt: now}
+	third := models.Order{ID: "3", Status: models.OrderStatusCancelled, CreatedAt: now, StatusChangedAt: now}
+	// forced back to another status since the first sync
+	reopened := models.Order{ID: "1", Status: models.OrderStatusReady, CreatedAt: now, StatusChangedAt: now.Add(time.Second)}
+
+	var filters []models.OrderFilter
+	gomock.InOrder(
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+			filters = append(filters, filter)
+			return models.OrderPage{Results: []models.Order{first, second}}, nil
+		}),
+		orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+			filters = append(filters, filter)
+			return models.OrderPage{Results: []models.Order{reopened, third}}, nil
+		}),
+	)
+
+	report, err := reportUseCase.GetDailyReport(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, report.Partial)
+	assert.Equal(t, 2, report.Summary.Orders)
+
+	report, err = reportUseCase.GetDailyReport(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.True(t, report.Partial)
+	assert.Equal(t, 2, report.Summary.Cancelled)
+
+	// the whole day is listed first, then only the orders changed since, in any status
+	require.Len(t, filters, 2)
+	assert.Equal(t, []string{models.OrderStatusDelivered, models.OrderStatusCancelled}, filters[0].Statuses)
+	assert.Equal(t, models.OrderStatuses, filters[1].Statuses)
+	assert.Equal(t, filters[0].ChangedTo.Add(-time.Minute), filters[1].ChangedFrom)
+	assert.False(t, filters[1].ChangedTo.Before(filters[0].ChangedTo))
+	assert.True(t, filters[1].WithHistory)
+}
+
+func TestReportUseCase_GetDailyReport_DaysInParallel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	reportUseCase := usecases.NewReportUseCase(orderUseCase, reportLocation, logger.NewNopLogger())
+
+	slowDay := time.Date(2024, 3, 10, 0, 0, 0, 0, reportLocation)
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+		if filter.ChangedFrom.Equal(slowDay) {
+			close(listing)
+			<-release
+		}
+		return models.OrderPage{}, nil
+	}).Times(2)
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := reportUseCase.GetDailyReport(context.Background(), slowDay)
+		slowDone <- err
+	}()
+	<-listing
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := reportUseCase.GetDailyReport(context.Background(), slowDay.AddDate(0, 0, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the report of another day waited for the listing of the slow one")
+	}
+
+	close(release)
+	assert.NoError(t, <-slowDone)
+}
+
+func TestReportUseCase_GetDailyReport_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orderUseCase := mock_usecases.NewMockOrderUseCase(ctrl)
+	reportUseCase := usecases.NewReportUseCase(orderUseCase, reportLocation, logger.NewNopLogger())
+
+	_, err := reportUseCase.GetDailyReport(context.Background(), time.Now().AddDate(0, 0, 2))
+	assert.ErrorIs(t, err, usecases.ErrInvalidReportDate)
+
+	orderUseCase.EXPECT().GetOrders(gomock.Any(), gomock.Any()).Return(models.OrderPage{}, errors.New("internal error"))
+	_, err = reportUseCase.GetDailyReport(context.Background(), time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	assert.EqualError(t, err, "internal error")
+}
@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/usecases/dto"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WebhookUseCase manages the webhook subscriptions of the partners and
+// exposes their delivery log.
+type WebhookUseCase interface {
+	CreateSubscription(ctx context.Context, request dto.WebhookSubscriptionRequest) (models.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+}
+
+type webhookUseCase struct {
+	webhookRepository gateways.WebhookRepository
+	logger            logger.Logger
+}
+
+func NewWebhookUseCase(webhookRepository gateways.WebhookRepository, logger logger.Logger) WebhookUseCase {
+	return &webhookUseCase{
+		webhookRepository: webhookRepository,
+		logger:            logger,
+	}
+}
+
+func (w *webhookUseCase) CreateSubscription(ctx context.Context, request dto.WebhookSubscriptionRequest) (subscription models.WebhookSubscription, err error) {
+	ctx, span := tracer.Start(ctx, "webhookUseCase.CreateSubscription")
+	defer tracing.EndSpan(span, &err)
+
+	subscription = models.WebhookSubscription{
+		ID:        uuid.NewString(),
+		URL:       request.URL,
+		Events:    request.Events,
+		Secret:    request.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	span.SetAttributes(attribute.String("webhook.id", subscription.ID))
+
+	err = w.webhookRepository.SaveSubscription(ctx, subscription)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	w.logger.WithContext(ctx).Infof("webhook subscription [%s] created for events %v", subscription.ID, subscription.Events)
+
+	return subscription, nil
+}
+
+func (w *webhookUseCase) GetSubscriptions(ctx context.Context) (subscriptions []models.WebhookSubscription, err error) {
+	ctx, span := tracer.Start(ctx, "webhookUseCase.GetSubscriptions")
+	defer tracing.EndSpan(span, &err)
+
+	return w.webhookRepository.GetSubscriptions(ctx)
+}
+
+func (w *webhookUseCase) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "webhookUseCase.DeleteSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+
+	err = w.webhookRepository.DeleteSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	w.logger.WithContext(ctx).Infof("webhook subscription [%s] deleted", id)
+
+	return nil
+}
+
+// GetDeliveries returns ErrWebhookNotFound when the subscription of the
+// filter does not exist, rather than an empty log.
+func (w *webhookUseCase) GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (deliveries []models.WebhookDelivery, err error) {
+	ctx, span := tracer.Start(ctx, "webhookUseCase.GetDeliveries")
+	span.SetAttributes(attribute.String("webhook.id", filter.SubscriptionID))
+	defer tracing.EndSpan(span, &err)
+
+	_, err = w.webhookRepository.GetSubscription(ctx, filter.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.webhookRepository.GetDeliveries(ctx, filter)
+}
@@ -0,0 +1,33 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAMQPHeadersCarrier(t *testing.T) {
+	propagator := propagation.TraceContext{}
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03},
+		SpanID:     trace.SpanID{0x04, 0x05},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	headers := amqp.Table{}
+	propagator.Inject(ctx, amqpHeadersCarrier(headers))
+	assert.Contains(t, headers, "traceparent")
+
+	// headers may arrive as raw bytes depending on the publisher
+	headers["traceparent"] = []byte(headers["traceparent"].(string))
+
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), amqpHeadersCarrier(headers)))
+	assert.Equal(t, spanContext.TraceID(), extracted.TraceID())
+	assert.Equal(t, spanContext.SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
@@ -0,0 +1,317 @@
+package broker
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// fakeAWS is a local stand-in for SQS (JSON protocol) and SNS (query
+// protocol), reached by the real SDK clients through BaseEndpoint.
+// Publishing to a topic fans out to the queues subscribed to it.
+type fakeAWS struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	queues        map[string][]*fakeSQSMessage
+	subscriptions map[string][]fakeSubscription
+	published     []fakeSNSPublish
+	extensions    int
+	nextID        int
+}
+
+type fakeSubscription struct {
+	queue string
+	raw   bool
+}
+
+type fakeSQSMessage struct {
+	id            string
+	body          string
+	attributes    map[string]string
+	receiptHandle string
+	visibleAt     time.Time
+	receiveCount  int
+	deleted       bool
+}
+
+type fakeSNSPublish struct {
+	topicArn       string
+	message        string
+	messageGroupID string
+	attributes     map[string]string
+}
+
+func newFakeAWS(t *testing.T) *fakeAWS {
+	f := &fakeAWS{
+		queues:        map[string][]*fakeSQSMessage{},
+		subscriptions: map[string][]fakeSubscription{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeAWS) sqsClient() *sqs.Client {
+	return sqs.NewFromConfig(f.config(), func(o *sqs.Options) {
+		o.BaseEndpoint = aws.String(f.server.URL)
+	})
+}
+
+func (f *fakeAWS) snsClient() *sns.Client {
+	return sns.NewFromConfig(f.config(), func(o *sns.Options) {
+		o.BaseEndpoint = aws.String(f.server.URL)
+	})
+}
+
+func (f *fakeAWS) config() aws.Config {
+	return aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("test", "test", ""),
+	}
+}
+
+func (f *fakeAWS) createQueue(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queues[name] = []*fakeSQSMessage{}
+}
+
+func (f *fakeAWS) subscribe(topicArn string, queue string, raw bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscriptions[topicArn] = append(f.subscriptions[topicArn], fakeSubscription{queue: queue, raw: raw})
+}
+
+func (f *fakeAWS) send(queue string, body string, attributes map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sendLocked(queue, body, attributes)
+}
+
+func (f *fakeAWS) sendLocked(queue string, body string, attributes map[string]string) {
+	f.nextID++
+	f.queues[queue] = append(f.queues[queue], &fakeSQSMessage{
+		id:         fmt.Sprintf("msg-%d", f.nextID),
+		body:       body,
+		attributes: attributes,
+	})
+}
+
+// remaining counts the messages of queue not deleted yet.
+func (f *fakeAWS) remaining(queue string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, msg := range f.queues[queue] {
+		if !msg.deleted {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeAWS) receiveCount(queue string, index int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queues[queue][index].receiveCount
+}
+
+func (f *fakeAWS) visibilityExtensions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.extensions
+}
+
+func (f *fakeAWS) publishes() []fakeSNSPublish {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeSNSPublish(nil), f.published...)
+}
+
+func (f *fakeAWS) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	if strings.HasPrefix(target, "AmazonSQS.") {
+		f.serveSQS(w, r, strings.TrimPrefix(target, "AmazonSQS."))
+		return
+	}
+	f.serveSNS(w, r)
+}
+
+func (f *fakeAWS) serveSQS(w http.ResponseWriter, r *http.Request, operation string) {
+	var input struct {
+		QueueName           string
+		QueueUrl            string
+		ReceiptHandle       string
+		MaxNumberOfMessages int
+		WaitTimeSeconds     int
+		VisibilityTimeout   int
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeSQSError(w, "InvalidParameterValue", err.Error())
+		return
+	}
+	queue := input.QueueName
+	if input.QueueUrl != "" {
+		queue = input.QueueUrl[strings.LastIndex(input.QueueUrl, "/")+1:]
+	}
+
+	f.mu.Lock()
+	_, exists := f.queues[queue]
+	f.mu.Unlock()
+	if !exists {
+		writeSQSError(w, "QueueDoesNotExist", "the queue does not exist")
+		return
+	}
+
+	switch operation {
+	case "GetQueueUrl":
+		writeSQSResponse(w, map[string]any{"QueueUrl": f.server.URL + "/000000000000/" + queue})
+	case "ReceiveMessage":
+		writeSQSResponse(w, map[string]any{"Messages": f.receive(r.Context(), queue, input.MaxNumberOfMessages, input.WaitTimeSeconds, input.VisibilityTimeout)})
+	case "DeleteMessage":
+		f.withMessage(queue, input.ReceiptHandle, func(msg *fakeSQSMessage) { msg.deleted = true })
+		writeSQSResponse(w, map[string]any{})
+	case "ChangeMessageVisibility":
+		f.withMessage(queue, input.ReceiptHandle, func(msg *fakeSQSMessage) {
+			if input.VisibilityTimeout > 0 {
+				f.extensions++
+			}
+			msg.visibleAt = time.Now().Add(time.Duration(input.VisibilityTimeout) * time.Second)
+		})
+		writeSQSResponse(w, map[string]any{})
+	default:
+		writeSQSError(w, "InvalidAction", operation)
+	}
+}
+
+// receive long polls for up to waitSeconds until a message is visible.
+func (f *fakeAWS) receive(ctx context.Context, queue string, max int, waitSeconds int, visibilitySeconds int) []map[string]any {
+	deadline := time.Now().Add(time.Duration(waitSeconds) * time.Second)
+	for {
+		messages := f.takeVisible(queue, max, visibilitySeconds)
+		if len(messages) > 0 || time.Now().After(deadline) {
+			return messages
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (f *fakeAWS) takeVisible(queue string, max int, visibilitySeconds int) []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	messages := []map[string]any{}
+	for _, msg := range f.queues[queue] {
+		if len(messages) == max {
+			break
+		}
+		if msg.deleted || now.Before(msg.visibleAt) {
+			continue
+		}
+
+		msg.receiveCount++
+		msg.receiptHandle = fmt.Sprintf("%s-%d", msg.id, msg.receiveCount)
+		msg.visibleAt = now.Add(time.Duration(visibilitySeconds) * time.Second)
+
+		checksum := md5.Sum([]byte(msg.body))
+		attributes := map[string]any{}
+		for name, value := range msg.attributes {
+			attributes[name] = map[string]string{"DataType": "String", "StringValue": value}
+		}
+		messages = append(messages, map[string]any{
+			"MessageId":         msg.id,
+			"ReceiptHandle":     msg.receiptHandle,
+			"Body":              msg.body,
+			"MD5OfBody":         hex.EncodeToString(checksum[:]),
+			"MessageAttributes": attributes,
+			"Attributes":        map[string]string{"ApproximateReceiveCount": fmt.Sprint(msg.receiveCount)},
+		})
+	}
+	return messages
+}
+
+func (f *fakeAWS) withMessage(queue string, receiptHandle string, update func(msg *fakeSQSMessage)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range f.queues[queue] {
+		if msg.receiptHandle == receiptHandle {
+			update(msg)
+		}
+	}
+}
+
+func writeSQSResponse(w http.ResponseWriter, output any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(output)
+}
+
+func writeSQSError(w http.ResponseWriter, code string, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.sqs#" + code, "message": message})
+}
+
+func (f *fakeAWS) serveSNS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "Publish" {
+		http.Error(w, "unsupported action", http.StatusBadRequest)
+		return
+	}
+
+	publish := fakeSNSPublish{
+		topicArn:       r.Form.Get("TopicArn"),
+		message:        r.Form.Get("Message"),
+		messageGroupID: r.Form.Get("MessageGroupId"),
+		attributes:     map[string]string{},
+	}
+	for i := 1; r.Form.Has(fmt.Sprintf("MessageAttributes.entry.%d.Name", i)); i++ {
+		prefix := fmt.Sprintf("MessageAttributes.entry.%d.", i)
+		publish.attributes[r.Form.Get(prefix+"Name")] = r.Form.Get(prefix + "Value.StringValue")
+	}
+
+	f.mu.Lock()
+	f.published = append(f.published, publish)
+	f.nextID++
+	messageID := fmt.Sprintf("sns-%d", f.nextID)
+	for _, subscription := range f.subscriptions[publish.topicArn] {
+		if subscription.raw {
+			f.sendLocked(subscription.queue, publish.message, publish.attributes)
+			continue
+		}
+
+		envelopeAttributes := map[string]map[string]string{}
+		for name, value := range publish.attributes {
+			envelopeAttributes[name] = map[string]string{"Type": "String", "Value": value}
+		}
+		envelope, _ := json.Marshal(map[string]any{
+			"Type":              "Notification",
+			"MessageId":         messageID,
+			"TopicArn":          publish.topicArn,
+			"Message":           publish.message,
+			"MessageAttributes": envelopeAttributes,
+		})
+		f.sendLocked(subscription.queue, string(envelope), nil)
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<PublishResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/"><PublishResult><MessageId>%s</MessageId></PublishResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></PublishResponse>`, messageID)
+}
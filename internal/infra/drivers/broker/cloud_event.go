@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CloudEventsSpecVersion is the only version of the CloudEvents spec the
+// envelope is read and written in.
+const CloudEventsSpecVersion = "1.0"
+
+// ErrNotCloudEvent is returned by DecodeCloudEvent for messages published
+// before the envelope, which are bare event payloads.
+var ErrNotCloudEvent = errors.New("message is not a cloud event")
+
+// CloudEvent is the structured JSON mode envelope of the CloudEvents spec.
+// The version of the payload is the ".vN" suffix of Type, so consumers can
+// route each version to its own handler.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewCloudEvent wraps data, marshalled to JSON, in an envelope of type
+// "<eventType>.v<version>" with a new ID.
+func NewCloudEvent(source string, eventType string, version int, dataSchema string, subject string, data any) (CloudEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              uuid.NewString(),
+		Source:          source,
+		Type:            fmt.Sprintf("%s.v%d", eventType, version),
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		DataSchema:      dataSchema,
+		Data:            payload,
+	}, nil
+}
+
+// DecodeCloudEvent reads an enveloped message. Messages without a
+// specversion attribute are reported with ErrNotCloudEvent.
+func DecodeCloudEvent(message []byte) (CloudEvent, error) {
+	// legacy payloads may use the attribute names with other types, so the
+	// envelope is only decoded once specversion shows it is one
+	var probe struct {
+		SpecVersion *string `json:"specversion"`
+	}
+	err := json.Unmarshal(message, &probe)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	if probe.SpecVersion == nil {
+		return CloudEvent{}, ErrNotCloudEvent
+	}
+	if *probe.SpecVersion != CloudEventsSpecVersion {
+		return CloudEvent{}, fmt.Errorf("unsupported cloud events specversion [%s]", *probe.SpecVersion)
+	}
+
+	event := CloudEvent{}
+	err = json.Unmarshal(message, &event)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to unmarshal cloud event: %w", err)
+	}
+	if event.ID == "" || event.Source == "" || event.Type == "" {
+		return CloudEvent{}, errors.New("cloud event is missing one of id, source or type")
+	}
+
+	return event, nil
+}
+
+// EventType splits Type into the event name and the payload version. Types
+// without a version suffix are version 1.
+func (e CloudEvent) EventType() (string, int) {
+	index := strings.LastIndex(e.Type, ".v")
+	if index < 0 {
+		return e.Type, 1
+	}
+	version, err := strconv.Atoi(e.Type[index+2:])
+	if err != nil || version < 1 {
+		return e.Type, 1
+	}
+	return e.Type[:index], version
+}
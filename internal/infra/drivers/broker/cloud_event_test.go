@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEvent_RoundTrip(t *testing.T) {
+	event, err := NewCloudEvent("/production", "br.com.g73.order.status_changed", 2, "https://schemas/status", "42", map[string]any{"orderId": 42})
+	require.NoError(t, err)
+
+	message, err := json.Marshal(event)
+	require.NoError(t, err)
+	decoded, err := DecodeCloudEvent(message)
+	require.NoError(t, err)
+
+	assert.Equal(t, CloudEventsSpecVersion, decoded.SpecVersion)
+	assert.NotEmpty(t, decoded.ID)
+	assert.Equal(t, "/production", decoded.Source)
+	assert.Equal(t, "br.com.g73.order.status_changed.v2", decoded.Type)
+	assert.Equal(t, "42", decoded.Subject)
+	assert.Equal(t, "https://schemas/status", decoded.DataSchema)
+	assert.JSONEq(t, `{"orderId":42}`, string(decoded.Data))
+
+	eventType, version := decoded.EventType()
+	assert.Equal(t, "br.com.g73.order.status_changed", eventType)
+	assert.Equal(t, 2, version)
+}
+
+func TestDecodeCloudEvent(t *testing.T) {
+	tests := []struct {
+		name          string
+		message       string
+		expectedError string
+		notCloudEvent bool
+	}{
+		{
+			name:          "legacy payload",
+			message:       `{"id": 7, "status": "PAID"}`,
+			notCloudEvent: true,
+		},
+		{
+			name:          "unsupported spec version",
+			message:       `{"specversion": "0.3", "id": "1", "source": "/order", "type": "order.v1"}`,
+			expectedError: "unsupported cloud events specversion [0.3]",
+		},
+		{
+			name:          "missing type",
+			message:       `{"specversion": "1.0", "id": "1", "source": "/order"}`,
+			expectedError: "cloud event is missing one of id, source or type",
+		},
+		{
+			name:          "not json",
+			message:       `order 7`,
+			expectedError: "failed to unmarshal message",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeCloudEvent([]byte(test.message))
+
+			if test.notCloudEvent {
+				assert.ErrorIs(t, err, ErrNotCloudEvent)
+				return
+			}
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+func TestCloudEvent_EventType(t *testing.T) {
+	tests := []struct {
+		eventType       string
+		expectedName    string
+		expectedVersion int
+	}{
+		{eventType: "br.com.g73.order.production.v1", expectedName: "br.com.g73.order.production", expectedVersion: 1},
+		{eventType: "br.com.g73.order.production.v12", expectedName: "br.com.g73.order.production", expectedVersion: 12},
+		{eventType: "br.com.g73.order.production", expectedName: "br.com.g73.order.production", expectedVersion: 1},
+		{eventType: "br.com.g73.order.verified", expectedName: "br.com.g73.order.verified", expectedVersion: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.eventType, func(t *testing.T) {
+			name, version := CloudEvent{Type: test.eventType}.EventType()
+
+			assert.Equal(t, test.expectedName, name)
+			assert.Equal(t, test.expectedVersion, version)
+		})
+	}
+}
@@ -1,5 +1,23 @@
 package broker
 
+import "context"
+
 type Consumer interface {
-	StartConsumer(processMessage func(message []byte) error)
+	StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error)
+}
+
+// PooledConsumer processes messages on its own pool of workers, keeping the
+// messages of a key in order. A single StartPool call uses every worker, so
+// callers must not run it more than once at a time.
+type PooledConsumer interface {
+	Consumer
+	StartPool(ctx context.Context, workers int, processMessage func(ctx context.Context, message []byte) error)
 }
+
+// KeyFunc extracts from a message body the key whose messages must be
+// processed in order. An empty key can be processed by any worker.
+type KeyFunc func(message []byte) string
+
+// ConsumerOpener builds a consumer of queue on demand. close releases it,
+// handing the deliveries it did not ack back to the broker.
+type ConsumerOpener func(queue string) (consumer Consumer, close func() error, err error)
@@ -0,0 +1,36 @@
+package broker
+
+import "github.com/segmentio/kafka-go"
+
+// kafkaHeadersCarrier adapts Kafka record headers to the OpenTelemetry
+// TextMapCarrier so trace context can travel with the message.
+type kafkaHeadersCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaHeadersCarrier) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeadersCarrier) Set(key, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeadersCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, header := range *c.headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
@@ -0,0 +1,192 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	kafkaRetryInitialBackoff = 200 * time.Millisecond
+	kafkaRetryMaxBackoff     = 10 * time.Second
+)
+
+// KafkaReader is the part of *kafka.Reader the consumer uses.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// NewKafkaReader joins the consumer group on topic. Commits are synchronous
+// so an offset is stored as soon as the consumer commits it.
+func NewKafkaReader(brokers []string, groupID string, topic string) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        brokers,
+		GroupID:        groupID,
+		Topic:          topic,
+		CommitInterval: 0,
+	})
+}
+
+type kafkaConsumer struct {
+	reader            KafkaReader
+	topic             string
+	processingTimeout time.Duration
+	maxAttempts       int
+	deadLetters       Publisher
+	deadLetterTopic   string
+	retryBackoff      time.Duration
+	logger            logger.Logger
+}
+
+// NewKafkaConsumer gives up on a message after maxAttempts failures and
+// publishes it to deadLetterTopic, so it stops holding back its partition.
+func NewKafkaConsumer(reader KafkaReader, topic string, processingTimeout time.Duration, maxAttempts int, deadLetters Publisher, deadLetterTopic string, logger logger.Logger) Consumer {
+	return &kafkaConsumer{
+		reader:            reader,
+		topic:             topic,
+		processingTimeout: processingTimeout,
+		maxAttempts:       maxAttempts,
+		deadLetters:       deadLetters,
+		deadLetterTopic:   deadLetterTopic,
+		retryBackoff:      kafkaRetryInitialBackoff,
+		logger:            logger.WithFields(map[string]any{"topic": topic}),
+	}
+}
+
+// StartConsumer blocks processing messages until ctx is cancelled or the
+// reader fails. Kafka has no nack: a failed message is retried in place,
+// holding back its partition, until it is dead lettered. Its offset is only
+// committed once it is processed or dead lettered, so a restart delivers
+// it again.
+func (c *kafkaConsumer) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	c.logger.Infof("Starting consuming topic [%s]", c.topic)
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.logger.Infof("Stopping consuming topic [%s]", c.topic)
+				return
+			}
+			c.logger.WithError(err).Errorf("topic [%s] consumer stopped working", c.topic)
+			return
+		}
+
+		if !c.process(ctx, msg, processMessage) {
+			c.logger.Infof("Stopping consuming topic [%s]", c.topic)
+			return
+		}
+
+		// the work is done, record it even if shutdown has begun
+		commitCtx, cancel := context.WithTimeout(context.Background(), c.processingTimeout)
+		err = c.reader.CommitMessages(commitCtx, msg)
+		cancel()
+		if err != nil {
+			c.logger.WithError(err).Errorf("failed to commit offset [%d] of partition [%d]", msg.Offset, msg.Partition)
+		}
+	}
+}
+
+// process returns false when ctx is done before the message could be
+// processed or dead lettered.
+func (c *kafkaConsumer) process(ctx context.Context, msg kafka.Message, processMessage func(ctx context.Context, message []byte) error) bool {
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.handleMessage(ctx, msg, processMessage)
+		if err == nil {
+			return true
+		}
+		if attempt >= c.maxAttempts {
+			break
+		}
+		if !c.wait(ctx, &backoff) {
+			return false
+		}
+	}
+
+	for {
+		err := c.deadLetter(ctx, msg)
+		if err == nil {
+			return true
+		}
+		c.logger.WithError(err).Errorf("failed to dead letter offset [%d] of partition [%d]", msg.Offset, msg.Partition)
+		if !c.wait(ctx, &backoff) {
+			return false
+		}
+	}
+}
+
+func (c *kafkaConsumer) deadLetter(ctx context.Context, msg kafka.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	err := c.deadLetters.Publish(ctx, c.deadLetterTopic, string(msg.Key), msg.Value)
+	if err != nil {
+		return err
+	}
+	metrics.IncConsumerDeadLettered(c.deadLetterTopic, "max_attempts")
+	c.logger.Warnf("offset [%d] of partition [%d] dead lettered after [%d] attempts", msg.Offset, msg.Partition, c.maxAttempts)
+	return nil
+}
+
+// wait sleeps for backoff, doubling it up to kafkaRetryMaxBackoff, and
+// returns false when ctx is done first.
+func (c *kafkaConsumer) wait(ctx context.Context, backoff *time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(*backoff):
+	}
+	*backoff *= 2
+	if *backoff > kafkaRetryMaxBackoff {
+		*backoff = kafkaRetryMaxBackoff
+	}
+	return true
+}
+
+func (c *kafkaConsumer) handleMessage(ctx context.Context, msg kafka.Message, processMessage func(ctx context.Context, message []byte) error) error {
+	metrics.IncConsumerReceived(c.topic)
+
+	messageID := fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+	ctx = logger.ContextWithMessageID(ctx, messageID)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, kafkaHeadersCarrier{headers: &msg.Headers})
+	ctx, span := tracer.Start(ctx, c.topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(c.topic),
+			semconv.MessagingMessageID(messageID),
+			semconv.MessagingKafkaDestinationPartition(msg.Partition),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			attribute.Int("messaging.message.body.size", len(msg.Value)),
+		),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	c.logger.WithContext(ctx).Debugf("Received a message: %s", msg.Value)
+
+	start := time.Now()
+	err := processMessage(ctx, msg.Value)
+	metrics.ObserveConsumerProcessing(c.topic, start, err)
+	tracing.EndSpan(span, &err)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Errorf("failed to process message")
+		metrics.IncConsumerNacked(c.topic)
+		return err
+	}
+
+	metrics.IncConsumerAcked(c.topic)
+	return nil
+}
@@ -0,0 +1,148 @@
+package broker
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka is an in-process cluster with partitioned topics and consumer
+// group offsets, enough to exercise the Kafka consumer and publisher.
+type fakeKafka struct {
+	mu         sync.Mutex
+	partitions int
+	balancer   kafka.Balancer
+	topics     map[string][][]kafka.Message
+	// committed holds the next offset to read per group, topic and partition
+	committed map[string]map[int]int64
+	notify    chan struct{}
+}
+
+func newFakeKafka(partitions int, balancer kafka.Balancer) *fakeKafka {
+	return &fakeKafka{
+		partitions: partitions,
+		balancer:   balancer,
+		topics:     map[string][][]kafka.Message{},
+		committed:  map[string]map[int]int64{},
+		notify:     make(chan struct{}),
+	}
+}
+
+func (f *fakeKafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	partitionIDs := make([]int, f.partitions)
+	for i := range partitionIDs {
+		partitionIDs[i] = i
+	}
+
+	for _, msg := range msgs {
+		partitions, ok := f.topics[msg.Topic]
+		if !ok {
+			partitions = make([][]kafka.Message, f.partitions)
+		}
+		partition := f.balancer.Balance(msg, partitionIDs...)
+		msg.Partition = partition
+		msg.Offset = int64(len(partitions[partition]))
+		partitions[partition] = append(partitions[partition], msg)
+		f.topics[msg.Topic] = partitions
+	}
+
+	close(f.notify)
+	f.notify = make(chan struct{})
+	return nil
+}
+
+func (f *fakeKafka) Close() error {
+	return nil
+}
+
+// partition returns the messages written to a partition of topic.
+func (f *fakeKafka) partition(topic string, partition int) []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	partitions, ok := f.topics[topic]
+	if !ok {
+		return nil
+	}
+	return append([]kafka.Message(nil), partitions[partition]...)
+}
+
+func (f *fakeKafka) committedOffset(groupID, topic string, partition int) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.committed[groupID+"/"+topic][partition]
+}
+
+// newReader joins groupID on topic, resuming from the committed offsets like
+// a restarted consumer would.
+func (f *fakeKafka) newReader(groupID, topic string) *fakeKafkaReader {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	positions := map[int]int64{}
+	for partition, offset := range f.committed[groupID+"/"+topic] {
+		positions[partition] = offset
+	}
+	return &fakeKafkaReader{kafka: f, groupID: groupID, topic: topic, positions: positions, closed: make(chan struct{})}
+}
+
+type fakeKafkaReader struct {
+	kafka     *fakeKafka
+	groupID   string
+	topic     string
+	positions map[int]int64
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *fakeKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.kafka.mu.Lock()
+		partitions := r.kafka.topics[r.topic]
+		for partition, messages := range partitions {
+			position := r.positions[partition]
+			if position < int64(len(messages)) {
+				r.positions[partition] = position + 1
+				r.kafka.mu.Unlock()
+				return messages[position], nil
+			}
+		}
+		notify := r.kafka.notify
+		r.kafka.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.closed:
+			return kafka.Message{}, io.EOF
+		case <-notify:
+		}
+	}
+}
+
+func (r *fakeKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.kafka.mu.Lock()
+	defer r.kafka.mu.Unlock()
+
+	key := r.groupID + "/" + r.topic
+	if r.kafka.committed[key] == nil {
+		r.kafka.committed[key] = map[int]int64{}
+	}
+	for _, msg := range msgs {
+		if msg.Offset+1 > r.kafka.committed[key][msg.Partition] {
+			r.kafka.committed[key][msg.Partition] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *fakeKafkaReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KafkaWriter is the part of *kafka.Writer the publisher uses.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// NewKafkaWriter creates a writer for any topic that picks the partition by
+// hashing the message key, so the events of an order stay in order.
+func NewKafkaWriter(brokers []string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+}
+
+// PingKafka succeeds when any of the brokers accepts a connection.
+func PingKafka(ctx context.Context, brokers []string) error {
+	var err error
+	for _, address := range brokers {
+		var conn *kafka.Conn
+		conn, err = kafka.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+	}
+	return fmt.Errorf("failed to reach kafka brokers %v: %w", brokers, err)
+}
+
+type kafkaPublisher struct {
+	writer KafkaWriter
+}
+
+func NewKafkaPublisher(writer KafkaWriter) Publisher {
+	return &kafkaPublisher{writer: writer}
+}
+
+// Publish writes the message to the destination topic keyed by key.
+func (p *kafkaPublisher) Publish(ctx context.Context, destination string, key string, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(destination),
+			semconv.MessagingKafkaMessageKey(key),
+		),
+	)
+	defer tracing.EndSpan(span, &err)
+
+	headers := []kafka.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, kafkaHeadersCarrier{headers: &headers})
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   destination,
+		Key:     []byte(key),
+		Value:   message,
+		Headers: headers,
+	})
+	metrics.IncPublished("kafka", destination, err)
+	if err != nil {
+		return fmt.Errorf("failed to write message to topic [%s]: %w", destination, err)
+	}
+
+	return nil
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
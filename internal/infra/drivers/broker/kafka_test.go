@@ -0,0 +1,203 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consumeKafka runs a consumer of topic until stop is called, dead
+// lettering to topic.dlq after maxAttempts.
+func consumeKafka(cluster *fakeKafka, reader KafkaReader, topic string, maxAttempts int, process func(message []byte) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := NewKafkaConsumer(reader, topic, time.Second, maxAttempts, NewKafkaPublisher(cluster), topic+".dlq", logger.NewNopLogger())
+	consumer.(*kafkaConsumer).retryBackoff = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.StartConsumer(ctx, func(ctx context.Context, message []byte) error {
+			return process(message)
+		})
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestNewKafkaWriter_PartitionsByKey(t *testing.T) {
+	writer := NewKafkaWriter([]string{"localhost:9092"})
+	defer writer.Close()
+
+	assert.IsType(t, &kafka.Hash{}, writer.Balancer)
+	assert.Empty(t, writer.Topic, "the topic comes from each message")
+}
+
+func TestKafkaPublisher_Publish(t *testing.T) {
+	cluster := newFakeKafka(4, &kafka.Hash{})
+	publisher := NewKafkaPublisher(cluster)
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	for _, key := range []string{"7", "8", "7", "9", "7"} {
+		require.NoError(t, publisher.Publish(ctx, "orders-ready", key, []byte(`{"orderId":`+key+`}`)))
+	}
+
+	partitionsOfKey := map[string]map[int]bool{}
+	total := 0
+	for partition := 0; partition < 4; partition++ {
+		for _, msg := range cluster.partition("orders-ready", partition) {
+			total++
+			key := string(msg.Key)
+			if partitionsOfKey[key] == nil {
+				partitionsOfKey[key] = map[int]bool{}
+			}
+			partitionsOfKey[key][partition] = true
+
+			carrier := kafkaHeadersCarrier{headers: &msg.Headers}
+			assert.Contains(t, carrier.Get("traceparent"), spanContext.TraceID().String())
+		}
+	}
+
+	assert.Equal(t, 5, total)
+	for key, partitions := range partitionsOfKey {
+		assert.Len(t, partitions, 1, "messages of order [%s] share a partition", key)
+	}
+}
+
+func TestKafkaConsumer_CommitsAfterSuccess(t *testing.T) {
+	cluster := newFakeKafka(1, &kafka.Hash{})
+	publisher := NewKafkaPublisher(cluster)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, "orders", "1", []byte("first")))
+	require.NoError(t, publisher.Publish(ctx, "orders", "2", []byte("second")))
+
+	processed := make(chan string, 10)
+	stop := consumeKafka(cluster, cluster.newReader("production", "orders"), "orders", 1000, func(message []byte) error {
+		processed <- string(message)
+		return nil
+	})
+	defer stop()
+
+	assert.Equal(t, "first", receive(t, processed))
+	assert.Equal(t, "second", receive(t, processed))
+	assert.Eventually(t, func() bool {
+		return cluster.committedOffset("production", "orders", 0) == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestKafkaConsumer_RetriesWithoutCommitting(t *testing.T) {
+	cluster := newFakeKafka(1, &kafka.Hash{})
+	publisher := NewKafkaPublisher(cluster)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, "orders", "1", []byte("poison")))
+	require.NoError(t, publisher.Publish(ctx, "orders", "2", []byte("next")))
+
+	mu := sync.Mutex{}
+	attempts := 0
+	stop := consumeKafka(cluster, cluster.newReader("production", "orders"), "orders", 1000, func(message []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		return errors.New("store is down")
+	})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts >= 3
+	}, time.Second, time.Millisecond, "failed message is retried")
+	stop()
+	assert.Zero(t, cluster.committedOffset("production", "orders", 0), "failed message is not committed")
+
+	// a restarted consumer of the group gets the message again
+	processed := make(chan string, 10)
+	stop = consumeKafka(cluster, cluster.newReader("production", "orders"), "orders", 1000, func(message []byte) error {
+		processed <- string(message)
+		return nil
+	})
+	defer stop()
+
+	assert.Equal(t, "poison", receive(t, processed))
+	assert.Equal(t, "next", receive(t, processed))
+	assert.Eventually(t, func() bool {
+		return cluster.committedOffset("production", "orders", 0) == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestKafkaConsumer_DeadLettersAfterMaxAttempts(t *testing.T) {
+	cluster := newFakeKafka(1, &kafka.Hash{})
+	publisher := NewKafkaPublisher(cluster)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, "orders", "1", []byte("poison")))
+	require.NoError(t, publisher.Publish(ctx, "orders", "2", []byte("next")))
+
+	mu := sync.Mutex{}
+	attempts := 0
+	processed := make(chan string, 10)
+	stop := consumeKafka(cluster, cluster.newReader("production", "orders"), "orders", 3, func(message []byte) error {
+		if string(message) == "poison" {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			return errors.New("invalid order")
+		}
+		processed <- string(message)
+		return nil
+	})
+	defer stop()
+
+	assert.Equal(t, "next", receive(t, processed), "the partition moves on")
+	assert.Eventually(t, func() bool {
+		return cluster.committedOffset("production", "orders", 0) == 2
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, 3, attempts)
+	mu.Unlock()
+	deadLetters := cluster.partition("orders.dlq", 0)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "poison", string(deadLetters[0].Value))
+	assert.Equal(t, "1", string(deadLetters[0].Key))
+}
+
+func TestKafkaConsumer_StopsWhenReaderCloses(t *testing.T) {
+	cluster := newFakeKafka(1, &kafka.Hash{})
+	reader := cluster.newReader("production", "orders")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewKafkaConsumer(reader, "orders", time.Second, 1, NewKafkaPublisher(cluster), "orders.dlq", logger.NewNopLogger()).StartConsumer(context.Background(), func(ctx context.Context, message []byte) error {
+			return nil
+		})
+	}()
+
+	require.NoError(t, reader.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop when the reader was closed")
+	}
+}
@@ -0,0 +1,234 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrBrokerClosed is returned when publishing to a closed in-memory broker.
+var ErrBrokerClosed = errors.New("broker is closed")
+
+// MemoryBroker stands in for RabbitMQ when the service runs without one.
+// Messages published to a destination are queued under that name, as the
+// RabbitMQ default exchange does, until a consumer of the queue takes them.
+// Nothing survives a restart.
+type MemoryBroker interface {
+	Publisher
+	NewConsumer(queueName string, processingTimeout time.Duration, logger logger.Logger) Consumer
+	Pending(queueName string) int
+}
+
+type memoryMessage struct {
+	id      string
+	body    []byte
+	headers propagation.MapCarrier
+}
+
+type memoryQueue struct {
+	messages []memoryMessage
+	// notify holds a token while the queue may have messages
+	notify chan struct{}
+}
+
+type memoryBroker struct {
+	mu       sync.Mutex
+	queues   map[string]*memoryQueue
+	sequence int
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func NewMemoryBroker() MemoryBroker {
+	return &memoryBroker{
+		queues: map[string]*memoryQueue{},
+		closed: make(chan struct{}),
+	}
+}
+
+// Publish queues the message under destination; the key is not used since
+// queues have a single partition.
+func (b *memoryBroker) Publish(ctx context.Context, destination string, key string, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("memory"),
+			semconv.MessagingDestinationName(destination),
+		),
+	)
+	defer tracing.EndSpan(span, &err)
+	defer func() { metrics.IncPublished("memory", destination, err) }()
+
+	select {
+	case <-b.closed:
+		return ErrBrokerClosed
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sequence++
+	queue := b.queue(destination)
+	queue.messages = append(queue.messages, memoryMessage{
+		id:      strconv.Itoa(b.sequence),
+		body:    append([]byte(nil), message...),
+		headers: headers,
+	})
+	signal(queue.notify)
+
+	return nil
+}
+
+// Close stops every consumer; messages still queued are dropped.
+func (b *memoryBroker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+// Pending returns how many messages wait in the queue, including the ones
+// requeued after a nack.
+func (b *memoryBroker) Pending(queueName string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.queue(queueName).messages)
+}
+
+func (b *memoryBroker) NewConsumer(queueName string, processingTimeout time.Duration, logger logger.Logger) Consumer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return &memoryConsumer{
+		broker:            b,
+		queueName:         queueName,
+		queue:             b.queue(queueName),
+		processingTimeout: processingTimeout,
+		logger:            logger.WithFields(map[string]any{"queue": queueName}),
+	}
+}
+
+// queue must be called with mu held.
+func (b *memoryBroker) queue(name string) *memoryQueue {
+	queue, ok := b.queues[name]
+	if !ok {
+		queue = &memoryQueue{notify: make(chan struct{}, 1)}
+		b.queues[name] = queue
+	}
+	return queue
+}
+
+// take pops the head of the queue, the message is back in the queue only
+// if the consumer requeues it.
+func (b *memoryBroker) take(queue *memoryQueue) (memoryMessage, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(queue.messages) == 0 {
+		return memoryMessage{}, false
+	}
+	msg := queue.messages[0]
+	queue.messages = queue.messages[1:]
+	if len(queue.messages) > 0 {
+		signal(queue.notify)
+	}
+	return msg, true
+}
+
+// requeue puts a nacked message back at the head of the queue, where
+// RabbitMQ would redeliver it from.
+func (b *memoryBroker) requeue(queue *memoryQueue, msg memoryMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queue.messages = append([]memoryMessage{msg}, queue.messages...)
+	signal(queue.notify)
+}
+
+func signal(notify chan struct{}) {
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
+type memoryConsumer struct {
+	broker            *memoryBroker
+	queueName         string
+	queue             *memoryQueue
+	processingTimeout time.Duration
+	logger            logger.Logger
+}
+
+// StartConsumer blocks processing messages until ctx is cancelled or the
+// broker is closed, acking and nacking them like the RabbitMQ consumer.
+func (c *memoryConsumer) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	c.logger.Infof("Starting consuming queue [%s]", c.queueName)
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Infof("Stopping consuming queue [%s]", c.queueName)
+			return
+		case <-c.broker.closed:
+			c.logger.Errorf("queue [%s] consumer stopped working", c.queueName)
+			return
+		case <-c.queue.notify:
+		}
+
+		msg, ok := c.broker.take(c.queue)
+		if ok {
+			c.handleMessage(ctx, msg, processMessage)
+		}
+	}
+}
+
+func (c *memoryConsumer) handleMessage(ctx context.Context, msg memoryMessage, processMessage func(ctx context.Context, message []byte) error) {
+	metrics.IncConsumerReceived(c.queueName)
+
+	ctx = logger.ContextWithMessageID(ctx, msg.id)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, msg.headers)
+	ctx, span := tracer.Start(ctx, c.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("memory"),
+			semconv.MessagingDestinationName(c.queueName),
+			semconv.MessagingMessageID(msg.id),
+			attribute.Int("messaging.message.body.size", len(msg.body)),
+		),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	c.logger.WithContext(ctx).Debugf("Received a message: %s", msg.body)
+
+	start := time.Now()
+	err := processMessage(ctx, msg.body)
+	metrics.ObserveConsumerProcessing(c.queueName, start, err)
+	tracing.EndSpan(span, &err)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Errorf("failed to process message")
+		c.broker.requeue(c.queue, msg)
+		metrics.IncConsumerNacked(c.queueName)
+		return
+	}
+
+	metrics.IncConsumerAcked(c.queueName)
+}
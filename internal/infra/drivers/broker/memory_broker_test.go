@@ -0,0 +1,109 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startMemoryConsumer consumes the queue in the background until the test
+// ends, sending every processed body to the returned channel.
+func startMemoryConsumer(t *testing.T, b MemoryBroker, queue string, process func(message []byte) error) <-chan string {
+	ctx, cancel := context.WithCancel(context.Background())
+	processed := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.NewConsumer(queue, time.Second, logger.NewNopLogger()).StartConsumer(ctx, func(ctx context.Context, message []byte) error {
+			err := process(message)
+			processed <- string(message)
+			return err
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return processed
+}
+
+func receive(t *testing.T, processed <-chan string) string {
+	select {
+	case message := <-processed:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("message was not consumed")
+		return ""
+	}
+}
+
+func TestMemoryBroker_PublishAndAck(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx := context.Background()
+
+	require.NoError(t, b.Publish(ctx, "orders", "1", []byte("first")))
+	require.NoError(t, b.Publish(ctx, "orders", "1", []byte("second")))
+	require.NoError(t, b.Publish(ctx, "other", "1", []byte("ignored")))
+	assert.Equal(t, 2, b.Pending("orders"))
+
+	processed := startMemoryConsumer(t, b, "orders", func(message []byte) error { return nil })
+
+	assert.Equal(t, "first", receive(t, processed))
+	assert.Equal(t, "second", receive(t, processed))
+	assert.Eventually(t, func() bool { return b.Pending("orders") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, b.Pending("other"))
+}
+
+func TestMemoryBroker_NackRequeuesAtHead(t *testing.T) {
+	b := NewMemoryBroker()
+	ctx := context.Background()
+
+	require.NoError(t, b.Publish(ctx, "orders", "1", []byte("flaky")))
+	require.NoError(t, b.Publish(ctx, "orders", "1", []byte("next")))
+
+	mu := sync.Mutex{}
+	failures := 2
+	processed := startMemoryConsumer(t, b, "orders", func(message []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(message) == "flaky" && failures > 0 {
+			failures--
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	assert.Equal(t, "flaky", receive(t, processed))
+	assert.Equal(t, "flaky", receive(t, processed), "nacked message is redelivered before the next one")
+	assert.Equal(t, "flaky", receive(t, processed))
+	assert.Equal(t, "next", receive(t, processed))
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	b := NewMemoryBroker()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		b.NewConsumer("orders", time.Second, logger.NewNopLogger()).StartConsumer(context.Background(), func(ctx context.Context, message []byte) error {
+			return nil
+		})
+	}()
+
+	require.NoError(t, b.Close())
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop when the broker was closed")
+	}
+
+	err := b.Publish(context.Background(), "orders", "1", []byte("late"))
+	assert.ErrorIs(t, err, ErrBrokerClosed)
+}
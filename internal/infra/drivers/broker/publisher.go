@@ -2,7 +2,10 @@ package broker
 
 import "context"
 
+// Publisher sends messages to a destination. The key identifies the entity
+// the message is about; brokers that partition use it to keep the messages
+// of an entity in order.
 type Publisher interface {
-	Publish(ctx context.Context, destination string, message []byte) error
+	Publish(ctx context.Context, destination string, key string, message []byte) error
 	Close() error
 }
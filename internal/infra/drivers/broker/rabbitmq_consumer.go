@@ -1,54 +1,343 @@
 package broker
 
 import (
+	"context"
 	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker")
+
+const (
+	rabbitMQRetryInitialBackoff = 200 * time.Millisecond
+	rabbitMQRetryMaxBackoff     = 10 * time.Second
+)
+
+// RabbitMQChannel is the part of *amqp.Channel the consumer uses.
+type RabbitMQChannel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+}
+
 type rabbitConsumer struct {
-	channel    *amqp.Channel
+	channel           RabbitMQChannel
+	queueName         string
+	prefetch          int
+	keyOf             KeyFunc
+	processingTimeout time.Duration
+	maxAttempts       int
+	deadLetters       Publisher
+	deadLetterQueue   string
+	retryBackoff      time.Duration
+	logger            logger.Logger
+
+	// next spreads the messages without a key over the workers
+	next atomic.Uint32
+
+	// mu guards the registration made by the constructor, used by the first
+	// pool; the following pools register their own
+	mu         sync.Mutex
 	messagesCh <-chan amqp.Delivery
-	queueName  string
-}
-
-func NewRabbitMQConsumer(channel *amqp.Channel, queueName string) (Consumer, error) {
-	messagesCh, err := channel.Consume(
-		queueName, // queue
-		"",        // consumer
-		false,     // auto-ack, set to false for manual ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // args
+	tag        string
+	tags       atomic.Uint32
+}
+
+// NewRabbitMQConsumer limits the unacked deliveries of the channel to
+// prefetch, which bounds the messages waiting for a worker. It registers
+// the consumer right away, so a missing queue fails fast. A message is
+// given up on after maxAttempts failures and published to deadLetterQueue.
+func NewRabbitMQConsumer(channel RabbitMQChannel, queueName string, prefetch int, keyOf KeyFunc, processingTimeout time.Duration, maxAttempts int, deadLetters Publisher, deadLetterQueue string, logger logger.Logger) (PooledConsumer, error) {
+	err := channel.Qos(prefetch, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set the prefetch of the queue [%s], error: [%w]", queueName, err)
+	}
+
+	consumer := &rabbitConsumer{
+		channel:           channel,
+		queueName:         queueName,
+		prefetch:          prefetch,
+		keyOf:             keyOf,
+		processingTimeout: processingTimeout,
+		maxAttempts:       maxAttempts,
+		deadLetters:       deadLetters,
+		deadLetterQueue:   deadLetterQueue,
+		retryBackoff:      rabbitMQRetryInitialBackoff,
+		logger:            logger.WithFields(map[string]any{"queue": queueName}),
+	}
+	consumer.messagesCh, consumer.tag, err = consumer.consume()
+	if err != nil {
+		return nil, err
+	}
+
+	return consumer, nil
+}
+
+// consume registers a consumer of the queue, the broker pushing it up to
+// prefetch deliveries until it is cancelled.
+func (c *rabbitConsumer) consume() (<-chan amqp.Delivery, string, error) {
+	tag := fmt.Sprintf("%s-%d", c.queueName, c.tags.Add(1))
+	messagesCh, err := c.channel.Consume(
+		c.queueName, // queue
+		tag,         // consumer
+		false,       // auto-ack, set to false for manual ack
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // args
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to register a consumer for the queue [%s], error: [%w]", queueName, err)
+		return nil, "", fmt.Errorf("failed to register a consumer for the queue [%s], error: [%w]", c.queueName, err)
 	}
+	return messagesCh, tag, nil
+}
 
-	return &rabbitConsumer{channel: channel, queueName: queueName, messagesCh: messagesCh}, nil
+// registration returns the consumer registered by the constructor the
+// first time, and a new one after a pool cancelled it.
+func (c *rabbitConsumer) registration() (<-chan amqp.Delivery, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messagesCh != nil {
+		messagesCh, tag := c.messagesCh, c.tag
+		c.messagesCh, c.tag = nil, ""
+		return messagesCh, tag, nil
+	}
+	return c.consume()
 }
 
-func (c *rabbitConsumer) StartConsumer(processMessage func(message []byte) error) {
-	log.Infof("Starting consuming queue [%s]", c.queueName)
-	forever := make(chan bool)
-	go func() {
-		for msg := range c.messagesCh {
-			log.Debugf("Received a message: %s", msg.Body)
+// StartConsumer processes the deliveries one at a time.
+func (c *rabbitConsumer) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	c.StartPool(ctx, 1, processMessage)
+}
+
+// StartPool blocks processing deliveries on workers goroutines until ctx is
+// cancelled or the delivery channel is closed by the broker. Deliveries are
+// sharded by key, so the messages of an order are processed in the order
+// they arrived, and each one is acked on its own once processed. A failed
+// delivery is retried in place with backoff, holding back the following
+// messages of its order, until it is dead lettered. It returns
+// after the workers finish; the consumer is cancelled first, so the broker
+// stops pushing deliveries and they are left to the other instances until
+// the next StartPool, and the deliveries not started are requeued.
+func (c *rabbitConsumer) StartPool(ctx context.Context, workers int, processMessage func(ctx context.Context, message []byte) error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	messagesCh, tag, err := c.registration()
+	if err != nil {
+		c.logger.WithError(err).Errorf("queue [%s] consumer stopped working", c.queueName)
+		return
+	}
 
-			err := processMessage(msg.Body)
-			if err != nil {
-				log.Errorf("failed to process message, error: %s", err.Error())
-				msg.Nack(false, true)
-				continue
+	// with at most prefetch unacked deliveries the shards never fill up,
+	// so a slow order does not hold back the others
+	shards := make([]chan amqp.Delivery, workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shards[i] = make(chan amqp.Delivery, c.prefetch)
+		wg.Add(1)
+		go func(shard <-chan amqp.Delivery) {
+			defer wg.Done()
+			for msg := range shard {
+				if ctx.Err() != nil {
+					c.requeue(msg)
+					continue
+				}
+				c.process(ctx, msg, processMessage)
 			}
+		}(shards[i])
+	}
+
+	c.logger.Infof("Starting consuming queue [%s] with [%d] workers", c.queueName, workers)
+	c.dispatch(ctx, messagesCh, shards)
+	c.cancel(messagesCh, tag)
+
+	for _, shard := range shards {
+		close(shard)
+	}
+	wg.Wait()
+}
 
-			// Acknowledge the message after successful processing
-			msg.Ack(false)
+func (c *rabbitConsumer) dispatch(ctx context.Context, messagesCh <-chan amqp.Delivery, shards []chan amqp.Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Infof("Stopping consuming queue [%s]", c.queueName)
+			return
+		case msg, ok := <-messagesCh:
+			if !ok {
+				c.logger.Errorf("queue [%s] consumer stopped working", c.queueName)
+				return
+			}
+			shards[c.shard(msg.Body, len(shards))] <- msg
 		}
-	}()
+	}
+}
+
+// cancel unregisters the consumer and requeues the deliveries the broker
+// pushed before it was cancelled, the delivery channel being closed once
+// they are all received.
+func (c *rabbitConsumer) cancel(messagesCh <-chan amqp.Delivery, tag string) {
+	err := c.channel.Cancel(tag, false)
+	if err != nil {
+		// the channel is closed, and its deliveries requeued by the broker
+		c.logger.WithError(err).Warnf("failed to cancel consumer [%s]", tag)
+		return
+	}
+
+	for msg := range messagesCh {
+		msg.Nack(false, true)
+		metrics.IncConsumerNacked(c.queueName)
+	}
+}
 
-	<-forever
-	log.Errorf("queue [%s] consumer stopped working", c.queueName)
+func (c *rabbitConsumer) shard(body []byte, shards int) int {
+	key := ""
+	if c.keyOf != nil {
+		key = c.keyOf(body)
+	}
+	if key == "" {
+		return int(c.next.Add(1) % uint32(shards))
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(shards))
+}
+
+// process acks the delivery once it is processed or dead lettered, and
+// requeues it when ctx is done first.
+func (c *rabbitConsumer) process(ctx context.Context, msg amqp.Delivery, processMessage func(ctx context.Context, message []byte) error) {
+	backoff := c.retryBackoff
+	for attempt := previousAttempts(msg) + 1; ; attempt++ {
+		err := c.handleMessage(ctx, msg, processMessage)
+		if err == nil {
+			c.ack(msg)
+			return
+		}
+		if attempt >= c.maxAttempts {
+			break
+		}
+		if !c.wait(ctx, &backoff) {
+			c.requeue(msg)
+			return
+		}
+	}
+
+	for {
+		err := c.deadLetter(ctx, msg)
+		if err == nil {
+			c.ack(msg)
+			return
+		}
+		c.logger.WithError(err).Errorf("failed to dead letter message [%s]", msg.MessageId)
+		if !c.wait(ctx, &backoff) {
+			c.requeue(msg)
+			return
+		}
+	}
+}
+
+// previousAttempts returns the failed deliveries of the message before this
+// one: the x-delivery-count of quorum queues, or one for a redelivery of a
+// classic queue, which does not count them.
+func previousAttempts(msg amqp.Delivery) int {
+	switch count := msg.Headers["x-delivery-count"].(type) {
+	case int64:
+		return int(count)
+	case int32:
+		return int(count)
+	case int:
+		return count
+	}
+	if msg.Redelivered {
+		return 1
+	}
+	return 0
+}
+
+func (c *rabbitConsumer) deadLetter(ctx context.Context, msg amqp.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	key := ""
+	if c.keyOf != nil {
+		key = c.keyOf(msg.Body)
+	}
+	err := c.deadLetters.Publish(ctx, c.deadLetterQueue, key, msg.Body)
+	if err != nil {
+		return err
+	}
+	metrics.IncConsumerDeadLettered(c.deadLetterQueue, "max_attempts")
+	c.logger.Warnf("message [%s] dead lettered after [%d] attempts", msg.MessageId, c.maxAttempts)
+	return nil
+}
+
+// wait sleeps for backoff, doubling it up to rabbitMQRetryMaxBackoff, and
+// returns false when ctx is done first.
+func (c *rabbitConsumer) wait(ctx context.Context, backoff *time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(*backoff):
+	}
+	*backoff *= 2
+	if *backoff > rabbitMQRetryMaxBackoff {
+		*backoff = rabbitMQRetryMaxBackoff
+	}
+	return true
+}
+
+func (c *rabbitConsumer) ack(msg amqp.Delivery) {
+	msg.Ack(false)
+	metrics.IncConsumerAcked(c.queueName)
+}
+
+func (c *rabbitConsumer) requeue(msg amqp.Delivery) {
+	msg.Nack(false, true)
+	metrics.IncConsumerNacked(c.queueName)
+}
+
+func (c *rabbitConsumer) handleMessage(ctx context.Context, msg amqp.Delivery, processMessage func(ctx context.Context, message []byte) error) error {
+	metrics.IncConsumerReceived(c.queueName)
+
+	ctx = logger.ContextWithMessageID(ctx, msg.MessageId)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, amqpHeadersCarrier(msg.Headers))
+	ctx, span := tracer.Start(ctx, c.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(c.queueName),
+			semconv.MessagingMessageID(msg.MessageId),
+			attribute.Int("messaging.message.body.size", len(msg.Body)),
+		),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	c.logger.WithContext(ctx).Debugf("Received a message: %s", msg.Body)
+
+	start := time.Now()
+	err := processMessage(ctx, msg.Body)
+	metrics.ObserveConsumerProcessing(c.queueName, start, err)
+	tracing.EndSpan(span, &err)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Errorf("failed to process message")
+	}
+	return err
 }
@@ -0,0 +1,400 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRabbitMQChannel pushes the deliveries of the queue to the consumer
+// registered, holding them in the queue while there is none.
+type fakeRabbitMQChannel struct {
+	prefetch   int
+	deliveries chan amqp.Delivery
+	qosErr     error
+
+	mu        sync.Mutex
+	consumers map[string]chan struct{}
+	consumes  int
+}
+
+func (c *fakeRabbitMQChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	c.prefetch = prefetchCount
+	return c.qosErr
+}
+
+func (c *fakeRabbitMQChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.consumers == nil {
+		c.consumers = map[string]chan struct{}{}
+	}
+	if _, ok := c.consumers[consumer]; ok {
+		return nil, errors.New("consumer tag in use")
+	}
+	cancelled := make(chan struct{})
+	c.consumers[consumer] = cancelled
+	c.consumes++
+
+	pushed := make(chan amqp.Delivery)
+	go func() {
+		defer close(pushed)
+		for {
+			select {
+			case <-cancelled:
+				return
+			case msg := <-c.deliveries:
+				select {
+				case pushed <- msg:
+				case <-cancelled:
+					c.deliveries <- msg
+					return
+				}
+			}
+		}
+	}()
+	return pushed, nil
+}
+
+func (c *fakeRabbitMQChannel) Cancel(consumer string, noWait bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cancelled, ok := c.consumers[consumer]
+	if !ok {
+		return errors.New("unknown consumer tag")
+	}
+	close(cancelled)
+	delete(c.consumers, consumer)
+	return nil
+}
+
+// registrations returns the consumers registered and the times Consume was
+// called.
+func (c *fakeRabbitMQChannel) registrations() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.consumers), c.consumes
+}
+
+// fakeAcknowledger records the outcome of each delivery by its tag.
+type fakeAcknowledger struct {
+	mu       sync.Mutex
+	acked    []uint64
+	requeued []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func (a *fakeAcknowledger) outcomes() ([]uint64, []uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]uint64{}, a.acked...), append([]uint64{}, a.requeued...)
+}
+
+// firstByteKey shards the test messages by their first byte, "a" and "b"
+// landing on different workers of a pool of two.
+func firstByteKey(message []byte) string {
+	return string(message[:1])
+}
+
+func rabbitDelivery(acknowledger amqp.Acknowledger, tag uint64, body string) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: tag, Body: []byte(body)}
+}
+
+// fakeDeadLetters records the messages published, failing while err is set.
+type fakeDeadLetters struct {
+	mu        sync.Mutex
+	err       error
+	published []string
+	keys      []string
+}
+
+func (p *fakeDeadLetters) Publish(ctx context.Context, destination string, key string, message []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, destination+":"+string(message))
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func (p *fakeDeadLetters) Close() error {
+	return nil
+}
+
+func (p *fakeDeadLetters) messages() ([]string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.published...), append([]string{}, p.keys...)
+}
+
+func newTestRabbitMQConsumer(t *testing.T, channel *fakeRabbitMQChannel, maxAttempts int, deadLetters Publisher) PooledConsumer {
+	consumer, err := NewRabbitMQConsumer(channel, "orders-in-progress", 10, firstByteKey, 5*time.Second, maxAttempts, deadLetters, "orders-in-progress.dlq", logger.NewNopLogger())
+	require.NoError(t, err)
+	consumer.(*rabbitConsumer).retryBackoff = time.Millisecond
+	return consumer
+}
+
+// consumeRabbitMQ runs a pool of workers on channel until stop is called,
+// dead lettering to deadLetters after maxAttempts.
+func consumeRabbitMQ(t *testing.T, channel *fakeRabbitMQChannel, workers int, maxAttempts int, deadLetters Publisher, process func(ctx context.Context, message []byte) error) (stop func()) {
+	return startRabbitMQPool(newTestRabbitMQConsumer(t, channel, maxAttempts, deadLetters), workers, process)
+}
+
+func startRabbitMQPool(consumer PooledConsumer, workers int, process func(ctx context.Context, message []byte) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.StartPool(ctx, workers, process)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestNewRabbitMQConsumer_SetsPrefetch(t *testing.T) {
+	channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery)}
+	_, err := NewRabbitMQConsumer(channel, "orders-in-progress", 20, nil, time.Second, 5, &fakeDeadLetters{}, "orders-in-progress.dlq", logger.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 20, channel.prefetch)
+
+	channel.qosErr = errors.New("channel closed")
+	_, err = NewRabbitMQConsumer(channel, "orders-in-progress", 20, nil, time.Second, 5, &fakeDeadLetters{}, "orders-in-progress.dlq", logger.NewNopLogger())
+	assert.ErrorContains(t, err, "failed to set the prefetch of the queue [orders-in-progress]")
+}
+
+func TestRabbitMQConsumer_ProcessesKeysInOrderAndConcurrently(t *testing.T) {
+	channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery, 10)}
+	acknowledger := &fakeAcknowledger{}
+
+	var mu sync.Mutex
+	processed := []string{}
+	release := make(chan struct{})
+	failed := false
+	stop := consumeRabbitMQ(t, channel, 2, 5, &fakeDeadLetters{}, func(ctx context.Context, message []byte) error {
+		if string(message) == "a1" {
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, string(message))
+		if string(message) == "a2" && !failed {
+			failed = true
+			return errors.New("store unavailable")
+		}
+		return nil
+	})
+	defer stop()
+
+	channel.deliveries <- rabbitDelivery(acknowledger, 1, "a1")
+	channel.deliveries <- rabbitDelivery(acknowledger, 2, "a2")
+	channel.deliveries <- rabbitDelivery(acknowledger, 3, "b1")
+	channel.deliveries <- rabbitDelivery(acknowledger, 4, "a3")
+
+	assert.Eventually(t, func() bool {
+		acked, _ := acknowledger.outcomes()
+		return len(acked) == 1
+	}, time.Second, time.Millisecond, "another order is not held back by a slow one")
+	acked, _ := acknowledger.outcomes()
+	assert.Equal(t, []uint64{3}, acked)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		acked, _ := acknowledger.outcomes()
+		return len(acked) == 4
+	}, time.Second, time.Millisecond)
+
+	acked, requeued := acknowledger.outcomes()
+	assert.Equal(t, []uint64{3, 1, 2, 4}, acked)
+	assert.Empty(t, requeued, "the failed delivery is retried in place")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"b1", "a1", "a2", "a2", "a3"}, processed, "the next message of the order waits for the retry")
+}
+
+func TestRabbitMQConsumer_RequeuesPendingDeliveriesOnStop(t *testing.T) {
+	channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery, 10)}
+	acknowledger := &fakeAcknowledger{}
+
+	started := make(chan struct{})
+	stop := consumeRabbitMQ(t, channel, 2, 5, &fakeDeadLetters{}, func(ctx context.Context, message []byte) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	})
+
+	channel.deliveries <- rabbitDelivery(acknowledger, 1, "a1")
+	channel.deliveries <- rabbitDelivery(acknowledger, 2, "a2")
+	<-started
+	assert.Eventually(t, func() bool { return len(channel.deliveries) == 0 }, time.Second, time.Millisecond)
+
+	stop()
+
+	acked, requeued := acknowledger.outcomes()
+	assert.Equal(t, []uint64{1}, acked, "the delivery in process finishes")
+	assert.Equal(t, []uint64{2}, requeued, "the waiting delivery goes back to the queue")
+}
+
+func TestRabbitMQConsumer_HoldsNoDeliveriesWhileStopped(t *testing.T) {
+	channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery, 10)}
+	acknowledger := &fakeAcknowledger{}
+	consumer := newTestRabbitMQConsumer(t, channel, 5, &fakeDeadLetters{})
+
+	processed := make(chan string, 10)
+	process := func(ctx context.Context, message []byte) error {
+		processed <- string(message)
+		return nil
+	}
+
+	stop := startRabbitMQPool(consumer, 2, process)
+	channel.deliveries <- rabbitDelivery(acknowledger, 1, "a1")
+	assert.Equal(t, "a1", <-processed)
+	stop()
+
+	registered, consumes := channel.registrations()
+	assert.Equal(t, 0, registered, "the consumer is cancelled while stopped")
+	assert.Equal(t, 1, consumes)
+
+	channel.deliveries <- rabbitDelivery(acknowledger, 2, "a2")
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, channel.deliveries, 1, "the delivery stays in the queue")
+	assert.Empty(t, processed)
+
+	stop = startRabbitMQPool(consumer, 2, process)
+	defer stop()
+	assert.Equal(t, "a2", <-processed)
+
+	registered, consumes = channel.registrations()
+	assert.Equal(t, 1, registered)
+	assert.Equal(t, 2, consumes, "the consumer is registered again")
+	assert.Eventually(t, func() bool {
+		acked, _ := acknowledger.outcomes()
+		return len(acked) == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestRabbitMQConsumer_DeadLettersAfterMaxAttempts(t *testing.T) {
+	tests := []struct {
+		name             string
+		delivery         amqp.Delivery
+		expectedAttempts int
+	}{
+		{
+			name:             "first delivery",
+			delivery:         rabbitDelivery(nil, 1, "a1"),
+			expectedAttempts: 3,
+		},
+		{
+			name: "delivery counted by a quorum queue",
+			delivery: amqp.Delivery{
+				DeliveryTag: 1,
+				Body:        []byte("a1"),
+				Redelivered: true,
+				Headers:     amqp.Table{"x-delivery-count": int64(2)},
+			},
+			expectedAttempts: 1,
+		},
+		{
+			name:             "redelivery of a classic queue",
+			delivery:         amqp.Delivery{DeliveryTag: 1, Body: []byte("a1"), Redelivered: true},
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery, 10)}
+			acknowledger := &fakeAcknowledger{}
+			deadLetters := &fakeDeadLetters{}
+
+			var mu sync.Mutex
+			attempts := 0
+			processed := make(chan string, 10)
+			stop := consumeRabbitMQ(t, channel, 1, 3, deadLetters, func(ctx context.Context, message []byte) error {
+				if string(message) == "a1" {
+					mu.Lock()
+					defer mu.Unlock()
+					attempts++
+					return errors.New("invalid order")
+				}
+				processed <- string(message)
+				return nil
+			})
+			defer stop()
+
+			test.delivery.Acknowledger = acknowledger
+			channel.deliveries <- test.delivery
+			channel.deliveries <- rabbitDelivery(acknowledger, 2, "a2")
+
+			assert.Equal(t, "a2", <-processed, "the order moves on")
+			assert.Eventually(t, func() bool {
+				acked, _ := acknowledger.outcomes()
+				return len(acked) == 2
+			}, time.Second, time.Millisecond)
+
+			acked, requeued := acknowledger.outcomes()
+			assert.Equal(t, []uint64{1, 2}, acked)
+			assert.Empty(t, requeued)
+			mu.Lock()
+			assert.Equal(t, test.expectedAttempts, attempts)
+			mu.Unlock()
+			published, keys := deadLetters.messages()
+			assert.Equal(t, []string{"orders-in-progress.dlq:a1"}, published)
+			assert.Equal(t, []string{"a"}, keys)
+		})
+	}
+}
+
+func TestRabbitMQConsumer_RequeuesWhenStoppedBeforeDeadLettering(t *testing.T) {
+	channel := &fakeRabbitMQChannel{deliveries: make(chan amqp.Delivery, 10)}
+	acknowledger := &fakeAcknowledger{}
+	deadLetters := &fakeDeadLetters{err: errors.New("connection closed")}
+
+	attempted := make(chan struct{}, 10)
+	stop := consumeRabbitMQ(t, channel, 1, 1, deadLetters, func(ctx context.Context, message []byte) error {
+		attempted <- struct{}{}
+		return errors.New("invalid order")
+	})
+
+	channel.deliveries <- rabbitDelivery(acknowledger, 1, "a1")
+	<-attempted
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	acked, requeued := acknowledger.outcomes()
+	assert.Empty(t, acked)
+	assert.Equal(t, []uint64{1}, requeued, "the delivery goes back to the queue")
+	published, _ := deadLetters.messages()
+	assert.Empty(t, published)
+}
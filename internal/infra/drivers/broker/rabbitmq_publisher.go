@@ -3,7 +3,12 @@ package broker
 import (
 	"context"
 
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type rabbitMQPublisher struct {
@@ -16,16 +21,33 @@ func NewRabbitMQPublisher(conn *amqp.Connection, channel *amqp.Channel, exchange
 	return &rabbitMQPublisher{connection: conn, channel: channel, exchange: exchange}
 }
 
-func (c *rabbitMQPublisher) Publish(ctx context.Context, destination string, message []byte) error {
-	err := c.channel.Publish(
+// Publish routes the message by destination; RabbitMQ has no use for the key.
+func (c *rabbitMQPublisher) Publish(ctx context.Context, destination string, key string, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(c.exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(destination),
+		),
+	)
+	defer tracing.EndSpan(span, &err)
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, amqpHeadersCarrier(headers))
+
+	err = c.channel.PublishWithContext(
+		ctx,
 		c.exchange,  //exchange,
 		destination, // routing key
 		false,       // mandatory
 		false,       // immediate
 		amqp.Publishing{
 			ContentType: "application/json",
+			Headers:     headers,
 			Body:        message,
 		})
+	metrics.IncPublished(c.exchange, destination, err)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,86 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SNSClient is the part of *sns.Client the publisher uses.
+type SNSClient interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type snsPublisher struct {
+	client SNSClient
+}
+
+func NewSNSPublisher(client SNSClient) Publisher {
+	return &snsPublisher{client: client}
+}
+
+// Publish sends the message to the destination topic ARN. The key and the
+// trace context travel as message attributes; on FIFO topics the key is also
+// the message group, keeping the events of an order in order.
+func (p *snsPublisher) Publish(ctx context.Context, destination string, key string, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, destination+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("aws_sns"),
+			semconv.MessagingDestinationName(destination),
+		),
+	)
+	defer tracing.EndSpan(span, &err)
+
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+
+	attributes := map[string]types.MessageAttributeValue{
+		"content-type": stringAttribute("application/json"),
+	}
+	if key != "" {
+		attributes["message-key"] = stringAttribute(key)
+	}
+	for name, value := range headers {
+		attributes[name] = stringAttribute(value)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(destination),
+		Message:           aws.String(string(message)),
+		MessageAttributes: attributes,
+	}
+	if strings.HasSuffix(destination, ".fifo") && key != "" {
+		// deduplication relies on the topic content-based deduplication
+		input.MessageGroupId = aws.String(key)
+	}
+
+	_, err = p.client.Publish(ctx, input)
+	metrics.IncPublished("sns", destination, err)
+	if err != nil {
+		return fmt.Errorf("failed to publish to topic [%s]: %w", destination, err)
+	}
+
+	return nil
+}
+
+func (p *snsPublisher) Close() error {
+	return nil
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
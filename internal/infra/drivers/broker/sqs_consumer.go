@@ -0,0 +1,305 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// sqsWaitTimeSeconds is the longest long polling SQS allows.
+	sqsWaitTimeSeconds = 20
+	sqsMaxMessages     = 10
+	sqsMaxRetryBackoff = 10 * time.Second
+	// a failed message is released for sqsReleaseBackoff, doubled on each
+	// receive up to sqsMaxReleaseBackoff
+	sqsReleaseBackoff    = 2 * time.Second
+	sqsMaxReleaseBackoff = 5 * time.Minute
+)
+
+// SQSClient is the part of *sqs.Client the consumer uses.
+type SQSClient interface {
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+type sqsConsumer struct {
+	client            SQSClient
+	queueName         string
+	queueURL          string
+	visibilityTimeout time.Duration
+	processingTimeout time.Duration
+	retryBackoff      time.Duration
+	releaseBackoff    time.Duration
+	logger            logger.Logger
+}
+
+// NewSQSConsumer resolves the URL of queueName. Received messages stay
+// invisible for visibilityTimeout, extended while they are processed.
+func NewSQSConsumer(ctx context.Context, client SQSClient, queueName string, visibilityTimeout time.Duration, processingTimeout time.Duration, logger logger.Logger) (Consumer, error) {
+	output, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the url of the queue [%s]: %w", queueName, err)
+	}
+
+	return &sqsConsumer{
+		client:            client,
+		queueName:         queueName,
+		queueURL:          aws.ToString(output.QueueUrl),
+		visibilityTimeout: visibilityTimeout,
+		processingTimeout: processingTimeout,
+		retryBackoff:      time.Second,
+		releaseBackoff:    sqsReleaseBackoff,
+		logger:            logger.WithFields(map[string]any{"queue": queueName}),
+	}, nil
+}
+
+// StartConsumer long polls the queue until ctx is cancelled. A processed
+// message is deleted; a failed one is made visible again, which is how SQS
+// requeues, after a delay growing with its receive count so a message that
+// keeps failing is not redelivered in a loop. Receive errors are retried
+// with backoff.
+func (c *sqsConsumer) StartConsumer(ctx context.Context, processMessage func(ctx context.Context, message []byte) error) {
+	c.logger.Infof("Starting consuming queue [%s]", c.queueName)
+	backoff := c.retryBackoff
+	for {
+		output, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(c.queueURL),
+			MaxNumberOfMessages:         sqsMaxMessages,
+			WaitTimeSeconds:             sqsWaitTimeSeconds,
+			VisibilityTimeout:           int32(c.visibilityTimeout.Seconds()),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+		})
+		if ctx.Err() != nil {
+			c.logger.Infof("Stopping consuming queue [%s]", c.queueName)
+			return
+		}
+		if err != nil {
+			c.logger.WithError(err).Errorf("failed to receive messages, retrying in [%s]", backoff)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > sqsMaxRetryBackoff {
+				backoff = sqsMaxRetryBackoff
+			}
+			continue
+		}
+		backoff = c.retryBackoff
+
+		c.handleBatch(ctx, output.Messages, processMessage)
+	}
+}
+
+// handleBatch processes the messages one by one while a heartbeat keeps
+// the ones not yet handled invisible.
+func (c *sqsConsumer) handleBatch(ctx context.Context, messages []types.Message, processMessage func(ctx context.Context, message []byte) error) {
+	if len(messages) == 0 {
+		return
+	}
+
+	inFlight := &sqsInFlight{handles: map[string]bool{}}
+	for _, msg := range messages {
+		inFlight.handles[aws.ToString(msg.ReceiptHandle)] = true
+	}
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go c.extendVisibility(heartbeatCtx, inFlight)
+
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			// the rest becomes visible again once the timeout expires
+			return
+		}
+		c.handleMessage(ctx, msg, inFlight, processMessage)
+	}
+}
+
+// sqsInFlight holds the receipt handles of the batch not yet deleted or
+// released. The heartbeat extends a copy of them, so a message settled
+// meanwhile may be extended once more: that fails for a deleted message and
+// only delays a released one further.
+type sqsInFlight struct {
+	mu      sync.Mutex
+	handles map[string]bool
+}
+
+func (f *sqsInFlight) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	handles := make([]string, 0, len(f.handles))
+	for handle := range f.handles {
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+func (f *sqsInFlight) has(handle string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.handles[handle]
+}
+
+func (c *sqsConsumer) extendVisibility(ctx context.Context, inFlight *sqsInFlight) {
+	ticker := time.NewTicker(c.visibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, handle := range inFlight.list() {
+			err := c.changeVisibility(ctx, handle, c.visibilityTimeout)
+			if err != nil && ctx.Err() == nil && inFlight.has(handle) {
+				c.logger.WithError(err).Warnf("failed to extend message visibility")
+			}
+		}
+	}
+}
+
+func (c *sqsConsumer) handleMessage(ctx context.Context, msg types.Message, inFlight *sqsInFlight, processMessage func(ctx context.Context, message []byte) error) {
+	metrics.IncConsumerReceived(c.queueName)
+
+	messageID := aws.ToString(msg.MessageId)
+	body, attributes := unwrapSQSMessage(msg)
+
+	ctx = logger.ContextWithMessageID(ctx, messageID)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, attributes)
+	ctx, span := tracer.Start(ctx, c.queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemAWSSqs,
+			semconv.MessagingDestinationName(c.queueName),
+			semconv.MessagingMessageID(messageID),
+			attribute.Int("messaging.message.body.size", len(body)),
+			attribute.String("messaging.sqs.receive_count", msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)]),
+		),
+	)
+
+	processCtx, cancel := context.WithTimeout(ctx, c.processingTimeout)
+	defer cancel()
+
+	c.logger.WithContext(processCtx).Debugf("Received a message: %s", body)
+
+	start := time.Now()
+	err := processMessage(processCtx, body)
+	metrics.ObserveConsumerProcessing(c.queueName, start, err)
+	tracing.EndSpan(span, &err)
+
+	handle := aws.ToString(msg.ReceiptHandle)
+	inFlight.mu.Lock()
+	delete(inFlight.handles, handle)
+	inFlight.mu.Unlock()
+
+	// settle the message even if shutdown has begun
+	settleCtx, cancelSettle := context.WithTimeout(context.Background(), c.processingTimeout)
+	defer cancelSettle()
+
+	if err != nil {
+		c.logger.WithContext(processCtx).WithError(err).Errorf("failed to process message")
+		err = c.changeVisibility(settleCtx, handle, c.releaseVisibility(msg))
+		if err != nil {
+			c.logger.WithContext(processCtx).WithError(err).Warnf("failed to release message")
+		}
+		metrics.IncConsumerNacked(c.queueName)
+		return
+	}
+
+	_, err = c.client.DeleteMessage(settleCtx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueURL),
+		ReceiptHandle: aws.String(handle),
+	})
+	if err != nil {
+		// it will be delivered again and must be handled idempotently
+		c.logger.WithContext(processCtx).WithError(err).Errorf("failed to delete message")
+		return
+	}
+	metrics.IncConsumerAcked(c.queueName)
+}
+
+// releaseVisibility is how long a failed message stays invisible, doubling
+// with every receive.
+func (c *sqsConsumer) releaseVisibility(msg types.Message) time.Duration {
+	receiveCount, err := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || receiveCount < 1 {
+		receiveCount = 1
+	}
+
+	visibility := c.releaseBackoff
+	for i := 1; i < receiveCount && visibility < sqsMaxReleaseBackoff; i++ {
+		visibility *= 2
+	}
+	if visibility > sqsMaxReleaseBackoff {
+		visibility = sqsMaxReleaseBackoff
+	}
+	return visibility
+}
+
+func (c *sqsConsumer) changeVisibility(ctx context.Context, handle string, timeout time.Duration) error {
+	_, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.queueURL),
+		ReceiptHandle:     aws.String(handle),
+		VisibilityTimeout: int32(timeout.Seconds()),
+	})
+	return err
+}
+
+// snsNotification is the envelope SNS wraps messages in when the queue
+// subscription does not use raw message delivery.
+type snsNotification struct {
+	Type              string `json:"Type"`
+	TopicArn          string `json:"TopicArn"`
+	Message           string `json:"Message"`
+	MessageAttributes map[string]struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// unwrapSQSMessage returns the published body and its string attributes,
+// unwrapping the SNS envelope when present.
+func unwrapSQSMessage(msg types.Message) ([]byte, propagation.MapCarrier) {
+	body := aws.ToString(msg.Body)
+
+	notification := snsNotification{}
+	if json.Unmarshal([]byte(body), &notification) == nil && notification.Type == "Notification" && notification.TopicArn != "" {
+		attributes := propagation.MapCarrier{}
+		for name, value := range notification.MessageAttributes {
+			if value.Type == "String" {
+				attributes[name] = value.Value
+			}
+		}
+		return []byte(notification.Message), attributes
+	}
+
+	attributes := propagation.MapCarrier{}
+	for name, value := range msg.MessageAttributes {
+		if value.StringValue != nil {
+			attributes[name] = *value.StringValue
+		}
+	}
+	return []byte(body), attributes
+}
@@ -0,0 +1,164 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ordersReadyTopic     = "arn:aws:sns:us-east-1:000000000000:orders-ready"
+	ordersReadyFifoTopic = "arn:aws:sns:us-east-1:000000000000:orders-ready.fifo"
+)
+
+// consumeSQS runs a consumer of queue until stop is called.
+func consumeSQS(t *testing.T, fake *fakeAWS, queue string, visibilityTimeout time.Duration, process func(ctx context.Context, message []byte) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer, err := NewSQSConsumer(ctx, fake.sqsClient(), queue, visibilityTimeout, 5*time.Second, logger.NewNopLogger())
+	require.NoError(t, err)
+	consumer.(*sqsConsumer).retryBackoff = time.Millisecond
+	consumer.(*sqsConsumer).releaseBackoff = time.Second
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.StartConsumer(ctx, process)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func tracedContext() (context.Context, trace.SpanContext) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x03},
+		SpanID:     trace.SpanID{0x04},
+		TraceFlags: trace.FlagsSampled,
+	})
+	return trace.ContextWithSpanContext(context.Background(), spanContext), spanContext
+}
+
+func TestSNSPublisher_Publish(t *testing.T) {
+	fake := newFakeAWS(t)
+	publisher := NewSNSPublisher(fake.snsClient())
+	ctx, spanContext := tracedContext()
+
+	require.NoError(t, publisher.Publish(ctx, ordersReadyTopic, "7", []byte(`{"orderId":7}`)))
+	require.NoError(t, publisher.Publish(ctx, ordersReadyFifoTopic, "8", []byte(`{"orderId":8}`)))
+
+	published := fake.publishes()
+	require.Len(t, published, 2)
+
+	assert.Equal(t, ordersReadyTopic, published[0].topicArn)
+	assert.Equal(t, `{"orderId":7}`, published[0].message)
+	assert.Equal(t, "7", published[0].attributes["message-key"])
+	assert.Equal(t, "application/json", published[0].attributes["content-type"])
+	assert.Contains(t, published[0].attributes["traceparent"], spanContext.TraceID().String())
+	assert.Empty(t, published[0].messageGroupID, "standard topics have no message groups")
+
+	assert.Equal(t, "8", published[1].messageGroupID, "the key groups the messages of a fifo topic")
+}
+
+func TestSQSConsumer_UnwrapsNotificationsAndDeletesOnSuccess(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		fake := newFakeAWS(t)
+		fake.createQueue("orders-ready")
+		fake.subscribe(ordersReadyTopic, "orders-ready", raw)
+
+		ctx, spanContext := tracedContext()
+		require.NoError(t, NewSNSPublisher(fake.snsClient()).Publish(ctx, ordersReadyTopic, "7", []byte(`{"orderId":7}`)))
+
+		received := make(chan string, 1)
+		var traceID trace.TraceID
+		stop := consumeSQS(t, fake, "orders-ready", 30*time.Second, func(ctx context.Context, message []byte) error {
+			traceID = trace.SpanContextFromContext(ctx).TraceID()
+			received <- string(message)
+			return nil
+		})
+
+		select {
+		case message := <-received:
+			assert.Equal(t, `{"orderId":7}`, message, "raw delivery: %v", raw)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not consumed")
+		}
+		assert.Eventually(t, func() bool { return fake.remaining("orders-ready") == 0 }, 2*time.Second, 10*time.Millisecond, "processed messages are deleted")
+		stop()
+
+		assert.Equal(t, spanContext.TraceID(), traceID, "the trace continues from the publisher")
+	}
+}
+
+func TestSQSConsumer_FailedMessageIsReleased(t *testing.T) {
+	fake := newFakeAWS(t)
+	fake.createQueue("orders-in-progress")
+	fake.send("orders-in-progress", `{"id":1}`, nil)
+
+	var attempts atomic.Int32
+	stop := consumeSQS(t, fake, "orders-in-progress", 30*time.Second, func(ctx context.Context, message []byte) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("transient failure")
+		}
+		return nil
+	})
+	defer stop()
+
+	// with a 30s visibility timeout the retry only happens this soon because
+	// the failed message was released for the 1s backoff
+	assert.Eventually(t, func() bool { return fake.remaining("orders-in-progress") == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Equal(t, 2, fake.receiveCount("orders-in-progress", 0))
+}
+
+func TestSQSConsumer_ReleaseVisibilityGrowsWithReceives(t *testing.T) {
+	consumer := &sqsConsumer{releaseBackoff: sqsReleaseBackoff}
+	receive := func(count string) types.Message {
+		return types.Message{Attributes: map[string]string{string(types.MessageSystemAttributeNameApproximateReceiveCount): count}}
+	}
+
+	assert.Equal(t, 2*time.Second, consumer.releaseVisibility(types.Message{}))
+	assert.Equal(t, 2*time.Second, consumer.releaseVisibility(receive("1")))
+	assert.Equal(t, 4*time.Second, consumer.releaseVisibility(receive("2")))
+	assert.Equal(t, 16*time.Second, consumer.releaseVisibility(receive("4")))
+	assert.Equal(t, sqsMaxReleaseBackoff, consumer.releaseVisibility(receive("50")))
+}
+
+func TestSQSConsumer_ExtendsVisibilityWhileProcessing(t *testing.T) {
+	fake := newFakeAWS(t)
+	fake.createQueue("orders-in-progress")
+	fake.send("orders-in-progress", `{"id":1}`, nil)
+
+	var attempts atomic.Int32
+	stop := consumeSQS(t, fake, "orders-in-progress", time.Second, func(ctx context.Context, message []byte) error {
+		attempts.Add(1)
+		time.Sleep(1500 * time.Millisecond)
+		return nil
+	})
+	defer stop()
+
+	assert.Eventually(t, func() bool { return fake.remaining("orders-in-progress") == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), attempts.Load(), "the message must not be redelivered while it is processed")
+	assert.Equal(t, 1, fake.receiveCount("orders-in-progress", 0))
+	assert.GreaterOrEqual(t, fake.visibilityExtensions(), 2)
+}
+
+func TestNewSQSConsumer_UnknownQueue(t *testing.T) {
+	fake := newFakeAWS(t)
+
+	_, err := NewSQSConsumer(context.Background(), fake.sqsClient(), "missing", 30*time.Second, time.Second, logger.NewNopLogger())
+
+	assert.ErrorContains(t, err, "failed to get the url of the queue [missing]")
+}
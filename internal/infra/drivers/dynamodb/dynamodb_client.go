@@ -0,0 +1,175 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrConditionFailed is returned when the condition expression of a write
+// does not hold for the stored item.
+var ErrConditionFailed = errors.New("dynamodb condition check failed")
+
+type DynamoDBClient interface {
+	GetItem(ctx context.Context, tableName string, key map[string]types.AttributeValue) (map[string]types.AttributeValue, error)
+	QueryItem(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+	QueryItemDescending(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+	ScanItem(ctx context.Context, tableName string, expr expression.Expression, segment int32, totalSegments int32, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+	PutItem(ctx context.Context, tableName string, item map[string]types.AttributeValue, expr expression.Expression) error
+	UpdateItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error
+	DeleteItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error
+	DescribeTable(ctx context.Context, tableName string) (*types.TableDescription, error)
+}
+
+type dynamoDBClient struct {
+	client *dynamodb.Client
+}
+
+func NewDynamoDBClient(client *dynamodb.Client) DynamoDBClient {
+	return &dynamoDBClient{client: client}
+}
+
+func (d *dynamoDBClient) GetItem(ctx context.Context, tableName string, key map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	result, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &tableName,
+		Key:       key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Item, nil
+}
+
+// QueryItem returns a single page of the query along with the key to resume
+// from, which is nil on the last page. A limit of zero reads every page.
+func (d *dynamoDBClient) QueryItem(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	return d.query(ctx, tableName, expr, indexName, limit, startKey, true)
+}
+
+// QueryItemDescending is QueryItem reading the index in descending order
+// of its sort key.
+func (d *dynamoDBClient) QueryItemDescending(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	return d.query(ctx, tableName, expr, indexName, limit, startKey, false)
+}
+
+func (d *dynamoDBClient) query(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue, ascending bool) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 &tableName,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		IndexName:                 aws.String(indexName),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(ascending),
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(limit)
+	}
+
+	var items []map[string]types.AttributeValue
+	for {
+		response, err := d.client.Query(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		items = append(items, response.Items...)
+		if limit > 0 || response.LastEvaluatedKey == nil {
+			return items, response.LastEvaluatedKey, nil
+		}
+		input.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
+
+// ScanItem returns a single page of the scan along with the key to resume
+// from, which is nil on the last page. With more than one segment, only
+// the given segment of a parallel scan is read. The limit bounds the items
+// read, before the filter is applied.
+func (d *dynamoDBClient) ScanItem(ctx context.Context, tableName string, expr expression.Expression, segment int32, totalSegments int32, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                 &tableName,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ExclusiveStartKey:         startKey,
+	}
+	if totalSegments > 1 {
+		input.Segment = aws.Int32(segment)
+		input.TotalSegments = aws.Int32(totalSegments)
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(limit)
+	}
+
+	response, err := d.client.Scan(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	return response.Items, response.LastEvaluatedKey, nil
+}
+
+func (d *dynamoDBClient) PutItem(ctx context.Context, tableName string, item map[string]types.AttributeValue, expr expression.Expression) error {
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 &tableName,
+		Item:                      item,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func (d *dynamoDBClient) UpdateItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error {
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &tableName,
+		Key:                       key,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func (d *dynamoDBClient) DeleteItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 &tableName,
+		Key:                       key,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func (d *dynamoDBClient) DescribeTable(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	result, err := d.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: &tableName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Table, nil
+}
+
+func mapError(err error) error {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return ErrConditionFailed
+	}
+	return err
+}
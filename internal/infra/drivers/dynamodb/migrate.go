@@ -0,0 +1,409 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// migrationControlPK is the PK of the item recording the applied
+// migrations and the progress of the running one. Migrations assume a
+// table keyed by PK alone.
+const migrationControlPK = "MIGRATIONS"
+
+// migrationPageSize is how many items a segment reads per scan by default,
+// and so how many are migrated again when an interrupted migration is
+// resumed.
+const migrationPageSize = 100
+
+const (
+	MigrationStatusApplied  = "APPLIED"
+	MigrationStatusMigrated = "MIGRATED"
+	MigrationStatusPending  = "PENDING"
+)
+
+// ErrMigrationRunning is returned when the control item shows another
+// migration in progress than the one about to run.
+var ErrMigrationRunning = errors.New("another migration is running")
+
+// Migration rewrites the items of a table matching Filter, or every item
+// when it is not set. Transform returns the change of one item, or false
+// when it has nothing to change, which keeps the migration idempotent;
+// the condition of the change should only hold while the item is still as
+// it was read.
+type Migration struct {
+	Version   int
+	Name      string
+	Filter    expression.ConditionBuilder
+	Transform func(item map[string]types.AttributeValue) (ItemChange, bool, error)
+}
+
+type ItemChange struct {
+	Update    expression.UpdateBuilder
+	Condition expression.ConditionBuilder
+}
+
+// MigrateOptions sets how many segments of the parallel scan are read at
+// once, how many items each one reads per scan and, with DryRun, only
+// counts the items the migrations would change.
+type MigrateOptions struct {
+	Segments int
+	PageSize int32
+	DryRun   bool
+}
+
+// MigrationResult counts the items matching the filter of a migration,
+// the ones changed and the ones skipped because they were already migrated
+// or changed meanwhile. Already applied migrations keep their counts.
+type MigrationResult struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Resumed bool   `json:"resumed,omitempty"`
+	Scanned int    `json:"scanned"`
+	Updated int    `json:"updated"`
+	Skipped int    `json:"skipped"`
+}
+
+type migrationControl struct {
+	Applied        map[string]appliedMigration `dynamodbav:"Applied"`
+	RunningVersion int                         `dynamodbav:"RunningVersion,omitempty"`
+	TotalSegments  int                         `dynamodbav:"TotalSegments,omitempty"`
+	Segments       map[string]segmentProgress  `dynamodbav:"Segments,omitempty"`
+}
+
+type appliedMigration struct {
+	Name      string    `dynamodbav:"Name"`
+	AppliedAt time.Time `dynamodbav:"AppliedAt"`
+	Scanned   int       `dynamodbav:"Scanned"`
+	Updated   int       `dynamodbav:"Updated"`
+	Skipped   int       `dynamodbav:"Skipped"`
+}
+
+// segmentProgress is saved after every page, LastKey being where the scan
+// of the segment resumes from.
+type segmentProgress struct {
+	LastKey map[string]string `dynamodbav:"LastKey,omitempty"`
+	Done    bool              `dynamodbav:"Done"`
+	Scanned int               `dynamodbav:"Scanned"`
+	Updated int               `dynamodbav:"Updated"`
+	Skipped int               `dynamodbav:"Skipped"`
+}
+
+// Migrate runs, in order of version, every migration not yet recorded as
+// applied in the control item. An interrupted migration resumes from the
+// last page saved by each of its segments, keeping its segment count.
+func Migrate(ctx context.Context, client DynamoDBClient, table string, migrations []Migration, options MigrateOptions) ([]MigrationResult, error) {
+	migrations = append([]Migration{}, migrations...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicated migration version [%d]", migrations[i].Version)
+		}
+	}
+	options = options.withDefaults()
+
+	control, err := loadMigrationControl(ctx, client, table, options.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MigrationResult{}
+	for _, m := range migrations {
+		if applied, ok := control.Applied[strconv.Itoa(m.Version)]; ok {
+			results = append(results, MigrationResult{
+				Version: m.Version,
+				Name:    m.Name,
+				Status:  MigrationStatusApplied,
+				Scanned: applied.Scanned,
+				Updated: applied.Updated,
+				Skipped: applied.Skipped,
+			})
+			continue
+		}
+
+		result, err := runMigration(ctx, client, table, m, control, options)
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// ApplyMigration runs m over the whole table without recording it in the
+// control item, for one-off jobs that can run again at any time. An
+// interrupted run starts over, which the idempotence of the migration
+// makes safe.
+func ApplyMigration(ctx context.Context, client DynamoDBClient, table string, m Migration, options MigrateOptions) (MigrationResult, error) {
+	options = options.withDefaults()
+	result := MigrationResult{Version: m.Version, Name: m.Name, Status: MigrationStatusPending}
+
+	err := runMigrationSegments(ctx, client, table, m, options.Segments, map[string]segmentProgress{}, options, &result, nil)
+	if err != nil {
+		return result, err
+	}
+	if !options.DryRun {
+		result.Status = MigrationStatusMigrated
+	}
+	return result, nil
+}
+
+func (o MigrateOptions) withDefaults() MigrateOptions {
+	if o.Segments < 1 {
+		o.Segments = 1
+	}
+	if o.PageSize < 1 {
+		o.PageSize = migrationPageSize
+	}
+	return o
+}
+
+// loadMigrationControl reads the control item, creating it unless dryRun.
+func loadMigrationControl(ctx context.Context, client DynamoDBClient, table string, dryRun bool) (migrationControl, error) {
+	control := migrationControl{Applied: map[string]appliedMigration{}}
+	key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: migrationControlPK}}
+
+	item, err := client.GetItem(ctx, table, key)
+	if err != nil {
+		return control, fmt.Errorf("failed to get migration control: %w", err)
+	}
+	if len(item) > 0 {
+		err = attributevalue.UnmarshalMap(item, &control)
+		if err != nil {
+			return control, fmt.Errorf("failed to unmarshal migration control: %w", err)
+		}
+		return control, nil
+	}
+	if dryRun {
+		return control, nil
+	}
+
+	item, err = attributevalue.MarshalMap(control)
+	if err != nil {
+		return control, fmt.Errorf("failed to marshal migration control: %w", err)
+	}
+	item["PK"] = key["PK"]
+	expr, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("PK"))).Build()
+	if err != nil {
+		return control, fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	// created meanwhile by another run, which is fine as long as it is empty
+	err = client.PutItem(ctx, table, item, expr)
+	if err != nil && !errors.Is(err, ErrConditionFailed) {
+		return control, fmt.Errorf("failed to create migration control: %w", err)
+	}
+	return control, nil
+}
+
+func runMigration(ctx context.Context, client DynamoDBClient, table string, m Migration, control migrationControl, options MigrateOptions) (MigrationResult, error) {
+	result := MigrationResult{Version: m.Version, Name: m.Name, Status: MigrationStatusPending}
+
+	totalSegments := options.Segments
+	progress := map[string]segmentProgress{}
+	switch {
+	case options.DryRun:
+	case control.RunningVersion == m.Version:
+		result.Resumed = true
+		totalSegments = control.TotalSegments
+		progress = control.Segments
+	case control.RunningVersion != 0:
+		return result, fmt.Errorf("%w: migration [%d] must be resumed before [%d]", ErrMigrationRunning, control.RunningVersion, m.Version)
+	default:
+		err := updateMigrationControl(ctx, client, table,
+			expression.Set(expression.Name("RunningVersion"), expression.Value(m.Version)).
+				Set(expression.Name("TotalSegments"), expression.Value(totalSegments)).
+				Set(expression.Name("Segments"), expression.Value(map[string]segmentProgress{})),
+			expression.AttributeNotExists(expression.Name("RunningVersion")))
+		if errors.Is(err, ErrConditionFailed) {
+			return result, fmt.Errorf("%w: migration [%d] was started by another run", ErrMigrationRunning, m.Version)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	var saveProgress func(segment int, progress segmentProgress) error
+	if !options.DryRun {
+		saveProgress = func(segment int, progress segmentProgress) error {
+			return updateMigrationControl(ctx, client, table,
+				expression.Set(expression.Name("Segments."+strconv.Itoa(segment)), expression.Value(progress)),
+				expression.Name("RunningVersion").Equal(expression.Value(m.Version)))
+		}
+	}
+	err := runMigrationSegments(ctx, client, table, m, totalSegments, progress, options, &result, saveProgress)
+	if err != nil {
+		return result, err
+	}
+	if options.DryRun {
+		return result, nil
+	}
+
+	applied := appliedMigration{Name: m.Name, AppliedAt: time.Now().UTC(), Scanned: result.Scanned, Updated: result.Updated, Skipped: result.Skipped}
+	err = updateMigrationControl(ctx, client, table,
+		expression.Set(expression.Name("Applied."+strconv.Itoa(m.Version)), expression.Value(applied)).
+			Remove(expression.Name("RunningVersion")).
+			Remove(expression.Name("TotalSegments")).
+			Remove(expression.Name("Segments")),
+		expression.Name("RunningVersion").Equal(expression.Value(m.Version)))
+	if err != nil {
+		return result, fmt.Errorf("failed to record migration [%d %s]: %w", m.Version, m.Name, err)
+	}
+
+	result.Status = MigrationStatusMigrated
+	return result, nil
+}
+
+// runMigrationSegments migrates every segment in parallel, adding their
+// counts to result. saveProgress, when set, is called after every page of
+// a segment.
+func runMigrationSegments(ctx context.Context, client DynamoDBClient, table string, m Migration, totalSegments int, progress map[string]segmentProgress, options MigrateOptions, result *MigrationResult, saveProgress func(segment int, progress segmentProgress) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	for segment := 0; segment < totalSegments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+
+			segmentResult, err := runMigrationSegment(ctx, client, table, m, segment, totalSegments, progress[strconv.Itoa(segment)], options, saveProgress)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Scanned += segmentResult.Scanned
+			result.Updated += segmentResult.Updated
+			result.Skipped += segmentResult.Skipped
+			if err != nil {
+				errs = append(errs, fmt.Errorf("segment [%d]: %w", segment, err))
+				cancel()
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to apply migration [%d %s]: %w", m.Version, m.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// runMigrationSegment migrates one segment from where its progress stopped
+// and returns its progress, counting the pages of previous runs.
+func runMigrationSegment(ctx context.Context, client DynamoDBClient, table string, m Migration, segment int, totalSegments int, progress segmentProgress, options MigrateOptions, saveProgress func(segment int, progress segmentProgress) error) (segmentProgress, error) {
+	if progress.Done {
+		return progress, nil
+	}
+
+	var expr expression.Expression
+	if m.Filter.IsSet() {
+		var err error
+		expr, err = expression.NewBuilder().WithFilter(m.Filter).Build()
+		if err != nil {
+			return progress, fmt.Errorf("failed to create scan expr: %w", err)
+		}
+	}
+
+	var startKey map[string]types.AttributeValue
+	if len(progress.LastKey) > 0 {
+		var err error
+		startKey, err = attributevalue.MarshalMap(progress.LastKey)
+		if err != nil {
+			return progress, fmt.Errorf("failed to marshal scan key: %w", err)
+		}
+	}
+
+	for {
+		items, lastKey, err := client.ScanItem(ctx, table, expr, int32(segment), int32(totalSegments), options.PageSize, startKey)
+		if err != nil {
+			return progress, fmt.Errorf("failed to scan items: %w", err)
+		}
+
+		for _, item := range items {
+			if pk, ok := item["PK"].(*types.AttributeValueMemberS); ok && pk.Value == migrationControlPK {
+				continue
+			}
+			progress.Scanned++
+
+			updated, err := migrateItem(ctx, client, table, m, item, options.DryRun)
+			if err != nil {
+				return progress, err
+			}
+			if updated {
+				progress.Updated++
+			} else {
+				progress.Skipped++
+			}
+		}
+
+		progress.LastKey = nil
+		err = attributevalue.UnmarshalMap(lastKey, &progress.LastKey)
+		if err != nil {
+			return progress, fmt.Errorf("failed to unmarshal scan key: %w", err)
+		}
+		progress.Done = len(lastKey) == 0
+
+		if saveProgress != nil {
+			err = saveProgress(segment, progress)
+			if err != nil {
+				return progress, fmt.Errorf("failed to save progress: %w", err)
+			}
+		}
+
+		if progress.Done {
+			return progress, nil
+		}
+		startKey = lastKey
+	}
+}
+
+// migrateItem writes the change of the item and reports whether it was
+// written, or would be with dryRun.
+func migrateItem(ctx context.Context, client DynamoDBClient, table string, m Migration, item map[string]types.AttributeValue, dryRun bool) (bool, error) {
+	change, ok, err := m.Transform(item)
+	if err != nil {
+		return false, fmt.Errorf("failed to transform item: %w", err)
+	}
+	if !ok || dryRun {
+		return ok, nil
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(change.Update).WithCondition(change.Condition).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = client.UpdateItem(ctx, table, map[string]types.AttributeValue{"PK": item["PK"]}, expr)
+	if errors.Is(err, ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update item: %w", err)
+	}
+
+	return true, nil
+}
+
+func updateMigrationControl(ctx context.Context, client DynamoDBClient, table string, update expression.UpdateBuilder, condition expression.ConditionBuilder) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: migrationControlPK}}
+	return client.UpdateItem(ctx, table, key, expr)
+}
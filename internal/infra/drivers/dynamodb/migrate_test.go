@@ -0,0 +1,272 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mock_dynamodb "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb/mocks"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+var controlKey = map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "MIGRATIONS"}}
+
+// markMigration sets Migrated on every item without it.
+func markMigration(version int) Migration {
+	return Migration{
+		Version: version,
+		Name:    "mark",
+		Transform: func(item map[string]types.AttributeValue) (ItemChange, bool, error) {
+			if _, ok := item["Migrated"]; ok {
+				return ItemChange{}, false, nil
+			}
+			return ItemChange{
+				Update:    expression.Set(expression.Name("Migrated"), expression.Value(true)),
+				Condition: expression.AttributeNotExists(expression.Name("Migrated")),
+			}, true, nil
+		},
+	}
+}
+
+func markExpr() expression.Expression {
+	expr, _ := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("Migrated"), expression.Value(true))).
+		WithCondition(expression.AttributeNotExists(expression.Name("Migrated"))).
+		Build()
+	return expr
+}
+
+func testItem(pk string, migrated bool) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: pk}}
+	if migrated {
+		item["Migrated"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+	return item
+}
+
+func itemKey(pk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: pk}}
+}
+
+func controlItem(control migrationControl) map[string]types.AttributeValue {
+	item, _ := attributevalue.MarshalMap(control)
+	item["PK"] = controlKey["PK"]
+	return item
+}
+
+func TestMigrate(t *testing.T) {
+	table := "Kitchen"
+
+	tests := []struct {
+		name            string
+		migrations      []Migration
+		options         MigrateOptions
+		mockSetup       func(client *mock_dynamodb.MockDynamoDBClient)
+		expectedResults []MigrationResult
+		expectedError   error
+	}{
+		{
+			name:       "applies pending migrations",
+			migrations: []Migration{markMigration(2), markMigration(1)},
+			options:    MigrateOptions{Segments: 1},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				applied := migrationControl{Applied: map[string]appliedMigration{"1": {Name: "mark", Scanned: 7, Updated: 7}}}
+				gomock.InOrder(
+					client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(controlItem(applied), nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil),
+					client.EXPECT().
+						ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(100), nil).
+						Return([]map[string]types.AttributeValue{controlItem(applied), testItem("1", false), testItem("2", true), testItem("3", false)}, nil, nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("1"), markExpr()).Return(nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("3"), markExpr()).Return(ErrConditionFailed),
+					client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil),
+				)
+			},
+			expectedResults: []MigrationResult{
+				{Version: 1, Name: "mark", Status: MigrationStatusApplied, Scanned: 7, Updated: 7},
+				{Version: 2, Name: "mark", Status: MigrationStatusMigrated, Scanned: 3, Updated: 1, Skipped: 2},
+			},
+		},
+		{
+			name:       "creates the control item",
+			migrations: []Migration{markMigration(1)},
+			options:    MigrateOptions{Segments: 2},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(nil, nil)
+				client.EXPECT().PutItem(gomock.Any(), table, controlItem(migrationControl{Applied: map[string]appliedMigration{}}), gomock.Any()).Return(nil)
+				client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil).Times(4)
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(2), int32(100), nil).
+					Return([]map[string]types.AttributeValue{testItem("1", false)}, nil, nil)
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(1), int32(2), int32(100), nil).
+					Return([]map[string]types.AttributeValue{testItem("2", false)}, nil, nil)
+				client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("1"), markExpr()).Return(nil)
+				client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("2"), markExpr()).Return(nil)
+			},
+			expectedResults: []MigrationResult{
+				{Version: 1, Name: "mark", Status: MigrationStatusMigrated, Scanned: 2, Updated: 2},
+			},
+		},
+		{
+			name:       "resumes an interrupted migration",
+			migrations: []Migration{markMigration(1)},
+			options:    MigrateOptions{Segments: 4},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				running := migrationControl{
+					Applied:        map[string]appliedMigration{},
+					RunningVersion: 1,
+					TotalSegments:  2,
+					Segments: map[string]segmentProgress{
+						"0": {Done: true, Scanned: 5, Updated: 5},
+						"1": {LastKey: map[string]string{"PK": "9"}, Scanned: 3, Updated: 2, Skipped: 1},
+					},
+				}
+				gomock.InOrder(
+					client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(controlItem(running), nil),
+					client.EXPECT().
+						ScanItem(gomock.Any(), table, expression.Expression{}, int32(1), int32(2), int32(100), itemKey("9")).
+						Return([]map[string]types.AttributeValue{testItem("10", false)}, nil, nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("10"), markExpr()).Return(nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil).Times(2),
+				)
+			},
+			expectedResults: []MigrationResult{
+				{Version: 1, Name: "mark", Status: MigrationStatusMigrated, Resumed: true, Scanned: 9, Updated: 8, Skipped: 1},
+			},
+		},
+		{
+			name:       "dry run",
+			migrations: []Migration{markMigration(1)},
+			options:    MigrateOptions{Segments: 1, DryRun: true},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(nil, nil)
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(100), nil).
+					Return([]map[string]types.AttributeValue{testItem("1", false), testItem("2", true)}, nil, nil)
+			},
+			expectedResults: []MigrationResult{
+				{Version: 1, Name: "mark", Status: MigrationStatusPending, Scanned: 2, Updated: 1, Skipped: 1},
+			},
+		},
+		{
+			name:       "another migration running",
+			migrations: []Migration{markMigration(1)},
+			options:    MigrateOptions{Segments: 1},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				running := migrationControl{Applied: map[string]appliedMigration{}, RunningVersion: 3, TotalSegments: 1}
+				client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(controlItem(running), nil)
+			},
+			expectedResults: []MigrationResult{{Version: 1, Name: "mark", Status: MigrationStatusPending}},
+			expectedError:   ErrMigrationRunning,
+		},
+		{
+			name:       "scan error",
+			migrations: []Migration{markMigration(1)},
+			options:    MigrateOptions{Segments: 1},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				client.EXPECT().GetItem(gomock.Any(), table, controlKey).Return(controlItem(migrationControl{Applied: map[string]appliedMigration{}}), nil)
+				client.EXPECT().UpdateItem(gomock.Any(), table, controlKey, gomock.Any()).Return(nil)
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(100), nil).
+					Return(nil, nil, errors.New("throttled"))
+			},
+			expectedResults: []MigrationResult{{Version: 1, Name: "mark", Status: MigrationStatusPending}},
+			expectedError:   errors.New("failed to apply migration [1 mark]: segment [0]: failed to scan items: throttled"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			tt.mockSetup(client)
+
+			results, err := Migrate(context.Background(), client, table, tt.migrations, tt.options)
+			switch {
+			case tt.expectedError == nil:
+				assert.NoError(t, err)
+			case errors.Is(err, tt.expectedError):
+			default:
+				assert.EqualError(t, err, tt.expectedError.Error())
+			}
+			assert.Equal(t, tt.expectedResults, results)
+		})
+	}
+}
+
+func TestMigrate_DuplicatedVersion(t *testing.T) {
+	_, err := Migrate(context.Background(), nil, "Kitchen", []Migration{markMigration(1), markMigration(1)}, MigrateOptions{})
+	assert.EqualError(t, err, "duplicated migration version [1]")
+}
+
+func TestApplyMigration(t *testing.T) {
+	table := "Kitchen"
+
+	tests := []struct {
+		name           string
+		options        MigrateOptions
+		mockSetup      func(client *mock_dynamodb.MockDynamoDBClient)
+		expectedResult MigrationResult
+		expectedError  error
+	}{
+		{
+			name:    "success",
+			options: MigrateOptions{PageSize: 2},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				gomock.InOrder(
+					client.EXPECT().
+						ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(2), nil).
+						Return([]map[string]types.AttributeValue{testItem("1", false), testItem("2", true)}, itemKey("2"), nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("1"), markExpr()).Return(nil),
+					client.EXPECT().
+						ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(2), itemKey("2")).
+						Return([]map[string]types.AttributeValue{testItem("3", false)}, nil, nil),
+					client.EXPECT().UpdateItem(gomock.Any(), table, itemKey("3"), markExpr()).Return(ErrConditionFailed),
+				)
+			},
+			expectedResult: MigrationResult{Version: 1, Name: "mark", Status: MigrationStatusMigrated, Scanned: 3, Updated: 1, Skipped: 2},
+		},
+		{
+			name:    "dry run",
+			options: MigrateOptions{DryRun: true},
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(100), nil).
+					Return([]map[string]types.AttributeValue{testItem("1", false), testItem("2", true)}, nil, nil)
+			},
+			expectedResult: MigrationResult{Version: 1, Name: "mark", Status: MigrationStatusPending, Scanned: 2, Updated: 1, Skipped: 1},
+		},
+		{
+			name: "scan error",
+			mockSetup: func(client *mock_dynamodb.MockDynamoDBClient) {
+				client.EXPECT().
+					ScanItem(gomock.Any(), table, expression.Expression{}, int32(0), int32(1), int32(100), nil).
+					Return(nil, nil, errors.New("throttled"))
+			},
+			expectedResult: MigrationResult{Version: 1, Name: "mark", Status: MigrationStatusPending},
+			expectedError:  errors.New("failed to apply migration [1 mark]: segment [0]: failed to scan items: throttled"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			tt.mockSetup(client)
+
+			result, err := ApplyMigration(context.Background(), client, table, markMigration(1), tt.options)
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
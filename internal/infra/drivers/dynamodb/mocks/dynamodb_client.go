@@ -0,0 +1,162 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dynamodb_client.go
+//
+// Generated by this command:
+//
+//	mockgen -source=dynamodb_client.go -destination=mocks/dynamodb_client.go
+//
+
+// Package mock_dynamodb is a generated GoMock package.
+package mock_dynamodb
+
+import (
+	context "context"
+	reflect "reflect"
+
+	expression "github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDynamoDBClient is a mock of DynamoDBClient interface.
+type MockDynamoDBClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockDynamoDBClientMockRecorder
+}
+
+// MockDynamoDBClientMockRecorder is the mock recorder for MockDynamoDBClient.
+type MockDynamoDBClientMockRecorder struct {
+	mock *MockDynamoDBClient
+}
+
+// NewMockDynamoDBClient creates a new mock instance.
+func NewMockDynamoDBClient(ctrl *gomock.Controller) *MockDynamoDBClient {
+	mock := &MockDynamoDBClient{ctrl: ctrl}
+	mock.recorder = &MockDynamoDBClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDynamoDBClient) EXPECT() *MockDynamoDBClientMockRecorder {
+	return m.recorder
+}
+
+// DeleteItem mocks base method.
+func (m *MockDynamoDBClient) DeleteItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteItem", ctx, tableName, key, expr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteItem indicates an expected call of DeleteItem.
+func (mr *MockDynamoDBClientMockRecorder) DeleteItem(ctx, tableName, key, expr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteItem", reflect.TypeOf((*MockDynamoDBClient)(nil).DeleteItem), ctx, tableName, key, expr)
+}
+
+// DescribeTable mocks base method.
+func (m *MockDynamoDBClient) DescribeTable(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeTable", ctx, tableName)
+	ret0, _ := ret[0].(*types.TableDescription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeTable indicates an expected call of DescribeTable.
+func (mr *MockDynamoDBClientMockRecorder) DescribeTable(ctx, tableName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeTable", reflect.TypeOf((*MockDynamoDBClient)(nil).DescribeTable), ctx, tableName)
+}
+
+// GetItem mocks base method.
+func (m *MockDynamoDBClient) GetItem(ctx context.Context, tableName string, key map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItem", ctx, tableName, key)
+	ret0, _ := ret[0].(map[string]types.AttributeValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItem indicates an expected call of GetItem.
+func (mr *MockDynamoDBClientMockRecorder) GetItem(ctx, tableName, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockDynamoDBClient)(nil).GetItem), ctx, tableName, key)
+}
+
+// PutItem mocks base method.
+func (m *MockDynamoDBClient) PutItem(ctx context.Context, tableName string, item map[string]types.AttributeValue, expr expression.Expression) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutItem", ctx, tableName, item, expr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutItem indicates an expected call of PutItem.
+func (mr *MockDynamoDBClientMockRecorder) PutItem(ctx, tableName, item, expr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutItem", reflect.TypeOf((*MockDynamoDBClient)(nil).PutItem), ctx, tableName, item, expr)
+}
+
+// QueryItem mocks base method.
+func (m *MockDynamoDBClient) QueryItem(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryItem", ctx, tableName, expr, indexName, limit, startKey)
+	ret0, _ := ret[0].([]map[string]types.AttributeValue)
+	ret1, _ := ret[1].(map[string]types.AttributeValue)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// QueryItem indicates an expected call of QueryItem.
+func (mr *MockDynamoDBClientMockRecorder) QueryItem(ctx, tableName, expr, indexName, limit, startKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryItem", reflect.TypeOf((*MockDynamoDBClient)(nil).QueryItem), ctx, tableName, expr, indexName, limit, startKey)
+}
+
+// QueryItemDescending mocks base method.
+func (m *MockDynamoDBClient) QueryItemDescending(ctx context.Context, tableName string, expr expression.Expression, indexName string, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryItemDescending", ctx, tableName, expr, indexName, limit, startKey)
+	ret0, _ := ret[0].([]map[string]types.AttributeValue)
+	ret1, _ := ret[1].(map[string]types.AttributeValue)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// QueryItemDescending indicates an expected call of QueryItemDescending.
+func (mr *MockDynamoDBClientMockRecorder) QueryItemDescending(ctx, tableName, expr, indexName, limit, startKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryItemDescending", reflect.TypeOf((*MockDynamoDBClient)(nil).QueryItemDescending), ctx, tableName, expr, indexName, limit, startKey)
+}
+
+// ScanItem mocks base method.
+func (m *MockDynamoDBClient) ScanItem(ctx context.Context, tableName string, expr expression.Expression, segment, totalSegments, limit int32, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanItem", ctx, tableName, expr, segment, totalSegments, limit, startKey)
+	ret0, _ := ret[0].([]map[string]types.AttributeValue)
+	ret1, _ := ret[1].(map[string]types.AttributeValue)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ScanItem indicates an expected call of ScanItem.
+func (mr *MockDynamoDBClientMockRecorder) ScanItem(ctx, tableName, expr, segment, totalSegments, limit, startKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanItem", reflect.TypeOf((*MockDynamoDBClient)(nil).ScanItem), ctx, tableName, expr, segment, totalSegments, limit, startKey)
+}
+
+// UpdateItem mocks base method.
+func (m *MockDynamoDBClient) UpdateItem(ctx context.Context, tableName string, key map[string]types.AttributeValue, expr expression.Expression) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateItem", ctx, tableName, key, expr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateItem indicates an expected call of UpdateItem.
+func (mr *MockDynamoDBClientMockRecorder) UpdateItem(ctx, tableName, key, expr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateItem", reflect.TypeOf((*MockDynamoDBClient)(nil).UpdateItem), ctx, tableName, key, expr)
+}
@@ -1,36 +1,302 @@
-package http
-
-import (
-	"bytes"
-	httpClient "net/http"
-	"time"
-)
-
-type HttpClient interface {
-	DoGet(url string) (*httpClient.Response, error)
-	DoPut(url string, body []byte) (*httpClient.Response, error)
-}
-
-type client struct {
-	client *httpClient.Client
-}
-
-func NewHttpClient(timeout time.Duration) HttpClient {
-	return client{
-		client: &httpClient.Client{
-			Timeout: timeout,
-		},
-	}
-}
-
-func (c client) DoPut(url string, body []byte) (*httpClient.Response, error) {
-	req, err := httpClient.NewRequest(httpClient.MethodPut, url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-	return c.client.Do(req)
-}
-
-func (c client) DoGet(url string) (*httpClient.Response, error) {
-	return httpClient.Get(url)
-}
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	httpClient "net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http")
+
+var (
+	// ErrCircuitOpen is returned without calling the host while its circuit
+	// breaker is open.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrResponseTooLarge is returned when the response body exceeds
+	// Options.MaxResponseBytes.
+	ErrResponseTooLarge = errors.New("response body is too large")
+	// ErrAddressNotAllowed is returned when Options.DenyPrivateAddresses is
+	// set and the host resolves to a private address.
+	ErrAddressNotAllowed = errors.New("address is not allowed")
+)
+
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRetries       = 2
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultMaxRetryBackoff  = 2 * time.Second
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultMaxResponseBytes = 1 << 20
+)
+
+// Options configures the client. Zero values fall back to the defaults.
+type Options struct {
+	// Timeout bounds every attempt, including reading the response.
+	Timeout time.Duration
+	// MaxRetries is how many times an idempotent request is retried after
+	// a network error, 429, 502, 503 or 504. Negative disables retries.
+	MaxRetries int
+	// RetryBackoff is the base of the exponential backoff between retries,
+	// each wait being a random duration up to the capped exponential step.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// FailureThreshold is the number of consecutive failures, network
+	// errors or 5xx responses, that opens the circuit of a host.
+	FailureThreshold int
+	// OpenTimeout is how long a circuit stays open before a single trial
+	// request is let through.
+	OpenTimeout      time.Duration
+	MaxResponseBytes int64
+	// DenyPrivateAddresses refuses to connect to loopback, private,
+	// link-local and unspecified addresses, checked after the DNS lookup,
+	// for URLs given by third parties. Requests skip the proxy so the
+	// check applies to the host itself.
+	DenyPrivateAddresses bool
+}
+
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// Response is fully read, so callers have nothing to close.
+type Response struct {
+	StatusCode int
+	Header     httpClient.Header
+	Body       []byte
+}
+
+type HttpClient interface {
+	Do(ctx context.Context, request Request) (Response, error)
+	DoGet(ctx context.Context, url string, headers map[string]string) (Response, error)
+	DoPut(ctx context.Context, url string, body []byte, headers map[string]string) (Response, error)
+	DoPost(ctx context.Context, url string, body []byte, headers map[string]string) (Response, error)
+}
+
+type client struct {
+	client  *httpClient.Client
+	options Options
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	now      func() time.Time
+}
+
+func NewHttpClient(options Options) HttpClient {
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = defaultMaxRetries
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = defaultRetryBackoff
+	}
+	if options.MaxRetryBackoff <= 0 {
+		options.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultFailureThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = defaultOpenTimeout
+	}
+	if options.MaxResponseBytes <= 0 {
+		options.MaxResponseBytes = defaultMaxResponseBytes
+	}
+
+	c := &httpClient.Client{
+		Timeout: options.Timeout,
+	}
+	if options.DenyPrivateAddresses {
+		c.Transport = newPublicTransport()
+	}
+
+	return &client{
+		client:   c,
+		options:  options,
+		breakers: map[string]*circuitBreaker{},
+		now:      time.Now,
+	}
+}
+
+func (c *client) DoGet(ctx context.Context, url string, headers map[string]string) (Response, error) {
+	return c.Do(ctx, Request{Method: httpClient.MethodGet, URL: url, Headers: headers})
+}
+
+func (c *client) DoPut(ctx context.Context, url string, body []byte, headers map[string]string) (Response, error) {
+	return c.Do(ctx, Request{Method: httpClient.MethodPut, URL: url, Headers: headers, Body: body})
+}
+
+func (c *client) DoPost(ctx context.Context, url string, body []byte, headers map[string]string) (Response, error) {
+	return c.Do(ctx, Request{Method: httpClient.MethodPost, URL: url, Headers: headers, Body: body})
+}
+
+// Do sends the request through the circuit breaker of its host, retrying
+// idempotent methods on transient failures. Non 2xx responses are not
+// errors; the caller decides what each status code means.
+func (c *client) Do(ctx context.Context, request Request) (response Response, err error) {
+	ctx, span := tracer.Start(ctx, "HTTP "+request.Method, trace.WithSpanKind(trace.SpanKindClient))
+	defer tracing.EndSpan(span, &err)
+
+	target, err := url.Parse(request.URL)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to parse url [%s]: %w", request.URL, err)
+	}
+	span.SetAttributes(attribute.String("http.request.method", request.Method), attribute.String("server.address", target.Host))
+
+	retries := 0
+	if isIdempotent(request.Method) && c.options.MaxRetries > 0 {
+		retries = c.options.MaxRetries
+	}
+
+	breaker := c.breaker(target.Host)
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow(c.now()) {
+			return Response{}, fmt.Errorf("failed to %s [%s]: %w", request.Method, target.Host, ErrCircuitOpen)
+		}
+
+		response, err = c.send(ctx, request)
+		// the caller giving up or a refused address says nothing about the
+		// health of the host
+		if ctx.Err() != nil || errors.Is(err, ErrAddressNotAllowed) {
+			breaker.release()
+		} else {
+			healthy := err == nil && response.StatusCode < httpClient.StatusInternalServerError
+			breaker.record(c.now(), healthy || errors.Is(err, ErrResponseTooLarge))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode), attribute.Int("http.request.resend_count", attempt))
+
+		if attempt >= retries || !isRetryable(ctx, response, err) {
+			return response, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return response, err
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+func (c *client) send(ctx context.Context, request Request) (Response, error) {
+	req, err := httpClient.NewRequestWithContext(ctx, request.Method, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to %s [%s]: %w", request.Method, request.URL, err)
+	}
+	defer resp.Body.Close()
+
+	response := Response{StatusCode: resp.StatusCode, Header: resp.Header}
+	response.Body, err = io.ReadAll(io.LimitReader(resp.Body, c.options.MaxResponseBytes+1))
+	if err != nil {
+		return response, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(response.Body)) > c.options.MaxResponseBytes {
+		response.Body = nil
+		return response, fmt.Errorf("failed to read response, limit of [%d] bytes: %w", c.options.MaxResponseBytes, ErrResponseTooLarge)
+	}
+
+	return response, nil
+}
+
+func (c *client) breaker(host string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	breaker, ok := c.breakers[host]
+	if !ok {
+		breaker = newCircuitBreaker(c.options.FailureThreshold, c.options.OpenTimeout)
+		c.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// backoff returns a random wait up to RetryBackoff*2^attempt, capped at
+// MaxRetryBackoff, so that clients retrying together spread out.
+func (c *client) backoff(attempt int) time.Duration {
+	step := c.options.RetryBackoff << attempt
+	if step <= 0 || step > c.options.MaxRetryBackoff {
+		step = c.options.MaxRetryBackoff
+	}
+	return time.Duration(rand.Int63n(int64(step) + 1))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case httpClient.MethodGet, httpClient.MethodHead, httpClient.MethodOptions, httpClient.MethodPut, httpClient.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryable(ctx context.Context, response Response, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrResponseTooLarge) || errors.Is(err, ErrAddressNotAllowed) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case httpClient.StatusTooManyRequests, httpClient.StatusBadGateway, httpClient.StatusServiceUnavailable, httpClient.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newPublicTransport is the default transport without a proxy, refusing to
+// connect to non public addresses.
+func newPublicTransport() *httpClient.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("failed to dial [%s]: %w", address, ErrAddressNotAllowed)
+			}
+			return nil
+		},
+	}
+
+	transport := httpClient.DefaultTransport.(*httpClient.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	httpClient "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestServer answers with the statuses in order, repeating the last
+// one, and counts the requests it got.
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(httpClient.HandlerFunc(func(w httpClient.ResponseWriter, r *httpClient.Request) {
+		call := int(calls.Add(1))
+		if call > len(statuses) {
+			call = len(statuses)
+		}
+		w.WriteHeader(statuses[call-1])
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestClient(options Options) *client {
+	options.RetryBackoff = time.Millisecond
+	options.MaxRetryBackoff = time.Millisecond
+	return NewHttpClient(options).(*client)
+}
+
+func TestClient_Do_SendsRequest(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x05},
+		SpanID:     trace.SpanID{0x06},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	var received *httpClient.Request
+	var body []byte
+	server := httptest.NewServer(httpClient.HandlerFunc(func(w httpClient.ResponseWriter, r *httpClient.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Request-Id", "42")
+		w.WriteHeader(httpClient.StatusAccepted)
+		w.Write([]byte(`{"status":"PAID"}`))
+	}))
+	defer server.Close()
+
+	response, err := newTestClient(Options{}).Do(ctx, Request{
+		Method:  httpClient.MethodPatch,
+		URL:     server.URL + "/orders/7",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Body:    []byte(`{"status":"PAID"}`),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, httpClient.StatusAccepted, response.StatusCode)
+	assert.Equal(t, `{"status":"PAID"}`, string(response.Body))
+	assert.Equal(t, "42", response.Header.Get("X-Request-Id"))
+	assert.Equal(t, httpClient.MethodPatch, received.Method)
+	assert.Equal(t, "/orders/7", received.URL.Path)
+	assert.Equal(t, "Bearer token", received.Header.Get("Authorization"))
+	assert.Contains(t, received.Header.Get("traceparent"), trace.TraceID{0x05}.String())
+	assert.Equal(t, `{"status":"PAID"}`, string(body))
+}
+
+func TestClient_DoGet_UsesTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(httpClient.HandlerFunc(func(w httpClient.ResponseWriter, r *httpClient.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	_, err := newTestClient(Options{Timeout: 50 * time.Millisecond, MaxRetries: -1}).DoGet(context.Background(), server.URL, nil)
+
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClient_Do_Retries(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		statuses      []int
+		options       Options
+		expectedCalls int32
+		expectedCode  int
+	}{
+		{
+			name:          "idempotent request is retried until it succeeds",
+			method:        httpClient.MethodGet,
+			statuses:      []int{httpClient.StatusServiceUnavailable, httpClient.StatusBadGateway, httpClient.StatusOK},
+			expectedCalls: 3,
+			expectedCode:  httpClient.StatusOK,
+		},
+		{
+			name:          "retries stop at the limit",
+			method:        httpClient.MethodPut,
+			statuses:      []int{httpClient.StatusTooManyRequests},
+			options:       Options{MaxRetries: 3},
+			expectedCalls: 4,
+			expectedCode:  httpClient.StatusTooManyRequests,
+		},
+		{
+			name:          "post is not retried",
+			method:        httpClient.MethodPost,
+			statuses:      []int{httpClient.StatusServiceUnavailable, httpClient.StatusOK},
+			expectedCalls: 1,
+			expectedCode:  httpClient.StatusServiceUnavailable,
+		},
+		{
+			name:          "client errors are not retried",
+			method:        httpClient.MethodGet,
+			statuses:      []int{httpClient.StatusNotFound, httpClient.StatusOK},
+			expectedCalls: 1,
+			expectedCode:  httpClient.StatusNotFound,
+		},
+		{
+			name:          "internal errors are not retried",
+			method:        httpClient.MethodGet,
+			statuses:      []int{httpClient.StatusInternalServerError, httpClient.StatusOK},
+			expectedCalls: 1,
+			expectedCode:  httpClient.StatusInternalServerError,
+		},
+		{
+			name:          "retries can be disabled",
+			method:        httpClient.MethodGet,
+			statuses:      []int{httpClient.StatusServiceUnavailable, httpClient.StatusOK},
+			options:       Options{MaxRetries: -1},
+			expectedCalls: 1,
+			expectedCode:  httpClient.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := newTestServer(t, test.statuses...)
+
+			response, err := newTestClient(test.options).Do(context.Background(), Request{Method: test.method, URL: server.URL})
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, response.StatusCode)
+			assert.Equal(t, test.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestClient_Do_RetriesNetworkErrors(t *testing.T) {
+	server, _ := newTestServer(t, httpClient.StatusOK)
+	server.Close()
+
+	var attempts int
+	c := newTestClient(Options{MaxRetries: 2})
+	c.client.Transport = roundTripperFunc(func(r *httpClient.Request) (*httpClient.Response, error) {
+		attempts++
+		return httpClient.DefaultTransport.RoundTrip(r)
+	})
+
+	_, err := c.DoGet(context.Background(), server.URL, nil)
+
+	assert.ErrorContains(t, err, "failed to GET")
+	assert.Equal(t, 3, attempts)
+}
+
+func TestClient_Do_CancelledContextStopsRetries(t *testing.T) {
+	server, calls := newTestServer(t, httpClient.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := NewHttpClient(Options{MaxRetries: 10, RetryBackoff: time.Hour, MaxRetryBackoff: time.Hour}).(*client)
+	go func() {
+		for calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	response, err := c.DoGet(ctx, server.URL, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, httpClient.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_Do_DenyPrivateAddresses(t *testing.T) {
+	server, calls := newTestServer(t, httpClient.StatusOK)
+	c := newTestClient(Options{MaxRetries: 2, FailureThreshold: 1, DenyPrivateAddresses: true})
+
+	_, err := c.DoGet(context.Background(), server.URL, nil)
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+	assert.Equal(t, int32(0), calls.Load())
+
+	_, err = c.DoGet(context.Background(), server.URL, nil)
+	assert.ErrorIs(t, err, ErrAddressNotAllowed, "refused addresses do not open the circuit")
+
+	for _, ip := range []string{"127.0.0.1", "10.0.0.7", "192.168.1.1", "169.254.169.254", "0.0.0.0", "::1", "fd00::1", "::ffff:10.0.0.7"} {
+		assert.False(t, isPublicIP(net.ParseIP(ip)), ip)
+	}
+	assert.True(t, isPublicIP(net.ParseIP("8.8.8.8")))
+	assert.True(t, isPublicIP(net.ParseIP("2001:4860:4860::8888")))
+}
+
+func TestClient_Do_LimitsResponseSize(t *testing.T) {
+	server := httptest.NewServer(httpClient.HandlerFunc(func(w httpClient.ResponseWriter, r *httpClient.Request) {
+		w.Write([]byte(strings.Repeat("a", 11)))
+	}))
+	defer server.Close()
+
+	c := newTestClient(Options{MaxResponseBytes: 10})
+
+	response, err := c.DoGet(context.Background(), server.URL, nil)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	assert.Equal(t, httpClient.StatusOK, response.StatusCode)
+	assert.Nil(t, response.Body)
+
+	c.options.MaxResponseBytes = 11
+	response, err = c.DoGet(context.Background(), server.URL, nil)
+	require.NoError(t, err)
+	assert.Len(t, response.Body, 11)
+}
+
+func TestClient_Do_CircuitBreaker(t *testing.T) {
+	failing, failingCalls := newTestServer(t, httpClient.StatusInternalServerError, httpClient.StatusInternalServerError, httpClient.StatusOK)
+	healthy, _ := newTestServer(t, httpClient.StatusOK)
+
+	now := time.Now()
+	c := newTestClient(Options{FailureThreshold: 2, OpenTimeout: time.Minute})
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		response, err := c.DoPost(context.Background(), failing.URL, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, httpClient.StatusInternalServerError, response.StatusCode)
+	}
+
+	_, err := c.DoPost(context.Background(), failing.URL, nil, nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), failingCalls.Load(), "an open circuit does not call the host")
+
+	_, err = c.DoPost(context.Background(), healthy.URL, nil, nil)
+	assert.NoError(t, err, "circuits are per host")
+
+	now = now.Add(time.Minute)
+	response, err := c.DoPost(context.Background(), failing.URL, nil, nil)
+	require.NoError(t, err, "the trial request goes through after the open timeout")
+	assert.Equal(t, httpClient.StatusOK, response.StatusCode)
+
+	_, err = c.DoPost(context.Background(), failing.URL, nil, nil)
+	assert.NoError(t, err, "a successful trial closes the circuit")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+
+	breaker.record(now, false)
+	assert.True(t, breaker.allow(now))
+	breaker.record(now, true)
+	breaker.record(now, false)
+	assert.True(t, breaker.allow(now), "a success resets the consecutive failures")
+
+	breaker.record(now, false)
+	assert.False(t, breaker.allow(now))
+	assert.False(t, breaker.allow(now.Add(59*time.Second)))
+
+	assert.True(t, breaker.allow(now.Add(time.Minute)))
+	assert.False(t, breaker.allow(now.Add(time.Minute)), "a single trial is let through")
+	breaker.release()
+	assert.True(t, breaker.allow(now.Add(time.Minute)), "a released trial can be retaken")
+
+	breaker.record(now.Add(time.Minute), false)
+	assert.False(t, breaker.allow(now.Add(time.Minute+time.Second)), "a failed trial opens the circuit again")
+}
+
+type roundTripperFunc func(r *httpClient.Request) (*httpClient.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *httpClient.Request) (*httpClient.Response, error) {
+	return f(r)
+}
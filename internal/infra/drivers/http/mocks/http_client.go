@@ -5,13 +5,15 @@
 //
 //	mockgen -source=http_client.go -destination=mocks/http_client.go
 //
+
 // Package mock_http is a generated GoMock package.
 package mock_http
 
 import (
-	http "net/http"
+	context "context"
 	reflect "reflect"
 
+	http "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -38,32 +40,62 @@ func (m *MockHttpClient) EXPECT() *MockHttpClientMockRecorder {
 	return m.recorder
 }
 
+// Do mocks base method.
+func (m *MockHttpClient) Do(ctx context.Context, request http.Request) (http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, request)
+	ret0, _ := ret[0].(http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockHttpClientMockRecorder) Do(ctx, request any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockHttpClient)(nil).Do), ctx, request)
+}
+
 // DoGet mocks base method.
-func (m *MockHttpClient) DoGet(url string) (*http.Response, error) {
+func (m *MockHttpClient) DoGet(ctx context.Context, url string, headers map[string]string) (http.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DoGet", url)
-	ret0, _ := ret[0].(*http.Response)
+	ret := m.ctrl.Call(m, "DoGet", ctx, url, headers)
+	ret0, _ := ret[0].(http.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DoGet indicates an expected call of DoGet.
-func (mr *MockHttpClientMockRecorder) DoGet(url any) *gomock.Call {
+func (mr *MockHttpClientMockRecorder) DoGet(ctx, url, headers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoGet", reflect.TypeOf((*MockHttpClient)(nil).DoGet), ctx, url, headers)
+}
+
+// DoPost mocks base method.
+func (m *MockHttpClient) DoPost(ctx context.Context, url string, body []byte, headers map[string]string) (http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoPost", ctx, url, body, headers)
+	ret0, _ := ret[0].(http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoPost indicates an expected call of DoPost.
+func (mr *MockHttpClientMockRecorder) DoPost(ctx, url, body, headers any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoGet", reflect.TypeOf((*MockHttpClient)(nil).DoGet), url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoPost", reflect.TypeOf((*MockHttpClient)(nil).DoPost), ctx, url, body, headers)
 }
 
 // DoPut mocks base method.
-func (m *MockHttpClient) DoPut(url string, body []byte) (*http.Response, error) {
+func (m *MockHttpClient) DoPut(ctx context.Context, url string, body []byte, headers map[string]string) (http.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DoPut", url, body)
-	ret0, _ := ret[0].(*http.Response)
+	ret := m.ctrl.Call(m, "DoPut", ctx, url, body, headers)
+	ret0, _ := ret[0].(http.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DoPut indicates an expected call of DoPut.
-func (mr *MockHttpClientMockRecorder) DoPut(url, body any) *gomock.Call {
+func (mr *MockHttpClientMockRecorder) DoPut(ctx, url, body, headers any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoPut", reflect.TypeOf((*MockHttpClient)(nil).DoPut), url, body)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoPut", reflect.TypeOf((*MockHttpClient)(nil).DoPut), ctx, url, body, headers)
 }
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID serializes migrations when several replicas start at once.
+const migrationLockID = 7306021
+
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// Migrate applies, in order, every embedded migration not yet recorded in
+// schema_migrations. Each migration runs in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	pending, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+	if err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range pending {
+		err = applyMigration(ctx, conn, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	var applied bool
+	err := conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", m.version).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("failed to check migration [%s]: %w", m.name, err)
+	}
+	if applied {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration [%s]: %w", m.name, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, m.script)
+	if err != nil {
+		return fmt.Errorf("failed to apply migration [%s]: %w", m.name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name)
+	if err != nil {
+		return fmt.Errorf("failed to record migration [%s]: %w", m.name, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit migration [%s]: %w", m.name, err)
+	}
+
+	return nil
+}
+
+// loadMigrations reads the embedded scripts, named <version>_<name>.sql.
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	result := make([]migration, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name [%s]: %w", file, err)
+		}
+
+		script, err := migrations.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration [%s]: %w", file, err)
+		}
+
+		result = append(result, migration{version: version, name: name, script: string(script)})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].version < result[j].version
+	})
+
+	return result, nil
+}
@@ -0,0 +1,34 @@
+CREATE TABLE orders (
+    id           TEXT PRIMARY KEY,
+    status       TEXT        NOT NULL,
+    customer_cpf TEXT        NOT NULL DEFAULT '',
+    entity       TEXT        NOT NULL DEFAULT 'ORDER',
+    created_at   TIMESTAMPTZ NOT NULL,
+    finished_at  TIMESTAMPTZ,
+    version      INTEGER     NOT NULL DEFAULT 1,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX orders_created_at_idx ON orders (created_at, id);
+CREATE INDEX orders_status_created_at_idx ON orders (status, created_at, id);
+
+CREATE TABLE order_items (
+    order_id            TEXT    NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    position            INTEGER NOT NULL,
+    quantity            INTEGER NOT NULL,
+    type                TEXT    NOT NULL,
+    product_name        TEXT    NOT NULL,
+    product_description TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE TABLE order_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    TEXT        NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status   TEXT        NOT NULL,
+    version     INTEGER     NOT NULL,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_status_history_order_id_idx ON order_status_history (order_id, id);
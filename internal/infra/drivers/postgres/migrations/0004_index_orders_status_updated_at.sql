@@ -0,0 +1 @@
+CREATE INDEX orders_status_updated_at_idx ON orders (status, updated_at);
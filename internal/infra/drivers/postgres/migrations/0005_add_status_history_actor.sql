@@ -0,0 +1 @@
+ALTER TABLE order_status_history ADD COLUMN actor TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (created_at, id) WHERE status = 'PENDING';
@@ -0,0 +1,3 @@
+ALTER TABLE webhook_deliveries
+    ADD COLUMN owner TEXT NOT NULL DEFAULT '',
+    ADD COLUMN lease_until TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
@@ -0,0 +1,31 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type S3Client interface {
+	PutObject(ctx context.Context, bucket string, key string, contentType string, body []byte) error
+}
+
+type s3Client struct {
+	client *s3.Client
+}
+
+func NewS3Client(client *s3.Client) S3Client {
+	return &s3Client{client: client}
+}
+
+func (c *s3Client) PutObject(ctx context.Context, bucket string, key string, contentType string, body []byte) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id           TEXT PRIMARY KEY,
+    status       TEXT    NOT NULL,
+    customer_cpf TEXT    NOT NULL DEFAULT '',
+    entity       TEXT    NOT NULL DEFAULT 'ORDER',
+    created_at   TEXT    NOT NULL,
+    finished_at  TEXT,
+    version      INTEGER NOT NULL DEFAULT 1,
+    updated_at   TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON orders (created_at, id);
+CREATE INDEX IF NOT EXISTS orders_status_updated_at_idx ON orders (status, updated_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    order_id            TEXT    NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    position            INTEGER NOT NULL,
+    quantity            INTEGER NOT NULL,
+    type                TEXT    NOT NULL,
+    product_name        TEXT    NOT NULL,
+    product_description TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id    TEXT    NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status   TEXT    NOT NULL,
+    reason      TEXT    NOT NULL DEFAULT '',
+    actor       TEXT    NOT NULL DEFAULT '',
+    version     INTEGER NOT NULL,
+    changed_at  TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id, id);
+
+CREATE TABLE IF NOT EXISTS archived_orders (
+    id          TEXT PRIMARY KEY,
+    status      TEXT NOT NULL,
+    created_at  TEXT NOT NULL,
+    archived_at TEXT NOT NULL,
+    payload     TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id         TEXT PRIMARY KEY,
+    url        TEXT NOT NULL,
+    events     TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               TEXT PRIMARY KEY,
+    subscription_id  TEXT    NOT NULL,
+    order_id         INTEGER NOT NULL,
+    event            TEXT    NOT NULL,
+    status           TEXT    NOT NULL,
+    attempts         INTEGER NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error       TEXT    NOT NULL DEFAULT '',
+    created_at       TEXT    NOT NULL,
+    updated_at       TEXT    NOT NULL,
+    owner            TEXT    NOT NULL DEFAULT '',
+    lease_until      TEXT    NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (created_at, id) WHERE status = 'PENDING';
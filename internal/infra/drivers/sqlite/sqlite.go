@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed schema.sql
+var schema string
+
+// NewSQLiteDB opens the database file in WAL mode, creating it and its
+// schema when missing. Transactions take the write lock when they begin so
+// concurrent writers wait on busy_timeout instead of failing to upgrade.
+func NewSQLiteDB(ctx context.Context, path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite [%s]: %w", path, err)
+	}
+
+	_, err = db.ExecContext(ctx, schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	// files created before the column existed are not changed by the schema
+	err = addColumn(ctx, db, "order_status_history", "reason", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = addColumn(ctx, db, "order_status_history", "actor", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = addColumn(ctx, db, "webhook_deliveries", "owner", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = addColumn(ctx, db, "webhook_deliveries", "lease_until", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func addColumn(ctx context.Context, db *sql.DB, table string, column string, definition string) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect sqlite table [%s]: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column [%s] to sqlite table [%s]: %w", column, table, err)
+	}
+	return nil
+}
+
+// Compact moves the WAL content into the database file and rebuilds it to
+// give back the pages freed by deleted rows.
+func Compact(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint sqlite wal: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, "VACUUM")
+	if err != nil {
+		return fmt.Errorf("failed to vacuum sqlite: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package gateways
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DeadLetterQueue parks the messages that can never be processed, such as
+// events of an unknown type or version, so they stop being redelivered and
+// can be inspected or replayed later.
+type DeadLetterQueue interface {
+	// Send publishes message unchanged; reason is a short code recorded in
+	// the metrics.
+	Send(ctx context.Context, key string, message []byte, reason string) error
+}
+
+type deadLetterQueue struct {
+	publisher   broker.Publisher
+	destination string
+	timeout     time.Duration
+}
+
+func NewDeadLetterQueue(publisher broker.Publisher, destination string, timeout time.Duration) DeadLetterQueue {
+	return deadLetterQueue{publisher: publisher, destination: destination, timeout: timeout}
+}
+
+func (q deadLetterQueue) Send(ctx context.Context, key string, message []byte, reason string) (err error) {
+	ctx, span := tracer.Start(ctx, "deadLetterQueue.Send")
+	span.SetAttributes(attribute.String("dead_letter.reason", reason))
+	defer tracing.EndSpan(span, &err)
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	err = q.publisher.Publish(ctx, q.destination, key, message)
+	if err != nil {
+		return fmt.Errorf("failed to publish to the dead letter queue [%s], error: %w", q.destination, err)
+	}
+	metrics.IncConsumerDeadLettered(q.destination, reason)
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package gateways
+
+import "errors"
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrOrderAlreadyExists = errors.New("order already exists")
+	ErrInvalidCursor      = errors.New("invalid page cursor")
+	ErrWebhookNotFound    = errors.New("webhook subscription not found")
+	// ErrWebhookDeliveryLeased is returned when claiming a delivery that is
+	// no longer pending or is leased by another dispatcher.
+	ErrWebhookDeliveryLeased = errors.New("webhook delivery is leased by another dispatcher")
+	// ErrOrderConflict is returned when concurrent writers keep changing the
+	// order faster than an update can be applied.
+	ErrOrderConflict = errors.New("order was modified concurrently")
+)
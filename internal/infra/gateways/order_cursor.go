@@ -0,0 +1,39 @@
+package gateways
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// orderCursor is the keyset position of the SQL repositories, which list
+// orders by creation time and id.
+type orderCursor struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        string    `json:"id"`
+}
+
+func encodeOrderCursor(cursor orderCursor) (string, error) {
+	content, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+func decodeOrderCursor(cursor string) (orderCursor, error) {
+	content, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return orderCursor{}, ErrInvalidCursor
+	}
+
+	result := orderCursor{}
+	err = json.Unmarshal(content, &result)
+	if err != nil || result.ID == "" {
+		return orderCursor{}, ErrInvalidCursor
+	}
+
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package gateways
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/s3"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrderExporter copies archived orders to cold storage as JSON Lines, one
+// order per line.
+type OrderExporter interface {
+	// ExportOrders writes the orders to a new file called name, replacing
+	// any previous file with that name.
+	ExportOrders(ctx context.Context, name string, orders []models.Order) error
+}
+
+type fileOrderExporter struct {
+	dir string
+}
+
+// NewFileOrderExporter writes the exports into dir, which is created when
+// missing.
+func NewFileOrderExporter(dir string) OrderExporter {
+	return &fileOrderExporter{dir: dir}
+}
+
+func (e *fileOrderExporter) ExportOrders(ctx context.Context, name string, orders []models.Order) (err error) {
+	_, span := tracer.Start(ctx, "fileOrderExporter.ExportOrders")
+	span.SetAttributes(attribute.String("export.name", name), attribute.Int("orders.exported", len(orders)))
+	defer tracing.EndSpan(span, &err)
+
+	content, err := encodeJSONLines(orders)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(e.dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	// written aside and renamed, so a crash never leaves a truncated export
+	file, err := os.CreateTemp(e.dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	err = os.Rename(file.Name(), filepath.Join(e.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	return nil
+}
+
+type s3OrderExporter struct {
+	s3Client s3.S3Client
+	bucket   string
+	prefix   string
+	timeout  time.Duration
+}
+
+// NewS3OrderExporter writes the exports to bucket, under prefix, on S3 or
+// any S3 compatible storage.
+func NewS3OrderExporter(s3Client s3.S3Client, bucket string, prefix string, timeout time.Duration) OrderExporter {
+	return &s3OrderExporter{
+		s3Client: s3Client,
+		bucket:   bucket,
+		prefix:   prefix,
+		timeout:  timeout,
+	}
+}
+
+func (e *s3OrderExporter) ExportOrders(ctx context.Context, name string, orders []models.Order) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "s3OrderExporter.ExportOrders")
+	span.SetAttributes(attribute.String("export.name", name), attribute.Int("orders.exported", len(orders)))
+	defer tracing.EndSpan(span, &err)
+
+	content, err := encodeJSONLines(orders)
+	if err != nil {
+		return err
+	}
+
+	err = e.s3Client.PutObject(ctx, e.bucket, path.Join(e.prefix, name), "application/x-ndjson", content)
+	if err != nil {
+		return fmt.Errorf("failed to put export object: %w", err)
+	}
+
+	return nil
+}
+
+func encodeJSONLines(orders []models.Order) ([]byte, error) {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	for _, order := range orders {
+		err := encoder.Encode(order)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal order [%s]: %w", order.ID, err)
+		}
+	}
+	return content.Bytes(), nil
+}
@@ -0,0 +1,76 @@
+package gateways
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	mock_s3 "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/s3/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var exportedOrders = []models.Order{
+	{ID: "1", Status: models.OrderStatusDelivered, CreatedAt: time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)},
+	{ID: "2", Status: models.OrderStatusCancelled, CreatedAt: time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)},
+}
+
+func decodeJSONLines(t *testing.T, content []byte) []models.Order {
+	var orders []models.Order
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		var order models.Order
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &order))
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+func TestFileOrderExporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	exporter := NewFileOrderExporter(dir)
+
+	err := exporter.ExportOrders(context.Background(), "orders-0001.jsonl", exportedOrders)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dir, "orders-0001.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, exportedOrders, decodeJSONLines(t, content))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestS3OrderExporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockS3Client := mock_s3.NewMockS3Client(ctrl)
+	exporter := NewS3OrderExporter(mockS3Client, "kitchen-archive", "orders", time.Second)
+
+	var body []byte
+	mockS3Client.EXPECT().
+		PutObject(gomock.Any(), "kitchen-archive", "orders/orders-0001.jsonl", "application/x-ndjson", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, bucket string, key string, contentType string, content []byte) error {
+			body = content
+			return nil
+		})
+
+	err := exporter.ExportOrders(context.Background(), "orders-0001.jsonl", exportedOrders)
+	require.NoError(t, err)
+	assert.Equal(t, exportedOrders, decodeJSONLines(t, body))
+
+	mockS3Client.EXPECT().
+		PutObject(gomock.Any(), "kitchen-archive", "orders/orders-0002.jsonl", "application/x-ndjson", gomock.Any()).
+		Return(errors.New("access denied"))
+
+	err = exporter.ExportOrders(context.Background(), "orders-0002.jsonl", exportedOrders)
+	assert.EqualError(t, err, "failed to put export object: access denied")
+}
@@ -0,0 +1,208 @@
+package gateways
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrderMigrations rewrites the orders and webhook items saved by previous
+// versions of the DynamoDB repositories; once released, a version is never
+// reused nor its migration changed, as it may already be applied.
+var OrderMigrations = []dynamodb.Migration{
+	statusIndexKeysMigration,
+	{
+		Version:   2,
+		Name:      "status_history",
+		Filter:    expression.Name("GSI1PK").In(expression.Value("ORDER"), expression.Value(archivedOrderEntity)).And(expression.AttributeNotExists(expression.Name("StatusHistory"))),
+		Transform: migrateStatusHistory,
+	},
+	{
+		Version:   3,
+		Name:      "active_status_shards",
+		Filter:    expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(expression.Name("GSI2PK").In(unshardedActivePartitions[0], unshardedActivePartitions[1:]...)),
+		Transform: migrateActiveStatusShards,
+	},
+	{
+		Version:   4,
+		Name:      "archived_status_index_keys",
+		Filter:    expression.Name("GSI1PK").Equal(expression.Value(archivedOrderEntity)).And(expression.AttributeNotExists(expression.Name("GSI2SK"))),
+		Transform: migrateStatusIndexKeys,
+	},
+	{
+		Version:   5,
+		Name:      "webhook_delivery_keys",
+		Filter:    expression.Name("PK").BeginsWith(webhookDeliveryEntity + "#").And(expression.AttributeNotExists(expression.Name("GSI2SK"))),
+		Transform: migrateWebhookDeliveryKeys,
+	},
+}
+
+// webhookDeliveryMigrationRetention is the default WEBHOOK_DELIVERY_RETENTION,
+// given to the deliveries logged before they expired.
+const webhookDeliveryMigrationRetention = 720 * time.Hour
+
+// statusIndexKeysMigration is also run on its own, at any time, by
+// BackfillOrderStatusKeys.
+var statusIndexKeysMigration = dynamodb.Migration{
+	Version:   1,
+	Name:      "status_index_keys",
+	Filter:    expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(expression.AttributeNotExists(expression.Name("GSI2SK"))),
+	Transform: migrateStatusIndexKeys,
+}
+
+// StatusKeysBackfill is the outcome of BackfillOrderStatusKeys.
+type StatusKeysBackfill struct {
+	Missing int `json:"missing"`
+	Updated int `json:"updated"`
+	// Skipped orders changed while being backfilled, running it again
+	// picks them up.
+	Skipped int `json:"skipped"`
+}
+
+// BackfillOrderStatusKeys sets the status index keys of the listed orders
+// saved without them, as the status_index_keys migration does, but without
+// recording it as applied, so it can run again whenever orders are found
+// missing the keys. With dryRun it only counts them.
+func BackfillOrderStatusKeys(ctx context.Context, dynamodbClient dynamodb.DynamoDBClient, table string, dryRun bool) (backfill StatusKeysBackfill, err error) {
+	ctx, span := tracer.Start(ctx, "BackfillOrderStatusKeys")
+	defer tracing.EndSpan(span, &err)
+
+	result, err := dynamodb.ApplyMigration(ctx, dynamodbClient, table, statusIndexKeysMigration, dynamodb.MigrateOptions{PageSize: dynamodbBatchPageSize, DryRun: dryRun})
+	backfill.Missing = result.Scanned
+	if !dryRun {
+		backfill.Updated = result.Updated
+		backfill.Skipped = result.Skipped
+	}
+	if err != nil {
+		return backfill, fmt.Errorf("failed to backfill status keys: %w", err)
+	}
+	span.SetAttributes(attribute.Int("orders.updated", backfill.Updated))
+
+	return backfill, nil
+}
+
+// unshardedActivePartitions are the status index partitions of the active
+// statuses before they were sharded.
+var unshardedActivePartitions = func() []expression.OperandBuilder {
+	partitions := []expression.OperandBuilder{}
+	for _, status := range models.OrderStatuses {
+		if (models.Order{Status: status}).IsActive() {
+			partitions = append(partitions, expression.Value("ORDER#"+status))
+		}
+	}
+	return partitions
+}()
+
+// migrateStatusIndexKeys sets the status index keys of the orders saved
+// before the index existed, and of the orders archived while the archive
+// removed them, which left them out of the listings of past days.
+func migrateStatusIndexKeys(item map[string]types.AttributeValue) (dynamodb.ItemChange, bool, error) {
+	if _, ok := item["GSI2SK"]; ok {
+		return dynamodb.ItemChange{}, false, nil
+	}
+
+	order := models.Order{}
+	err := attributevalue.UnmarshalMap(item, &order)
+	if err != nil {
+		return dynamodb.ItemChange{}, false, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+
+	// orders saved before StatusChangedAt existed are sharded by creation
+	changedAt := order.StatusChangedAt
+	if changedAt.IsZero() {
+		changedAt = order.CreatedAt
+	}
+
+	return dynamodb.ItemChange{
+		Update: expression.Set(expression.Name("GSI2PK"), expression.Value(orderStatusPartition(order.Status, order.ID, changedAt))).
+			Set(expression.Name("GSI2SK"), expression.Value(orderStatusSortKey(order))),
+		Condition: expression.AttributeNotExists(expression.Name("GSI2SK")).
+			And(expression.Name("Status").Equal(expression.Value(order.Status))),
+	}, true, nil
+}
+
+// migrateStatusHistory starts the history of the orders saved before it
+// was recorded with their current status.
+func migrateStatusHistory(item map[string]types.AttributeValue) (dynamodb.ItemChange, bool, error) {
+	if _, ok := item["StatusHistory"]; ok {
+		return dynamodb.ItemChange{}, false, nil
+	}
+
+	order := models.Order{}
+	err := attributevalue.UnmarshalMap(item, &order)
+	if err != nil {
+		return dynamodb.ItemChange{}, false, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+
+	changedAt := order.StatusChangedAt
+	if changedAt.IsZero() {
+		changedAt = order.CreatedAt
+	}
+	history := []models.OrderStatusChange{{To: order.Status, ChangedAt: changedAt}}
+
+	return dynamodb.ItemChange{
+		Update: expression.Set(expression.Name("StatusHistory"), expression.Value(history)),
+		Condition: expression.AttributeNotExists(expression.Name("StatusHistory")).
+			And(expression.Name("Status").Equal(expression.Value(order.Status))),
+	}, true, nil
+}
+
+// migrateActiveStatusShards moves the orders of an active status from the
+// single partition of the status to the shard of their ID.
+func migrateActiveStatusShards(item map[string]types.AttributeValue) (dynamodb.ItemChange, bool, error) {
+	keys := struct {
+		ID        string `dynamodbav:"PK"`
+		Status    string `dynamodbav:"Status"`
+		Partition string `dynamodbav:"GSI2PK"`
+	}{}
+	err := attributevalue.UnmarshalMap(item, &keys)
+	if err != nil {
+		return dynamodb.ItemChange{}, false, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+	if !(models.Order{Status: keys.Status}).IsActive() || keys.Partition != "ORDER#"+keys.Status {
+		return dynamodb.ItemChange{}, false, nil
+	}
+
+	return dynamodb.ItemChange{
+		Update:    expression.Set(expression.Name("GSI2PK"), expression.Value(orderStatusPartition(keys.Status, keys.ID, time.Time{}))),
+		Condition: expression.Name("GSI2PK").Equal(expression.Value(keys.Partition)),
+	}, true, nil
+}
+
+// migrateWebhookDeliveryKeys moves the deliveries logged in the GSI1PK
+// partition of their subscription to its StatusIndex partition, sorted by
+// creation time, and sets their TTL. Pending deliveries move to the
+// partition the dispatcher resumes them from.
+func migrateWebhookDeliveryKeys(item map[string]types.AttributeValue) (dynamodb.ItemChange, bool, error) {
+	if _, ok := item["GSI2SK"]; ok {
+		return dynamodb.ItemChange{}, false, nil
+	}
+
+	delivery := models.WebhookDelivery{}
+	err := attributevalue.UnmarshalMap(item, &delivery)
+	if err != nil {
+		return dynamodb.ItemChange{}, false, fmt.Errorf("failed to unmarshal webhook delivery: %w", err)
+	}
+
+	update := expression.Set(expression.Name("GSI2PK"), expression.Value(webhookDeliveryPartition(delivery.SubscriptionID))).
+		Set(expression.Name("GSI2SK"), expression.Value(webhookDeliverySortKey(delivery))).
+		Set(expression.Name("ExpiresAt"), expression.Value(delivery.CreatedAt.Add(webhookDeliveryMigrationRetention).Unix()))
+	if entity := webhookDeliveryIndexEntity(delivery); entity != "" {
+		update = update.Set(expression.Name("GSI1PK"), expression.Value(entity))
+	} else {
+		update = update.Remove(expression.Name("GSI1PK"))
+	}
+
+	return dynamodb.ItemChange{
+		Update:    update,
+		Condition: expression.AttributeNotExists(expression.Name("GSI2SK")),
+	}, true, nil
+}
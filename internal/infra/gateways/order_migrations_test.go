@@ -0,0 +1,155 @@
+package gateways
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderMigrations(t *testing.T) {
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	legacy, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusDelivered, Entity: "ORDER", CreatedAt: createdAt})
+	migrated := map[string]types.AttributeValue{}
+	for name, value := range legacy {
+		migrated[name] = value
+	}
+	migrated["GSI2SK"] = &types.AttributeValueMemberS{Value: "2024-05-10T12:00:00.000000000Z#1"}
+	migrated["StatusHistory"] = &types.AttributeValueMemberL{}
+
+	tests := []struct {
+		name           string
+		version        int
+		expectedChange dynamodb.ItemChange
+	}{
+		{
+			name:    "status index keys",
+			version: 1,
+			expectedChange: dynamodb.ItemChange{
+				Update: expression.Set(expression.Name("GSI2PK"), expression.Value("ORDER#DELIVERED#2024-05-10")).
+					Set(expression.Name("GSI2SK"), expression.Value("2024-05-10T12:00:00.000000000Z#1")),
+				Condition: expression.AttributeNotExists(expression.Name("GSI2SK")).
+					And(expression.Name("Status").Equal(expression.Value(models.OrderStatusDelivered))),
+			},
+		},
+		{
+			name:    "status history",
+			version: 2,
+			expectedChange: dynamodb.ItemChange{
+				Update: expression.Set(expression.Name("StatusHistory"), expression.Value([]models.OrderStatusChange{{To: models.OrderStatusDelivered, ChangedAt: createdAt}})),
+				Condition: expression.AttributeNotExists(expression.Name("StatusHistory")).
+					And(expression.Name("Status").Equal(expression.Value(models.OrderStatusDelivered))),
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration := OrderMigrations[i]
+			require.Equal(t, tt.version, migration.Version)
+
+			change, ok, err := migration.Transform(legacy)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, buildItemChange(t, tt.expectedChange), buildItemChange(t, change))
+
+			_, ok, err = migration.Transform(migrated)
+			assert.NoError(t, err)
+			assert.False(t, ok, "migrated items are left as they are")
+		})
+	}
+}
+
+func buildItemChange(t *testing.T, change dynamodb.ItemChange) expression.Expression {
+	expr, err := expression.NewBuilder().WithUpdate(change.Update).WithCondition(change.Condition).Build()
+	require.NoError(t, err)
+	return expr
+}
+
+func TestOrderMigrations_ActiveStatusShards(t *testing.T) {
+	migration := OrderMigrations[2]
+	require.Equal(t, 3, migration.Version)
+
+	unsharded, _ := attributevalue.MarshalMap(map[string]string{"PK": "7", "Status": models.OrderStatusReady, "GSI2PK": "ORDER#READY"})
+	change, ok, err := migration.Transform(unsharded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, buildItemChange(t, dynamodb.ItemChange{
+		Update:    expression.Set(expression.Name("GSI2PK"), expression.Value(activeStatusPartition(models.OrderStatusReady, orderStatusShard("7")))),
+		Condition: expression.Name("GSI2PK").Equal(expression.Value("ORDER#READY")),
+	}), buildItemChange(t, change))
+
+	for _, item := range []map[string]string{
+		{"PK": "7", "Status": models.OrderStatusReady, "GSI2PK": activeStatusPartition(models.OrderStatusReady, orderStatusShard("7"))},
+		{"PK": "8", "Status": models.OrderStatusDelivered, "GSI2PK": "ORDER#DELIVERED#2024-05-10"},
+	} {
+		sharded, _ := attributevalue.MarshalMap(item)
+		_, ok, err = migration.Transform(sharded)
+		assert.NoError(t, err)
+		assert.False(t, ok, "sharded and completed orders are left as they are")
+	}
+}
+
+func TestOrderMigrations_ArchivedStatusIndexKeys(t *testing.T) {
+	migration := OrderMigrations[3]
+	require.Equal(t, 4, migration.Version)
+
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	archived, _ := attributevalue.MarshalMap(models.Order{ID: "9", Status: models.OrderStatusDelivered, Entity: archivedOrderEntity, CreatedAt: createdAt, StatusChangedAt: createdAt.Add(time.Hour)})
+	change, ok, err := migration.Transform(archived)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, buildItemChange(t, dynamodb.ItemChange{
+		Update: expression.Set(expression.Name("GSI2PK"), expression.Value("ORDER#DELIVERED#2024-05-10")).
+			Set(expression.Name("GSI2SK"), expression.Value("2024-05-10T12:00:00.000000000Z#9")),
+		Condition: expression.AttributeNotExists(expression.Name("GSI2SK")).
+			And(expression.Name("Status").Equal(expression.Value(models.OrderStatusDelivered))),
+	}), buildItemChange(t, change))
+}
+
+func TestOrderMigrations_WebhookDeliveryKeys(t *testing.T) {
+	migration := OrderMigrations[4]
+	require.Equal(t, 5, migration.Version)
+
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	legacy, _ := attributevalue.MarshalMap(models.WebhookDelivery{ID: "d-1", SubscriptionID: "wh-1", OrderID: 7, Status: models.WebhookDeliverySucceeded, CreatedAt: createdAt})
+	legacy["PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-1"}
+	legacy["GSI1PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#wh-1"}
+
+	change, ok, err := migration.Transform(legacy)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, buildItemChange(t, dynamodb.ItemChange{
+		Update: expression.Set(expression.Name("GSI2PK"), expression.Value("WEBHOOK_DELIVERY#wh-1")).
+			Set(expression.Name("GSI2SK"), expression.Value("2024-05-10T12:00:00.000000000Z#d-1")).
+			Set(expression.Name("ExpiresAt"), expression.Value(createdAt.Add(720*time.Hour).Unix())).
+			Remove(expression.Name("GSI1PK")),
+		Condition: expression.AttributeNotExists(expression.Name("GSI2SK")),
+	}), buildItemChange(t, change))
+
+	pending, _ := attributevalue.MarshalMap(models.WebhookDelivery{ID: "d-2", SubscriptionID: "wh-1", OrderID: 7, Status: models.WebhookDeliveryPending, CreatedAt: createdAt})
+	pending["PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-2"}
+	pending["GSI1PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#wh-1"}
+
+	change, ok, err = migration.Transform(pending)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, buildItemChange(t, dynamodb.ItemChange{
+		Update: expression.Set(expression.Name("GSI2PK"), expression.Value("WEBHOOK_DELIVERY#wh-1")).
+			Set(expression.Name("GSI2SK"), expression.Value("2024-05-10T12:00:00.000000000Z#d-2")).
+			Set(expression.Name("ExpiresAt"), expression.Value(createdAt.Add(720*time.Hour).Unix())).
+			Set(expression.Name("GSI1PK"), expression.Value("WEBHOOK_DELIVERY_PENDING")),
+		Condition: expression.AttributeNotExists(expression.Name("GSI2SK")),
+	}), buildItemChange(t, change), "pending deliveries stay listed for the dispatcher")
+
+	legacy["GSI2SK"] = &types.AttributeValueMemberS{Value: "2024-05-10T12:00:00.000000000Z#d-1"}
+	_, ok, err = migration.Transform(legacy)
+	assert.NoError(t, err)
+	assert.False(t, ok, "migrated deliveries are left as they are")
+}
@@ -4,35 +4,63 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/IgorRamosBR/g73-techchallenge-order/pkg/events"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/broker"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// EventSource is the CloudEvents source of the events this service
+	// publishes.
+	EventSource = "/" + tracing.ServiceName
+
+	OrderStatusChangedEventType    = "br.com.g73.production.order.status_changed"
+	OrderStatusChangedEventVersion = 1
+
+	// EventsSchemaURL points at the payloads shared with the order service.
+	EventsSchemaURL = "https://github.com/IgorRamosBR/g73-techchallenge-order/blob/v0.1.1/pkg/events/payloads.go"
 )
 
 type OrderNotify interface {
-	NotifyOrder(orderId int, status string) error
+	NotifyOrder(ctx context.Context, orderId int, status string) error
 }
 
 type orderNotify struct {
 	publisher   broker.Publisher
 	destination string
+	timeout     time.Duration
 }
 
-func NewOrderNotify(publisher broker.Publisher, destination string) OrderNotify {
-	return orderNotify{publisher: publisher, destination: destination}
+func NewOrderNotify(publisher broker.Publisher, destination string, timeout time.Duration) OrderNotify {
+	return orderNotify{publisher: publisher, destination: destination, timeout: timeout}
 }
 
-func (o orderNotify) NotifyOrder(orderId int, status string) error {
-	message, err := json.Marshal(events.OrderStatusEventDTO{
+func (o orderNotify) NotifyOrder(ctx context.Context, orderId int, status string) (err error) {
+	ctx, span := tracer.Start(ctx, "orderNotify.NotifyOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", status))
+	defer tracing.EndSpan(span, &err)
+
+	event, err := broker.NewCloudEvent(EventSource, OrderStatusChangedEventType, OrderStatusChangedEventVersion, EventsSchemaURL+"#OrderStatusEventDTO", strconv.Itoa(orderId), events.OrderStatusEventDTO{
 		OrderId: orderId,
 		Status:  status,
 	})
+	if err != nil {
+		return fmt.Errorf("failed to create event of order[%d] with status[%s], error: %v", orderId, status, err)
+	}
+
+	message, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payment order[%d] with status[%s], error: %v", orderId, status, err)
 	}
 
-	ctx := context.Background()
-	err = o.publisher.Publish(ctx, o.destination, message)
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	err = o.publisher.Publish(ctx, o.destination, strconv.Itoa(orderId), message)
 	if err != nil {
 		return fmt.Errorf("failed to publish order[%d] with status[%s], error: %v", orderId, status, err)
 	}
@@ -1,62 +1,296 @@
 package gateways
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"strconv"
+	"time"
 
-	"github.com/IgorRamosBR/g73-techchallenge-payment/pkg/dynamodb"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = otel.Tracer("github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/gateways")
+
 type OrderRepository interface {
-	GetOrders() ([]models.Order, error)
-	SaveOrder(order models.Order) error
-	UpdateOrderStatus(orderId int, status string) error
+	GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error)
+	// GetOrder returns the order with its status history, oldest first.
+	GetOrder(ctx context.Context, orderId int) (models.Order, error)
+	SaveOrder(ctx context.Context, order models.Order) error
+	UpdateOrderStatus(ctx context.Context, orderId int, status string) error
+	// UpdateOrderStatusWithReason is UpdateOrderStatus recording why the
+	// status was changed, and by whom, in the history.
+	UpdateOrderStatusWithReason(ctx context.Context, orderId int, status string, reason string, actor string) error
 }
 
 type orderRepository struct {
 	table          string
+	timeout        time.Duration
 	dynamodbClient dynamodb.DynamoDBClient
+	now            func() time.Time
 }
 
-func NewOrderRepository(dynamodbClient dynamodb.DynamoDBClient, table string) OrderRepository {
+func NewOrderRepository(dynamodbClient dynamodb.DynamoDBClient, table string, timeout time.Duration) OrderRepository {
 	return &orderRepository{
 		dynamodbClient: dynamodbClient,
 		table:          table,
+		timeout:        timeout,
+		now:            time.Now,
 	}
 }
 
-func (r *orderRepository) GetOrders() ([]models.Order, error) {
+func (r *orderRepository) GetOrders(ctx context.Context, filter models.OrderFilter) (page models.OrderPage, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "orderRepository.GetOrders")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrders", time.Now(), &err)
+
+	startKey, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return models.OrderPage{}, err
+	}
+
+	changedExpr := changedAtCondition(filter)
+	if partitions, ok := statusPartitions(filter, r.now()); ok {
+		span.SetAttributes(attribute.String("dynamodb.index", statusIndex), attribute.Int("dynamodb.partitions", len(partitions)))
+		page, err := r.getOrdersByPartition(ctx, partitions, changedExpr, filter.Limit, startKey)
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+		listedOrderHistory(page.Results, filter.WithHistory)
+		return page, nil
+	}
+
 	entityExpr := expression.Key("GSI1PK").Equal(expression.Value("ORDER"))
-	expr, err := expression.NewBuilder().WithKeyCondition(entityExpr).Build()
+	builder := expression.NewBuilder().WithKeyCondition(entityExpr)
+	if len(filter.Statuses) > 0 {
+		statuses := make([]expression.OperandBuilder, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = expression.Value(status)
+		}
+		statusExpr := expression.Name("Status").In(statuses[0], statuses[1:]...)
+		if changedExpr.IsSet() {
+			statusExpr = statusExpr.And(changedExpr)
+		}
+		builder = builder.WithFilter(statusExpr)
+	} else if changedExpr.IsSet() {
+		builder = builder.WithFilter(changedExpr)
+	}
+
+	expr, err := builder.Build()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create query expr: %w", err)
+		return models.OrderPage{}, fmt.Errorf("failed to create query expr: %w", err)
 	}
 
-	items, err := r.dynamodbClient.QueryItem(r.table, expr, "SecondaryIndex")
+	items, lastKey, err := r.queryUntilLimit(ctx, expr, "SecondaryIndex", filter.Limit, startKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get orders: %w", err)
+		return models.OrderPage{}, fmt.Errorf("failed to get orders: %w", err)
 	}
 
 	orders := []models.Order{}
 	err = attributevalue.UnmarshalListOfMaps(items, &orders)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal orders: %w", err)
+		return models.OrderPage{}, fmt.Errorf("failed to unmarshal orders: %w", err)
+	}
+	listedOrderHistory(orders, filter.WithHistory)
+
+	next, err := encodeCursor(lastKey)
+	if err != nil {
+		return models.OrderPage{}, err
+	}
+
+	return models.OrderPage{Results: orders, Next: next}, nil
+}
+
+// getOrdersByPartition reads the status index partitions one after the
+// other. The cursor is the key the partition being read stopped at, or
+// only its GSI2PK when it has to be read from its start.
+func (r *orderRepository) getOrdersByPartition(ctx context.Context, partitions []string, filter expression.ConditionBuilder, limit int, startKey map[string]types.AttributeValue) (models.OrderPage, error) {
+	if startKey != nil {
+		cursor := map[string]string{}
+		err := attributevalue.UnmarshalMap(startKey, &cursor)
+		if err != nil {
+			return models.OrderPage{}, ErrInvalidCursor
+		}
+
+		i := 0
+		for i < len(partitions) && partitions[i] != cursor["GSI2PK"] {
+			i++
+		}
+		if i == len(partitions) {
+			return models.OrderPage{}, ErrInvalidCursor
+		}
+		partitions = partitions[i:]
+		if cursor["PK"] == "" {
+			startKey = nil
+		}
+	}
+
+	orders := []models.Order{}
+	for i, partition := range partitions {
+		builder := expression.NewBuilder().WithKeyCondition(expression.Key("GSI2PK").Equal(expression.Value(partition)))
+		if filter.IsSet() {
+			builder = builder.WithFilter(filter)
+		}
+		expr, err := builder.Build()
+		if err != nil {
+			return models.OrderPage{}, fmt.Errorf("failed to create query expr: %w", err)
+		}
+
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - len(orders)
+		}
+		items, lastKey, err := r.queryUntilLimit(ctx, expr, statusIndex, remaining, startKey)
+		if err != nil {
+			return models.OrderPage{}, fmt.Errorf("failed to get orders: %w", err)
+		}
+		startKey = nil
+
+		page := []models.Order{}
+		err = attributevalue.UnmarshalListOfMaps(items, &page)
+		if err != nil {
+			return models.OrderPage{}, fmt.Errorf("failed to unmarshal orders: %w", err)
+		}
+		orders = append(orders, page...)
+
+		if len(lastKey) == 0 && limit > 0 && len(orders) >= limit && i+1 < len(partitions) {
+			lastKey = map[string]types.AttributeValue{
+				"GSI2PK": &types.AttributeValueMemberS{Value: partitions[i+1]},
+			}
+		}
+		if len(lastKey) > 0 {
+			next, err := encodeCursor(lastKey)
+			if err != nil {
+				return models.OrderPage{}, err
+			}
+			return models.OrderPage{Results: orders, Next: next}, nil
+		}
+	}
+
+	return models.OrderPage{Results: orders}, nil
+}
+
+// queryUntilLimit queries the index until limit items pass the filter of
+// expr, or reads every item when limit is zero. DynamoDB applies the limit
+// before the filter, so a single query can return fewer items, or none,
+// while more are left to match.
+func (r *orderRepository) queryUntilLimit(ctx context.Context, expr expression.Expression, index string, limit int, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	items := []map[string]types.AttributeValue{}
+	for {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - len(items)
+		}
+		page, lastKey, err := r.dynamodbClient.QueryItem(ctx, r.table, expr, index, int32(remaining), startKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, page...)
+
+		if len(lastKey) == 0 || limit > 0 && len(items) >= limit {
+			return items, lastKey, nil
+		}
+		startKey = lastKey
+	}
+}
+
+func (r *orderRepository) GetOrder(ctx context.Context, orderId int) (order models.Order, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "orderRepository.GetOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrder", time.Now(), &err)
+
+	key, err := orderKey(orderId)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	av, err := r.dynamodbClient.GetItem(ctx, r.table, key)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to get order: %w", err)
+	}
+	if len(av) == 0 {
+		return models.Order{}, ErrOrderNotFound
+	}
+
+	err = attributevalue.UnmarshalMap(av, &order)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to unmarshal order: %w", err)
 	}
 
-	return orders, nil
+	fillOrderHistory(&order)
+
+	return order, nil
+}
+
+// listedOrderHistory keeps the history stored in the items of listed
+// orders only when it was asked for.
+func listedOrderHistory(orders []models.Order, withHistory bool) {
+	for i := range orders {
+		if withHistory {
+			fillOrderHistory(&orders[i])
+		} else {
+			orders[i].History = nil
+		}
+	}
+}
+
+func fillOrderHistory(order *models.Order) {
+	// the previous status isn't known when a change is appended, so it is
+	// taken from the entry before it
+	for i := 1; i < len(order.History); i++ {
+		order.History[i].From = order.History[i-1].To
+	}
+	if order.History == nil {
+		order.History = []models.OrderStatusChange{}
+	}
 }
 
-func (r *orderRepository) SaveOrder(order models.Order) error {
+func (r *orderRepository) SaveOrder(ctx context.Context, order models.Order) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "orderRepository.SaveOrder")
+	span.SetAttributes(attribute.String("order.id", order.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveOrder", time.Now(), &err)
+
+	order.StatusChangedAt = r.now().UTC()
+	order.History = []models.OrderStatusChange{{To: order.Status, ChangedAt: order.StatusChangedAt}}
 	av, err := attributevalue.MarshalMap(order)
 	if err != nil {
 		return fmt.Errorf("failed to marshal order: %w", err)
 	}
+	av["GSI2PK"] = &types.AttributeValueMemberS{Value: orderStatusPartition(order.Status, order.ID, order.StatusChangedAt)}
+	av["GSI2SK"] = &types.AttributeValueMemberS{Value: orderStatusSortKey(order)}
+
+	condition := expression.AttributeNotExists(expression.Name("PK"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
 
-	err = r.dynamodbClient.PutItem(r.table, av)
+	err = r.dynamodbClient.PutItem(ctx, r.table, av, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return ErrOrderAlreadyExists
+	}
 	if err != nil {
 		return fmt.Errorf("failed to put order: %w", err)
 	}
@@ -64,24 +298,645 @@ func (r *orderRepository) SaveOrder(order models.Order) error {
 	return nil
 }
 
-func (r *orderRepository) UpdateOrderStatus(orderId int, status string) error {
-	id, err := attributevalue.Marshal(strconv.Itoa(orderId))
+func (r *orderRepository) UpdateOrderStatus(ctx context.Context, orderId int, status string) error {
+	return r.UpdateOrderStatusWithReason(ctx, orderId, status, "", "")
+}
+
+// UpdateOrderStatusWithReason appends the change to the history in the
+// same update, so the status and its history never diverge. An order
+// without the status index sort key, saved before the index existed, is
+// read to set it, as it is made of the creation time.
+func (r *orderRepository) UpdateOrderStatusWithReason(ctx context.Context, orderId int, status string, reason string, actor string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "orderRepository.UpdateOrderStatus")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", status))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("UpdateOrderStatus", time.Now(), &err)
+
+	key, err := orderKey(orderId)
+	if err != nil {
+		return err
+	}
+
+	now := r.now().UTC()
+	change := models.OrderStatusChange{To: status, Reason: reason, Actor: actor, ChangedAt: now}
+	update := orderStatusUpdate(strconv.Itoa(orderId), change)
+	condition := expression.AttributeExists(expression.Name("GSI2SK"))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = r.dynamodbClient.UpdateItem(ctx, r.table, key, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return r.updateOrderStatusAndSortKey(ctx, key, change)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal order id: %w", err)
+		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
-	key := map[string]types.AttributeValue{"PK": id}
+	return nil
+}
 
-	update := expression.Set(expression.Name("Status"), expression.Value(status))
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+// updateOrderStatusAndSortKey is the update of an order missing the status
+// index sort key, or of a missing order.
+func (r *orderRepository) updateOrderStatusAndSortKey(ctx context.Context, key map[string]types.AttributeValue, change models.OrderStatusChange) error {
+	av, err := r.dynamodbClient.GetItem(ctx, r.table, key)
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+	if len(av) == 0 {
+		return ErrOrderNotFound
+	}
+
+	order := models.Order{}
+	err = attributevalue.UnmarshalMap(av, &order)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+
+	update := orderStatusUpdate(order.ID, change).Set(expression.Name("GSI2SK"), expression.Value(orderStatusSortKey(order)))
+	condition := expression.AttributeExists(expression.Name("PK"))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
 	if err != nil {
 		return fmt.Errorf("failed to create expression: %w", err)
 	}
 
-	err = r.dynamodbClient.UpdateItem(r.table, key, expr)
+	err = r.dynamodbClient.UpdateItem(ctx, r.table, key, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return ErrOrderNotFound
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	return nil
 }
+
+// orderStatusUpdate sets the status, appends the change to the history and
+// moves the order to the status index partition of the status.
+func orderStatusUpdate(id string, change models.OrderStatusChange) expression.UpdateBuilder {
+	history := expression.ListAppend(
+		expression.IfNotExists(expression.Name("StatusHistory"), expression.Value([]models.OrderStatusChange{})),
+		expression.Value([]models.OrderStatusChange{change}),
+	)
+	return expression.Set(expression.Name("Status"), expression.Value(change.To)).
+		Set(expression.Name("StatusChangedAt"), expression.Value(change.ChangedAt)).
+		Set(expression.Name("StatusHistory"), history).
+		Set(expression.Name("GSI2PK"), expression.Value(orderStatusPartition(change.To, id, change.ChangedAt)))
+}
+
+// statusIndex lists the orders of a status partition by creation time.
+// Active statuses are spread over activeStatusShards partitions by order
+// ID, so a busy kitchen doesn't write to a single hot partition; completed
+// statuses are sharded by the day of the change, as their partitions would
+// otherwise grow forever.
+const statusIndex = "StatusIndex"
+
+// orderTableIndexes are the global secondary indexes the repositories
+// query, which the table must be provisioned with.
+var orderTableIndexes = []string{"SecondaryIndex", statusIndex}
+
+// CheckOrderTable fails when the table is missing one of the indexes the
+// repositories query, so a deployment without them is not ready instead of
+// failing the listings.
+func CheckOrderTable(ctx context.Context, dynamodbClient dynamodb.DynamoDBClient, table string) error {
+	description, err := dynamodbClient.DescribeTable(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	indexes := map[string]bool{}
+	for _, index := range description.GlobalSecondaryIndexes {
+		if index.IndexName != nil {
+			indexes[*index.IndexName] = true
+		}
+	}
+	for _, index := range orderTableIndexes {
+		if !indexes[index] {
+			return fmt.Errorf("table [%s] has no index [%s]", table, index)
+		}
+	}
+	return nil
+}
+
+const activeStatusShards = 8
+
+func orderStatusPartition(status string, id string, changedAt time.Time) string {
+	if (models.Order{Status: status}).IsActive() {
+		return activeStatusPartition(status, orderStatusShard(id))
+	}
+	return "ORDER#" + status + "#" + changedAt.UTC().Format("2006-01-02")
+}
+
+func activeStatusPartition(status string, shard int) string {
+	return "ORDER#" + status + "#" + strconv.Itoa(shard)
+}
+
+func orderStatusShard(id string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return int(hash.Sum32() % activeStatusShards)
+}
+
+// orderStatusSortKey has a fixed width so it sorts as the creation time,
+// and the ID so orders created at the same time keep a stable order.
+func orderStatusSortKey(order models.Order) string {
+	return order.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000000Z") + "#" + order.ID
+}
+
+// maxStatusPartitionDays bounds the day partitions a listing of completed
+// statuses reads, wider windows filter the ORDER partition instead.
+const maxStatusPartitionDays = 31
+
+// statusPartitions returns the status index partitions holding the orders
+// of the filter, and whether the index can list them: the active statuses
+// are read from their shards, and the completed ones from the days of the
+// window, which must have a start and ends now when it has no end. An
+// empty Statuses lists every status.
+func statusPartitions(filter models.OrderFilter, now time.Time) ([]string, bool) {
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = models.OrderStatuses
+	}
+
+	active := []string{}
+	completed := []string{}
+	seen := map[string]bool{}
+	for _, status := range statuses {
+		if seen[status] {
+			continue
+		}
+		seen[status] = true
+		if (models.Order{Status: status}).IsActive() {
+			active = append(active, status)
+		} else {
+			completed = append(completed, status)
+		}
+	}
+
+	partitions := []string{}
+	for _, status := range active {
+		for shard := 0; shard < activeStatusShards; shard++ {
+			partitions = append(partitions, activeStatusPartition(status, shard))
+		}
+	}
+	if len(completed) == 0 {
+		return partitions, true
+	}
+	if filter.ChangedFrom.IsZero() {
+		return nil, false
+	}
+
+	to := filter.ChangedTo
+	if to.IsZero() {
+		to = now
+	}
+	first := filter.ChangedFrom.UTC().Truncate(24 * time.Hour)
+	last := to.UTC().Add(-time.Nanosecond).Truncate(24 * time.Hour)
+	if last.Sub(first) >= maxStatusPartitionDays*24*time.Hour {
+		return nil, false
+	}
+
+	for _, status := range completed {
+		for day := first; !day.After(last); day = day.Add(24 * time.Hour) {
+			partitions = append(partitions, orderStatusPartition(status, "", day))
+		}
+	}
+	return partitions, true
+}
+
+// changedAtCondition filters on the window of the filter, it is not set
+// when the window is open on both sides.
+func changedAtCondition(filter models.OrderFilter) expression.ConditionBuilder {
+	var condition expression.ConditionBuilder
+	if !filter.ChangedFrom.IsZero() {
+		condition = expression.Name("StatusChangedAt").GreaterThanEqual(expression.Value(filter.ChangedFrom.UTC()))
+	}
+	if !filter.ChangedTo.IsZero() {
+		before := expression.Name("StatusChangedAt").LessThan(expression.Value(filter.ChangedTo.UTC()))
+		if condition.IsSet() {
+			return condition.And(before)
+		}
+		return before
+	}
+	return condition
+}
+
+func orderKey(orderId int) (map[string]types.AttributeValue, error) {
+	id, err := attributevalue.Marshal(strconv.Itoa(orderId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order id: %w", err)
+	}
+
+	return map[string]types.AttributeValue{"PK": id}, nil
+}
+
+// encodeCursor turns the DynamoDB LastEvaluatedKey into an opaque cursor.
+// Every key attribute of the table and its index is a string.
+func encodeCursor(lastKey map[string]types.AttributeValue) (string, error) {
+	if len(lastKey) == 0 {
+		return "", nil
+	}
+
+	key := map[string]string{}
+	err := attributevalue.UnmarshalMap(lastKey, &key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	content, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	content, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	key := map[string]string{}
+	err = json.Unmarshal(content, &key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	startKey, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return startKey, nil
+}
+
+// archivedOrderEntity is the GSI1PK of archived orders, which takes them
+// out of the partition listed by GetOrders. They stay in the day partition
+// of their status until they expire, for the listings of past days.
+const archivedOrderEntity = "ORDER_ARCHIVE"
+
+// pendingArchiveEntity is the GSI1PK of the orders moved out of the listed
+// partition whose export is not confirmed yet, along with the name of
+// their export in ArchiveExport. An archive finishes them before moving
+// others, so an order is never archived without a copy.
+const pendingArchiveEntity = "ORDER_ARCHIVE_PENDING"
+
+// archiveExportKeyPrefix is the PK of the item recording that an export
+// was written, so a pending export is not written again with only the
+// orders left to confirm.
+const archiveExportKeyPrefix = "ARCHIVE_EXPORT#"
+
+// dynamodbBatchPageSize is how many items the batch jobs read per query or
+// scan, and so the most orders in one archive export file.
+const dynamodbBatchPageSize = 100
+
+type dynamodbOrderArchiver struct {
+	table          string
+	timeout        time.Duration
+	lookback       time.Duration
+	retention      time.Duration
+	dynamodbClient dynamodb.DynamoDBClient
+	exporter       OrderExporter
+	now            func() time.Time
+}
+
+// archivedOrderItem is an order moved by the archive, with the export it
+// belongs to while pending.
+type archivedOrderItem struct {
+	models.Order
+	ArchiveExport string `dynamodbav:"ArchiveExport"`
+}
+
+// NewOrderArchiver moves DELIVERED and CANCELLED orders out of the
+// listed partition, copying them with exporter, and sets their ExpiresAt
+// TTL attribute so DynamoDB deletes them after retention. The orders are
+// read from the day partitions of the status index, as far back as
+// lookback before the cutoff of an archive.
+func NewOrderArchiver(dynamodbClient dynamodb.DynamoDBClient, table string, exporter OrderExporter, lookback time.Duration, retention time.Duration, timeout time.Duration) OrderArchiver {
+	return &dynamodbOrderArchiver{
+		dynamodbClient: dynamodbClient,
+		table:          table,
+		exporter:       exporter,
+		lookback:       lookback,
+		retention:      retention,
+		timeout:        timeout,
+		now:            time.Now,
+	}
+}
+
+// ArchiveOrders archives the completed orders last changed before
+// completedBefore and returns how many were moved. The orders of a page
+// are moved first, those changed since they were read being left in
+// place, and then only the moved ones are exported, under a name kept on
+// them until the export is confirmed. The exports left pending by a
+// previous archive are finished first, under the same name, so a re-run
+// overwrites them instead of exporting the orders again.
+func (a *dynamodbOrderArchiver) ArchiveOrders(ctx context.Context, completedBefore time.Time) (archived int, err error) {
+	ctx, span := tracer.Start(ctx, "orderArchiver.ArchiveOrders")
+	defer tracing.EndSpan(span, &err)
+	defer func() { span.SetAttributes(attribute.Int("orders.archived", archived)) }()
+
+	archived, err = a.finishPendingExports(ctx)
+	if err != nil {
+		return archived, err
+	}
+
+	filter := expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(completedBeforeExpr(completedBefore.UTC()))
+	archivedAt := a.now().UTC()
+	for _, partition := range archivePartitions(completedBefore.UTC(), a.lookback) {
+		expr, err := expression.NewBuilder().WithKeyCondition(expression.Key("GSI2PK").Equal(expression.Value(partition.name))).WithFilter(filter).Build()
+		if err != nil {
+			return archived, fmt.Errorf("failed to create query expr: %w", err)
+		}
+
+		var startKey map[string]types.AttributeValue
+		for page := 1; ; page++ {
+			orders, lastKey, err := a.queryOrders(ctx, expr, startKey)
+			if err != nil {
+				return archived, err
+			}
+
+			name := fmt.Sprintf("orders-%s-%s-%04d.jsonl", archivedAt.Format("20060102T150405Z"), partition.suffix, page)
+			moved := []models.Order{}
+			for _, order := range orders {
+				ok, err := a.archiveOrder(ctx, order, archivedAt, name)
+				if err != nil {
+					return archived, err
+				}
+				if ok {
+					moved = append(moved, order)
+				}
+			}
+
+			err = a.export(ctx, name, moved)
+			if err != nil {
+				return archived, err
+			}
+			archived += len(moved)
+
+			if len(lastKey) == 0 {
+				break
+			}
+			startKey = lastKey
+		}
+	}
+
+	return archived, nil
+}
+
+// archivePartition is a day partition of a completed status, with the
+// suffix naming its exports.
+type archivePartition struct {
+	name   string
+	suffix string
+}
+
+// archivePartitions returns the day partitions of the completed statuses
+// from lookback before completedBefore to its day.
+func archivePartitions(completedBefore time.Time, lookback time.Duration) []archivePartition {
+	first := completedBefore.Add(-lookback).Truncate(24 * time.Hour)
+	last := completedBefore.Truncate(24 * time.Hour)
+
+	partitions := []archivePartition{}
+	for _, status := range []string{models.OrderStatusDelivered, models.OrderStatusCancelled} {
+		for day := first; !day.After(last); day = day.Add(24 * time.Hour) {
+			partitions = append(partitions, archivePartition{
+				name:   orderStatusPartition(status, "", day),
+				suffix: status + "-" + day.Format("20060102"),
+			})
+		}
+	}
+	return partitions
+}
+
+// finishPendingExports exports the orders left pending, grouped by their
+// export, and returns how many were confirmed.
+func (a *dynamodbOrderArchiver) finishPendingExports(ctx context.Context) (int, error) {
+	expr, err := expression.NewBuilder().WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value(pendingArchiveEntity))).Build()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create query expr: %w", err)
+	}
+
+	names := []string{}
+	exports := map[string][]models.Order{}
+	var startKey map[string]types.AttributeValue
+	for {
+		items, lastKey, err := a.queryItems(ctx, expr, "SecondaryIndex", startKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pending archived orders: %w", err)
+		}
+
+		pending := []archivedOrderItem{}
+		err = attributevalue.UnmarshalListOfMaps(items, &pending)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal orders: %w", err)
+		}
+		for _, item := range pending {
+			if _, ok := exports[item.ArchiveExport]; !ok {
+				names = append(names, item.ArchiveExport)
+			}
+			exports[item.ArchiveExport] = append(exports[item.ArchiveExport], item.Order)
+		}
+
+		if len(lastKey) == 0 {
+			break
+		}
+		startKey = lastKey
+	}
+
+	finished := 0
+	for _, name := range names {
+		err := a.export(ctx, name, exports[name])
+		if err != nil {
+			return finished, err
+		}
+		finished += len(exports[name])
+	}
+	return finished, nil
+}
+
+// export writes the orders moved under name, unless a previous archive
+// already did, and confirms them.
+func (a *dynamodbOrderArchiver) export(ctx context.Context, name string, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: archiveExportKeyPrefix + name}}
+	exported, err := a.isExported(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exported {
+		err = a.exporter.ExportOrders(ctx, name, orders)
+		if err != nil {
+			return fmt.Errorf("failed to export orders: %w", err)
+		}
+		err = a.markExported(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, order := range orders {
+		err := a.confirmArchived(ctx, order)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *dynamodbOrderArchiver) isExported(ctx context.Context, key map[string]types.AttributeValue) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	item, err := a.dynamodbClient.GetItem(ctx, a.table, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get archive export: %w", err)
+	}
+	return len(item) > 0, nil
+}
+
+// markExported records the export until the orders in it expire.
+func (a *dynamodbOrderArchiver) markExported(ctx context.Context, key map[string]types.AttributeValue) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	item := map[string]types.AttributeValue{
+		"PK":        key["PK"],
+		"ExpiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(a.now().Add(a.retention).Unix(), 10)},
+	}
+	err := a.dynamodbClient.PutItem(ctx, a.table, item, expression.Expression{})
+	if err != nil {
+		return fmt.Errorf("failed to record archive export: %w", err)
+	}
+	return nil
+}
+
+// confirmArchived moves an exported order from the pending partition to
+// the archived one.
+func (a *dynamodbOrderArchiver) confirmArchived(ctx context.Context, order models.Order) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	key, err := archivedOrderKey(order)
+	if err != nil {
+		return err
+	}
+	update := expression.Set(expression.Name("GSI1PK"), expression.Value(archivedOrderEntity)).
+		Remove(expression.Name("ArchiveExport"))
+	condition := expression.Name("GSI1PK").Equal(expression.Value(pendingArchiveEntity))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = a.dynamodbClient.UpdateItem(ctx, a.table, key, expr)
+	if err != nil && !errors.Is(err, dynamodb.ErrConditionFailed) {
+		return fmt.Errorf("failed to confirm archived order [%s]: %w", order.ID, err)
+	}
+	return nil
+}
+
+// completedBeforeExpr matches the orders last changed before
+// completedBefore. The orders saved before StatusChangedAt existed, which
+// lack it or have it zero, are matched by FinishedAt and, when it is not
+// set either, by CreatedAt.
+func completedBeforeExpr(completedBefore time.Time) expression.ConditionBuilder {
+	changedAt := expression.Name("StatusChangedAt")
+	finishedAt := expression.Name("FinishedAt")
+	zero := expression.Value(time.Time{})
+	before := expression.Value(completedBefore)
+
+	changed := changedAt.LessThan(before).And(changedAt.GreaterThan(zero))
+	finished := finishedAt.LessThan(before).And(finishedAt.GreaterThan(zero))
+	created := expression.Or(expression.AttributeNotExists(finishedAt), finishedAt.Equal(zero)).
+		And(expression.Name("CreatedAt").LessThan(before))
+	legacy := expression.Or(expression.AttributeNotExists(changedAt), changedAt.Equal(zero)).
+		And(expression.Or(finished, created))
+
+	return expression.Or(changed, legacy)
+}
+
+// unchangedExpr matches the order while its status and StatusChangedAt
+// are the ones read, a missing StatusChangedAt being read as zero.
+func unchangedExpr(order models.Order) expression.ConditionBuilder {
+	changedAt := expression.Name("StatusChangedAt").Equal(expression.Value(order.StatusChangedAt))
+	if order.StatusChangedAt.IsZero() {
+		changedAt = expression.Or(expression.AttributeNotExists(expression.Name("StatusChangedAt")), changedAt)
+	}
+	return expression.Name("Status").Equal(expression.Value(order.Status)).And(changedAt)
+}
+
+func (a *dynamodbOrderArchiver) queryOrders(ctx context.Context, expr expression.Expression, startKey map[string]types.AttributeValue) ([]models.Order, map[string]types.AttributeValue, error) {
+	items, lastKey, err := a.queryItems(ctx, expr, statusIndex, startKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get completed orders: %w", err)
+	}
+
+	orders := []models.Order{}
+	err = attributevalue.UnmarshalListOfMaps(items, &orders)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal orders: %w", err)
+	}
+
+	return orders, lastKey, nil
+}
+
+func (a *dynamodbOrderArchiver) queryItems(ctx context.Context, expr expression.Expression, index string, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	return a.dynamodbClient.QueryItem(ctx, a.table, expr, index, dynamodbBatchPageSize, startKey)
+}
+
+func archivedOrderKey(order models.Order) (map[string]types.AttributeValue, error) {
+	id, err := attributevalue.Marshal(order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order id: %w", err)
+	}
+	return map[string]types.AttributeValue{"PK": id}, nil
+}
+
+// archiveOrder moves the order to the pending partition of the export
+// name unless it changed since it was read, and reports whether it was
+// moved.
+func (a *dynamodbOrderArchiver) archiveOrder(ctx context.Context, order models.Order, archivedAt time.Time, name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	key, err := archivedOrderKey(order)
+	if err != nil {
+		return false, err
+	}
+
+	update := expression.Set(expression.Name("GSI1PK"), expression.Value(pendingArchiveEntity)).
+		Set(expression.Name("ArchiveExport"), expression.Value(name)).
+		Set(expression.Name("ArchivedAt"), expression.Value(archivedAt)).
+		Set(expression.Name("ExpiresAt"), expression.Value(archivedAt.Add(a.retention).Unix()))
+	condition := expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(unchangedExpr(order))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = a.dynamodbClient.UpdateItem(ctx, a.table, key, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to archive order [%s]: %w", order.ID, err)
+	}
+
+	return true, nil
+}
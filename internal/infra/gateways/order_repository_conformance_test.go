@@ -0,0 +1,233 @@
+package gateways
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOrderRepositoryConformance is the behaviour every OrderRepository
+// implementation must share. newRepository must return an empty store.
+func testOrderRepositoryConformance(t *testing.T, newRepository func(t *testing.T) OrderRepository) {
+	ctx := context.Background()
+
+	t.Run("save and list", func(t *testing.T) {
+		repo := newRepository(t)
+		order := newConformanceOrder(1, models.OrderStatusCreated)
+
+		err := repo.SaveOrder(ctx, order)
+		require.NoError(t, err)
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		require.Len(t, page.Results, 1)
+		assert.Equal(t, order.ID, page.Results[0].ID)
+		assert.Equal(t, order.Status, page.Results[0].Status)
+		assert.Equal(t, order.Items, page.Results[0].Items)
+		assert.True(t, order.CreatedAt.Equal(page.Results[0].CreatedAt))
+		assert.Empty(t, page.Next)
+	})
+
+	t.Run("save rejects duplicated order", func(t *testing.T) {
+		repo := newRepository(t)
+		order := newConformanceOrder(1, models.OrderStatusCreated)
+
+		require.NoError(t, repo.SaveOrder(ctx, order))
+
+		order.Status = models.OrderStatusReady
+		err := repo.SaveOrder(ctx, order)
+		assert.ErrorIs(t, err, ErrOrderAlreadyExists)
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, models.OrderStatusCreated, page.Results[0].Status)
+	})
+
+	t.Run("update status", func(t *testing.T) {
+		repo := newRepository(t)
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusCreated)))
+		saved, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), saved.Results[0].StatusChangedAt, time.Minute, "saving sets the status change time")
+		time.Sleep(10 * time.Millisecond)
+
+		err = repo.UpdateOrderStatus(ctx, 1, models.OrderStatusInProgress)
+		require.NoError(t, err)
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, models.OrderStatusInProgress, page.Results[0].Status)
+		assert.True(t, page.Results[0].StatusChangedAt.After(saved.Results[0].StatusChangedAt), "updating the status moves the status change time")
+	})
+
+	t.Run("get order with history", func(t *testing.T) {
+		repo := newRepository(t)
+		order := newConformanceOrder(1, models.OrderStatusCreated)
+		require.NoError(t, repo.SaveOrder(ctx, order))
+		require.NoError(t, repo.UpdateOrderStatus(ctx, 1, models.OrderStatusReady))
+		require.NoError(t, repo.UpdateOrderStatusWithReason(ctx, 1, models.OrderStatusCancelled, "customer left", "cashier-7"))
+
+		got, err := repo.GetOrder(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, order.Items, got.Items)
+		assert.Equal(t, models.OrderStatusCancelled, got.Status)
+		require.Len(t, got.History, 3)
+		for i, change := range got.History {
+			assert.False(t, change.ChangedAt.IsZero())
+			got.History[i].ChangedAt = time.Time{}
+		}
+		assert.Equal(t, []models.OrderStatusChange{
+			{To: models.OrderStatusCreated},
+			{From: models.OrderStatusCreated, To: models.OrderStatusReady},
+			{From: models.OrderStatusReady, To: models.OrderStatusCancelled, Reason: "customer left", Actor: "cashier-7"},
+		}, got.History)
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		assert.Nil(t, page.Results[0].History, "listings don't load the history")
+
+		_, err = repo.GetOrder(ctx, 404)
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+	})
+
+	t.Run("list orders with history", func(t *testing.T) {
+		repo := newRepository(t)
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusCreated)))
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(2, models.OrderStatusCreated)))
+		require.NoError(t, repo.UpdateOrderStatusWithReason(ctx, 2, models.OrderStatusDelivered, "picked up", "cashier-7"))
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{WithHistory: true})
+		require.NoError(t, err)
+		require.Len(t, page.Results, 2)
+		for _, order := range page.Results {
+			got, err := repo.GetOrder(ctx, mustAtoi(t, order.ID))
+			require.NoError(t, err)
+			assert.Equal(t, got.History, order.History)
+		}
+		assert.Len(t, page.Results[1].History, 2)
+	})
+
+	t.Run("update status of unknown order", func(t *testing.T) {
+		repo := newRepository(t)
+
+		err := repo.UpdateOrderStatus(ctx, 404, models.OrderStatusReady)
+		assert.ErrorIs(t, err, ErrOrderNotFound)
+
+		page, err := repo.GetOrders(ctx, models.OrderFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Results)
+	})
+
+	t.Run("filter by status", func(t *testing.T) {
+		repo := newRepository(t)
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusCreated)))
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(2, models.OrderStatusReady)))
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(3, models.OrderStatusDelivered)))
+
+		ids := collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusCreated, models.OrderStatusReady}})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("filter by status change window", func(t *testing.T) {
+		repo := newRepository(t)
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusDelivered)))
+		time.Sleep(time.Millisecond)
+		between := time.Now()
+		time.Sleep(time.Millisecond)
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(2, models.OrderStatusDelivered)))
+		require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(3, models.OrderStatusReady)))
+
+		ids := collectOrderIDs(t, repo, models.OrderFilter{ChangedFrom: between.Add(-time.Hour), ChangedTo: between})
+		assert.ElementsMatch(t, []string{"1"}, ids)
+
+		ids = collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedFrom: between, ChangedTo: between.Add(time.Hour)})
+		assert.ElementsMatch(t, []string{"2"}, ids)
+
+		ids = collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusReady, models.OrderStatusDelivered}, ChangedFrom: between})
+		assert.ElementsMatch(t, []string{"2", "3"}, ids)
+	})
+
+	t.Run("paginate", func(t *testing.T) {
+		repo := newRepository(t)
+		for i := 1; i <= 5; i++ {
+			status := models.OrderStatusCreated
+			if i%2 == 0 {
+				status = models.OrderStatusReady
+			}
+			require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(i, status)))
+		}
+
+		ids := collectOrderIDs(t, repo, models.OrderFilter{Limit: 2})
+		assert.ElementsMatch(t, []string{"1", "2", "3", "4", "5"}, ids)
+
+		ids = collectOrderIDs(t, repo, models.OrderFilter{Limit: 2, Statuses: []string{models.OrderStatusCreated}})
+		assert.ElementsMatch(t, []string{"1", "3", "5"}, ids)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		repo := newRepository(t)
+
+		_, err := repo.GetOrders(ctx, models.OrderFilter{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		repo := newRepository(t)
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := repo.SaveOrder(cancelledCtx, newConformanceOrder(1, models.OrderStatusCreated))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// collectOrderIDs follows the cursors until the last page, failing on
+// duplicated orders across pages.
+func collectOrderIDs(t *testing.T, repo OrderRepository, filter models.OrderFilter) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for pages := 0; pages < 100; pages++ {
+		page, err := repo.GetOrders(context.Background(), filter)
+		require.NoError(t, err)
+		if filter.Limit > 0 {
+			assert.LessOrEqual(t, len(page.Results), filter.Limit)
+		}
+
+		for _, order := range page.Results {
+			assert.False(t, seen[order.ID], "order [%s] returned twice", order.ID)
+			seen[order.ID] = true
+			ids = append(ids, order.ID)
+		}
+
+		if page.Next == "" {
+			return ids
+		}
+		filter.Cursor = page.Next
+	}
+
+	t.Fatal("pagination did not finish")
+	return nil
+}
+
+func mustAtoi(t *testing.T, value string) int {
+	i, err := strconv.Atoi(value)
+	require.NoError(t, err)
+	return i
+}
+
+func newConformanceOrder(id int, status string) models.Order {
+	return models.Order{
+		ID:        strconv.Itoa(id),
+		Status:    status,
+		CreatedAt: time.Date(2024, 5, 1, 12, id, 0, 0, time.UTC),
+		Items: []models.OrderItem{
+			{Quantity: 2, Type: "UNIT", Product: models.Product{Name: "Batata Frita", Description: "Batata canoa"}},
+		},
+		Entity: "ORDER",
+	}
+}
@@ -0,0 +1,152 @@
+package gateways
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+)
+
+// inMemoryOrderRepository keeps orders in process memory with the same
+// semantics as the DynamoDB repository. It is meant for local development
+// and tests; nothing survives a restart.
+type inMemoryOrderRepository struct {
+	mu      sync.RWMutex
+	orders  map[string]models.Order
+	history map[string][]models.OrderStatusChange
+}
+
+func NewInMemoryOrderRepository() OrderRepository {
+	return &inMemoryOrderRepository{
+		orders:  map[string]models.Order{},
+		history: map[string][]models.OrderStatusChange{},
+	}
+}
+
+// GetOrders lists orders sorted by creation time. Unlike DynamoDB, the
+// filter is applied before the limit, so pages are always full.
+func (r *inMemoryOrderRepository) GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	if err := ctx.Err(); err != nil {
+		return models.OrderPage{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := make([]models.Order, 0, len(r.orders))
+	for _, order := range r.orders {
+		if filter.MatchesStatus(order.Status) && filter.MatchesChangedAt(order.StatusChangedAt) {
+			order = copyOrder(order)
+			if filter.WithHistory {
+				order.History = append([]models.OrderStatusChange{}, r.history[order.ID]...)
+			}
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].ID < orders[j].ID
+		}
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+
+	start := 0
+	if filter.Cursor != "" {
+		lastID, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return models.OrderPage{}, ErrInvalidCursor
+		}
+		start = -1
+		for i, order := range orders {
+			if order.ID == string(lastID) {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return models.OrderPage{}, ErrInvalidCursor
+		}
+	}
+
+	orders = orders[start:]
+	if filter.Limit <= 0 || len(orders) <= filter.Limit {
+		return models.OrderPage{Results: orders}, nil
+	}
+
+	orders = orders[:filter.Limit]
+	next := base64.RawURLEncoding.EncodeToString([]byte(orders[len(orders)-1].ID))
+	return models.OrderPage{Results: orders, Next: next}, nil
+}
+
+func (r *inMemoryOrderRepository) GetOrder(ctx context.Context, orderId int) (models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Order{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id := strconv.Itoa(orderId)
+	order, ok := r.orders[id]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	order = copyOrder(order)
+	order.History = append([]models.OrderStatusChange{}, r.history[id]...)
+
+	return order, nil
+}
+
+func (r *inMemoryOrderRepository) SaveOrder(ctx context.Context, order models.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orders[order.ID]; ok {
+		return ErrOrderAlreadyExists
+	}
+	order.StatusChangedAt = time.Now().UTC()
+	order.History = nil
+	r.orders[order.ID] = copyOrder(order)
+	r.history[order.ID] = []models.OrderStatusChange{{To: order.Status, ChangedAt: order.StatusChangedAt}}
+
+	return nil
+}
+
+func (r *inMemoryOrderRepository) UpdateOrderStatus(ctx context.Context, orderId int, status string) error {
+	return r.UpdateOrderStatusWithReason(ctx, orderId, status, "", "")
+}
+
+func (r *inMemoryOrderRepository) UpdateOrderStatusWithReason(ctx context.Context, orderId int, status string, reason string, actor string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := strconv.Itoa(orderId)
+	order, ok := r.orders[id]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	change := models.OrderStatusChange{From: order.Status, To: status, Reason: reason, Actor: actor, ChangedAt: time.Now().UTC()}
+	order.Status = status
+	order.StatusChangedAt = change.ChangedAt
+	r.orders[id] = order
+	r.history[id] = append(r.history[id], change)
+
+	return nil
+}
+
+func copyOrder(order models.Order) models.Order {
+	order.Items = append([]models.OrderItem(nil), order.Items...)
+	return order
+}
@@ -0,0 +1,15 @@
+package gateways
+
+import "testing"
+
+func TestInMemoryOrderRepository(t *testing.T) {
+	testOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		return NewInMemoryOrderRepository()
+	})
+}
+
+func TestInMemoryWebhookRepository(t *testing.T) {
+	testWebhookRepositoryConformance(t, func(t *testing.T) WebhookRepository {
+		return NewInMemoryWebhookRepository()
+	})
+}
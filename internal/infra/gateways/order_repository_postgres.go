@@ -0,0 +1,353 @@
+package gateways
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxVersionConflicts bounds the optimistic locking retries of a status update.
+const maxVersionConflicts = 3
+
+var errVersionConflict = errors.New("order version changed")
+
+type postgresOrderRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresOrderRepository stores orders in the tables created by the
+// postgres driver migrations. Status updates use optimistic locking on the
+// order version and are recorded in order_status_history.
+func NewPostgresOrderRepository(db *sql.DB, timeout time.Duration) OrderRepository {
+	return &postgresOrderRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *postgresOrderRepository) GetOrders(ctx context.Context, filter models.OrderFilter) (page models.OrderPage, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresOrderRepository.GetOrders")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrders", time.Now(), &err)
+
+	query := strings.Builder{}
+	query.WriteString("SELECT id, status, customer_cpf, entity, created_at, finished_at, updated_at FROM orders WHERE true")
+	args := []any{}
+
+	if len(filter.Statuses) > 0 {
+		args = append(args, filter.Statuses)
+		fmt.Fprintf(&query, " AND status = ANY($%d)", len(args))
+	}
+	if !filter.ChangedFrom.IsZero() {
+		args = append(args, filter.ChangedFrom)
+		fmt.Fprintf(&query, " AND updated_at >= $%d", len(args))
+	}
+	if !filter.ChangedTo.IsZero() {
+		args = append(args, filter.ChangedTo)
+		fmt.Fprintf(&query, " AND updated_at < $%d", len(args))
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeOrderCursor(filter.Cursor)
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		fmt.Fprintf(&query, " AND (created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	query.WriteString(" ORDER BY created_at, id")
+	if filter.Limit > 0 {
+		// one extra row tells whether there is a next page
+		args = append(args, filter.Limit+1)
+		fmt.Fprintf(&query, " LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return models.OrderPage{}, fmt.Errorf("failed to get orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		order := models.Order{}
+		finishedAt := sql.NullTime{}
+		err = rows.Scan(&order.ID, &order.Status, &order.CustomerCPF, &order.Entity, &order.CreatedAt, &finishedAt, &order.StatusChangedAt)
+		if err != nil {
+			return models.OrderPage{}, fmt.Errorf("failed to scan order: %w", err)
+		}
+		order.FinishedAt = finishedAt.Time
+		orders = append(orders, order)
+	}
+	err = rows.Err()
+	if err != nil {
+		return models.OrderPage{}, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	if filter.Limit > 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+		last := orders[len(orders)-1]
+		page.Next, err = encodeOrderCursor(orderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+	}
+
+	err = r.loadItems(ctx, orders)
+	if err != nil {
+		return models.OrderPage{}, err
+	}
+	if filter.WithHistory {
+		err = r.loadHistory(ctx, orders)
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+	}
+
+	page.Results = orders
+	return page, nil
+}
+
+func (r *postgresOrderRepository) loadItems(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(orders))
+	index := make(map[string]int, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+		index[order.ID] = i
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT order_id, quantity, type, product_name, product_description
+		FROM order_items WHERE order_id = ANY($1) ORDER BY order_id, position`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		item := models.OrderItem{}
+		err = rows.Scan(&orderID, &item.Quantity, &item.Type, &item.Product.Name, &item.Product.Description)
+		if err != nil {
+			return fmt.Errorf("failed to scan order item: %w", err)
+		}
+		i := index[orderID]
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresOrderRepository) SaveOrder(ctx context.Context, order models.Order) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresOrderRepository.SaveOrder")
+	span.SetAttributes(attribute.String("order.id", order.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveOrder", time.Now(), &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	finishedAt := sql.NullTime{Time: order.FinishedAt, Valid: !order.FinishedAt.IsZero()}
+	result, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status, customer_cpf, entity, created_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (id) DO NOTHING`,
+		order.ID, order.Status, order.CustomerCPF, order.Entity, order.CreatedAt, finishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	if inserted == 0 {
+		return ErrOrderAlreadyExists
+	}
+
+	for position, item := range order.Items {
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, position, quantity, type, product_name, product_description)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			order.ID, position, item.Quantity, item.Type, item.Product.Name, item.Product.Description)
+		if err != nil {
+			return fmt.Errorf("failed to insert order item: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_id, to_status, version) VALUES ($1, $2, 1)`,
+		order.ID, order.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert order status history: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresOrderRepository) GetOrder(ctx context.Context, orderId int) (order models.Order, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresOrderRepository.GetOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrder", time.Now(), &err)
+
+	id := strconv.Itoa(orderId)
+	finishedAt := sql.NullTime{}
+	err = r.db.QueryRowContext(ctx, "SELECT id, status, customer_cpf, entity, created_at, finished_at, updated_at FROM orders WHERE id = $1", id).
+		Scan(&order.ID, &order.Status, &order.CustomerCPF, &order.Entity, &order.CreatedAt, &finishedAt, &order.StatusChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to get order: %w", err)
+	}
+	order.FinishedAt = finishedAt.Time
+
+	orders := []models.Order{order}
+	err = r.loadItems(ctx, orders)
+	if err != nil {
+		return models.Order{}, err
+	}
+	err = r.loadHistory(ctx, orders)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return orders[0], nil
+}
+
+func (r *postgresOrderRepository) loadHistory(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(orders))
+	index := make(map[string]int, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+		index[order.ID] = i
+		orders[i].History = []models.OrderStatusChange{}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT order_id, COALESCE(from_status, ''), to_status, reason, actor, changed_at
+		FROM order_status_history WHERE order_id = ANY($1) ORDER BY order_id, id`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get order status history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		change := models.OrderStatusChange{}
+		err = rows.Scan(&orderID, &change.From, &change.To, &change.Reason, &change.Actor, &change.ChangedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan order status history: %w", err)
+		}
+		i := index[orderID]
+		orders[i].History = append(orders[i].History, change)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to get order status history: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresOrderRepository) UpdateOrderStatus(ctx context.Context, orderId int, status string) error {
+	return r.UpdateOrderStatusWithReason(ctx, orderId, status, "", "")
+}
+
+func (r *postgresOrderRepository) UpdateOrderStatusWithReason(ctx context.Context, orderId int, status string, reason string, actor string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresOrderRepository.UpdateOrderStatus")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", status))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("UpdateOrderStatus", time.Now(), &err)
+
+	id := strconv.Itoa(orderId)
+	for attempt := 0; attempt < maxVersionConflicts; attempt++ {
+		err = r.updateOrderStatus(ctx, id, status, reason, actor)
+		if !errors.Is(err, errVersionConflict) {
+			return err
+		}
+	}
+
+	return ErrOrderConflict
+}
+
+// updateOrderStatus changes the status only if the order still has the
+// version it was read with, so the history always records the real transition.
+func (r *postgresOrderRepository) updateOrderStatus(ctx context.Context, id string, status string, reason string, actor string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentStatus string
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT status, version FROM orders WHERE id = $1", id).Scan(&currentStatus, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1, version = version + 1, updated_at = now()
+		WHERE id = $2 AND version = $3`, status, id, version)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	if updated == 0 {
+		return errVersionConflict
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_id, from_status, to_status, reason, actor, version) VALUES ($1, $2, $3, $4, $5, $6)`,
+		id, currentStatus, status, reason, actor, version+1)
+	if err != nil {
+		return fmt.Errorf("failed to insert order status history: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit order status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,486 @@
+package gateways
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/sqlite"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sqliteTimeFormat is fixed width so UTC timestamps stored as text sort
+// in chronological order.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqliteBatchSize bounds the bound parameters of an IN list and how long
+// the archive job holds the write lock.
+const sqliteBatchSize = 500
+
+// OrderArchiver moves completed orders out of the working tables.
+type OrderArchiver interface {
+	ArchiveOrders(ctx context.Context, completedBefore time.Time) (int, error)
+}
+
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type sqliteOrderRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewSQLiteOrderRepository stores orders in the schema created by the sqlite
+// driver, keeping the same status history as the postgres repository.
+func NewSQLiteOrderRepository(db *sql.DB, timeout time.Duration) OrderRepository {
+	return &sqliteOrderRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *sqliteOrderRepository) GetOrders(ctx context.Context, filter models.OrderFilter) (page models.OrderPage, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteOrderRepository.GetOrders")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrders", time.Now(), &err)
+
+	query := strings.Builder{}
+	query.WriteString("SELECT id, status, customer_cpf, entity, created_at, finished_at, updated_at FROM orders WHERE 1 = 1")
+	args := []any{}
+
+	if len(filter.Statuses) > 0 {
+		query.WriteString(" AND status IN (?" + strings.Repeat(", ?", len(filter.Statuses)-1) + ")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if !filter.ChangedFrom.IsZero() {
+		query.WriteString(" AND updated_at >= ?")
+		args = append(args, formatSQLiteTime(filter.ChangedFrom))
+	}
+	if !filter.ChangedTo.IsZero() {
+		query.WriteString(" AND updated_at < ?")
+		args = append(args, formatSQLiteTime(filter.ChangedTo))
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeOrderCursor(filter.Cursor)
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+		query.WriteString(" AND (created_at, id) > (?, ?)")
+		args = append(args, formatSQLiteTime(cursor.CreatedAt), cursor.ID)
+	}
+	query.WriteString(" ORDER BY created_at, id")
+	if filter.Limit > 0 {
+		// one extra row tells whether there is a next page
+		query.WriteString(" LIMIT ?")
+		args = append(args, filter.Limit+1)
+	}
+
+	orders, err := querySQLiteOrders(ctx, r.db, query.String(), args...)
+	if err != nil {
+		return models.OrderPage{}, err
+	}
+	if filter.WithHistory {
+		err = loadSQLiteOrderHistory(ctx, r.db, orders)
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+	}
+
+	if filter.Limit > 0 && len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+		last := orders[len(orders)-1]
+		page.Next, err = encodeOrderCursor(orderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		if err != nil {
+			return models.OrderPage{}, err
+		}
+	}
+
+	page.Results = orders
+	return page, nil
+}
+
+func (r *sqliteOrderRepository) SaveOrder(ctx context.Context, order models.Order) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteOrderRepository.SaveOrder")
+	span.SetAttributes(attribute.String("order.id", order.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveOrder", time.Now(), &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := formatSQLiteTime(time.Now())
+	result, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status, customer_cpf, entity, created_at, finished_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		order.ID, order.Status, order.CustomerCPF, order.Entity, formatSQLiteTime(order.CreatedAt), formatSQLiteNullTime(order.FinishedAt), now)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+	if inserted == 0 {
+		return ErrOrderAlreadyExists
+	}
+
+	for position, item := range order.Items {
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, position, quantity, type, product_name, product_description)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			order.ID, position, item.Quantity, item.Type, item.Product.Name, item.Product.Description)
+		if err != nil {
+			return fmt.Errorf("failed to insert order item: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_id, to_status, version, changed_at) VALUES (?, ?, 1, ?)`,
+		order.ID, order.Status, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert order status history: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sqliteOrderRepository) GetOrder(ctx context.Context, orderId int) (order models.Order, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteOrderRepository.GetOrder")
+	span.SetAttributes(attribute.Int("order.id", orderId))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetOrder", time.Now(), &err)
+
+	id := strconv.Itoa(orderId)
+	orders, err := querySQLiteOrders(ctx, r.db, "SELECT id, status, customer_cpf, entity, created_at, finished_at, updated_at FROM orders WHERE id = ?", id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if len(orders) == 0 {
+		return models.Order{}, ErrOrderNotFound
+	}
+	err = loadSQLiteOrderHistory(ctx, r.db, orders)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return orders[0], nil
+}
+
+func (r *sqliteOrderRepository) UpdateOrderStatus(ctx context.Context, orderId int, status string) error {
+	return r.UpdateOrderStatusWithReason(ctx, orderId, status, "", "")
+}
+
+// UpdateOrderStatusWithReason runs in an immediate transaction, so the
+// status read for the history cannot change before the update is written.
+func (r *sqliteOrderRepository) UpdateOrderStatusWithReason(ctx context.Context, orderId int, status string, reason string, actor string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteOrderRepository.UpdateOrderStatus")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", status))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("UpdateOrderStatus", time.Now(), &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id := strconv.Itoa(orderId)
+	var currentStatus string
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT status, version FROM orders WHERE id = ?", id).Scan(&currentStatus, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+
+	now := formatSQLiteTime(time.Now())
+	_, err = tx.ExecContext(ctx, "UPDATE orders SET status = ?, version = version + 1, updated_at = ? WHERE id = ?",
+		status, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_id, from_status, to_status, reason, actor, version, changed_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, currentStatus, status, reason, actor, version+1, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert order status history: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit order status: %w", err)
+	}
+
+	return nil
+}
+
+type sqliteOrderArchiver struct {
+	db *sql.DB
+}
+
+// NewSQLiteOrderArchiver moves DELIVERED and CANCELLED orders into
+// archived_orders as JSON documents and compacts the database file.
+func NewSQLiteOrderArchiver(db *sql.DB) OrderArchiver {
+	return &sqliteOrderArchiver{db: db}
+}
+
+// ArchiveOrders archives the completed orders last changed before
+// completedBefore and returns how many were moved.
+func (a *sqliteOrderArchiver) ArchiveOrders(ctx context.Context, completedBefore time.Time) (archived int, err error) {
+	ctx, span := tracer.Start(ctx, "sqliteOrderArchiver.ArchiveOrders")
+	defer tracing.EndSpan(span, &err)
+
+	for {
+		moved, err := a.archiveBatch(ctx, completedBefore)
+		archived += moved
+		if err != nil {
+			return archived, err
+		}
+		if moved < sqliteBatchSize {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("orders.archived", archived))
+
+	if archived == 0 {
+		return 0, nil
+	}
+
+	err = sqlite.Compact(ctx, a.db)
+	if err != nil {
+		return archived, err
+	}
+
+	return archived, nil
+}
+
+func (a *sqliteOrderArchiver) archiveBatch(ctx context.Context, completedBefore time.Time) (int, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	orders, err := querySQLiteOrders(ctx, tx, `SELECT id, status, customer_cpf, entity, created_at, finished_at, updated_at FROM orders
+		WHERE status IN (?, ?) AND updated_at < ? ORDER BY updated_at LIMIT ?`,
+		models.OrderStatusDelivered, models.OrderStatusCancelled, formatSQLiteTime(completedBefore), sqliteBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	archivedAt := formatSQLiteTime(time.Now())
+	for _, order := range orders {
+		payload, err := json.Marshal(order)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal order [%s]: %w", order.ID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO archived_orders (id, status, created_at, archived_at, payload) VALUES (?, ?, ?, ?, ?)`,
+			order.ID, order.Status, formatSQLiteTime(order.CreatedAt), archivedAt, string(payload))
+		if err != nil {
+			return 0, fmt.Errorf("failed to archive order [%s]: %w", order.ID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM orders WHERE id = ?", order.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete archived order [%s]: %w", order.ID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to commit archived orders: %w", err)
+	}
+
+	return len(orders), nil
+}
+
+// querySQLiteOrders runs a query over the orders table columns and loads
+// the items of every returned order.
+func querySQLiteOrders(ctx context.Context, db sqlQueryer, query string, args ...any) ([]models.Order, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	index := map[string]int{}
+	for rows.Next() {
+		order := models.Order{}
+		var createdAt, updatedAt string
+		var finishedAt sql.NullString
+		err = rows.Scan(&order.ID, &order.Status, &order.CustomerCPF, &order.Entity, &createdAt, &finishedAt, &updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		order.CreatedAt, err = time.Parse(sqliteTimeFormat, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse order created_at: %w", err)
+		}
+		order.StatusChangedAt, err = time.Parse(sqliteTimeFormat, updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse order updated_at: %w", err)
+		}
+		if finishedAt.Valid {
+			order.FinishedAt, err = time.Parse(sqliteTimeFormat, finishedAt.String)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse order finished_at: %w", err)
+			}
+		}
+
+		index[order.ID] = len(orders)
+		orders = append(orders, order)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	rows.Close()
+
+	for start := 0; start < len(orders); start += sqliteBatchSize {
+		end := start + sqliteBatchSize
+		if end > len(orders) {
+			end = len(orders)
+		}
+		err = loadSQLiteOrderItems(ctx, db, orders, index, orders[start:end])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return orders, nil
+}
+
+// loadSQLiteOrderItems fills the items of batch, a window of orders small
+// enough to stay under the bound parameters limit.
+func loadSQLiteOrderItems(ctx context.Context, db sqlQueryer, orders []models.Order, index map[string]int, batch []models.Order) error {
+	ids := make([]any, len(batch))
+	for i, order := range batch {
+		ids[i] = order.ID
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT order_id, quantity, type, product_name, product_description FROM order_items
+		WHERE order_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) ORDER BY order_id, position`, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		item := models.OrderItem{}
+		err = rows.Scan(&orderID, &item.Quantity, &item.Type, &item.Product.Name, &item.Product.Description)
+		if err != nil {
+			return fmt.Errorf("failed to scan order item: %w", err)
+		}
+		i := index[orderID]
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+
+	return nil
+}
+
+// loadSQLiteOrderHistory fills the status history of the orders, in
+// batches staying under the bound parameters limit.
+func loadSQLiteOrderHistory(ctx context.Context, db sqlQueryer, orders []models.Order) error {
+	index := make(map[string]int, len(orders))
+	for i, order := range orders {
+		index[order.ID] = i
+		orders[i].History = []models.OrderStatusChange{}
+	}
+
+	for start := 0; start < len(orders); start += sqliteBatchSize {
+		end := start + sqliteBatchSize
+		if end > len(orders) {
+			end = len(orders)
+		}
+		ids := make([]any, 0, end-start)
+		for _, order := range orders[start:end] {
+			ids = append(ids, order.ID)
+		}
+
+		err := loadSQLiteOrderHistoryBatch(ctx, db, orders, index, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadSQLiteOrderHistoryBatch(ctx context.Context, db sqlQueryer, orders []models.Order, index map[string]int, ids []any) error {
+	rows, err := db.QueryContext(ctx, `SELECT order_id, COALESCE(from_status, ''), to_status, reason, actor, changed_at FROM order_status_history
+		WHERE order_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) ORDER BY order_id, id`, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to get order status history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID, changedAt string
+		change := models.OrderStatusChange{}
+		err = rows.Scan(&orderID, &change.From, &change.To, &change.Reason, &change.Actor, &changedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan order status history: %w", err)
+		}
+		change.ChangedAt, err = time.Parse(sqliteTimeFormat, changedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse order status history changed_at: %w", err)
+		}
+		i := index[orderID]
+		orders[i].History = append(orders[i].History, change)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to get order status history: %w", err)
+	}
+
+	return nil
+}
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func formatSQLiteNullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatSQLiteTime(t), Valid: true}
+}
@@ -0,0 +1,99 @@
+package gateways
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteTestDB(t *testing.T) *sql.DB {
+	db, err := sqlite.NewSQLiteDB(context.Background(), filepath.Join(t.TempDir(), "production.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLiteOrderRepositoryConformance(t *testing.T) {
+	testOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		return NewSQLiteOrderRepository(newSQLiteTestDB(t), 5*time.Second)
+	})
+}
+
+func TestSQLiteWebhookRepositoryConformance(t *testing.T) {
+	testWebhookRepositoryConformance(t, func(t *testing.T) WebhookRepository {
+		return NewSQLiteWebhookRepository(newSQLiteTestDB(t), 5*time.Second)
+	})
+}
+
+func TestSQLiteOrderRepository_ReopenKeepsOrders(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "production.db")
+
+	db, err := sqlite.NewSQLiteDB(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, NewSQLiteOrderRepository(db, 5*time.Second).SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusCreated)))
+	// as in the files created before the status history had a reason
+	_, err = db.Exec("ALTER TABLE order_status_history DROP COLUMN reason")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = sqlite.NewSQLiteDB(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var journalMode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.Equal(t, "wal", journalMode)
+
+	repo := NewSQLiteOrderRepository(db, 5*time.Second)
+	page, err := repo.GetOrders(ctx, models.OrderFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Results, 1)
+
+	require.NoError(t, repo.UpdateOrderStatusWithReason(ctx, 1, models.OrderStatusCancelled, "duplicated order", ""))
+	order, err := repo.GetOrder(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "duplicated order", order.History[1].Reason)
+}
+
+func TestSQLiteOrderArchiver_ArchiveOrders(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteTestDB(t)
+	repo := NewSQLiteOrderRepository(db, 5*time.Second)
+	archiver := NewSQLiteOrderArchiver(db)
+
+	require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(1, models.OrderStatusDelivered)))
+	require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(2, models.OrderStatusCancelled)))
+	require.NoError(t, repo.SaveOrder(ctx, newConformanceOrder(3, models.OrderStatusReady)))
+
+	archived, err := archiver.ArchiveOrders(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, archived, "recently completed orders are kept")
+
+	archived, err = archiver.ArchiveOrders(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, archived)
+
+	ids := collectOrderIDs(t, repo, models.OrderFilter{})
+	assert.Equal(t, []string{"3"}, ids)
+
+	var payload string
+	require.NoError(t, db.QueryRow("SELECT payload FROM archived_orders WHERE id = '1'").Scan(&payload))
+	order := models.Order{}
+	require.NoError(t, json.Unmarshal([]byte(payload), &order))
+	assert.Equal(t, models.OrderStatusDelivered, order.Status)
+	assert.Equal(t, newConformanceOrder(1, models.OrderStatusDelivered).Items, order.Items)
+
+	var history int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM order_status_history WHERE order_id IN ('1', '2')").Scan(&history))
+	assert.Zero(t, history, "history of archived orders is removed with them")
+}
@@ -1,13 +1,22 @@
 package gateways
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strconv"
 	"testing"
+	"time"
 
-	mock_dynamodb "github.com/IgorRamosBR/g73-techchallenge-payment/pkg/dynamodb/mocks"
 	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	mock_dynamodb "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	awsDynamoDb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -20,7 +29,7 @@ func TestGetOrders(t *testing.T) {
 	table := "Kitchen"
 	gsi := "SecondaryIndex"
 	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
-	repo := NewOrderRepository(mockDynamoDBClient, table)
+	repo := NewOrderRepository(mockDynamoDBClient, table, time.Second)
 
 	tests := []struct {
 		name           string
@@ -45,8 +54,8 @@ func TestGetOrders(t *testing.T) {
 				expr, _ := expression.NewBuilder().WithKeyCondition(entityExpr).Build()
 
 				mockDynamoDBClient.EXPECT().
-					QueryItem(table, expr, gsi).
-					Return(expectedItems, nil)
+					QueryItem(gomock.Any(), table, expr, gsi, int32(0), nil).
+					Return(expectedItems, nil, nil)
 			},
 			expectedOrders: []models.Order{
 				{ID: "1", Status: "READY"},
@@ -61,8 +70,8 @@ func TestGetOrders(t *testing.T) {
 				expr, _ := expression.NewBuilder().WithKeyCondition(entityExpr).Build()
 
 				mockDynamoDBClient.EXPECT().
-					QueryItem(table, expr, gsi).
-					Return(nil, errors.New("dynamodb error"))
+					QueryItem(gomock.Any(), table, expr, gsi, int32(0), nil).
+					Return(nil, nil, errors.New("dynamodb error"))
 			},
 			expectedOrders: nil,
 			expectedError:  errors.New("failed to get orders: dynamodb error"),
@@ -73,8 +82,8 @@ func TestGetOrders(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockSetup()
 
-			orders, err := repo.GetOrders()
-			assert.Equal(t, len(tt.expectedOrders), len(orders))
+			page, err := repo.GetOrders(context.Background(), models.OrderFilter{})
+			assert.Equal(t, len(tt.expectedOrders), len(page.Results))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 			} else {
@@ -84,13 +93,222 @@ func TestGetOrders(t *testing.T) {
 	}
 }
 
+func TestGetOrders_FillsFilteredPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	table := "Kitchen"
+	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+	repo := NewOrderRepository(mockDynamoDBClient, table, time.Second)
+
+	filter := models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, Limit: 2}
+	statusExpr := expression.Name("Status").In(expression.Value(models.OrderStatusDelivered))
+	expr, _ := expression.NewBuilder().WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value("ORDER"))).WithFilter(statusExpr).Build()
+
+	first, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusDelivered})
+	second, _ := attributevalue.MarshalMap(models.Order{ID: "4", Status: models.OrderStatusDelivered})
+	firstKey, _ := attributevalue.MarshalMap(map[string]string{"PK": "2", "GSI1PK": "ORDER"})
+	emptyKey, _ := attributevalue.MarshalMap(map[string]string{"PK": "3", "GSI1PK": "ORDER"})
+	lastKey, _ := attributevalue.MarshalMap(map[string]string{"PK": "4", "GSI1PK": "ORDER"})
+	gomock.InOrder(
+		mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, expr, "SecondaryIndex", int32(2), nil).Return([]map[string]types.AttributeValue{first}, firstKey, nil),
+		mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, expr, "SecondaryIndex", int32(1), firstKey).Return(nil, emptyKey, nil),
+		mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, expr, "SecondaryIndex", int32(1), emptyKey).Return([]map[string]types.AttributeValue{second}, lastKey, nil),
+	)
+
+	page, err := repo.GetOrders(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Order{{ID: "1", Status: models.OrderStatusDelivered}, {ID: "4", Status: models.OrderStatusDelivered}}, page.Results, "the page is filled across the items filtered out")
+	next, _ := encodeCursor(lastKey)
+	assert.Equal(t, next, page.Next)
+}
+
+func TestCheckOrderTable(t *testing.T) {
+	index := func(name string) types.GlobalSecondaryIndexDescription {
+		return types.GlobalSecondaryIndexDescription{IndexName: aws.String(name)}
+	}
+
+	tests := []struct {
+		name          string
+		description   *types.TableDescription
+		describeErr   error
+		expectedError string
+	}{
+		{
+			name:        "every index",
+			description: &types.TableDescription{GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{index("SecondaryIndex"), index("StatusIndex")}},
+		},
+		{
+			name:          "without the status index",
+			description:   &types.TableDescription{GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{index("SecondaryIndex")}},
+			expectedError: "table [Kitchen] has no index [StatusIndex]",
+		},
+		{
+			name:          "missing table",
+			describeErr:   errors.New("table not found"),
+			expectedError: "table not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			mockDynamoDBClient.EXPECT().DescribeTable(gomock.Any(), "Kitchen").Return(tt.description, tt.describeErr)
+
+			err := CheckOrderTable(context.Background(), mockDynamoDBClient, "Kitchen")
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetOrders_StatusIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	table := "Kitchen"
+	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+	repo := NewOrderRepository(mockDynamoDBClient, table, time.Second)
+	ctx := context.Background()
+
+	received, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusReceived})
+	ready, _ := attributevalue.MarshalMap(models.Order{ID: "2", Status: models.OrderStatusReady})
+	receivedPartition := orderStatusPartition(models.OrderStatusReceived, "1", time.Time{})
+	readyPartition := orderStatusPartition(models.OrderStatusReady, "2", time.Time{})
+	readyKey, _ := attributevalue.MarshalMap(map[string]string{"PK": "2", "GSI2PK": readyPartition, "GSI2SK": "2024-05-10T12:00:00.000000000Z#2"})
+	calls := []any{}
+	for _, status := range []string{models.OrderStatusReceived, models.OrderStatusReady} {
+		for shard := 0; shard < activeStatusShards; shard++ {
+			partition := activeStatusPartition(status, shard)
+			call := mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, statusPartitionExpr(partition), "StatusIndex", int32(1), nil)
+			switch partition {
+			case receivedPartition:
+				call.Return([]map[string]types.AttributeValue{received}, nil, nil)
+			case readyPartition:
+				call.Return([]map[string]types.AttributeValue{ready}, readyKey, nil)
+				calls = append(calls, call)
+				call = mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, statusPartitionExpr(partition), "StatusIndex", int32(1), readyKey).Return(nil, nil, nil)
+			default:
+				call.Return(nil, nil, nil)
+			}
+			calls = append(calls, call)
+		}
+	}
+	gomock.InOrder(calls...)
+
+	filter := models.OrderFilter{Statuses: []string{models.OrderStatusReceived, models.OrderStatusReady, models.OrderStatusReceived}, Limit: 1}
+	ids := []string{}
+	for {
+		page, err := repo.GetOrders(ctx, filter)
+		assert.NoError(t, err)
+		for _, order := range page.Results {
+			ids = append(ids, order.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		filter.Cursor = page.Next
+	}
+	assert.Equal(t, []string{"1", "2"}, ids, "the partition of the next status starts on a new page")
+
+	cursor, _ := encodeCursor(readyKey)
+	_, err := repo.GetOrders(ctx, models.OrderFilter{Statuses: []string{models.OrderStatusReceived}, Cursor: cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor of a status out of the filter")
+}
+
+func TestStatusPartitions(t *testing.T) {
+	from := time.Date(2024, 5, 10, 3, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 5, 12, 9, 0, 0, 0, time.UTC)
+	activeShards := func(statuses ...string) []string {
+		partitions := []string{}
+		for _, status := range statuses {
+			for shard := 0; shard < activeStatusShards; shard++ {
+				partitions = append(partitions, activeStatusPartition(status, shard))
+			}
+		}
+		return partitions
+	}
+
+	tests := []struct {
+		name               string
+		filter             models.OrderFilter
+		expectedPartitions []string
+	}{
+		{
+			name:   "active statuses",
+			filter: models.OrderFilter{Statuses: []string{models.OrderStatusReady, models.OrderStatusCreated, models.OrderStatusReady}},
+			expectedPartitions: []string{
+				"ORDER#READY#0", "ORDER#READY#1", "ORDER#READY#2", "ORDER#READY#3", "ORDER#READY#4", "ORDER#READY#5", "ORDER#READY#6", "ORDER#READY#7",
+				"ORDER#CREATED#0", "ORDER#CREATED#1", "ORDER#CREATED#2", "ORDER#CREATED#3", "ORDER#CREATED#4", "ORDER#CREATED#5", "ORDER#CREATED#6", "ORDER#CREATED#7",
+			},
+		},
+		{
+			name:               "completed statuses in a window",
+			filter:             models.OrderFilter{Statuses: []string{models.OrderStatusDelivered, models.OrderStatusCancelled}, ChangedFrom: from, ChangedTo: from.Add(24 * time.Hour)},
+			expectedPartitions: []string{"ORDER#DELIVERED#2024-05-10", "ORDER#DELIVERED#2024-05-11", "ORDER#CANCELLED#2024-05-10", "ORDER#CANCELLED#2024-05-11"},
+		},
+		{
+			name:               "window ending at midnight",
+			filter:             models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedFrom: from, ChangedTo: time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)},
+			expectedPartitions: []string{"ORDER#DELIVERED#2024-05-10"},
+		},
+		{
+			name:               "window ending now",
+			filter:             models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedFrom: from},
+			expectedPartitions: []string{"ORDER#DELIVERED#2024-05-10", "ORDER#DELIVERED#2024-05-11", "ORDER#DELIVERED#2024-05-12"},
+		},
+		{
+			name:   "completed statuses without a window",
+			filter: models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedTo: from},
+		},
+		{
+			name:   "window too wide",
+			filter: models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedFrom: from, ChangedTo: from.Add(40 * 24 * time.Hour)},
+		},
+		{
+			name:               "active and completed statuses",
+			filter:             models.OrderFilter{Statuses: []string{models.OrderStatusReady, models.OrderStatusDelivered}, ChangedFrom: from, ChangedTo: from.Add(time.Hour)},
+			expectedPartitions: append(activeShards(models.OrderStatusReady), "ORDER#DELIVERED#2024-05-10"),
+		},
+		{
+			name:   "every status in a window",
+			filter: models.OrderFilter{ChangedFrom: now.Add(-time.Hour)},
+			expectedPartitions: append(activeShards(models.OrderStatusCreated, models.OrderStatusReceived, models.OrderStatusInProgress, models.OrderStatusReady),
+				"ORDER#DELIVERED#2024-05-12", "ORDER#CANCELLED#2024-05-12"),
+		},
+		{
+			name: "every status",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partitions, ok := statusPartitions(tt.filter, now)
+			assert.Equal(t, tt.expectedPartitions != nil, ok)
+			assert.Equal(t, tt.expectedPartitions, partitions)
+		})
+	}
+}
+
+func statusPartitionExpr(partition string) expression.Expression {
+	keyExpr := expression.Key("GSI2PK").Equal(expression.Value(partition))
+	expr, _ := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	return expr
+}
+
 func TestSaveOrder(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	table := "Kitchen"
 	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
-	repo := NewOrderRepository(mockDynamoDBClient, table)
+	repo := NewOrderRepository(mockDynamoDBClient, table, time.Second)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	repo.(*orderRepository).now = func() time.Time { return now }
 
 	tests := []struct {
 		name          string
@@ -102,10 +320,9 @@ func TestSaveOrder(t *testing.T) {
 			name:  "success",
 			order: models.Order{ID: "1", Status: "NEW"},
 			mockSetup: func() {
-				order := models.Order{ID: "1", Status: "NEW"}
-				orderAV, _ := attributevalue.MarshalMap(order)
+				orderAV := saveOrderItem(models.Order{ID: "1", Status: "NEW", StatusChangedAt: now, History: []models.OrderStatusChange{{To: "NEW", ChangedAt: now}}})
 				mockDynamoDBClient.EXPECT().
-					PutItem(table, orderAV).
+					PutItem(gomock.Any(), table, orderAV, saveOrderExpr()).
 					Return(nil)
 			},
 			expectedError: nil,
@@ -114,21 +331,31 @@ func TestSaveOrder(t *testing.T) {
 			name:  "dynamodb error",
 			order: models.Order{ID: "1", Status: "NEW"},
 			mockSetup: func() {
-				order := models.Order{ID: "1", Status: "NEW"}
-				orderAV, _ := attributevalue.MarshalMap(order)
+				orderAV := saveOrderItem(models.Order{ID: "1", Status: "NEW", StatusChangedAt: now, History: []models.OrderStatusChange{{To: "NEW", ChangedAt: now}}})
 				mockDynamoDBClient.EXPECT().
-					PutItem(table, orderAV).
+					PutItem(gomock.Any(), table, orderAV, saveOrderExpr()).
 					Return(errors.New("dynamodb error"))
 			},
 			expectedError: errors.New("failed to put order: dynamodb error"),
 		},
+		{
+			name:  "order already exists",
+			order: models.Order{ID: "1", Status: "NEW"},
+			mockSetup: func() {
+				orderAV := saveOrderItem(models.Order{ID: "1", Status: "NEW", StatusChangedAt: now, History: []models.OrderStatusChange{{To: "NEW", ChangedAt: now}}})
+				mockDynamoDBClient.EXPECT().
+					PutItem(gomock.Any(), table, orderAV, saveOrderExpr()).
+					Return(dynamodb.ErrConditionFailed)
+			},
+			expectedError: ErrOrderAlreadyExists,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockSetup()
 
-			err := repo.SaveOrder(tt.order)
+			err := repo.SaveOrder(context.Background(), tt.order)
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 			} else {
@@ -144,7 +371,9 @@ func TestUpdateOrderStatus(t *testing.T) {
 
 	table := "Kitchen"
 	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
-	repo := NewOrderRepository(mockDynamoDBClient, table)
+	repo := NewOrderRepository(mockDynamoDBClient, table, time.Second)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	repo.(*orderRepository).now = func() time.Time { return now }
 
 	tests := []struct {
 		name          string
@@ -160,14 +389,13 @@ func TestUpdateOrderStatus(t *testing.T) {
 			mockSetup: func() {
 				ID := 1
 				status := "COMPLETED"
-				IDAV, _ := attributevalue.Marshal(ID)
-				key := map[string]types.AttributeValue{"OrderID": IDAV}
+				IDAV, _ := attributevalue.Marshal(strconv.Itoa(ID))
+				key := map[string]types.AttributeValue{"PK": IDAV}
 
-				update := expression.Set(expression.Name("Status"), expression.Value(status))
-				expr, _ := expression.NewBuilder().WithUpdate(update).Build()
+				expr := updateOrderStatusExpr(status, now, "")
 
 				mockDynamoDBClient.EXPECT().
-					UpdateItem(table, key, expr).
+					UpdateItem(gomock.Any(), table, key, expr).
 					Return(nil)
 			},
 			expectedError: nil,
@@ -179,25 +407,67 @@ func TestUpdateOrderStatus(t *testing.T) {
 			mockSetup: func() {
 				ID := 1
 				status := "COMPLETED"
-				IDAV, _ := attributevalue.Marshal(ID)
-				key := map[string]types.AttributeValue{"OrderID": IDAV}
+				IDAV, _ := attributevalue.Marshal(strconv.Itoa(ID))
+				key := map[string]types.AttributeValue{"PK": IDAV}
 
-				update := expression.Set(expression.Name("Status"), expression.Value(status))
-				expr, _ := expression.NewBuilder().WithUpdate(update).Build()
+				expr := updateOrderStatusExpr(status, now, "")
 
 				mockDynamoDBClient.EXPECT().
-					UpdateItem(table, key, expr).
+					UpdateItem(gomock.Any(), table, key, expr).
 					Return(errors.New("dynamodb error"))
 			},
 			expectedError: errors.New("failed to update order status: dynamodb error"),
 		},
+		{
+			name:   "order not found",
+			ID:     1,
+			status: "COMPLETED",
+			mockSetup: func() {
+				IDAV, _ := attributevalue.Marshal("1")
+				key := map[string]types.AttributeValue{"PK": IDAV}
+
+				expr := updateOrderStatusExpr("COMPLETED", now, "")
+
+				gomock.InOrder(
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, key, expr).
+						Return(dynamodb.ErrConditionFailed),
+					mockDynamoDBClient.EXPECT().
+						GetItem(gomock.Any(), table, key).
+						Return(nil, nil),
+				)
+			},
+			expectedError: ErrOrderNotFound,
+		},
+		{
+			name:   "order saved before the status index",
+			ID:     1,
+			status: "COMPLETED",
+			mockSetup: func() {
+				IDAV, _ := attributevalue.Marshal("1")
+				key := map[string]types.AttributeValue{"PK": IDAV}
+				legacy, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusReady, CreatedAt: now.Add(-time.Hour)})
+
+				gomock.InOrder(
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, key, updateOrderStatusExpr("COMPLETED", now, "")).
+						Return(dynamodb.ErrConditionFailed),
+					mockDynamoDBClient.EXPECT().
+						GetItem(gomock.Any(), table, key).
+						Return(legacy, nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, key, updateOrderStatusExpr("COMPLETED", now, "2024-05-10T11:00:00.000000000Z#1")).
+						Return(nil),
+				)
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockSetup()
 
-			err := repo.UpdateOrderStatus(tt.ID, tt.status)
+			err := repo.UpdateOrderStatus(context.Background(), tt.ID, tt.status)
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 			} else {
@@ -206,3 +476,580 @@ func TestUpdateOrderStatus(t *testing.T) {
 		})
 	}
 }
+
+// updateOrderStatusExpr is the status update of order 1, setting the
+// status index sortKey when it is not empty.
+func updateOrderStatusExpr(status string, now time.Time, sortKey string) expression.Expression {
+	history := expression.ListAppend(
+		expression.IfNotExists(expression.Name("StatusHistory"), expression.Value([]models.OrderStatusChange{})),
+		expression.Value([]models.OrderStatusChange{{To: status, ChangedAt: now}}),
+	)
+	update := expression.Set(expression.Name("Status"), expression.Value(status)).
+		Set(expression.Name("StatusChangedAt"), expression.Value(now)).
+		Set(expression.Name("StatusHistory"), history).
+		Set(expression.Name("GSI2PK"), expression.Value(orderStatusPartition(status, "1", now)))
+	condition := expression.AttributeExists(expression.Name("GSI2SK"))
+	if sortKey != "" {
+		update = update.Set(expression.Name("GSI2SK"), expression.Value(sortKey))
+		condition = expression.AttributeExists(expression.Name("PK"))
+	}
+	expr, _ := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	return expr
+}
+
+// saveOrderItem is the order as written by SaveOrder, with its status
+// index keys.
+func saveOrderItem(order models.Order) map[string]types.AttributeValue {
+	item, _ := attributevalue.MarshalMap(order)
+	item["GSI2PK"] = &types.AttributeValueMemberS{Value: activeStatusPartition("NEW", orderStatusShard(order.ID))}
+	item["GSI2SK"] = &types.AttributeValueMemberS{Value: "0001-01-01T00:00:00.000000000Z#" + order.ID}
+	return item
+}
+
+func saveOrderExpr() expression.Expression {
+	condition := expression.AttributeNotExists(expression.Name("PK"))
+	expr, _ := expression.NewBuilder().WithCondition(condition).Build()
+	return expr
+}
+
+type fakeOrderExporter struct {
+	exports map[string][]models.Order
+	err     error
+}
+
+func (e *fakeOrderExporter) ExportOrders(ctx context.Context, name string, orders []models.Order) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.exports[name] = orders
+	return nil
+}
+
+func TestArchiveOrders(t *testing.T) {
+	table := "Kitchen"
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	completedBefore := now.Add(-24 * time.Hour)
+	retention := 30 * 24 * time.Hour
+	orders := []models.Order{
+		{ID: "1", Status: models.OrderStatusDelivered, Entity: "ORDER", StatusChangedAt: now.Add(-48 * time.Hour)},
+		{ID: "2", Status: models.OrderStatusCancelled, Entity: "ORDER", StatusChangedAt: now.Add(-36 * time.Hour)},
+		// saved before StatusChangedAt existed
+		{ID: "3", Status: models.OrderStatusDelivered, Entity: "ORDER", CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	var items []map[string]types.AttributeValue
+	for _, order := range orders {
+		item, _ := attributevalue.MarshalMap(order)
+		items = append(items, item)
+	}
+	delete(items[2], "StatusChangedAt")
+	lastKey, _ := attributevalue.MarshalMap(map[string]string{"PK": "3", "GSI2PK": "ORDER#DELIVERED#2024-05-08"})
+	deliveredName := "orders-20240510T120000Z-DELIVERED-20240508-0001.jsonl"
+	cancelledName := "orders-20240510T120000Z-CANCELLED-20240508-0001.jsonl"
+	// left pending by the archive of the day before
+	pendingName := "orders-20240509T120000Z-DELIVERED-20240507-0001.jsonl"
+	pendingItem, _ := attributevalue.MarshalMap(archivedOrderItem{Order: orders[0], ArchiveExport: pendingName})
+
+	noPending := func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) *gomock.Call {
+		return mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, pendingArchiveExpr(), "SecondaryIndex", int32(100), nil).Return(nil, nil, nil)
+	}
+	emptyPartition := func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient, partition string) *gomock.Call {
+		return mockDynamoDBClient.EXPECT().QueryItem(gomock.Any(), table, archiveOrdersExpr(partition, completedBefore), "StatusIndex", int32(100), nil).Return(nil, nil, nil)
+	}
+	exportKey := func(name string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "ARCHIVE_EXPORT#" + name}}
+	}
+	exported := func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient, name string, ids ...string) []any {
+		marker := exportKey(name)
+		marker["ExpiresAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(retention).Unix(), 10)}
+		calls := []any{
+			mockDynamoDBClient.EXPECT().GetItem(gomock.Any(), table, exportKey(name)).Return(nil, nil),
+			mockDynamoDBClient.EXPECT().PutItem(gomock.Any(), table, marker, expression.Expression{}).Return(nil),
+		}
+		for _, id := range ids {
+			calls = append(calls, mockDynamoDBClient.EXPECT().UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: id}}, confirmArchivedExpr()).Return(nil))
+		}
+		return calls
+	}
+
+	tests := []struct {
+		name             string
+		exportErr        error
+		mockSetup        func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient)
+		expectedArchived int
+		expectedExports  map[string][]models.Order
+		expectedError    error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				calls := []any{
+					noPending(mockDynamoDBClient),
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, archiveOrdersExpr("ORDER#DELIVERED#2024-05-08", completedBefore), "StatusIndex", int32(100), nil).
+						Return([]map[string]types.AttributeValue{items[0], items[2]}, lastKey, nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[0]["PK"]}, archiveOrderExpr(orders[0], now, retention, deliveredName)).
+						Return(nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[2]["PK"]}, archiveOrderExpr(orders[2], now, retention, deliveredName)).
+						Return(dynamodb.ErrConditionFailed),
+				}
+				calls = append(calls, exported(mockDynamoDBClient, deliveredName, "1")...)
+				calls = append(calls,
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, archiveOrdersExpr("ORDER#DELIVERED#2024-05-08", completedBefore), "StatusIndex", int32(100), lastKey).
+						Return(nil, nil, nil),
+					emptyPartition(mockDynamoDBClient, "ORDER#DELIVERED#2024-05-09"),
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, archiveOrdersExpr("ORDER#CANCELLED#2024-05-08", completedBefore), "StatusIndex", int32(100), nil).
+						Return([]map[string]types.AttributeValue{items[1]}, nil, nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[1]["PK"]}, archiveOrderExpr(orders[1], now, retention, cancelledName)).
+						Return(nil),
+				)
+				calls = append(calls, exported(mockDynamoDBClient, cancelledName, "2")...)
+				calls = append(calls, emptyPartition(mockDynamoDBClient, "ORDER#CANCELLED#2024-05-09"))
+				gomock.InOrder(calls...)
+			},
+			expectedArchived: 2,
+			expectedExports: map[string][]models.Order{
+				deliveredName: {orders[0]},
+				cancelledName: {orders[1]},
+			},
+		},
+		{
+			name: "pending export written",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				gomock.InOrder(
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, pendingArchiveExpr(), "SecondaryIndex", int32(100), nil).
+						Return([]map[string]types.AttributeValue{pendingItem}, nil, nil),
+					mockDynamoDBClient.EXPECT().
+						GetItem(gomock.Any(), table, exportKey(pendingName)).
+						Return(exportKey(pendingName), nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[0]["PK"]}, confirmArchivedExpr()).
+						Return(nil),
+				)
+				for _, partition := range []string{"ORDER#DELIVERED#2024-05-08", "ORDER#DELIVERED#2024-05-09", "ORDER#CANCELLED#2024-05-08", "ORDER#CANCELLED#2024-05-09"} {
+					emptyPartition(mockDynamoDBClient, partition)
+				}
+			},
+			expectedArchived: 1,
+			expectedExports:  map[string][]models.Order{},
+		},
+		{
+			name: "pending export not written",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				calls := []any{
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, pendingArchiveExpr(), "SecondaryIndex", int32(100), nil).
+						Return([]map[string]types.AttributeValue{pendingItem}, nil, nil),
+				}
+				calls = append(calls, exported(mockDynamoDBClient, pendingName, "1")...)
+				gomock.InOrder(calls...)
+				for _, partition := range []string{"ORDER#DELIVERED#2024-05-08", "ORDER#DELIVERED#2024-05-09", "ORDER#CANCELLED#2024-05-08", "ORDER#CANCELLED#2024-05-09"} {
+					emptyPartition(mockDynamoDBClient, partition)
+				}
+			},
+			expectedArchived: 1,
+			expectedExports:  map[string][]models.Order{pendingName: {orders[0]}},
+		},
+		{
+			name:      "export error",
+			exportErr: errors.New("disk full"),
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				gomock.InOrder(
+					noPending(mockDynamoDBClient),
+					mockDynamoDBClient.EXPECT().
+						QueryItem(gomock.Any(), table, archiveOrdersExpr("ORDER#DELIVERED#2024-05-08", completedBefore), "StatusIndex", int32(100), nil).
+						Return([]map[string]types.AttributeValue{items[0]}, nil, nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[0]["PK"]}, archiveOrderExpr(orders[0], now, retention, deliveredName)).
+						Return(nil),
+					mockDynamoDBClient.EXPECT().
+						GetItem(gomock.Any(), table, exportKey(deliveredName)).
+						Return(nil, nil),
+				)
+			},
+			expectedExports: map[string][]models.Order{},
+			expectedError:   errors.New("failed to export orders: disk full"),
+		},
+		{
+			name: "dynamodb error",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				mockDynamoDBClient.EXPECT().
+					QueryItem(gomock.Any(), table, pendingArchiveExpr(), "SecondaryIndex", int32(100), nil).
+					Return(nil, nil, errors.New("dynamodb error"))
+			},
+			expectedExports: map[string][]models.Order{},
+			expectedError:   errors.New("failed to get pending archived orders: dynamodb error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			exporter := &fakeOrderExporter{exports: map[string][]models.Order{}, err: tt.exportErr}
+			archiver := NewOrderArchiver(mockDynamoDBClient, table, exporter, 24*time.Hour, retention, time.Second)
+			archiver.(*dynamodbOrderArchiver).now = func() time.Time { return now }
+			tt.mockSetup(mockDynamoDBClient)
+
+			archived, err := archiver.ArchiveOrders(context.Background(), completedBefore)
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedArchived, archived)
+			assert.Equal(t, tt.expectedExports, exporter.exports)
+		})
+	}
+}
+
+func pendingArchiveExpr() expression.Expression {
+	expr, _ := expression.NewBuilder().WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value("ORDER_ARCHIVE_PENDING"))).Build()
+	return expr
+}
+
+func archiveOrdersExpr(partition string, completedBefore time.Time) expression.Expression {
+	keyExpr := expression.Key("GSI2PK").Equal(expression.Value(partition))
+	filter := expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(completedBeforeExpr(completedBefore))
+	expr, _ := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(filter).Build()
+	return expr
+}
+
+func archiveOrderExpr(order models.Order, now time.Time, retention time.Duration, name string) expression.Expression {
+	update := expression.Set(expression.Name("GSI1PK"), expression.Value("ORDER_ARCHIVE_PENDING")).
+		Set(expression.Name("ArchiveExport"), expression.Value(name)).
+		Set(expression.Name("ArchivedAt"), expression.Value(now)).
+		Set(expression.Name("ExpiresAt"), expression.Value(now.Add(retention).Unix()))
+	condition := expression.Name("GSI1PK").Equal(expression.Value("ORDER")).And(unchangedExpr(order))
+	expr, _ := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	return expr
+}
+
+func confirmArchivedExpr() expression.Expression {
+	update := expression.Set(expression.Name("GSI1PK"), expression.Value("ORDER_ARCHIVE")).
+		Remove(expression.Name("ArchiveExport"))
+	condition := expression.Name("GSI1PK").Equal(expression.Value("ORDER_ARCHIVE_PENDING"))
+	expr, _ := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	return expr
+}
+
+// newDynamoDBTestClient connects to DynamoDB Local, e.g.
+// DYNAMODB_TEST_ENDPOINT=http://localhost:8000 go test ./internal/infra/gateways/...
+func newDynamoDBTestClient(t *testing.T) *awsDynamoDb.Client {
+	endpoint := os.Getenv("DYNAMODB_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_TEST_ENDPOINT not set")
+	}
+
+	return awsDynamoDb.New(awsDynamoDb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+}
+
+// newDynamoDBTestTable creates an empty table with the production key
+// schema and indexes, documented in the README.
+func newDynamoDBTestTable(t *testing.T, client *awsDynamoDb.Client) (dynamodb.DynamoDBClient, string) {
+	table := fmt.Sprintf("Kitchen-%d", time.Now().UnixNano())
+	_, err := client.CreateTable(context.Background(), &awsDynamoDb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GSI1PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GSI2PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GSI2SK"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String("SecondaryIndex"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("GSI1PK"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}, {
+			IndexName: aws.String("StatusIndex"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("GSI2PK"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("GSI2SK"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table [%s]: %s", table, err.Error())
+	}
+	t.Cleanup(func() {
+		client.DeleteTable(context.Background(), &awsDynamoDb.DeleteTableInput{TableName: aws.String(table)})
+	})
+
+	return dynamodb.NewDynamoDBClient(client), table
+}
+
+func TestDynamoDBOrderRepositoryConformance(t *testing.T) {
+	client := newDynamoDBTestClient(t)
+
+	testOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		client, table := newDynamoDBTestTable(t, client)
+		return NewOrderRepository(client, table, 5*time.Second)
+	})
+}
+
+func TestDynamoDBWebhookRepositoryConformance(t *testing.T) {
+	client := newDynamoDBTestClient(t)
+
+	testWebhookRepositoryConformance(t, func(t *testing.T) WebhookRepository {
+		client, table := newDynamoDBTestTable(t, client)
+		return NewWebhookRepository(client, table, 5*time.Second, time.Hour)
+	})
+}
+
+func TestDynamoDBOrderArchiver(t *testing.T) {
+	client, table := newDynamoDBTestTable(t, newDynamoDBTestClient(t))
+	repo := NewOrderRepository(client, table, 5*time.Second)
+	exporter := &fakeOrderExporter{exports: map[string][]models.Order{}}
+	archiver := NewOrderArchiver(client, table, exporter, 30*24*time.Hour, time.Hour, 5*time.Second)
+	ctx := context.Background()
+
+	for id, status := range []string{models.OrderStatusReady, models.OrderStatusDelivered, models.OrderStatusCancelled} {
+		err := repo.SaveOrder(ctx, models.Order{ID: strconv.Itoa(id + 1), Status: status, Entity: "ORDER"})
+		assert.NoError(t, err)
+	}
+
+	// saved before StatusChangedAt existed, one finished and one only created
+	// two days ago, and one finished within the hour
+	twoDaysAgo := time.Now().Add(-48 * time.Hour)
+	for id, legacy := range []models.Order{
+		{ID: "4", Status: models.OrderStatusDelivered, Entity: "ORDER", CreatedAt: twoDaysAgo, FinishedAt: twoDaysAgo},
+		{ID: "5", Status: models.OrderStatusCancelled, Entity: "ORDER", CreatedAt: twoDaysAgo},
+		{ID: "6", Status: models.OrderStatusDelivered, Entity: "ORDER", CreatedAt: twoDaysAgo, FinishedAt: time.Now()},
+	} {
+		item, _ := attributevalue.MarshalMap(legacy)
+		delete(item, "StatusChangedAt")
+		if id == 1 {
+			delete(item, "FinishedAt")
+		}
+		// the keys set by the status_index_keys migration
+		item["GSI2PK"] = &types.AttributeValueMemberS{Value: orderStatusPartition(legacy.Status, legacy.ID, legacy.CreatedAt)}
+		item["GSI2SK"] = &types.AttributeValueMemberS{Value: orderStatusSortKey(legacy)}
+		assert.NoError(t, client.PutItem(ctx, table, item, expression.Expression{}))
+	}
+
+	archived, err := archiver.ArchiveOrders(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, archived, "only the legacy orders completed two days ago are archived")
+
+	archived, err = archiver.ArchiveOrders(ctx, time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, archived)
+	assert.NotEmpty(t, exporter.exports)
+
+	page, err := repo.GetOrders(ctx, models.OrderFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Results, 1)
+	assert.Equal(t, "1", page.Results[0].ID)
+
+	order, err := repo.GetOrder(ctx, 2)
+	assert.NoError(t, err, "archived orders can still be read until they expire")
+	assert.Equal(t, "ORDER_ARCHIVE", order.Entity)
+
+	exports := len(exporter.exports)
+	archived, err = archiver.ArchiveOrders(ctx, time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	assert.Len(t, exporter.exports, exports, "nothing is exported again")
+}
+
+func TestDynamoDBUpdateOrderStatus_LegacyOrder(t *testing.T) {
+	client, table := newDynamoDBTestTable(t, newDynamoDBTestClient(t))
+	repo := NewOrderRepository(client, table, 5*time.Second)
+	ctx := context.Background()
+
+	// saved before the status index
+	legacy, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusReceived, Entity: "ORDER", CreatedAt: time.Now()})
+	assert.NoError(t, client.PutItem(ctx, table, legacy, expression.Expression{}))
+
+	assert.NoError(t, repo.UpdateOrderStatus(ctx, 1, models.OrderStatusReady))
+	assert.Equal(t, []string{"1"}, collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusReady}}), "the update sets the sort key of the status index")
+	assert.ErrorIs(t, repo.UpdateOrderStatus(ctx, 2, models.OrderStatusReady), ErrOrderNotFound)
+}
+
+func TestDynamoDBOrderMigrations(t *testing.T) {
+	client, table := newDynamoDBTestTable(t, newDynamoDBTestClient(t))
+	repo := NewOrderRepository(client, table, 5*time.Second)
+	ctx := context.Background()
+
+	// saved as before the status index and the history
+	for i := 1; i <= 5; i++ {
+		legacy, _ := attributevalue.MarshalMap(models.Order{ID: strconv.Itoa(i), Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: time.Now()})
+		assert.NoError(t, client.PutItem(ctx, table, legacy, expression.Expression{}))
+	}
+	assert.NoError(t, repo.SaveOrder(ctx, models.Order{ID: "6", Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: time.Now()}))
+	// saved in the single partition of the status, before it was sharded
+	for _, id := range []string{"7", "8"} {
+		unsharded, _ := attributevalue.MarshalMap(models.Order{ID: id, Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: time.Now(), StatusChangedAt: time.Now(),
+			History: []models.OrderStatusChange{{To: models.OrderStatusReady, ChangedAt: time.Now()}}})
+		unsharded["GSI2PK"] = &types.AttributeValueMemberS{Value: "ORDER#READY"}
+		unsharded["GSI2SK"] = &types.AttributeValueMemberS{Value: orderStatusSortKey(models.Order{ID: id})}
+		assert.NoError(t, client.PutItem(ctx, table, unsharded, expression.Expression{}))
+	}
+	// archived while the archive removed the status index keys
+	deliveredAt := time.Now().UTC()
+	archived, _ := attributevalue.MarshalMap(models.Order{ID: "9", Status: models.OrderStatusDelivered, Entity: archivedOrderEntity, CreatedAt: deliveredAt, StatusChangedAt: deliveredAt,
+		History: []models.OrderStatusChange{{To: models.OrderStatusDelivered, ChangedAt: deliveredAt}}})
+	assert.NoError(t, client.PutItem(ctx, table, archived, expression.Expression{}))
+	// logged in the GSI1PK partition of its subscription, without a TTL
+	delivery, _ := attributevalue.MarshalMap(newConformanceDelivery("d-1", "wh-1", 7, 0))
+	delivery["PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-1"}
+	delivery["GSI1PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#wh-1"}
+	assert.NoError(t, client.PutItem(ctx, table, delivery, expression.Expression{}))
+	pendingDelivery := newConformanceDelivery("d-2", "wh-1", 7, 1)
+	pendingDelivery.Status = models.WebhookDeliveryPending
+	pending, _ := attributevalue.MarshalMap(pendingDelivery)
+	pending["PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-2"}
+	pending["GSI1PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#wh-1"}
+	assert.NoError(t, client.PutItem(ctx, table, pending, expression.Expression{}))
+
+	results, err := dynamodb.Migrate(ctx, client, table, OrderMigrations, dynamodb.MigrateOptions{Segments: 2, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, results[0].Updated)
+	assert.Equal(t, dynamodb.MigrationStatusPending, results[0].Status)
+
+	results, err = dynamodb.Migrate(ctx, client, table, OrderMigrations, dynamodb.MigrateOptions{Segments: 2})
+	assert.NoError(t, err)
+	for i, updated := range []int{5, 5, 2, 1, 2} {
+		assert.Equal(t, dynamodb.MigrationStatusMigrated, results[i].Status)
+		assert.Equal(t, updated, results[i].Updated)
+	}
+
+	ids := collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusReady}})
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4", "5", "6", "7", "8"}, ids)
+	order, err := repo.GetOrder(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, order.History, 1)
+	ids = collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusDelivered}, ChangedFrom: deliveredAt.Add(-time.Minute), ChangedTo: deliveredAt.Add(time.Minute)})
+	assert.Equal(t, []string{"9"}, ids, "archived orders are back in the day partition of their status")
+	webhookRepo := NewWebhookRepository(client, table, 5*time.Second, time.Hour)
+	deliveries, err := webhookRepo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d-2", "d-1"}, deliveryIDs(deliveries))
+	deliveries, err = webhookRepo.GetPendingDeliveries(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d-2"}, deliveryIDs(deliveries), "pending deliveries are still resumed")
+
+	results, err = dynamodb.Migrate(ctx, client, table, OrderMigrations, dynamodb.MigrateOptions{Segments: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, dynamodb.MigrationStatusApplied, results[0].Status, "applied migrations are not run again")
+}
+
+func TestBackfillOrderStatusKeys(t *testing.T) {
+	table := "Kitchen"
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		{ID: "1", Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: createdAt},
+		{ID: "2", Status: models.OrderStatusDelivered, Entity: "ORDER", CreatedAt: createdAt, StatusChangedAt: createdAt.Add(24 * time.Hour)},
+	}
+	var items []map[string]types.AttributeValue
+	var updateExprs []expression.Expression
+	for _, order := range orders {
+		item, _ := attributevalue.MarshalMap(order)
+		items = append(items, item)
+		change, _, _ := migrateStatusIndexKeys(item)
+		updateExprs = append(updateExprs, buildItemChange(t, change))
+	}
+	filter := expression.Name("GSI1PK").Equal(expression.Value("ORDER")).
+		And(expression.AttributeNotExists(expression.Name("GSI2SK")))
+	scanExpr, _ := expression.NewBuilder().WithFilter(filter).Build()
+
+	tests := []struct {
+		name             string
+		dryRun           bool
+		mockSetup        func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient)
+		expectedBackfill StatusKeysBackfill
+		expectedError    error
+	}{
+		{
+			name: "success",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				gomock.InOrder(
+					mockDynamoDBClient.EXPECT().
+						ScanItem(gomock.Any(), table, scanExpr, int32(0), int32(1), int32(100), nil).
+						Return(items, nil, nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[0]["PK"]}, updateExprs[0]).
+						Return(nil),
+					mockDynamoDBClient.EXPECT().
+						UpdateItem(gomock.Any(), table, map[string]types.AttributeValue{"PK": items[1]["PK"]}, updateExprs[1]).
+						Return(dynamodb.ErrConditionFailed),
+				)
+			},
+			expectedBackfill: StatusKeysBackfill{Missing: 2, Updated: 1, Skipped: 1},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				mockDynamoDBClient.EXPECT().
+					ScanItem(gomock.Any(), table, scanExpr, int32(0), int32(1), int32(100), nil).
+					Return(items, nil, nil)
+			},
+			expectedBackfill: StatusKeysBackfill{Missing: 2},
+		},
+		{
+			name: "dynamodb error",
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				mockDynamoDBClient.EXPECT().
+					ScanItem(gomock.Any(), table, scanExpr, int32(0), int32(1), int32(100), nil).
+					Return(nil, nil, errors.New("dynamodb error"))
+			},
+			expectedError: errors.New("failed to backfill status keys: failed to apply migration [1 status_index_keys]: segment [0]: failed to scan items: dynamodb error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			tt.mockSetup(mockDynamoDBClient)
+
+			backfill, err := BackfillOrderStatusKeys(context.Background(), mockDynamoDBClient, table, tt.dryRun)
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedBackfill, backfill)
+		})
+	}
+}
+
+func TestDynamoDBBackfillOrderStatusKeys(t *testing.T) {
+	client, table := newDynamoDBTestTable(t, newDynamoDBTestClient(t))
+	repo := NewOrderRepository(client, table, 5*time.Second)
+	ctx := context.Background()
+
+	// saved as before the status index, without its keys
+	legacy, _ := attributevalue.MarshalMap(models.Order{ID: "1", Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: time.Now()})
+	assert.NoError(t, client.PutItem(ctx, table, legacy, expression.Expression{}))
+	assert.NoError(t, repo.SaveOrder(ctx, models.Order{ID: "2", Status: models.OrderStatusReady, Entity: "ORDER", CreatedAt: time.Now()}))
+
+	ids := collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusReady}})
+	assert.Equal(t, []string{"2"}, ids)
+
+	backfill, err := BackfillOrderStatusKeys(ctx, client, table, false)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusKeysBackfill{Missing: 1, Updated: 1}, backfill)
+
+	ids = collectOrderIDs(t, repo, models.OrderFilter{Statuses: []string{models.OrderStatusReady}})
+	assert.ElementsMatch(t, []string{"1", "2"}, ids)
+
+	backfill, err = BackfillOrderStatusKeys(ctx, client, table, false)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusKeysBackfill{}, backfill, "running again changes nothing")
+}
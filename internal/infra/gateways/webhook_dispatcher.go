@@ -0,0 +1,375 @@
+package gateways
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	WebhookEventType = "order.status_changed"
+
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	maxWebhookRetryBackoff = time.Minute
+	// webhookDeliveryLease is renewed before every attempt, so it outlasts
+	// the longest backoff plus an attempt.
+	webhookDeliveryLease = 2 * time.Minute
+)
+
+var errDispatcherClosed = errors.New("webhook dispatcher closed")
+
+// WebhookPayload is the body POSTed to the subscribers. ID is the delivery
+// id, also sent in the X-Webhook-Id header, so receivers can deduplicate.
+type WebhookPayload struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OrderID    int       `json:"orderId"`
+	Status     string    `json:"status"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// WebhookDispatcher delivers order status changes to the webhook
+// subscriptions accepting them.
+type WebhookDispatcher interface {
+	// Dispatch records a pending delivery for every matching subscription
+	// and sends them in the background, retrying with backoff. A
+	// subscription failing does not skip the others.
+	Dispatch(ctx context.Context, orderId int, status string) error
+	// Resume sends the pending deliveries once their lease expires, which
+	// is at once for those released by Close. Every attempt first claims
+	// the delivery, so the dispatchers sharing the store do not send it
+	// together; receivers still deduplicate by X-Webhook-Id.
+	Resume(ctx context.Context) error
+	// Close stops the pending retries and waits for the attempts in flight.
+	// The deliveries stopped stay pending, for Resume.
+	Close()
+}
+
+type webhookDispatcher struct {
+	owner        string
+	repository   WebhookRepository
+	httpClient   httpDriver.HttpClient
+	maxAttempts  int
+	retryBackoff time.Duration
+	logger       logger.Logger
+
+	mu         sync.Mutex
+	closed     bool
+	closing    chan struct{}
+	deliveries sync.WaitGroup
+}
+
+func NewWebhookDispatcher(repository WebhookRepository, httpClient httpDriver.HttpClient, maxAttempts int, retryBackoff time.Duration, logger logger.Logger) WebhookDispatcher {
+	return &webhookDispatcher{
+		owner:        uuid.NewString(),
+		repository:   repository,
+		httpClient:   httpClient,
+		maxAttempts:  maxAttempts,
+		retryBackoff: retryBackoff,
+		logger:       logger,
+		closing:      make(chan struct{}),
+	}
+}
+
+func (d *webhookDispatcher) Dispatch(ctx context.Context, orderId int, status string) (err error) {
+	ctx, span := tracer.Start(ctx, "webhookDispatcher.Dispatch")
+	span.SetAttributes(attribute.Int("order.id", orderId), attribute.String("order.status", status))
+	defer tracing.EndSpan(span, &err)
+
+	subscriptions, err := d.repository.GetSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var errs []error
+	for _, subscription := range subscriptions {
+		if !subscription.Accepts(status) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			ID:             uuid.NewString(),
+			SubscriptionID: subscription.ID,
+			OrderID:        orderId,
+			Event:          status,
+			Status:         models.WebhookDeliveryPending,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			Owner:          d.owner,
+			LeaseUntil:     now.Add(webhookDeliveryLease),
+		}
+		err = d.repository.SaveDelivery(ctx, delivery)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to save webhook delivery for subscription [%s]: %w", subscription.ID, err))
+			continue
+		}
+
+		err = d.start(span.SpanContext(), subscription, delivery)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *webhookDispatcher) Resume(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "webhookDispatcher.Resume")
+	defer tracing.EndSpan(span, &err)
+
+	deliveries, err := d.repository.GetPendingDeliveries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get pending webhook deliveries: %w", err)
+	}
+	span.SetAttributes(attribute.Int("webhook.deliveries", len(deliveries)))
+
+	subscriptions := map[string]models.WebhookSubscription{}
+	var errs []error
+	for _, delivery := range deliveries {
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = d.repository.GetSubscription(ctx, delivery.SubscriptionID)
+			if errors.Is(err, ErrWebhookNotFound) {
+				d.failOrphanDelivery(ctx, delivery)
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get webhook subscription [%s]: %w", delivery.SubscriptionID, err))
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		err = d.start(span.SpanContext(), subscription, delivery)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// failOrphanDelivery fails the delivery of a deleted subscription, unless
+// another dispatcher holds it.
+func (d *webhookDispatcher) failOrphanDelivery(ctx context.Context, delivery models.WebhookDelivery) {
+	err := d.claim(ctx, &delivery)
+	if err != nil {
+		if !errors.Is(err, ErrWebhookDeliveryLeased) {
+			d.logger.WithContext(ctx).WithError(err).Errorf("failed to claim webhook delivery [%s]", delivery.ID)
+		}
+		return
+	}
+
+	delivery.Status = models.WebhookDeliveryFailed
+	delivery.LastError = ErrWebhookNotFound.Error()
+	delivery.UpdatedAt = time.Now().UTC()
+	d.saveDelivery(ctx, delivery)
+}
+
+// start sends the delivery in the background, unless the dispatcher is
+// closed.
+func (d *webhookDispatcher) start(spanContext trace.SpanContext, subscription models.WebhookSubscription, delivery models.WebhookDelivery) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errDispatcherClosed
+	}
+	d.deliveries.Add(1)
+	d.mu.Unlock()
+
+	// the delivery outlives the call that started it
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+	ctx = logger.ContextWithOrderID(ctx, strconv.Itoa(delivery.OrderID))
+	go func() {
+		defer d.deliveries.Done()
+		d.deliver(ctx, subscription, delivery)
+	}()
+
+	return nil
+}
+
+func (d *webhookDispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.closing)
+	}
+	d.mu.Unlock()
+
+	d.deliveries.Wait()
+}
+
+// deliver attempts the delivery until it succeeds, fails permanently,
+// runs out of attempts or the dispatcher closes, saving the outcome of
+// every attempt.
+func (d *webhookDispatcher) deliver(ctx context.Context, subscription models.WebhookSubscription, delivery models.WebhookDelivery) {
+	ctx, span := tracer.Start(ctx, "webhookDispatcher.deliver")
+	span.SetAttributes(attribute.String("webhook.id", subscription.ID), attribute.String("webhook.delivery.id", delivery.ID))
+	var err error
+	defer tracing.EndSpan(span, &err)
+
+	body, err := json.Marshal(WebhookPayload{
+		ID:         delivery.ID,
+		Type:       WebhookEventType,
+		OrderID:    delivery.OrderID,
+		Status:     delivery.Event,
+		OccurredAt: delivery.CreatedAt,
+	})
+	if err != nil {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = fmt.Sprintf("failed to marshal webhook payload: %v", err)
+		d.saveDelivery(ctx, delivery)
+		return
+	}
+
+	// another dispatcher holds the delivery; it is taken over only if that
+	// one stops renewing the lease
+	if wait := time.Until(delivery.LeaseUntil); delivery.Owner != d.owner && wait > 0 {
+		select {
+		case <-d.closing:
+			return
+		case <-time.After(wait):
+		}
+	}
+
+	backoff := d.retryBackoff
+	for {
+		err = d.claim(ctx, &delivery)
+		if errors.Is(err, ErrWebhookDeliveryLeased) {
+			d.logger.WithContext(ctx).Infof("webhook delivery [%s] is sent by another dispatcher", delivery.ID)
+			return
+		}
+		if err != nil {
+			d.logger.WithContext(ctx).WithError(err).Errorf("failed to claim webhook delivery [%s], stopping it until a dispatcher resumes it", delivery.ID)
+			return
+		}
+
+		var statusCode int
+		statusCode, err = d.send(ctx, subscription, delivery.ID, body)
+		metrics.IncWebhookAttempt(delivery.Event, err)
+
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = ""
+		delivery.UpdatedAt = time.Now().UTC()
+		if err == nil {
+			delivery.Status = models.WebhookDeliverySucceeded
+			d.saveDelivery(ctx, delivery)
+			return
+		}
+
+		delivery.LastError = err.Error()
+		if !isRetryableWebhookStatus(statusCode) || errors.Is(err, httpDriver.ErrAddressNotAllowed) || delivery.Attempts >= d.maxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+			d.saveDelivery(ctx, delivery)
+			d.logger.WithContext(ctx).WithError(err).Warnf("webhook delivery [%s] to subscription [%s] failed after [%d] attempts", delivery.ID, subscription.ID, delivery.Attempts)
+			return
+		}
+		d.saveDelivery(ctx, delivery)
+
+		select {
+		case <-d.closing:
+			err = errDispatcherClosed
+			// released so the next start resumes it at once
+			delivery.Owner = ""
+			delivery.LeaseUntil = time.Time{}
+			d.saveDelivery(ctx, delivery)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxWebhookRetryBackoff {
+			backoff = maxWebhookRetryBackoff
+		}
+	}
+}
+
+// claim leases the delivery to the dispatcher for one more attempt.
+func (d *webhookDispatcher) claim(ctx context.Context, delivery *models.WebhookDelivery) error {
+	leaseUntil := time.Now().UTC().Add(webhookDeliveryLease)
+	err := d.repository.ClaimDelivery(ctx, delivery.ID, d.owner, leaseUntil)
+	if err != nil {
+		return err
+	}
+
+	delivery.Owner = d.owner
+	delivery.LeaseUntil = leaseUntil
+	return nil
+}
+
+func (d *webhookDispatcher) send(ctx context.Context, subscription models.WebhookSubscription, deliveryID string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"Content-Type":         "application/json",
+		WebhookIDHeader:        deliveryID,
+		WebhookEventHeader:     WebhookEventType,
+		WebhookTimestampHeader: timestamp,
+		WebhookSignatureHeader: SignWebhookPayload(subscription.Secret, timestamp, body),
+	}
+
+	// the response body is ignored, so only its status matters when it is
+	// over the client limit
+	response, err := d.httpClient.DoPost(ctx, subscription.URL, body, headers)
+	if err != nil && !errors.Is(err, httpDriver.ErrResponseTooLarge) {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status code [%d]", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+func (d *webhookDispatcher) saveDelivery(ctx context.Context, delivery models.WebhookDelivery) {
+	err := d.repository.SaveDelivery(ctx, delivery)
+	if err != nil {
+		d.logger.WithContext(ctx).WithError(err).Errorf("failed to save webhook delivery [%s]", delivery.ID)
+	}
+}
+
+// isRetryableWebhookStatus reports whether a failed attempt may succeed
+// later: network errors, which have no status code, server errors, timeouts
+// and throttling. Other client errors are permanent.
+func isRetryableWebhookStatus(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests
+}
+
+// SignWebhookPayload returns the X-Webhook-Signature of body: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed by the subscription secret.
+// Receivers recompute it and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,283 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	httpDriver "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/http"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver is a partner endpoint answering with the next status of
+// statuses, repeating the last one.
+type webhookReceiver struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		status := receiver.statuses[0]
+		if len(receiver.statuses) > 1 {
+			receiver.statuses = receiver.statuses[1:]
+		}
+		receiver.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestDispatcher(t *testing.T, subscriptions ...models.WebhookSubscription) (WebhookDispatcher, WebhookRepository) {
+	repository := NewInMemoryWebhookRepository()
+	for _, subscription := range subscriptions {
+		require.NoError(t, repository.SaveSubscription(context.Background(), subscription))
+	}
+
+	dispatcher := NewWebhookDispatcher(repository, httpDriver.NewHttpClient(httpDriver.Options{Timeout: time.Second}), 3, time.Millisecond, logger.NewNopLogger())
+	t.Cleanup(dispatcher.Close)
+	return dispatcher, repository
+}
+
+func waitForDelivery(t *testing.T, repository WebhookRepository, subscriptionID string, status string) models.WebhookDelivery {
+	var delivery models.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries, err := repository.GetDeliveries(context.Background(), models.WebhookDeliveryFilter{SubscriptionID: subscriptionID})
+		if err != nil || len(deliveries) != 1 {
+			return false
+		}
+		delivery = deliveries[0]
+		return delivery.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return delivery
+}
+
+func TestWebhookDispatcher_DeliversSignedPayload(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	ready := models.WebhookSubscription{ID: "ready", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}, Secret: "ready-secret"}
+	delivered := models.WebhookSubscription{ID: "delivered", URL: receiver.server.URL, Events: []string{models.OrderStatusDelivered}, Secret: "other-secret"}
+	dispatcher, repository := newTestDispatcher(t, ready, delivered)
+
+	err := dispatcher.Dispatch(context.Background(), 7, models.OrderStatusReady)
+	require.NoError(t, err)
+
+	delivery := waitForDelivery(t, repository, "ready", models.WebhookDeliverySucceeded)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+	assert.Equal(t, 7, delivery.OrderID)
+	assert.Equal(t, models.OrderStatusReady, delivery.Event)
+
+	require.Equal(t, 1, receiver.received(), "only the subscriptions of the event are called")
+	request, body := receiver.requests[0], receiver.bodies[0]
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, delivery.ID, request.Header.Get(WebhookIDHeader))
+	assert.Equal(t, WebhookEventType, request.Header.Get(WebhookEventHeader))
+	expectedSignature := SignWebhookPayload("ready-secret", request.Header.Get(WebhookTimestampHeader), body)
+	assert.Equal(t, expectedSignature, request.Header.Get(WebhookSignatureHeader))
+
+	payload := WebhookPayload{}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, delivery.ID, payload.ID)
+	assert.Equal(t, 7, payload.OrderID)
+	assert.Equal(t, models.OrderStatusReady, payload.Status)
+
+	deliveries, err := repository.GetDeliveries(context.Background(), models.WebhookDeliveryFilter{SubscriptionID: "delivered"})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestWebhookDispatcher_Retries(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		expectedStatus   string
+		expectedAttempts int
+		expectedCode     int
+	}{
+		{
+			name:             "server errors are retried",
+			statuses:         []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusNoContent},
+			expectedStatus:   models.WebhookDeliverySucceeded,
+			expectedAttempts: 3,
+			expectedCode:     http.StatusNoContent,
+		},
+		{
+			name:             "gives up after the max attempts",
+			statuses:         []int{http.StatusServiceUnavailable},
+			expectedStatus:   models.WebhookDeliveryFailed,
+			expectedAttempts: 3,
+			expectedCode:     http.StatusServiceUnavailable,
+		},
+		{
+			name:             "client errors are permanent",
+			statuses:         []int{http.StatusGone},
+			expectedStatus:   models.WebhookDeliveryFailed,
+			expectedAttempts: 1,
+			expectedCode:     http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, tt.statuses...)
+			subscription := models.WebhookSubscription{ID: "wh-1", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}, Secret: "secret"}
+			dispatcher, repository := newTestDispatcher(t, subscription)
+
+			require.NoError(t, dispatcher.Dispatch(context.Background(), 7, models.OrderStatusReady))
+
+			delivery := waitForDelivery(t, repository, "wh-1", tt.expectedStatus)
+			assert.Equal(t, tt.expectedAttempts, delivery.Attempts)
+			assert.Equal(t, tt.expectedCode, delivery.LastStatusCode)
+			assert.Equal(t, tt.expectedAttempts, receiver.received())
+		})
+	}
+}
+
+// failingWebhookRepository fails to save the deliveries of one subscription.
+type failingWebhookRepository struct {
+	WebhookRepository
+	subscriptionID string
+}
+
+func (r failingWebhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	if delivery.SubscriptionID == r.subscriptionID {
+		return errors.New("store unavailable")
+	}
+	return r.WebhookRepository.SaveDelivery(ctx, delivery)
+}
+
+func TestWebhookDispatcher_DispatchContinuesAfterAFailure(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	repository := NewInMemoryWebhookRepository()
+	for _, id := range []string{"wh-1", "wh-2"} {
+		require.NoError(t, repository.SaveSubscription(context.Background(), models.WebhookSubscription{ID: id, URL: receiver.server.URL, Events: []string{models.OrderStatusReady}}))
+	}
+	dispatcher := NewWebhookDispatcher(failingWebhookRepository{WebhookRepository: repository, subscriptionID: "wh-1"}, httpDriver.NewHttpClient(httpDriver.Options{Timeout: time.Second}), 3, time.Millisecond, logger.NewNopLogger())
+	t.Cleanup(dispatcher.Close)
+
+	err := dispatcher.Dispatch(context.Background(), 7, models.OrderStatusReady)
+	assert.ErrorContains(t, err, "subscription [wh-1]: store unavailable")
+
+	waitForDelivery(t, repository, "wh-2", models.WebhookDeliverySucceeded)
+	assert.Equal(t, 1, receiver.received())
+}
+
+func TestWebhookDispatcher_PrivateAddressesArePermanent(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	repository := NewInMemoryWebhookRepository()
+	require.NoError(t, repository.SaveSubscription(context.Background(), models.WebhookSubscription{ID: "wh-1", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}}))
+	dispatcher := NewWebhookDispatcher(repository, httpDriver.NewHttpClient(httpDriver.Options{Timeout: time.Second, DenyPrivateAddresses: true}), 3, time.Millisecond, logger.NewNopLogger())
+	t.Cleanup(dispatcher.Close)
+
+	require.NoError(t, dispatcher.Dispatch(context.Background(), 7, models.OrderStatusReady))
+
+	delivery := waitForDelivery(t, repository, "wh-1", models.WebhookDeliveryFailed)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.LastError, httpDriver.ErrAddressNotAllowed.Error())
+	assert.Zero(t, receiver.received())
+}
+
+func TestWebhookDispatcher_CloseStopsRetries(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	repository := NewInMemoryWebhookRepository()
+	require.NoError(t, repository.SaveSubscription(context.Background(), models.WebhookSubscription{ID: "wh-1", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}}))
+	dispatcher := NewWebhookDispatcher(repository, httpDriver.NewHttpClient(httpDriver.Options{Timeout: time.Second}), 5, time.Hour, logger.NewNopLogger())
+
+	require.NoError(t, dispatcher.Dispatch(context.Background(), 7, models.OrderStatusReady))
+	require.Eventually(t, func() bool { return receiver.received() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	var closed atomic.Bool
+	go func() {
+		dispatcher.Close()
+		closed.Store(true)
+	}()
+	assert.Eventually(t, closed.Load, 5*time.Second, 5*time.Millisecond, "close must not wait for the backoff")
+
+	delivery := waitForDelivery(t, repository, "wh-1", models.WebhookDeliveryPending)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, 503, delivery.LastStatusCode)
+	assert.Empty(t, delivery.Owner, "the lease is released for the next start")
+	assert.True(t, delivery.LeaseUntil.IsZero())
+
+	assert.ErrorIs(t, dispatcher.Dispatch(context.Background(), 8, models.OrderStatusReady), errDispatcherClosed)
+	assert.ErrorIs(t, dispatcher.Resume(context.Background()), errDispatcherClosed)
+}
+
+func TestWebhookDispatcher_Resume(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	subscription := models.WebhookSubscription{ID: "wh-1", URL: receiver.server.URL, Secret: "s3cr3t", Events: []string{models.OrderStatusReady}}
+	dispatcher, repository := newTestDispatcher(t, subscription)
+
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pending := models.WebhookDelivery{ID: "d-1", SubscriptionID: "wh-1", OrderID: 7, Event: models.OrderStatusReady, Status: models.WebhookDeliveryPending, Attempts: 1, LastStatusCode: 503, CreatedAt: createdAt, UpdatedAt: createdAt}
+	orphan := models.WebhookDelivery{ID: "d-2", SubscriptionID: "wh-2", OrderID: 7, Event: models.OrderStatusReady, Status: models.WebhookDeliveryPending, CreatedAt: createdAt, UpdatedAt: createdAt}
+	require.NoError(t, repository.SaveDelivery(context.Background(), pending))
+	require.NoError(t, repository.SaveDelivery(context.Background(), orphan))
+
+	require.NoError(t, dispatcher.Resume(context.Background()))
+
+	delivery := waitForDelivery(t, repository, "wh-1", models.WebhookDeliverySucceeded)
+	assert.Equal(t, 2, delivery.Attempts, "the attempts of the previous run count")
+	assert.Equal(t, 1, receiver.received())
+	assert.Equal(t, "d-1", receiver.requests[0].Header.Get(WebhookIDHeader))
+
+	payload := WebhookPayload{}
+	require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+	assert.Equal(t, WebhookPayload{ID: "d-1", Type: WebhookEventType, OrderID: 7, Status: models.OrderStatusReady, OccurredAt: createdAt}, payload)
+
+	delivery = waitForDelivery(t, repository, "wh-2", models.WebhookDeliveryFailed)
+	assert.Equal(t, ErrWebhookNotFound.Error(), delivery.LastError, "deliveries of deleted subscriptions are not resumed")
+}
+
+func TestWebhookDispatcher_ResumeWaitsForTheLease(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	subscription := models.WebhookSubscription{ID: "wh-1", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}}
+	held := models.WebhookSubscription{ID: "wh-2", URL: receiver.server.URL, Events: []string{models.OrderStatusReady}}
+	dispatcher, repository := newTestDispatcher(t, subscription, held)
+
+	now := time.Now().UTC()
+	expiring := models.WebhookDelivery{ID: "d-1", SubscriptionID: "wh-1", OrderID: 7, Event: models.OrderStatusReady, Status: models.WebhookDeliveryPending,
+		CreatedAt: now, UpdatedAt: now, Owner: "crashed", LeaseUntil: now.Add(100 * time.Millisecond)}
+	leased := models.WebhookDelivery{ID: "d-2", SubscriptionID: "wh-2", OrderID: 7, Event: models.OrderStatusReady, Status: models.WebhookDeliveryPending,
+		CreatedAt: now, UpdatedAt: now, Owner: "other", LeaseUntil: now.Add(time.Hour)}
+	require.NoError(t, repository.SaveDelivery(context.Background(), expiring))
+	require.NoError(t, repository.SaveDelivery(context.Background(), leased))
+
+	require.NoError(t, dispatcher.Resume(context.Background()))
+	assert.Zero(t, receiver.received(), "nothing is sent while the leases hold")
+
+	delivery := waitForDelivery(t, repository, "wh-1", models.WebhookDeliverySucceeded)
+	assert.NotEqual(t, "crashed", delivery.Owner, "the expired lease is taken over")
+	assert.Equal(t, 1, receiver.received())
+
+	delivery = waitForDelivery(t, repository, "wh-2", models.WebhookDeliveryPending)
+	assert.Equal(t, "other", delivery.Owner, "the live dispatcher keeps its delivery")
+}
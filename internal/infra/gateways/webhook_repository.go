@@ -0,0 +1,447 @@
+package gateways
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WebhookRepository stores the webhook subscriptions and their delivery
+// log next to the orders.
+type WebhookRepository interface {
+	SaveSubscription(ctx context.Context, subscription models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (models.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	// SaveDelivery inserts or replaces the delivery with the same ID.
+	SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	// ClaimDelivery leases the pending delivery to owner until leaseUntil,
+	// when its lease expired or owner already holds it. Otherwise it returns
+	// ErrWebhookDeliveryLeased.
+	ClaimDelivery(ctx context.Context, id string, owner string, leaseUntil time.Time) error
+	// GetDeliveries lists the deliveries of filter.SubscriptionID, newest first.
+	GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	// GetPendingDeliveries lists the pending deliveries of every
+	// subscription, oldest first.
+	GetPendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error)
+}
+
+const (
+	webhookEntity         = "WEBHOOK"
+	webhookDeliveryEntity = "WEBHOOK_DELIVERY"
+
+	webhookPendingDeliveryEntity = "WEBHOOK_DELIVERY_PENDING"
+)
+
+// webhookItem and webhookDeliveryItem share the order table, with their
+// own key prefixes. Subscriptions are listed from their GSI1PK partition,
+// deliveries from the StatusIndex partition of their subscription, sorted
+// by creation time, and expire by the ExpiresAt TTL. Pending deliveries
+// are also in the sparse GSI1PK partition of webhookPendingDeliveryEntity,
+// so the dispatcher finds them on start.
+type webhookItem struct {
+	PK     string `dynamodbav:"PK"`
+	Entity string `dynamodbav:"GSI1PK"`
+	models.WebhookSubscription
+}
+
+type webhookDeliveryItem struct {
+	PK        string `dynamodbav:"PK"`
+	Entity    string `dynamodbav:"GSI1PK,omitempty"`
+	Partition string `dynamodbav:"GSI2PK"`
+	SortKey   string `dynamodbav:"GSI2SK"`
+	ExpiresAt int64  `dynamodbav:"ExpiresAt"`
+	// LeaseExpiresAt is the lease in Unix milliseconds, comparable in the
+	// claim condition.
+	LeaseExpiresAt int64 `dynamodbav:"LeaseUntil,omitempty"`
+	models.WebhookDelivery
+}
+
+func newWebhookDeliveryItem(delivery models.WebhookDelivery, retention time.Duration) webhookDeliveryItem {
+	item := webhookDeliveryItem{
+		PK:              webhookDeliveryKey(delivery.ID),
+		Entity:          webhookDeliveryIndexEntity(delivery),
+		Partition:       webhookDeliveryPartition(delivery.SubscriptionID),
+		SortKey:         webhookDeliverySortKey(delivery),
+		ExpiresAt:       delivery.CreatedAt.Add(retention).Unix(),
+		WebhookDelivery: delivery,
+	}
+	if !delivery.LeaseUntil.IsZero() {
+		item.LeaseExpiresAt = delivery.LeaseUntil.UnixMilli()
+	}
+	return item
+}
+
+func (i webhookDeliveryItem) delivery() models.WebhookDelivery {
+	delivery := i.WebhookDelivery
+	if i.LeaseExpiresAt != 0 {
+		delivery.LeaseUntil = time.UnixMilli(i.LeaseExpiresAt).UTC()
+	}
+	return delivery
+}
+
+type webhookRepository struct {
+	table          string
+	timeout        time.Duration
+	retention      time.Duration
+	dynamodbClient dynamodb.DynamoDBClient
+}
+
+// NewWebhookRepository keeps each delivery for retention after it was
+// created.
+func NewWebhookRepository(dynamodbClient dynamodb.DynamoDBClient, table string, timeout time.Duration, retention time.Duration) WebhookRepository {
+	return &webhookRepository{
+		dynamodbClient: dynamodbClient,
+		table:          table,
+		timeout:        timeout,
+		retention:      retention,
+	}
+}
+
+func (r *webhookRepository) SaveSubscription(ctx context.Context, subscription models.WebhookSubscription) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.SaveSubscription")
+	span.SetAttributes(attribute.String("webhook.id", subscription.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveSubscription", time.Now(), &err)
+
+	av, err := attributevalue.MarshalMap(webhookItem{
+		PK:                  webhookKey(subscription.ID),
+		Entity:              webhookEntity,
+		WebhookSubscription: subscription,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook subscription: %w", err)
+	}
+
+	err = r.dynamodbClient.PutItem(ctx, r.table, av, expression.Expression{})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) GetSubscription(ctx context.Context, id string) (subscription models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.GetSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscription", time.Now(), &err)
+
+	key, err := attributevalue.MarshalMap(map[string]string{"PK": webhookKey(id)})
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("failed to marshal webhook key: %w", err)
+	}
+
+	av, err := r.dynamodbClient.GetItem(ctx, r.table, key)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	if len(av) == 0 {
+		return models.WebhookSubscription{}, ErrWebhookNotFound
+	}
+
+	item := webhookItem{}
+	err = attributevalue.UnmarshalMap(av, &item)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("failed to unmarshal webhook subscription: %w", err)
+	}
+
+	return item.WebhookSubscription, nil
+}
+
+func (r *webhookRepository) GetSubscriptions(ctx context.Context) (subscriptions []models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.GetSubscriptions")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscriptions", time.Now(), &err)
+
+	items := []webhookItem{}
+	err = r.queryEntity(ctx, webhookEntity, expression.ConditionBuilder{}, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+
+	subscriptions = make([]models.WebhookSubscription, len(items))
+	for i, item := range items {
+		subscriptions[i] = item.WebhookSubscription
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.DeleteSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("DeleteSubscription", time.Now(), &err)
+
+	key, err := attributevalue.MarshalMap(map[string]string{"PK": webhookKey(id)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook key: %w", err)
+	}
+
+	condition := expression.AttributeExists(expression.Name("PK"))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = r.dynamodbClient.DeleteItem(ctx, r.table, key, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.SaveDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", delivery.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveDelivery", time.Now(), &err)
+
+	av, err := attributevalue.MarshalMap(newWebhookDeliveryItem(delivery, r.retention))
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+
+	err = r.dynamodbClient.PutItem(ctx, r.table, av, expression.Expression{})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) ClaimDelivery(ctx context.Context, id string, owner string, leaseUntil time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.ClaimDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("ClaimDelivery", time.Now(), &err)
+
+	key, err := attributevalue.MarshalMap(map[string]string{"PK": webhookDeliveryKey(id)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery key: %w", err)
+	}
+
+	update := expression.Set(expression.Name("Owner"), expression.Value(owner)).
+		Set(expression.Name("LeaseUntil"), expression.Value(leaseUntil.UnixMilli()))
+	condition := expression.Name("Status").Equal(expression.Value(models.WebhookDeliveryPending)).
+		And(expression.Or(
+			expression.Name("Owner").Equal(expression.Value(owner)),
+			expression.AttributeNotExists(expression.Name("LeaseUntil")),
+			expression.Name("LeaseUntil").LessThanEqual(expression.Value(time.Now().UnixMilli())),
+		))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to create expression: %w", err)
+	}
+
+	err = r.dynamodbClient.UpdateItem(ctx, r.table, key, expr)
+	if errors.Is(err, dynamodb.ErrConditionFailed) {
+		return ErrWebhookDeliveryLeased
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries reads the delivery partition of the subscription newest
+// first, until the limit is reached after the filter.
+func (r *webhookRepository) GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.GetDeliveries")
+	span.SetAttributes(attribute.String("webhook.id", filter.SubscriptionID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetDeliveries", time.Now(), &err)
+
+	builder := expression.NewBuilder().WithKeyCondition(expression.Key("GSI2PK").Equal(expression.Value(webhookDeliveryPartition(filter.SubscriptionID))))
+	condition := expression.ConditionBuilder{}
+	if filter.Status != "" {
+		condition = expression.Name("Status").Equal(expression.Value(filter.Status))
+	}
+	if filter.OrderID != 0 {
+		orderCondition := expression.Name("OrderID").Equal(expression.Value(filter.OrderID))
+		if condition.IsSet() {
+			condition = condition.And(orderCondition)
+		} else {
+			condition = orderCondition
+		}
+	}
+	if condition.IsSet() {
+		builder = builder.WithFilter(condition)
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query expr: %w", err)
+	}
+
+	deliveries = []models.WebhookDelivery{}
+	var startKey map[string]types.AttributeValue
+	for {
+		remaining := 0
+		if filter.Limit > 0 {
+			remaining = filter.Limit - len(deliveries)
+		}
+		items, lastKey, err := r.dynamodbClient.QueryItemDescending(ctx, r.table, expr, statusIndex, int32(remaining), startKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+		}
+
+		page := []webhookDeliveryItem{}
+		err = attributevalue.UnmarshalListOfMaps(items, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook deliveries: %w", err)
+		}
+		for _, item := range page {
+			deliveries = append(deliveries, item.delivery())
+		}
+
+		if len(lastKey) == 0 || (filter.Limit > 0 && len(deliveries) >= filter.Limit) {
+			return deliveries, nil
+		}
+		startKey = lastKey
+	}
+}
+
+// GetPendingDeliveries reads the sparse partition of the pending
+// deliveries, which SaveDelivery leaves once they are done.
+func (r *webhookRepository) GetPendingDeliveries(ctx context.Context) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "webhookRepository.GetPendingDeliveries")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetPendingDeliveries", time.Now(), &err)
+
+	items := []webhookDeliveryItem{}
+	err = r.queryEntity(ctx, webhookPendingDeliveryEntity, expression.ConditionBuilder{}, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending webhook deliveries: %w", err)
+	}
+
+	deliveries = make([]models.WebhookDelivery, len(items))
+	for i, item := range items {
+		deliveries[i] = item.delivery()
+	}
+	sortPendingWebhookDeliveries(deliveries)
+
+	return deliveries, nil
+}
+
+func (r *webhookRepository) queryEntity(ctx context.Context, entity string, filter expression.ConditionBuilder, out any) error {
+	builder := expression.NewBuilder().WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value(entity)))
+	if filter.IsSet() {
+		builder = builder.WithFilter(filter)
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return fmt.Errorf("failed to create query expr: %w", err)
+	}
+
+	items, _, err := r.dynamodbClient.QueryItem(ctx, r.table, expr, "SecondaryIndex", 0, nil)
+	if err != nil {
+		return err
+	}
+
+	return attributevalue.UnmarshalListOfMaps(items, out)
+}
+
+func webhookKey(id string) string {
+	return webhookEntity + "#" + id
+}
+
+func webhookDeliveryKey(id string) string {
+	return webhookDeliveryEntity + "#" + id
+}
+
+func webhookDeliveryPartition(subscriptionID string) string {
+	return webhookDeliveryEntity + "#" + subscriptionID
+}
+
+// webhookDeliveryIndexEntity puts only the pending deliveries in the
+// SecondaryIndex.
+func webhookDeliveryIndexEntity(delivery models.WebhookDelivery) string {
+	if delivery.Status != models.WebhookDeliveryPending {
+		return ""
+	}
+	return webhookPendingDeliveryEntity
+}
+
+// webhookDeliverySortKey has a fixed width so it sorts as the creation
+// time, and the ID so deliveries created at the same time keep a stable
+// order.
+func webhookDeliverySortKey(delivery models.WebhookDelivery) string {
+	return delivery.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000000Z") + "#" + delivery.ID
+}
+
+// sortWebhookDeliveries orders the deliveries newest first and applies
+// the limit.
+func sortWebhookDeliveries(deliveries []models.WebhookDelivery, limit int) []models.WebhookDelivery {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].ID > deliveries[j].ID
+		}
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// canClaimWebhookDelivery reports whether owner may lease the delivery at
+// now.
+func canClaimWebhookDelivery(delivery models.WebhookDelivery, owner string, now time.Time) bool {
+	return delivery.Status == models.WebhookDeliveryPending && (delivery.Owner == owner || !delivery.LeaseUntil.After(now))
+}
+
+// sortPendingWebhookDeliveries orders the deliveries oldest first, the
+// order they were dispatched in.
+func sortPendingWebhookDeliveries(deliveries []models.WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].ID < deliveries[j].ID
+		}
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+}
@@ -0,0 +1,190 @@
+package gateways
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWebhookRepositoryConformance is the behaviour every WebhookRepository
+// implementation must share. newRepository must return an empty store.
+func testWebhookRepositoryConformance(t *testing.T, newRepository func(t *testing.T) WebhookRepository) {
+	ctx := context.Background()
+
+	t.Run("save, get and list subscriptions", func(t *testing.T) {
+		repo := newRepository(t)
+		first := newConformanceWebhook("wh-1", 1)
+		second := newConformanceWebhook("wh-2", 2)
+
+		require.NoError(t, repo.SaveSubscription(ctx, second))
+		require.NoError(t, repo.SaveSubscription(ctx, first))
+
+		subscription, err := repo.GetSubscription(ctx, "wh-1")
+		require.NoError(t, err)
+		assertWebhookEqual(t, first, subscription)
+
+		subscriptions, err := repo.GetSubscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, subscriptions, 2)
+		assertWebhookEqual(t, first, subscriptions[0])
+		assertWebhookEqual(t, second, subscriptions[1])
+	})
+
+	t.Run("delete subscription", func(t *testing.T) {
+		repo := newRepository(t)
+		require.NoError(t, repo.SaveSubscription(ctx, newConformanceWebhook("wh-1", 1)))
+
+		require.NoError(t, repo.DeleteSubscription(ctx, "wh-1"))
+
+		_, err := repo.GetSubscription(ctx, "wh-1")
+		assert.ErrorIs(t, err, ErrWebhookNotFound)
+		assert.ErrorIs(t, repo.DeleteSubscription(ctx, "wh-1"), ErrWebhookNotFound)
+
+		subscriptions, err := repo.GetSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, subscriptions)
+	})
+
+	t.Run("save delivery replaces previous attempts", func(t *testing.T) {
+		repo := newRepository(t)
+		delivery := newConformanceDelivery("d-1", "wh-1", 7, 1)
+		require.NoError(t, repo.SaveDelivery(ctx, delivery))
+
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.Attempts = 3
+		delivery.LastStatusCode = 503
+		delivery.LastError = "unexpected status code [503]"
+		delivery.UpdatedAt = delivery.UpdatedAt.Add(time.Minute)
+		require.NoError(t, repo.SaveDelivery(ctx, delivery))
+
+		deliveries, err := repo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1"})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, models.WebhookDeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Equal(t, 503, deliveries[0].LastStatusCode)
+		assert.Equal(t, delivery.LastError, deliveries[0].LastError)
+		assert.True(t, delivery.CreatedAt.Equal(deliveries[0].CreatedAt))
+		assert.True(t, delivery.UpdatedAt.Equal(deliveries[0].UpdatedAt))
+	})
+
+	t.Run("filter deliveries newest first", func(t *testing.T) {
+		repo := newRepository(t)
+		for i := 1; i <= 4; i++ {
+			delivery := newConformanceDelivery(fmt.Sprintf("d-%d", i), "wh-1", 7+i%2, i)
+			if i == 4 {
+				delivery.Status = models.WebhookDeliveryFailed
+			}
+			require.NoError(t, repo.SaveDelivery(ctx, delivery))
+		}
+		require.NoError(t, repo.SaveDelivery(ctx, newConformanceDelivery("d-5", "wh-2", 7, 5)))
+
+		deliveries, err := repo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d-4", "d-3", "d-2", "d-1"}, deliveryIDs(deliveries))
+
+		deliveries, err = repo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1", Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d-4", "d-3"}, deliveryIDs(deliveries))
+
+		deliveries, err = repo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1", OrderID: 8})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d-3", "d-1"}, deliveryIDs(deliveries))
+
+		deliveries, err = repo.GetDeliveries(ctx, models.WebhookDeliveryFilter{SubscriptionID: "wh-1", Status: models.WebhookDeliveryFailed})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d-4"}, deliveryIDs(deliveries))
+	})
+
+	t.Run("claim delivery", func(t *testing.T) {
+		repo := newRepository(t)
+		delivery := newConformanceDelivery("d-1", "wh-1", 7, 1)
+		delivery.Status = models.WebhookDeliveryPending
+		require.NoError(t, repo.SaveDelivery(ctx, delivery))
+		leaseUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+		require.NoError(t, repo.ClaimDelivery(ctx, "d-1", "dispatcher-1", leaseUntil))
+		assert.ErrorIs(t, repo.ClaimDelivery(ctx, "d-1", "dispatcher-2", leaseUntil), ErrWebhookDeliveryLeased)
+		require.NoError(t, repo.ClaimDelivery(ctx, "d-1", "dispatcher-1", leaseUntil.Add(time.Minute)), "the owner renews its lease")
+
+		deliveries, err := repo.GetPendingDeliveries(ctx)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, "dispatcher-1", deliveries[0].Owner)
+		assert.True(t, leaseUntil.Add(time.Minute).Equal(deliveries[0].LeaseUntil))
+
+		delivery.Owner = "dispatcher-1"
+		delivery.LeaseUntil = time.Now().Add(-time.Second)
+		require.NoError(t, repo.SaveDelivery(ctx, delivery))
+		require.NoError(t, repo.ClaimDelivery(ctx, "d-1", "dispatcher-2", leaseUntil), "expired leases are taken over")
+
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.LeaseUntil = time.Time{}
+		require.NoError(t, repo.SaveDelivery(ctx, delivery))
+		assert.ErrorIs(t, repo.ClaimDelivery(ctx, "d-1", "dispatcher-1", leaseUntil), ErrWebhookDeliveryLeased, "only pending deliveries are claimed")
+		assert.ErrorIs(t, repo.ClaimDelivery(ctx, "d-404", "dispatcher-1", leaseUntil), ErrWebhookDeliveryLeased)
+	})
+
+	t.Run("list pending deliveries oldest first", func(t *testing.T) {
+		repo := newRepository(t)
+		for i := 1; i <= 4; i++ {
+			delivery := newConformanceDelivery(fmt.Sprintf("d-%d", i), fmt.Sprintf("wh-%d", i%2+1), 7, 5-i)
+			delivery.Status = models.WebhookDeliveryPending
+			require.NoError(t, repo.SaveDelivery(ctx, delivery))
+		}
+
+		succeeded := newConformanceDelivery("d-2", "wh-1", 7, 3)
+		require.NoError(t, repo.SaveDelivery(ctx, succeeded))
+
+		deliveries, err := repo.GetPendingDeliveries(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d-4", "d-3", "d-1"}, deliveryIDs(deliveries))
+		assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	})
+}
+
+func newConformanceWebhook(id string, minute int) models.WebhookSubscription {
+	return models.WebhookSubscription{
+		ID:        id,
+		URL:       "https://partner.example.com/hooks/" + id,
+		Events:    []string{models.OrderStatusReady, models.OrderStatusDelivered},
+		Secret:    "s3cr3t-" + id,
+		CreatedAt: time.Date(2024, 5, 1, 12, minute, 0, 0, time.UTC),
+	}
+}
+
+func newConformanceDelivery(id string, subscriptionID string, orderID int, minute int) models.WebhookDelivery {
+	createdAt := time.Date(2024, 5, 1, 12, minute, 0, 0, time.UTC)
+	return models.WebhookDelivery{
+		ID:             id,
+		SubscriptionID: subscriptionID,
+		OrderID:        orderID,
+		Event:          models.OrderStatusReady,
+		Status:         models.WebhookDeliverySucceeded,
+		Attempts:       1,
+		LastStatusCode: 200,
+		CreatedAt:      createdAt,
+		UpdatedAt:      createdAt,
+	}
+}
+
+func assertWebhookEqual(t *testing.T, expected models.WebhookSubscription, actual models.WebhookSubscription) {
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.URL, actual.URL)
+	assert.Equal(t, expected.Events, actual.Events)
+	assert.Equal(t, expected.Secret, actual.Secret)
+	assert.True(t, expected.CreatedAt.Equal(actual.CreatedAt))
+}
+
+func deliveryIDs(deliveries []models.WebhookDelivery) []string {
+	ids := make([]string, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+	return ids
+}
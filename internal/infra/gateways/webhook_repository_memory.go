@@ -0,0 +1,151 @@
+package gateways
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+)
+
+type inMemoryWebhookRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[string]models.WebhookSubscription
+	deliveries    map[string]models.WebhookDelivery
+}
+
+func NewInMemoryWebhookRepository() WebhookRepository {
+	return &inMemoryWebhookRepository{
+		subscriptions: map[string]models.WebhookSubscription{},
+		deliveries:    map[string]models.WebhookDelivery{},
+	}
+}
+
+func (r *inMemoryWebhookRepository) SaveSubscription(ctx context.Context, subscription models.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscription.Events = append([]string(nil), subscription.Events...)
+	r.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (r *inMemoryWebhookRepository) GetSubscription(ctx context.Context, id string) (models.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return models.WebhookSubscription{}, ErrWebhookNotFound
+	}
+	subscription.Events = append([]string(nil), subscription.Events...)
+	return subscription, nil
+}
+
+func (r *inMemoryWebhookRepository) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscriptions := make([]models.WebhookSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscription.Events = append([]string(nil), subscription.Events...)
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+func (r *inMemoryWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.subscriptions[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(r.subscriptions, id)
+	return nil
+}
+
+func (r *inMemoryWebhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (r *inMemoryWebhookRepository) ClaimDelivery(ctx context.Context, id string, owner string, leaseUntil time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok || !canClaimWebhookDelivery(delivery, owner, time.Now()) {
+		return ErrWebhookDeliveryLeased
+	}
+	delivery.Owner = owner
+	delivery.LeaseUntil = leaseUntil
+	r.deliveries[id] = delivery
+	return nil
+}
+
+func (r *inMemoryWebhookRepository) GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if filter.Matches(delivery) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return sortWebhookDeliveries(deliveries, filter.Limit), nil
+}
+
+func (r *inMemoryWebhookRepository) GetPendingDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortPendingWebhookDeliveries(deliveries)
+	return deliveries, nil
+}
@@ -0,0 +1,257 @@
+package gateways
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type postgresWebhookRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresWebhookRepository stores webhooks in the tables created by the
+// postgres driver migrations, with the events of a subscription as JSONB.
+func NewPostgresWebhookRepository(db *sql.DB, timeout time.Duration) WebhookRepository {
+	return &postgresWebhookRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *postgresWebhookRepository) SaveSubscription(ctx context.Context, subscription models.WebhookSubscription) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.SaveSubscription")
+	span.SetAttributes(attribute.String("webhook.id", subscription.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveSubscription", time.Now(), &err)
+
+	events, err := json.Marshal(subscription.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook events: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO webhook_subscriptions (id, url, events, secret, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET url = excluded.url, events = excluded.events, secret = excluded.secret`,
+		subscription.ID, subscription.URL, string(events), subscription.Secret, subscription.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresWebhookRepository) GetSubscription(ctx context.Context, id string) (subscription models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.GetSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscription", time.Now(), &err)
+
+	subscriptions, err := r.querySubscriptions(ctx, "SELECT id, url, events, secret, created_at FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if len(subscriptions) == 0 {
+		return models.WebhookSubscription{}, ErrWebhookNotFound
+	}
+
+	return subscriptions[0], nil
+}
+
+func (r *postgresWebhookRepository) GetSubscriptions(ctx context.Context) (subscriptions []models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.GetSubscriptions")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscriptions", time.Now(), &err)
+
+	return r.querySubscriptions(ctx, "SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY created_at, id")
+}
+
+func (r *postgresWebhookRepository) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.DeleteSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("DeleteSubscription", time.Now(), &err)
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresWebhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.SaveDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", delivery.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveDelivery", time.Now(), &err)
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO webhook_deliveries
+		(id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, attempts = excluded.attempts,
+			last_status_code = excluded.last_status_code, last_error = excluded.last_error, updated_at = excluded.updated_at,
+			owner = excluded.owner, lease_until = excluded.lease_until`,
+		delivery.ID, delivery.SubscriptionID, delivery.OrderID, delivery.Event, delivery.Status, delivery.Attempts,
+		delivery.LastStatusCode, delivery.LastError, delivery.CreatedAt, delivery.UpdatedAt, delivery.Owner, delivery.LeaseUntil)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresWebhookRepository) ClaimDelivery(ctx context.Context, id string, owner string, leaseUntil time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.ClaimDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("ClaimDelivery", time.Now(), &err)
+
+	result, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries SET owner = $2, lease_until = $3
+		WHERE id = $1 AND status = $4 AND (owner = $2 OR lease_until <= $5)`,
+		id, owner, leaseUntil, models.WebhookDeliveryPending, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	if claimed == 0 {
+		return ErrWebhookDeliveryLeased
+	}
+
+	return nil
+}
+
+func (r *postgresWebhookRepository) GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.GetDeliveries")
+	span.SetAttributes(attribute.String("webhook.id", filter.SubscriptionID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetDeliveries", time.Now(), &err)
+
+	query := strings.Builder{}
+	query.WriteString(`SELECT id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until
+		FROM webhook_deliveries WHERE subscription_id = $1`)
+	args := []any{filter.SubscriptionID}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		fmt.Fprintf(&query, " AND status = $%d", len(args))
+	}
+	if filter.OrderID != 0 {
+		args = append(args, filter.OrderID)
+		fmt.Fprintf(&query, " AND order_id = $%d", len(args))
+	}
+	query.WriteString(" ORDER BY created_at DESC, id DESC")
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		fmt.Fprintf(&query, " LIMIT $%d", len(args))
+	}
+
+	return r.queryDeliveries(ctx, query.String(), args...)
+}
+
+func (r *postgresWebhookRepository) GetPendingDeliveries(ctx context.Context) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "postgresWebhookRepository.GetPendingDeliveries")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetPendingDeliveries", time.Now(), &err)
+
+	return r.queryDeliveries(ctx, `SELECT id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until
+		FROM webhook_deliveries WHERE status = $1 ORDER BY created_at, id`, models.WebhookDeliveryPending)
+}
+
+func (r *postgresWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery := models.WebhookDelivery{}
+		err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.OrderID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+			&delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt, &delivery.Owner, &delivery.LeaseUntil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *postgresWebhookRepository) querySubscriptions(ctx context.Context, query string, args ...any) ([]models.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		subscription := models.WebhookSubscription{}
+		var events string
+		err = rows.Scan(&subscription.ID, &subscription.URL, &events, &subscription.Secret, &subscription.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+
+		err = json.Unmarshal([]byte(events), &subscription.Events)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook events: %w", err)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,281 @@
+package gateways
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/metrics"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type sqliteWebhookRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewSQLiteWebhookRepository stores webhooks in the schema created by the
+// sqlite driver, with the events of a subscription as a JSON array.
+func NewSQLiteWebhookRepository(db *sql.DB, timeout time.Duration) WebhookRepository {
+	return &sqliteWebhookRepository{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (r *sqliteWebhookRepository) SaveSubscription(ctx context.Context, subscription models.WebhookSubscription) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.SaveSubscription")
+	span.SetAttributes(attribute.String("webhook.id", subscription.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveSubscription", time.Now(), &err)
+
+	events, err := json.Marshal(subscription.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook events: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO webhook_subscriptions (id, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET url = excluded.url, events = excluded.events, secret = excluded.secret`,
+		subscription.ID, subscription.URL, string(events), subscription.Secret, formatSQLiteTime(subscription.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("failed to save webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sqliteWebhookRepository) GetSubscription(ctx context.Context, id string) (subscription models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.GetSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscription", time.Now(), &err)
+
+	subscriptions, err := r.querySubscriptions(ctx, "SELECT id, url, events, secret, created_at FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if len(subscriptions) == 0 {
+		return models.WebhookSubscription{}, ErrWebhookNotFound
+	}
+
+	return subscriptions[0], nil
+}
+
+func (r *sqliteWebhookRepository) GetSubscriptions(ctx context.Context) (subscriptions []models.WebhookSubscription, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.GetSubscriptions")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetSubscriptions", time.Now(), &err)
+
+	return r.querySubscriptions(ctx, "SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY created_at, id")
+}
+
+func (r *sqliteWebhookRepository) DeleteSubscription(ctx context.Context, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.DeleteSubscription")
+	span.SetAttributes(attribute.String("webhook.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("DeleteSubscription", time.Now(), &err)
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *sqliteWebhookRepository) SaveDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.SaveDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", delivery.ID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("SaveDelivery", time.Now(), &err)
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO webhook_deliveries
+		(id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, attempts = excluded.attempts,
+			last_status_code = excluded.last_status_code, last_error = excluded.last_error, updated_at = excluded.updated_at,
+			owner = excluded.owner, lease_until = excluded.lease_until`,
+		delivery.ID, delivery.SubscriptionID, delivery.OrderID, delivery.Event, delivery.Status, delivery.Attempts,
+		delivery.LastStatusCode, delivery.LastError, formatSQLiteTime(delivery.CreatedAt), formatSQLiteTime(delivery.UpdatedAt),
+		delivery.Owner, formatSQLiteTime(delivery.LeaseUntil))
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDelivery compares the leases as text, which formatSQLiteTime keeps
+// in time order.
+func (r *sqliteWebhookRepository) ClaimDelivery(ctx context.Context, id string, owner string, leaseUntil time.Time) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.ClaimDelivery")
+	span.SetAttributes(attribute.String("webhook.delivery.id", id))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("ClaimDelivery", time.Now(), &err)
+
+	result, err := r.db.ExecContext(ctx, `UPDATE webhook_deliveries SET owner = ?, lease_until = ?
+		WHERE id = ? AND status = ? AND (owner = ? OR lease_until <= ?)`,
+		owner, formatSQLiteTime(leaseUntil), id, models.WebhookDeliveryPending, owner, formatSQLiteTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	if claimed == 0 {
+		return ErrWebhookDeliveryLeased
+	}
+
+	return nil
+}
+
+func (r *sqliteWebhookRepository) GetDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.GetDeliveries")
+	span.SetAttributes(attribute.String("webhook.id", filter.SubscriptionID))
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetDeliveries", time.Now(), &err)
+
+	query := strings.Builder{}
+	query.WriteString(`SELECT id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until
+		FROM webhook_deliveries WHERE subscription_id = ?`)
+	args := []any{filter.SubscriptionID}
+	if filter.Status != "" {
+		query.WriteString(" AND status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.OrderID != 0 {
+		query.WriteString(" AND order_id = ?")
+		args = append(args, filter.OrderID)
+	}
+	query.WriteString(" ORDER BY created_at DESC, id DESC")
+	if filter.Limit > 0 {
+		query.WriteString(" LIMIT ?")
+		args = append(args, filter.Limit)
+	}
+
+	return r.queryDeliveries(ctx, query.String(), args...)
+}
+
+func (r *sqliteWebhookRepository) GetPendingDeliveries(ctx context.Context) (deliveries []models.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "sqliteWebhookRepository.GetPendingDeliveries")
+	defer tracing.EndSpan(span, &err)
+	defer metrics.ObserveRepositoryCall("GetPendingDeliveries", time.Now(), &err)
+
+	return r.queryDeliveries(ctx, `SELECT id, subscription_id, order_id, event, status, attempts, last_status_code, last_error, created_at, updated_at, owner, lease_until
+		FROM webhook_deliveries WHERE status = ? ORDER BY created_at, id`, models.WebhookDeliveryPending)
+}
+
+func (r *sqliteWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery := models.WebhookDelivery{}
+		var createdAt, updatedAt, leaseUntil string
+		err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.OrderID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+			&delivery.LastStatusCode, &delivery.LastError, &createdAt, &updatedAt, &delivery.Owner, &leaseUntil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		delivery.CreatedAt, err = time.Parse(sqliteTimeFormat, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook delivery created_at: %w", err)
+		}
+		delivery.UpdatedAt, err = time.Parse(sqliteTimeFormat, updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook delivery updated_at: %w", err)
+		}
+		// deliveries saved before the column existed have no lease
+		if leaseUntil != "" {
+			delivery.LeaseUntil, err = time.Parse(sqliteTimeFormat, leaseUntil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse webhook delivery lease_until: %w", err)
+			}
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *sqliteWebhookRepository) querySubscriptions(ctx context.Context, query string, args ...any) ([]models.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		subscription := models.WebhookSubscription{}
+		var events, createdAt string
+		err = rows.Scan(&subscription.ID, &subscription.URL, &events, &subscription.Secret, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+
+		err = json.Unmarshal([]byte(events), &subscription.Events)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhook events: %w", err)
+		}
+		subscription.CreatedAt, err = time.Parse(sqliteTimeFormat, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook created_at: %w", err)
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
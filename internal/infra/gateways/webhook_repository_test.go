@@ -0,0 +1,152 @@
+package gateways
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb"
+	mock_dynamodb "github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/drivers/dynamodb/mocks"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestWebhookRepository_SaveDelivery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+	repo := NewWebhookRepository(mockDynamoDBClient, "Kitchen", 5*time.Second, 24*time.Hour)
+
+	delivery := newConformanceDelivery("d-1", "wh-1", 7, 0)
+	item, _ := attributevalue.MarshalMap(delivery)
+	item["PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-1"}
+	item["GSI2PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#wh-1"}
+	item["GSI2SK"] = &types.AttributeValueMemberS{Value: "2024-05-01T12:00:00.000000000Z#d-1"}
+	item["ExpiresAt"], _ = attributevalue.Marshal(delivery.CreatedAt.Add(24 * time.Hour).Unix())
+	mockDynamoDBClient.EXPECT().PutItem(gomock.Any(), "Kitchen", item, expression.Expression{}).Return(nil)
+
+	err := repo.SaveDelivery(context.Background(), delivery)
+	assert.NoError(t, err)
+
+	delivery.Status = models.WebhookDeliveryPending
+	item["Status"] = &types.AttributeValueMemberS{Value: models.WebhookDeliveryPending}
+	item["GSI1PK"] = &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY_PENDING"}
+	mockDynamoDBClient.EXPECT().PutItem(gomock.Any(), "Kitchen", item, expression.Expression{}).Return(nil)
+
+	err = repo.SaveDelivery(context.Background(), delivery)
+	assert.NoError(t, err, "pending deliveries are indexed for the dispatcher")
+}
+
+func TestWebhookRepository_ClaimDelivery(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientErr   error
+		expectedErr error
+	}{
+		{name: "claimed"},
+		{name: "leased by another dispatcher", clientErr: dynamodb.ErrConditionFailed, expectedErr: ErrWebhookDeliveryLeased},
+		{name: "client error", clientErr: errors.New("throttled"), expectedErr: errors.New("failed to claim webhook delivery: throttled")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			repo := NewWebhookRepository(mockDynamoDBClient, "Kitchen", 5*time.Second, 24*time.Hour)
+
+			key := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-1"}}
+			mockDynamoDBClient.EXPECT().UpdateItem(gomock.Any(), "Kitchen", key, gomock.Any()).Return(tt.clientErr)
+
+			err := repo.ClaimDelivery(context.Background(), "d-1", "dispatcher-1", time.Now().Add(time.Minute))
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWebhookRepository_GetDeliveries(t *testing.T) {
+	table := "Kitchen"
+	deliveries := []models.WebhookDelivery{
+		newConformanceDelivery("d-3", "wh-1", 7, 3),
+		newConformanceDelivery("d-2", "wh-1", 7, 2),
+		newConformanceDelivery("d-1", "wh-1", 7, 1),
+	}
+	items := make([]map[string]types.AttributeValue, len(deliveries))
+	for i, delivery := range deliveries {
+		items[i], _ = attributevalue.MarshalMap(delivery)
+	}
+	lastKey := map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "WEBHOOK_DELIVERY#d-2"}}
+
+	partition := expression.Key("GSI2PK").Equal(expression.Value("WEBHOOK_DELIVERY#wh-1"))
+	allExpr, _ := expression.NewBuilder().WithKeyCondition(partition).Build()
+	orderExpr, _ := expression.NewBuilder().WithKeyCondition(partition).
+		WithFilter(expression.Name("OrderID").Equal(expression.Value(7))).Build()
+
+	tests := []struct {
+		name               string
+		filter             models.WebhookDeliveryFilter
+		mockSetup          func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient)
+		expectedDeliveries []models.WebhookDelivery
+		expectedError      error
+	}{
+		{
+			name:   "every delivery",
+			filter: models.WebhookDeliveryFilter{SubscriptionID: "wh-1"},
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				mockDynamoDBClient.EXPECT().
+					QueryItemDescending(gomock.Any(), table, allExpr, "StatusIndex", int32(0), nil).
+					Return(items, nil, nil)
+			},
+			expectedDeliveries: deliveries,
+		},
+		{
+			name:   "reads pages until the limit after the filter",
+			filter: models.WebhookDeliveryFilter{SubscriptionID: "wh-1", OrderID: 7, Limit: 3},
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				gomock.InOrder(
+					mockDynamoDBClient.EXPECT().
+						QueryItemDescending(gomock.Any(), table, orderExpr, "StatusIndex", int32(3), nil).
+						Return(items[:1], lastKey, nil),
+					mockDynamoDBClient.EXPECT().
+						QueryItemDescending(gomock.Any(), table, orderExpr, "StatusIndex", int32(2), lastKey).
+						Return(items[1:], lastKey, nil),
+				)
+			},
+			expectedDeliveries: deliveries,
+		},
+		{
+			name:   "dynamodb error",
+			filter: models.WebhookDeliveryFilter{SubscriptionID: "wh-1"},
+			mockSetup: func(mockDynamoDBClient *mock_dynamodb.MockDynamoDBClient) {
+				mockDynamoDBClient.EXPECT().
+					QueryItemDescending(gomock.Any(), table, allExpr, "StatusIndex", int32(0), nil).
+					Return(nil, nil, errors.New("dynamodb error"))
+			},
+			expectedError: errors.New("failed to get webhook deliveries: dynamodb error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockDynamoDBClient := mock_dynamodb.NewMockDynamoDBClient(ctrl)
+			tt.mockSetup(mockDynamoDBClient)
+			repo := NewWebhookRepository(mockDynamoDBClient, table, 5*time.Second, 24*time.Hour)
+
+			deliveries, err := repo.GetDeliveries(context.Background(), tt.filter)
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedDeliveries, deliveries)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "UP"
+	StatusDown     = "DOWN"
+	StatusDraining = "DRAINING"
+)
+
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return checker{name: name, check: check}
+}
+
+func (c checker) Name() string {
+	return c.name
+}
+
+func (c checker) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Health interface {
+	Liveness() Report
+	Readiness(ctx context.Context) Report
+	StartDraining()
+}
+
+type health struct {
+	checkers []Checker
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewHealth(timeout time.Duration, checkers ...Checker) Health {
+	return &health{checkers: checkers, timeout: timeout}
+}
+
+func (h *health) Liveness() Report {
+	return Report{Status: StatusUp}
+}
+
+// Readiness runs every dependency check concurrently, each bounded by the
+// configured timeout. The report is DRAINING once shutdown has started so
+// the instance is taken out of rotation before the server stops.
+func (h *health) Readiness(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(h.checkers))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checkers {
+		wg.Add(1)
+		go func(c Checker) {
+			defer wg.Done()
+			result := h.runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.Name()] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if h.draining.Load() {
+		report.Status = StatusDraining
+	}
+
+	return report
+}
+
+func (h *health) StartDraining() {
+	h.draining.Store(true)
+}
+
+func (h *health) runCheck(ctx context.Context, c Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Check(ctx)
+	result := CheckResult{Status: StatusUp, Latency: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
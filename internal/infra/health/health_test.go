@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	upChecker := NewChecker("dynamodb", func(ctx context.Context) error { return nil })
+	downChecker := NewChecker("rabbitmq", func(ctx context.Context) error { return errors.New("connection is closed") })
+	slowChecker := NewChecker("consumer", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name           string
+		checkers       []Checker
+		draining       bool
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "all dependencies up",
+			checkers:       []Checker{upChecker},
+			expectedStatus: StatusUp,
+			expectedChecks: map[string]string{"dynamodb": StatusUp},
+		},
+		{
+			name:           "dependency down",
+			checkers:       []Checker{upChecker, downChecker},
+			expectedStatus: StatusDown,
+			expectedChecks: map[string]string{"dynamodb": StatusUp, "rabbitmq": StatusDown},
+		},
+		{
+			name:           "dependency times out",
+			checkers:       []Checker{slowChecker},
+			expectedStatus: StatusDown,
+			expectedChecks: map[string]string{"consumer": StatusDown},
+		},
+		{
+			name:           "draining",
+			checkers:       []Checker{upChecker},
+			draining:       true,
+			expectedStatus: StatusDraining,
+			expectedChecks: map[string]string{"dynamodb": StatusUp},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth(10*time.Millisecond, tt.checkers...)
+			if tt.draining {
+				h.StartDraining()
+			}
+
+			report := h.Readiness(context.Background())
+
+			assert.Equal(t, tt.expectedStatus, report.Status)
+			assert.Len(t, report.Checks, len(tt.expectedChecks))
+			for name, status := range tt.expectedChecks {
+				assert.Equal(t, status, report.Checks[name].Status)
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	h := NewHealth(time.Second, NewChecker("rabbitmq", func(ctx context.Context) error { return errors.New("down") }))
+
+	assert.Equal(t, StatusUp, h.Liveness().Status)
+}
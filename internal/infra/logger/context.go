@@ -0,0 +1,51 @@
+package logger
+
+import "context"
+
+const (
+	RequestIDField = "request_id"
+	MessageIDField = "message_id"
+	OrderIDField   = "order_id"
+)
+
+type correlationKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the HTTP request ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return contextWithField(ctx, RequestIDField, requestID)
+}
+
+// ContextWithMessageID returns a copy of ctx carrying the broker message ID.
+func ContextWithMessageID(ctx context.Context, messageID string) context.Context {
+	return contextWithField(ctx, MessageIDField, messageID)
+}
+
+// ContextWithOrderID returns a copy of ctx carrying the order being handled.
+func ContextWithOrderID(ctx context.Context, orderID string) context.Context {
+	return contextWithField(ctx, OrderIDField, orderID)
+}
+
+// RequestIDFromContext returns the HTTP request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	return correlationFields(ctx)[RequestIDField]
+}
+
+func contextWithField(ctx context.Context, key, value string) context.Context {
+	if value == "" {
+		return ctx
+	}
+
+	current := correlationFields(ctx)
+	fields := make(map[string]string, len(current)+1)
+	for k, v := range current {
+		fields[k] = v
+	}
+	fields[key] = value
+
+	return context.WithValue(ctx, correlationKey{}, fields)
+}
+
+func correlationFields(ctx context.Context) map[string]string {
+	fields, _ := ctx.Value(correlationKey{}).(map[string]string)
+	return fields
+}
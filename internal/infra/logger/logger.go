@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Fields map[string]any
+
+type Logger interface {
+	WithContext(ctx context.Context) Logger
+	WithFields(fields Fields) Logger
+	WithError(err error) Logger
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogger returns a JSON logger writing to stdout at the given level
+// ("debug", "info", "warn" or "error").
+func NewLogger(level string) (Logger, error) {
+	return newLogger(os.Stdout, level)
+}
+
+// NewStderrLogger is NewLogger writing to stderr, for commands printing
+// their results to stdout.
+func NewStderrLogger(level string) (Logger, error) {
+	return newLogger(os.Stderr, level)
+}
+
+// NewNopLogger returns a logger that discards every entry.
+func NewNopLogger() Logger {
+	l, _ := newLogger(io.Discard, "error")
+	return l
+}
+
+func newLogger(out io.Writer, level string) (Logger, error) {
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	l := logrus.New()
+	l.SetOutput(out)
+	l.SetLevel(logLevel)
+	l.SetFormatter(&logrus.JSONFormatter{
+		FieldMap: logrus.FieldMap{
+			logrus.FieldKeyTime: "timestamp",
+			logrus.FieldKeyMsg:  "message",
+		},
+	})
+
+	return logrusLogger{entry: logrus.NewEntry(l)}, nil
+}
+
+// WithContext adds the correlation IDs carried by ctx, along with the
+// current trace and span IDs, to the logger fields.
+func (l logrusLogger) WithContext(ctx context.Context) Logger {
+	fields := logrus.Fields{}
+	for key, value := range correlationFields(ctx) {
+		fields[key] = value
+	}
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.IsValid() {
+		fields["trace_id"] = spanContext.TraceID().String()
+		fields["span_id"] = spanContext.SpanID().String()
+	}
+
+	return logrusLogger{entry: l.entry.WithContext(ctx).WithFields(fields)}
+}
+
+func (l logrusLogger) WithFields(fields Fields) Logger {
+	return logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l logrusLogger) WithError(err error) Logger {
+	return logrusLogger{entry: l.entry.WithError(err)}
+}
+
+func (l logrusLogger) Debugf(format string, args ...any) {
+	l.entry.Debugf(format, args...)
+}
+
+func (l logrusLogger) Infof(format string, args ...any) {
+	l.entry.Infof(format, args...)
+}
+
+func (l logrusLogger) Warnf(format string, args ...any) {
+	l.entry.Warnf(format, args...)
+}
+
+func (l logrusLogger) Errorf(format string, args ...any) {
+	l.entry.Errorf(format, args...)
+}
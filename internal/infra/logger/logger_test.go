@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoggerWithContext(t *testing.T) {
+	var out bytes.Buffer
+	l, err := newLogger(&out, "info")
+	assert.NoError(t, err)
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x01},
+		SpanID:  trace.SpanID{0x02},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+	ctx = ContextWithRequestID(ctx, "req-1")
+	ctx = ContextWithOrderID(ctx, "42")
+
+	l.WithContext(ctx).Infof("order [%s] saved", "42")
+	l.WithContext(ctx).Debugf("filtered by level")
+
+	var entry map[string]any
+	err = json.Unmarshal(out.Bytes(), &entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "order [42] saved", entry["message"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "42", entry["order_id"])
+	assert.Equal(t, spanContext.TraceID().String(), entry["trace_id"])
+	assert.NotContains(t, entry, "message_id")
+}
+
+func TestNewLoggerInvalidLevel(t *testing.T) {
+	_, err := NewLogger("loud")
+	assert.Error(t, err)
+}
+
+func TestContextWithFieldDoesNotMutateParent(t *testing.T) {
+	parent := ContextWithRequestID(context.Background(), "req-1")
+	child := ContextWithOrderID(parent, "42")
+
+	assert.Equal(t, "req-1", RequestIDFromContext(child))
+	assert.NotContains(t, correlationFields(parent), OrderIDField)
+}
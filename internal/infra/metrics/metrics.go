@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "production"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	consumerMessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_received_total",
+		Help:      "Messages delivered to the consumer by queue.",
+	}, []string{"queue"})
+
+	consumerMessagesAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_acked_total",
+		Help:      "Messages acknowledged after successful processing by queue.",
+	}, []string{"queue"})
+
+	consumerMessagesNacked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_nacked_total",
+		Help:      "Messages negatively acknowledged after a processing failure by queue.",
+	}, []string{"queue"})
+
+	consumerMessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_dead_lettered_total",
+		Help:      "Messages moved to the dead letter queue by destination and reason.",
+	}, []string{"destination", "reason"})
+
+	consumerProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "processing_duration_seconds",
+		Help:      "Time spent processing a message by queue and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue", "result"})
+
+	consumerPaused = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "paused",
+		Help:      "Whether the queue is paused, by queue.",
+	}, []string{"queue"})
+
+	consumerBackpressurePauses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "backpressure_pauses_total",
+		Help:      "Times the queue was paused because the kitchen was at capacity, by queue.",
+	}, []string{"queue"})
+
+	kitchenActiveOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kitchen",
+		Name:      "active_orders",
+		Help:      "Orders the kitchen is working on, as of the last capacity check.",
+	})
+
+	kitchenStationActiveOrders = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kitchen",
+		Name:      "station_active_orders",
+		Help:      "Orders the kitchen is working on with items of the station, as of the last capacity check.",
+	}, []string{"station"})
+
+	publisherMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "publisher",
+		Name:      "messages_total",
+		Help:      "Published messages by exchange, destination and result.",
+	}, []string{"exchange", "destination", "result"})
+
+	repositoryCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "call_duration_seconds",
+		Help:      "Order repository call latency by operation and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	webhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "attempts_total",
+		Help:      "Webhook delivery attempts by event and result.",
+	}, []string{"event", "result"})
+)
+
+func ObserveHTTPRequest(method, route string, status int, start time.Time) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+func IncConsumerReceived(queue string) {
+	consumerMessagesReceived.WithLabelValues(queue).Inc()
+}
+
+func IncConsumerAcked(queue string) {
+	consumerMessagesAcked.WithLabelValues(queue).Inc()
+}
+
+func IncConsumerNacked(queue string) {
+	consumerMessagesNacked.WithLabelValues(queue).Inc()
+}
+
+func IncConsumerDeadLettered(destination, reason string) {
+	consumerMessagesDeadLettered.WithLabelValues(destination, reason).Inc()
+}
+
+func ObserveConsumerProcessing(queue string, start time.Time, err error) {
+	consumerProcessingDuration.WithLabelValues(queue, result(err)).Observe(time.Since(start).Seconds())
+}
+
+func SetConsumerPaused(queue string, paused bool) {
+	value := 0.0
+	if paused {
+		value = 1
+	}
+	consumerPaused.WithLabelValues(queue).Set(value)
+}
+
+func IncConsumerBackpressurePause(queue string) {
+	consumerBackpressurePauses.WithLabelValues(queue).Inc()
+}
+
+// SetKitchenLoad replaces the station gauges, so stations without active
+// orders are not reported with a stale count.
+func SetKitchenLoad(activeOrders int, stations map[string]int) {
+	kitchenActiveOrders.Set(float64(activeOrders))
+	kitchenStationActiveOrders.Reset()
+	for station, count := range stations {
+		kitchenStationActiveOrders.WithLabelValues(station).Set(float64(count))
+	}
+}
+
+func IncPublished(exchange, destination string, err error) {
+	publisherMessages.WithLabelValues(exchange, destination, result(err)).Inc()
+}
+
+func IncWebhookAttempt(event string, err error) {
+	webhookAttempts.WithLabelValues(event, result(err)).Inc()
+}
+
+// ObserveRepositoryCall takes a pointer to the call's error so it can be
+// deferred at the start of a method with named results.
+func ObserveRepositoryCall(operation string, start time.Time, err *error) {
+	repositoryCallDuration.WithLabelValues(operation, result(*err)).Observe(time.Since(start).Seconds())
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
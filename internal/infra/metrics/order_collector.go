@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type OrderLister interface {
+	GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error)
+}
+
+// orderCollector computes business gauges from the stored orders on scrape,
+// caching the last listing for refreshInterval so frequent scrapes don't
+// hammer the order store.
+type orderCollector struct {
+	orderLister     OrderLister
+	refreshInterval time.Duration
+	logger          logger.Logger
+
+	ordersByStatus    *prometheus.Desc
+	oldestActiveOrder *prometheus.Desc
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	orders      []models.Order
+}
+
+func NewOrderCollector(orderLister OrderLister, refreshInterval time.Duration, logger logger.Logger) prometheus.Collector {
+	return &orderCollector{
+		orderLister:     orderLister,
+		refreshInterval: refreshInterval,
+		logger:          logger,
+		ordersByStatus: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "orders", "by_status"),
+			"Orders currently stored by status.",
+			[]string{"status"}, nil,
+		),
+		oldestActiveOrder: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "orders", "oldest_active_age_seconds"),
+			"Age of the oldest order that is not delivered or cancelled.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *orderCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.ordersByStatus
+	ch <- c.oldestActiveOrder
+}
+
+func (c *orderCollector) Collect(ch chan<- prometheus.Metric) {
+	orders, err := c.getOrders()
+	if err != nil {
+		c.logger.WithError(err).Errorf("failed to collect order metrics")
+		return
+	}
+
+	now := time.Now()
+	countByStatus := map[string]int{}
+	var oldestActiveAge time.Duration
+	for _, order := range orders {
+		countByStatus[order.Status]++
+		if order.IsActive() && now.Sub(order.CreatedAt) > oldestActiveAge {
+			oldestActiveAge = now.Sub(order.CreatedAt)
+		}
+	}
+
+	for status, count := range countByStatus {
+		ch <- prometheus.MustNewConstMetric(c.ordersByStatus, prometheus.GaugeValue, float64(count), status)
+	}
+	ch <- prometheus.MustNewConstMetric(c.oldestActiveOrder, prometheus.GaugeValue, oldestActiveAge.Seconds())
+}
+
+func (c *orderCollector) getOrders() ([]models.Order, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.orders != nil && time.Since(c.lastRefresh) < c.refreshInterval {
+		return c.orders, nil
+	}
+
+	orders := []models.Order{}
+	filter := models.OrderFilter{}
+	for {
+		page, err := c.orderLister.GetOrders(context.Background(), filter)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, page.Results...)
+		if page.Next == "" {
+			break
+		}
+		filter.Cursor = page.Next
+	}
+
+	c.orders = orders
+	c.lastRefresh = time.Now()
+	return orders, nil
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/core/models"
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOrderLister struct {
+	orders []models.Order
+	err    error
+	calls  int
+}
+
+func (f *fakeOrderLister) GetOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	f.calls++
+	return models.OrderPage{Results: f.orders}, f.err
+}
+
+func TestOrderCollector(t *testing.T) {
+	lister := &fakeOrderLister{orders: []models.Order{
+		{ID: "1", Status: models.OrderStatusCreated, CreatedAt: time.Now()},
+		{ID: "2", Status: models.OrderStatusCreated, CreatedAt: time.Now()},
+		{ID: "3", Status: models.OrderStatusDelivered, CreatedAt: time.Now().Add(-time.Hour)},
+	}}
+	collector := NewOrderCollector(lister, time.Minute, logger.NewNopLogger())
+
+	expected := `
+# HELP production_orders_by_status Orders currently stored by status.
+# TYPE production_orders_by_status gauge
+production_orders_by_status{status="CREATED"} 2
+production_orders_by_status{status="DELIVERED"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "production_orders_by_status")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, testutil.CollectAndCount(collector))
+	assert.Equal(t, 1, lister.calls, "listing should be cached within the refresh interval")
+}
+
+func TestOrderCollector_ListerError(t *testing.T) {
+	lister := &fakeOrderLister{err: errors.New("dynamodb error")}
+	collector := NewOrderCollector(lister, time.Minute, logger.NewNopLogger())
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
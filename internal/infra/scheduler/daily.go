@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+)
+
+// TimeOfDayLayout is the layout of the times accepted by RunDaily.
+const TimeOfDayLayout = "15:04"
+
+// RunDaily calls job every day at the given local "HH:MM" time until ctx is
+// done. A failed run is logged and the job waits for the next day.
+func RunDaily(ctx context.Context, name string, at string, job func(ctx context.Context) error, log logger.Logger) error {
+	timeOfDay, err := time.Parse(TimeOfDayLayout, at)
+	if err != nil {
+		return fmt.Errorf("invalid time of day [%s] for job [%s]: %w", at, name, err)
+	}
+
+	log = log.WithFields(logger.Fields{"job": name})
+	go func() {
+		for {
+			next := nextDailyRun(time.Now(), timeOfDay.Hour(), timeOfDay.Minute())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			start := time.Now()
+			err := job(ctx)
+			if err != nil {
+				log.WithError(err).Errorf("job failed after [%s]", time.Since(start))
+				continue
+			}
+			log.Infof("job finished in [%s]", time.Since(start))
+		}
+	}()
+
+	log.Infof("job scheduled daily at [%s]", at)
+	return nil
+}
+
+func nextDailyRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IgorRamosBR/g73-techchallenge-production/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDailyRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "later today",
+			now:      time.Date(2024, 5, 1, 1, 30, 0, 0, time.UTC),
+			expected: time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "already ran today",
+			now:      time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "end of month",
+			now:      time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nextDailyRun(tt.now, 3, 0))
+		})
+	}
+}
+
+func TestRunDaily_InvalidTime(t *testing.T) {
+	err := RunDaily(context.Background(), "archive", "3am", func(ctx context.Context) error { return nil }, logger.NewNopLogger())
+	assert.ErrorContains(t, err, "invalid time of day [3am] for job [archive]")
+}